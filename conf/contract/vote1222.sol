@@ -9,6 +9,56 @@ pragma solidity ^0.4.10;
  */
 contract VoteContract {
 
+/***********************************************************************************************************************
+                                                        合约事件
+ **********************************************************************************************************************/
+
+    // 新建投票活动
+    event VoteCreated(bytes32 indexed vote_id, bytes32 creator_id, bytes32 start_time, bytes32 end_time);
+
+    // 用户投票成功, total为该选项当前票数
+    event BallotCast(bytes32 indexed vote_id, bytes32 option_id, bytes32 user_id, int32 total);
+
+    // 投票活动结果确认
+    event VoteFinalized(bytes32 indexed vote_id, bytes32 winner_id, int32 winner_total);
+
+    // 设置选民名单Merkle根
+    event VoterRootSet(bytes32 indexed vote_id, bytes32 root);
+
+    // 设置投票资格表达式哈希
+    event EligibilitySet(bytes32 indexed vote_id, bytes32 hash);
+
+    // 设置委托, vote_id为0表示全局委托, delegate_id为0表示撤销委托
+    event DelegationSet(bytes32 indexed vote_id, bytes32 delegator_id, bytes32 delegate_id);
+
+    // 提交问卷, answers_hash为全部答案的哈希
+    event SurveySubmitted(bytes32 indexed vote_id, bytes32 user_id, bytes32 answers_hash);
+
+    // 投票开始后添加选项, proposer_id为提议人, 自由填写选项经创建者批准时approver_id为创建者
+    event OptionAdded(bytes32 indexed vote_id, bytes32 option_id, bytes32 content, bytes32 proposer_id, bytes32 approver_id);
+
+    // 未过半数的投票活动产生下一轮决选
+    event RunoffLinked(bytes32 indexed vote_id, bytes32 runoff_id, int32 round);
+
+    // 设置参与式预算的总预算
+    event BudgetSet(bytes32 indexed vote_id, int64 budget);
+
+    // 提交预算选票, cost为所选选项的总成本
+    event BudgetBallotCast(bytes32 indexed vote_id, bytes32 user_id, bytes32 ballot_hash, int64 cost);
+
+    // 请愿达到签名目标, 自动结束
+    event PetitionCompleted(bytes32 indexed vote_id, int32 signatures, bytes32 complete_time);
+
+    // 两两比较投票的一次比较
+    event OptionsCompared(bytes32 indexed vote_id, bytes32 user_id, bytes32 winner_id, bytes32 loser_id);
+
+    // 设置选项附件哈希
+    event OptionAssetSet(bytes32 indexed vote_id, bytes32 option_id, bytes32 hash);
+
+    // 委托人票权计入最终受托人所投选项, total为该选项当前票数
+    event DelegationResolved(bytes32 indexed vote_id, bytes32 delegator_id, bytes32 delegate_id, bytes32 option_id,
+        int32 total);
+
 
 /***********************************************************************************************************************
                                                        投票内容表
@@ -79,6 +129,7 @@ contract VoteContract {
             }
         }
 
+        VoteCreated(newVote.id, newVote.creator_id, newVote.start_time, newVote.end_time);
         return (SUCCESS, "插入成功");
     }
 
@@ -127,6 +178,8 @@ contract VoteContract {
     bytes32 vote_id;      //所属投票的ID
     bytes32 content;      //内容
     int32  total;          //票数
+    int32  score;          //问卷排序题、打分题的得分
+    bytes32 question_id;  //问卷中所属问题ID
     }
 
     // 主键2结构体
@@ -178,11 +231,12 @@ contract VoteContract {
      * @dev 按主键更新多条投票选项内容
      *
      * @param id 字符串类型数据
+     * @param vote_id 选项所属投票活动ID
      *
      * @return int32 返回代码
      * @return bytes 返回消息
      */
-    function updateVoteOption(bytes32 id) public returns(int32, bytes) {
+    function updateVoteOption(bytes32 id, bytes32 vote_id) public returns(int32, bytes) {
 
         VoteOption memory newVoteOption;
         VoteOption memory oldVoteOption;
@@ -193,11 +247,16 @@ contract VoteContract {
         if (oldVoteOption.id == 0) {
             return (ERROR, "主键不存在，无法更新");
         }
+        if (!isPlainOption(vote_id, id)) {
+            return (ERROR, "选项不属于该投票");
+        }
         //从原有数据中取出部分用于更新
         oldVoteOption.total = _id2VoteOption[newVoteOption.id].total;
         newVoteOption.total = oldVoteOption.total + 1;
         newVoteOption.vote_id = _id2VoteOption[newVoteOption.id].vote_id;
         newVoteOption.content = _id2VoteOption[newVoteOption.id].content;
+        newVoteOption.score = _id2VoteOption[newVoteOption.id].score;
+        newVoteOption.question_id = _id2VoteOption[newVoteOption.id].question_id;
         // 存储数据
         _id2VoteOption[newVoteOption.id] = newVoteOption;
         return (SUCCESS, "更新成功");
@@ -297,6 +356,9 @@ contract VoteContract {
         if(_id2VoteOption[newVoteResult.option_id].id == 0){
             return (ERROR, "复合主键option_id不存在，无法插入");
         }
+        if (!isPlainOption(newVoteResult.vote_id, newVoteResult.option_id)) {
+            return (ERROR, "选项不属于该投票");
+        }
 
         // 存储主键
         _idInVoteResultArray.push(newVoteResult.id);
@@ -307,9 +369,25 @@ contract VoteContract {
         // 存储投票活动对应的投票记录
         _voteId2VoteResult[newVoteResult.vote_id].push(newVoteResult.id);
 
+        BallotCast(newVoteResult.vote_id, newVoteResult.option_id, newVoteResult.user_id,
+            _id2VoteOption[newVoteResult.option_id].total);
         return (SUCCESS, "插入成功");
     }
 
+    /**
+     * @dev 选项属于该投票活动, 且投票活动为单选或多选, 其他题型有各自的投票方法
+     *
+     * @param vote_id 投票活动ID
+     * @param option_id 选项ID
+     *
+     * @return bool 是否可直接投给该选项
+     */
+    function isPlainOption(bytes32 vote_id, bytes32 option_id) internal returns(bool) {
+        int32 select_type = _id2Vote[vote_id].select_type;
+        return _id2VoteOption[option_id].vote_id == vote_id &&
+            (select_type == SELECT_SINGLE || select_type == SELECT_MULTI);
+    }
+
         /**
      * @dev 按主键查询多条投票内容表
      *
@@ -374,6 +452,1030 @@ contract VoteContract {
         return (ERROR, _bytes32ArrayReturn, _bytes32ArrayReturn);
     }
 
+    bytes32[] _pageOptionReturn;
+    bytes32[] _pageTimeReturn;
+    bytes32[] _pageIDReturn;
+
+    /**
+     * @dev 分页查询投票记录, 用于导出大量选票
+     *
+     * @param id 投票活动ID
+     * @param offset 起始序号
+     * @param limit 最多返回条数
+     *
+     * @return int32 返回代码
+     * @return int32 返回记录总数
+     * @return bytes32[] 返回用户ID
+     * @return bytes32[] 返回选项ID
+     * @return bytes32[] 返回选项内容
+     * @return bytes32[] 返回投票时间
+     * @return bytes32[] 返回投票记录ID
+     */
+    function queryVoteRecordPage(bytes32 id, int32 offset, int32 limit) public returns(int32, int32, bytes32[],
+        bytes32[], bytes32[], bytes32[], bytes32[]) {
+
+        initArrayReturn();
+        userIDArrayReturn.length = 0;
+        _pageOptionReturn.length = 0;
+        _pageTimeReturn.length = 0;
+        _pageIDReturn.length = 0;
+
+        bytes32[] storage voteResultIds = _voteId2VoteResult[id];
+        if (offset < 0 || limit <= 0) {
+            return (ERROR, int32(voteResultIds.length), userIDArrayReturn, _pageOptionReturn, _bytes32ArrayReturn,
+                _pageTimeReturn, _pageIDReturn);
+        }
+        for (uint i = uint(offset); i < voteResultIds.length && i < uint(offset) + uint(limit); i++) {
+            VoteResult storage voteResult = _id2VoteResult[voteResultIds[i]];
+            userIDArrayReturn.push(voteResult.user_id);
+            _pageOptionReturn.push(voteResult.option_id);
+            _bytes32ArrayReturn.push(voteResult.option_content);
+            _pageTimeReturn.push(voteResult.create_time);
+            _pageIDReturn.push(voteResultIds[i]);
+        }
+        return (SUCCESS, int32(voteResultIds.length), userIDArrayReturn, _pageOptionReturn, _bytes32ArrayReturn,
+            _pageTimeReturn, _pageIDReturn);
+    }
+
+/***********************************************************************************************************************
+                                                        结果确认
+ **********************************************************************************************************************/
+
+    // 已确认结果的投票活动
+    mapping (bytes32 => bool) _finalizedVote;
+
+    /**
+     * @dev 确认投票活动结果, 得票最多的选项为胜出选项
+     *
+     * @param id 字符串类型数据
+     *
+     * @return int32 返回代码
+     * @return bytes 返回消息
+     */
+    function finalizeVote(bytes32 id) public returns(int32, bytes) {
+
+        if (_id2Vote[id].id == 0) {
+            return (ERROR, "投票活动不存在");
+        }
+        if (_finalizedVote[id]) {
+            return (ERROR, "投票结果已确认");
+        }
+        bytes32 winner_id;
+        int32 winner_total = -1;
+        uint length = _optionID2Vote[id].length;
+        for (uint i = 0; i < length; i++) {
+            VoteOption memory voteOption = _id2VoteOption[_optionID2Vote[id][i]];
+            if (voteOption.total > winner_total) {
+                winner_id = voteOption.id;
+                winner_total = voteOption.total;
+            }
+        }
+        _finalizedVote[id] = true;
+
+        VoteFinalized(id, winner_id, winner_total);
+        return (SUCCESS, "确认成功");
+    }
+
+    /**
+     * @dev 查询投票活动结果是否已确认
+     *
+     * @param id 字符串类型数据
+     *
+     * @return int32 返回代码
+     * @return bool 是否已确认
+     */
+    function queryFinalized(bytes32 id) public returns(int32, bool) {
+
+        if (_id2Vote[id].id == 0) {
+            return (ERROR, false);
+        }
+        return (SUCCESS, _finalizedVote[id]);
+    }
+
+/***********************************************************************************************************************
+                                                        选民名单
+ **********************************************************************************************************************/
+
+    // 投票活动的选民名单Merkle根, 为0表示不限制
+    mapping (bytes32 => bytes32) _voterRoot;
+
+    /**
+     * @dev 设置投票活动的选民名单Merkle根, 已有投票记录时不能修改
+     *
+     * @param id 字符串类型数据
+     * @param root 选民名单Merkle根
+     *
+     * @return int32 返回代码
+     * @return bytes 返回消息
+     */
+    function setVoterRoot(bytes32 id, bytes32 root) public returns(int32, bytes) {
+
+        if (_id2Vote[id].id == 0) {
+            return (ERROR, "投票活动不存在");
+        }
+        if (_voteId2VoteResult[id].length != 0) {
+            return (ERROR, "已有投票记录，无法修改选民名单");
+        }
+        _voterRoot[id] = root;
+
+        VoterRootSet(id, root);
+        return (SUCCESS, "设置成功");
+    }
+
+    /**
+     * @dev 查询投票活动的选民名单Merkle根
+     *
+     * @param id 字符串类型数据
+     *
+     * @return int32 返回代码
+     * @return bytes32 返回Merkle根
+     */
+    function queryVoterRoot(bytes32 id) public returns(int32, bytes32) {
+
+        if (_id2Vote[id].id == 0) {
+            return (ERROR, 0);
+        }
+        return (SUCCESS, _voterRoot[id]);
+    }
+
+    // 投票活动的投票资格表达式哈希, 为0表示不限制
+    mapping (bytes32 => bytes32) _eligibilityHash;
+
+    /**
+     * @dev 设置投票活动的投票资格表达式哈希, 已有投票记录时不能修改
+     *
+     * @param id 字符串类型数据
+     * @param hash 投票资格表达式哈希
+     *
+     * @return int32 返回代码
+     * @return bytes 返回消息
+     */
+    function setEligibilityHash(bytes32 id, bytes32 hash) public returns(int32, bytes) {
+
+        if (_id2Vote[id].id == 0) {
+            return (ERROR, "投票活动不存在");
+        }
+        if (_voteId2VoteResult[id].length != 0) {
+            return (ERROR, "已有投票记录，无法修改投票资格");
+        }
+        _eligibilityHash[id] = hash;
+
+        EligibilitySet(id, hash);
+        return (SUCCESS, "设置成功");
+    }
+
+    /**
+     * @dev 查询投票活动的投票资格表达式哈希
+     *
+     * @param id 字符串类型数据
+     *
+     * @return int32 返回代码
+     * @return bytes32 返回表达式哈希
+     */
+    function queryEligibilityHash(bytes32 id) public returns(int32, bytes32) {
+
+        if (_id2Vote[id].id == 0) {
+            return (ERROR, 0);
+        }
+        return (SUCCESS, _eligibilityHash[id]);
+    }
+
+/***********************************************************************************************************************
+                                                        问卷
+ **********************************************************************************************************************/
+    struct Question {
+    bytes32 id;            //主键
+    bytes32 vote_id;       //所属投票活动ID
+    bytes32 title;         //题目
+    int32 select_type;     //1:单选 2:多选 3:排序 4:打分
+    int32 max_score;       //打分题最高分
+    }
+
+    // 主键2结构体
+    mapping (bytes32 => Question) _id2Question;
+
+    // 投票活动的问题
+    mapping (bytes32 => bytes32[]) _voteId2Question;
+
+    // 问题的选项
+    mapping (bytes32 => bytes32[]) _questionId2Option;
+
+    int32 constant SELECT_SINGLE = 1;
+    int32 constant SELECT_MULTI = 2;
+    int32 constant SELECT_RANKED = 3;
+    int32 constant SELECT_SCORE = 4;
+
+    // 问题最高分数组
+    int32[] _maxScoreArrayReturn;
+
+    // 选项得分数组
+    int32[] _scoreArrayReturn;
+
+    /**
+     * @dev 插入问卷的一个问题及其选项, 已有投票记录时不能添加
+     *
+     * @param id 问题ID
+     * @param vote_id 投票活动ID
+     * @param title 题目
+     * @param select_type 题型
+     * @param max_score 打分题最高分
+     * @param option_ids 选项ID数组
+     * @param option_contents 选项内容数组
+     *
+     * @return int32 返回代码
+     * @return bytes 返回消息
+     */
+    function insertQuestion(bytes32 id, bytes32 vote_id, bytes32 title, int32 select_type, int32 max_score,
+        bytes32[] option_ids, bytes32[] option_contents) public returns(int32, bytes) {
+
+        if (_id2Question[id].id != 0) {
+            return (ERROR, "主键已经存在，无法插入");
+        }
+        if (_id2Vote[vote_id].id == 0) {
+            return (ERROR, "投票活动不存在");
+        }
+        if (_voteId2VoteResult[vote_id].length != 0) {
+            return (ERROR, "已有投票记录，无法添加问题");
+        }
+        if (select_type < SELECT_SINGLE || select_type > SELECT_SCORE) {
+            return (ERROR, "题型错误");
+        }
+        if (option_ids.length == 0 || option_ids.length != option_contents.length) {
+            return (ERROR, "选项错误");
+        }
+        for (uint i = 0; i < option_ids.length; i++) {
+            if (_id2VoteOption[option_ids[i]].id != 0) {
+                return (ERROR, "选项主键已经存在，无法插入");
+            }
+        }
+        _id2Question[id] = Question(id, vote_id, title, select_type, max_score);
+        _voteId2Question[vote_id].push(id);
+        for (i = 0; i < option_ids.length; i++) {
+            insertVoteOption(option_ids[i], vote_id, option_contents[i]);
+            _id2VoteOption[option_ids[i]].question_id = id;
+            _questionId2Option[id].push(option_ids[i]);
+        }
+        return (SUCCESS, "插入成功");
+    }
+
+    /**
+     * @dev 查询问卷的问题
+     *
+     * @param id 投票活动ID
+     *
+     * @return int32 返回代码
+     * @return bytes32[] 返回问题ID数组
+     * @return bytes32[] 返回题目数组
+     * @return int32[] 返回题型数组
+     * @return int32[] 返回最高分数组
+     */
+    function querySurvey(bytes32 id) public returns(int32, bytes32[], bytes32[], int32[], int32[]) {
+
+        initArrayReturn();
+        _maxScoreArrayReturn.length = 0;
+
+        bytes32[] storage questionIds = _voteId2Question[id];
+        for (uint i = 0; i < questionIds.length; i++) {
+            Question storage question = _id2Question[questionIds[i]];
+            _bytes32ArrayReturn.push(question.title);
+            _intArrayReturn.push(question.select_type);
+            _maxScoreArrayReturn.push(question.max_score);
+        }
+        return (SUCCESS, questionIds, _bytes32ArrayReturn, _intArrayReturn, _maxScoreArrayReturn);
+    }
+
+    /**
+     * @dev 查询问题的选项及结果
+     *
+     * @param id 问题ID
+     *
+     * @return int32 返回代码
+     * @return bytes32[] 返回选项ID数组
+     * @return bytes32[] 返回选项内容数组
+     * @return int32[] 返回票数数组
+     * @return int32[] 返回得分数组
+     */
+    function queryQuestionOptions(bytes32 id) public returns(int32, bytes32[], bytes32[], int32[], int32[]) {
+
+        initArrayReturn();
+        _scoreArrayReturn.length = 0;
+
+        bytes32[] storage optionIds = _questionId2Option[id];
+        for (uint i = 0; i < optionIds.length; i++) {
+            VoteOption storage voteOption = _id2VoteOption[optionIds[i]];
+            _bytes32ArrayReturn.push(voteOption.content);
+            _intArrayReturn.push(voteOption.total);
+            _scoreArrayReturn.push(voteOption.score);
+        }
+        return (SUCCESS, optionIds, _bytes32ArrayReturn, _intArrayReturn, _scoreArrayReturn);
+    }
+
+    /**
+     * @dev 一次提交问卷全部答案, 单选多选题value为1, 排序题value为名次, 打分题value为分数,
+     *      排序题第1名得n分, 第n名得1分, n为该题选项数
+     *
+     * @param id 投票记录ID
+     * @param vote_id 投票活动ID
+     * @param user_id 用户ID
+     * @param answers_hash 全部答案的哈希
+     * @param create_time 提交时间
+     * @param option_ids 选项ID数组
+     * @param values 选项对应的值
+     *
+     * @return int32 返回代码
+     * @return bytes 返回消息
+     */
+    function submitSurvey(bytes32 id, bytes32 vote_id, bytes32 user_id, bytes32 answers_hash, bytes32 create_time,
+        bytes32[] option_ids, int32[] values) public returns(int32, bytes) {
+
+        if (_voteId2Question[vote_id].length == 0) {
+            return (ERROR, "问卷不存在");
+        }
+        if (_finalizedVote[vote_id]) {
+            return (ERROR, "投票结果已确认");
+        }
+        if (_id2VoteResult[id].id != 0) {
+            return (ERROR, "主键已经存在，无法插入");
+        }
+        if (hasVoted(user_id, vote_id)) {
+            return (ERROR, "已投票");
+        }
+        if (option_ids.length == 0 || option_ids.length != values.length) {
+            return (ERROR, "答案错误");
+        }
+        for (uint i = 0; i < option_ids.length; i++) {
+            if (!validSurveyValue(vote_id, option_ids[i], values[i])) {
+                return (ERROR, "答案错误");
+            }
+        }
+        for (i = 0; i < option_ids.length; i++) {
+            VoteOption storage voteOption = _id2VoteOption[option_ids[i]];
+            voteOption.total += 1;
+            voteOption.score += surveyPoints(option_ids[i], values[i]);
+            BallotCast(vote_id, option_ids[i], user_id, voteOption.total);
+        }
+
+        _idInVoteResultArray.push(id);
+        _id2VoteResult[id] = VoteResult(id, vote_id, 0, answers_hash, user_id, 0, create_time);
+        _userId2VoteResult[user_id].push(id);
+        _voteId2VoteResult[vote_id].push(id);
+
+        SurveySubmitted(vote_id, user_id, answers_hash);
+        return (SUCCESS, "提交成功");
+    }
+
+    function validSurveyValue(bytes32 vote_id, bytes32 option_id, int32 value) internal returns(bool) {
+        VoteOption storage voteOption = _id2VoteOption[option_id];
+        if (voteOption.vote_id != vote_id || voteOption.question_id == 0) {
+            return false;
+        }
+        Question storage question = _id2Question[voteOption.question_id];
+        if (question.select_type == SELECT_RANKED) {
+            return value >= 1 && uint(value) <= _questionId2Option[question.id].length;
+        }
+        if (question.select_type == SELECT_SCORE) {
+            return value >= 0 && value <= question.max_score;
+        }
+        return value == 1;
+    }
+
+    function surveyPoints(bytes32 option_id, int32 value) internal returns(int32) {
+        Question storage question = _id2Question[_id2VoteOption[option_id].question_id];
+        if (question.select_type == SELECT_RANKED) {
+            return int32(_questionId2Option[question.id].length) + 1 - value;
+        }
+        if (question.select_type == SELECT_SCORE) {
+            return value;
+        }
+        return 0;
+    }
+
+/***********************************************************************************************************************
+                                                        添加选项
+ **********************************************************************************************************************/
+    // 选项的提议人
+    mapping (bytes32 => bytes32) _optionProposer;
+
+    /**
+     * @dev 投票结果确认前添加选项, 同一投票活动中内容相同的选项不能重复添加
+     *
+     * @param id 选项ID
+     * @param vote_id 投票活动ID
+     * @param content 规范化后的选项内容
+     * @param proposer_id 提议人ID
+     * @param approver_id 批准人ID, 无需批准时为空
+     *
+     * @return int32 返回代码
+     * @return bytes 返回消息
+     */
+    function addVoteOption(bytes32 id, bytes32 vote_id, bytes32 content, bytes32 proposer_id, bytes32 approver_id)
+        public returns(int32, bytes) {
+
+        if (_id2Vote[vote_id].id == 0) {
+            return (ERROR, "投票活动不存在");
+        }
+        if (_finalizedVote[vote_id]) {
+            return (ERROR, "投票结果已确认");
+        }
+        if (_voteId2Question[vote_id].length != 0 || _voteBudget[vote_id] != 0 || _voteId2Petition[vote_id].target != 0) {
+            return (ERROR, "问卷、预算投票和请愿不能添加选项");
+        }
+        bytes32[] storage optionIds = _optionID2Vote[vote_id];
+        for (uint i = 0; i < optionIds.length; i++) {
+            if (_id2VoteOption[optionIds[i]].content == content) {
+                return (ERROR, "选项已存在");
+            }
+        }
+        int32 code;
+        bytes memory message;
+        (code, message) = insertVoteOption(id, vote_id, content);
+        if (code != SUCCESS) {
+            return (code, message);
+        }
+        _optionProposer[id] = proposer_id;
+
+        OptionAdded(vote_id, id, content, proposer_id, approver_id);
+        return (SUCCESS, "添加成功");
+    }
+
+    /**
+     * @dev 查询选项的提议人, 创建投票时的选项无提议人
+     *
+     * @param id 选项ID
+     *
+     * @return int32 返回代码
+     * @return bytes32 返回提议人ID
+     */
+    function queryOptionProposer(bytes32 id) public returns(int32, bytes32) {
+        if (_id2VoteOption[id].id == 0) {
+            return (ERROR, 0);
+        }
+        return (SUCCESS, _optionProposer[id]);
+    }
+
+/***********************************************************************************************************************
+                                                        决选轮次
+ **********************************************************************************************************************/
+    // 投票活动的下一轮
+    mapping (bytes32 => bytes32) _nextRound;
+
+    // 投票活动的上一轮
+    mapping (bytes32 => bytes32) _previousRound;
+
+    // 投票活动的轮次, 首轮未记录
+    mapping (bytes32 => int32) _round;
+
+    /**
+     * @dev 关联投票活动与其决选轮, 每个投票活动只能有一个下一轮
+     *
+     * @param vote_id 上一轮投票活动ID
+     * @param runoff_id 决选轮投票活动ID
+     *
+     * @return int32 返回代码
+     * @return bytes 返回消息
+     */
+    function linkRunoff(bytes32 vote_id, bytes32 runoff_id) public returns(int32, bytes) {
+
+        if (_id2Vote[vote_id].id == 0 || _id2Vote[runoff_id].id == 0) {
+            return (ERROR, "投票活动不存在");
+        }
+        if (vote_id == runoff_id) {
+            return (ERROR, "决选轮不能是自身");
+        }
+        if (_nextRound[vote_id] != 0) {
+            return (ERROR, "已有决选轮");
+        }
+        if (_previousRound[runoff_id] != 0 || _voteId2VoteResult[runoff_id].length != 0) {
+            return (ERROR, "决选轮已关联或已有投票记录");
+        }
+        int32 round = roundOf(vote_id) + 1;
+        _nextRound[vote_id] = runoff_id;
+        _previousRound[runoff_id] = vote_id;
+        _round[runoff_id] = round;
+
+        RunoffLinked(vote_id, runoff_id, round);
+        return (SUCCESS, "关联成功");
+    }
+
+    /**
+     * @dev 查询投票活动的轮次
+     *
+     * @param id 投票活动ID
+     *
+     * @return int32 返回代码
+     * @return bytes32 返回上一轮ID
+     * @return bytes32 返回下一轮ID
+     * @return int32 返回轮次
+     */
+    function queryRound(bytes32 id) public returns(int32, bytes32, bytes32, int32) {
+        if (_id2Vote[id].id == 0) {
+            return (ERROR, 0, 0, 0);
+        }
+        return (SUCCESS, _previousRound[id], _nextRound[id], roundOf(id));
+    }
+
+    function roundOf(bytes32 id) internal returns(int32) {
+        if (_round[id] == 0) {
+            return 1;
+        }
+        return _round[id];
+    }
+
+/***********************************************************************************************************************
+                                                        参与式预算
+ **********************************************************************************************************************/
+    // 投票活动的总预算
+    mapping (bytes32 => int64) _voteBudget;
+
+    // 选项的成本
+    mapping (bytes32 => int64) _optionCost;
+
+    // 成本数组
+    int64[] _costArrayReturn;
+
+    /**
+     * @dev 设置投票活动的总预算和选项成本, 只能设置一次且须在投票前
+     *
+     * @param vote_id 投票活动ID
+     * @param budget 总预算
+     * @param option_ids 选项ID数组
+     * @param costs 选项成本数组
+     *
+     * @return int32 返回代码
+     * @return bytes 返回消息
+     */
+    function setBudget(bytes32 vote_id, int64 budget, bytes32[] option_ids, int64[] costs) public returns(int32, bytes) {
+
+        if (_id2Vote[vote_id].id == 0) {
+            return (ERROR, "投票活动不存在");
+        }
+        if (_voteBudget[vote_id] != 0) {
+            return (ERROR, "预算已设置");
+        }
+        if (_voteId2VoteResult[vote_id].length != 0) {
+            return (ERROR, "已有投票记录，无法设置预算");
+        }
+        if (budget <= 0 || option_ids.length == 0 || option_ids.length != costs.length) {
+            return (ERROR, "预算错误");
+        }
+        for (uint i = 0; i < option_ids.length; i++) {
+            if (_id2VoteOption[option_ids[i]].vote_id != vote_id || costs[i] <= 0 || costs[i] > budget) {
+                return (ERROR, "选项成本错误");
+            }
+        }
+        for (i = 0; i < option_ids.length; i++) {
+            _optionCost[option_ids[i]] = costs[i];
+        }
+        _voteBudget[vote_id] = budget;
+
+        BudgetSet(vote_id, budget);
+        return (SUCCESS, "设置成功");
+    }
+
+    /**
+     * @dev 查询投票活动的总预算和选项成本
+     *
+     * @param id 投票活动ID
+     *
+     * @return int32 返回代码
+     * @return int64 返回总预算
+     * @return bytes32[] 返回选项ID数组
+     * @return int64[] 返回选项成本数组
+     */
+    function queryBudget(bytes32 id) public returns(int32, int64, bytes32[], int64[]) {
+
+        _costArrayReturn.length = 0;
+
+        bytes32[] storage optionIds = _optionID2Vote[id];
+        for (uint i = 0; i < optionIds.length; i++) {
+            _costArrayReturn.push(_optionCost[optionIds[i]]);
+        }
+        if (_voteBudget[id] == 0) {
+            return (ERROR, 0, optionIds, _costArrayReturn);
+        }
+        return (SUCCESS, _voteBudget[id], optionIds, _costArrayReturn);
+    }
+
+    /**
+     * @dev 提交预算选票, 所选选项不能重复且总成本不能超过总预算
+     *
+     * @param id 投票记录ID
+     * @param vote_id 投票活动ID
+     * @param user_id 用户ID
+     * @param ballot_hash 所选选项集合的哈希
+     * @param create_time 提交时间
+     * @param option_ids 所选选项ID数组
+     *
+     * @return int32 返回代码
+     * @return bytes 返回消息
+     */
+    function castBudgetBallot(bytes32 id, bytes32 vote_id, bytes32 user_id, bytes32 ballot_hash, bytes32 create_time,
+        bytes32[] option_ids) public returns(int32, bytes) {
+
+        if (_voteBudget[vote_id] == 0) {
+            return (ERROR, "不是预算投票");
+        }
+        if (_finalizedVote[vote_id]) {
+            return (ERROR, "投票结果已确认");
+        }
+        if (_id2VoteResult[id].id != 0) {
+            return (ERROR, "主键已经存在，无法插入");
+        }
+        if (hasVoted(user_id, vote_id)) {
+            return (ERROR, "已投票");
+        }
+        if (option_ids.length == 0) {
+            return (ERROR, "须至少选择一个选项");
+        }
+        int64 cost = 0;
+        for (uint i = 0; i < option_ids.length; i++) {
+            if (_id2VoteOption[option_ids[i]].vote_id != vote_id || _optionCost[option_ids[i]] == 0) {
+                return (ERROR, "选项错误");
+            }
+            for (uint j = 0; j < i; j++) {
+                if (option_ids[j] == option_ids[i]) {
+                    return (ERROR, "选项重复");
+                }
+            }
+            cost += _optionCost[option_ids[i]];
+        }
+        if (cost > _voteBudget[vote_id]) {
+            return (ERROR, "超出预算");
+        }
+        for (i = 0; i < option_ids.length; i++) {
+            VoteOption storage voteOption = _id2VoteOption[option_ids[i]];
+            voteOption.total += 1;
+            BallotCast(vote_id, option_ids[i], user_id, voteOption.total);
+        }
+
+        _idInVoteResultArray.push(id);
+        _id2VoteResult[id] = VoteResult(id, vote_id, 0, ballot_hash, user_id, 0, create_time);
+        _userId2VoteResult[user_id].push(id);
+        _voteId2VoteResult[vote_id].push(id);
+
+        BudgetBallotCast(vote_id, user_id, ballot_hash, cost);
+        return (SUCCESS, "投票成功");
+    }
+
+/***********************************************************************************************************************
+                                                        请愿
+ **********************************************************************************************************************/
+    struct Petition {
+    bytes32 option_id;       //唯一的签名选项
+    int32 target;            //签名目标
+    bool completed;          //是否达到目标
+    bytes32 complete_time;   //达到目标的时间
+    }
+
+    // 投票活动ID2请愿
+    mapping (bytes32 => Petition) _voteId2Petition;
+
+    /**
+     * @dev 设置请愿的签名选项和签名目标, 只能设置一次
+     *
+     * @param vote_id 投票活动ID
+     * @param option_id 签名选项ID
+     * @param target 签名目标
+     *
+     * @return int32 返回代码
+     * @return bytes 返回消息
+     */
+    function setPetition(bytes32 vote_id, bytes32 option_id, int32 target) public returns(int32, bytes) {
+
+        if (_id2Vote[vote_id].id == 0) {
+            return (ERROR, "投票活动不存在");
+        }
+        if (_voteId2Petition[vote_id].target != 0) {
+            return (ERROR, "请愿已设置");
+        }
+        if (_id2VoteOption[option_id].vote_id != vote_id || _optionID2Vote[vote_id].length != 1) {
+            return (ERROR, "请愿须只有一个签名选项");
+        }
+        if (target <= 0) {
+            return (ERROR, "签名目标错误");
+        }
+        _voteId2Petition[vote_id] = Petition(option_id, target, false, 0);
+        return (SUCCESS, "设置成功");
+    }
+
+    /**
+     * @dev 签署请愿, 签名作为投票记录保存, 达到目标时写入完成记录, 之后不再接受签名
+     *
+     * @param id 投票记录ID
+     * @param vote_id 投票活动ID
+     * @param user_id 用户ID
+     * @param create_time 签名时间
+     *
+     * @return int32 返回代码
+     * @return bytes 返回消息
+     */
+    function signPetition(bytes32 id, bytes32 vote_id, bytes32 user_id, bytes32 create_time) public returns(int32, bytes) {
+
+        Petition storage petition = _voteId2Petition[vote_id];
+        if (petition.target == 0) {
+            return (ERROR, "请愿不存在");
+        }
+        if (petition.completed || _finalizedVote[vote_id]) {
+            return (ERROR, "请愿已结束");
+        }
+        if (_id2VoteResult[id].id != 0) {
+            return (ERROR, "主键已经存在，无法插入");
+        }
+        if (hasVoted(user_id, vote_id)) {
+            return (ERROR, "已签名");
+        }
+        VoteOption storage voteOption = _id2VoteOption[petition.option_id];
+        voteOption.total += 1;
+
+        _idInVoteResultArray.push(id);
+        _id2VoteResult[id] = VoteResult(id, vote_id, petition.option_id, voteOption.content, user_id, 0, create_time);
+        _userId2VoteResult[user_id].push(id);
+        _voteId2VoteResult[vote_id].push(id);
+        BallotCast(vote_id, petition.option_id, user_id, voteOption.total);
+
+        if (voteOption.total >= petition.target) {
+            petition.completed = true;
+            petition.complete_time = create_time;
+            PetitionCompleted(vote_id, voteOption.total, create_time);
+        }
+        return (SUCCESS, "签名成功");
+    }
+
+    /**
+     * @dev 查询请愿进度
+     *
+     * @param id 投票活动ID
+     *
+     * @return int32 返回代码
+     * @return int32 返回签名目标
+     * @return int32 返回签名数
+     * @return bool 返回是否达到目标
+     * @return bytes32 返回达到目标的时间
+     */
+    function queryPetition(bytes32 id) public returns(int32, int32, int32, bool, bytes32) {
+        Petition storage petition = _voteId2Petition[id];
+        if (petition.target == 0) {
+            return (ERROR, 0, 0, false, 0);
+        }
+        return (SUCCESS, petition.target, _id2VoteOption[petition.option_id].total, petition.completed,
+            petition.complete_time);
+    }
+
+/***********************************************************************************************************************
+                                                        两两比较
+ **********************************************************************************************************************/
+    int32 constant SELECT_PAIRWISE = 8;
+
+    // 比较记录ID2落选选项ID, 胜出选项记在投票记录的option_id
+    mapping (bytes32 => bytes32) _comparisonLoser;
+
+    bytes32[] _loserArrayReturn;
+
+    /**
+     * @dev 记录一次两两比较, 作为投票记录保存, 同一用户可有多条, 重复比较由服务端限制
+     *
+     * @param id 投票记录ID
+     * @param vote_id 投票活动ID
+     * @param user_id 用户ID
+     * @param winner_id 胜出选项ID
+     * @param loser_id 落选选项ID
+     * @param create_time 比较时间
+     *
+     * @return int32 返回代码
+     * @return bytes 返回消息
+     */
+    function compareOptions(bytes32 id, bytes32 vote_id, bytes32 user_id, bytes32 winner_id, bytes32 loser_id,
+        bytes32 create_time) public returns(int32, bytes) {
+
+        if (_id2Vote[vote_id].select_type != SELECT_PAIRWISE) {
+            return (ERROR, "不是两两比较投票");
+        }
+        if (_finalizedVote[vote_id]) {
+            return (ERROR, "投票结果已确认");
+        }
+        if (_id2VoteResult[id].id != 0) {
+            return (ERROR, "主键已经存在，无法插入");
+        }
+        if (winner_id == loser_id || _id2VoteOption[winner_id].vote_id != vote_id ||
+            _id2VoteOption[loser_id].vote_id != vote_id) {
+            return (ERROR, "选项错误");
+        }
+        VoteOption storage winner = _id2VoteOption[winner_id];
+        winner.total += 1;
+
+        _idInVoteResultArray.push(id);
+        _id2VoteResult[id] = VoteResult(id, vote_id, winner_id, winner.content, user_id, 0, create_time);
+        _userId2VoteResult[user_id].push(id);
+        _voteId2VoteResult[vote_id].push(id);
+        _comparisonLoser[id] = loser_id;
+
+        BallotCast(vote_id, winner_id, user_id, winner.total);
+        OptionsCompared(vote_id, user_id, winner_id, loser_id);
+        return (SUCCESS, "比较成功");
+    }
+
+    /**
+     * @dev 查询两两比较投票的全部比较
+     *
+     * @param id 投票活动ID
+     *
+     * @return int32 返回代码
+     * @return bytes32[] 返回胜出选项ID
+     * @return bytes32[] 返回落选选项ID
+     */
+    function queryComparisons(bytes32 id) public returns(int32, bytes32[], bytes32[]) {
+
+        initArrayReturn();
+        _loserArrayReturn.length = 0;
+
+        if (_id2Vote[id].select_type != SELECT_PAIRWISE) {
+            return (ERROR, _bytes32ArrayReturn, _loserArrayReturn);
+        }
+        bytes32[] storage resultIds = _voteId2VoteResult[id];
+        for (uint i = 0; i < resultIds.length; i++) {
+            _bytes32ArrayReturn.push(_id2VoteResult[resultIds[i]].option_id);
+            _loserArrayReturn.push(_comparisonLoser[resultIds[i]]);
+        }
+        return (SUCCESS, _bytes32ArrayReturn, _loserArrayReturn);
+    }
+
+/***********************************************************************************************************************
+                                                        选项附件
+ **********************************************************************************************************************/
+
+    // 选项附件哈希, 附件(图片、描述、链接)存链下
+    mapping (bytes32 => bytes32) _optionAsset;
+
+    // 选项id数组
+    bytes32[] _optionIDArrayReturn;
+
+    /**
+     * @dev 设置选项附件哈希, 已有投票记录时不能修改
+     *
+     * @param id 选项ID
+     * @param hash 附件哈希
+     *
+     * @return int32 返回代码
+     * @return bytes 返回消息
+     */
+    function setOptionAsset(bytes32 id, bytes32 hash) public returns(int32, bytes) {
+
+        if (_id2VoteOption[id].id == 0) {
+            return (ERROR, "选项不存在");
+        }
+        bytes32 vote_id = _id2VoteOption[id].vote_id;
+        if (_voteId2VoteResult[vote_id].length != 0) {
+            return (ERROR, "已有投票记录，无法修改选项附件");
+        }
+        _optionAsset[id] = hash;
+
+        OptionAssetSet(vote_id, id, hash);
+        return (SUCCESS, "设置成功");
+    }
+
+    /**
+     * @dev 查询投票活动各选项的附件哈希
+     *
+     * @param id 投票活动ID
+     *
+     * @return int32 返回代码
+     * @return bytes32[] 返回选项ID数组
+     * @return bytes32[] 返回附件哈希数组
+     */
+    function queryOptionAssets(bytes32 id) public returns(int32, bytes32[], bytes32[]) {
+
+        initArrayReturn();
+        _optionIDArrayReturn.length = 0;
+
+        uint length = _optionID2Vote[id].length;
+        for (uint i = 0; i < length; i++) {
+            bytes32 option_id = _optionID2Vote[id][i];
+            _optionIDArrayReturn.push(option_id);
+            _bytes32ArrayReturn.push(_optionAsset[option_id]);
+        }
+        return (SUCCESS, _optionIDArrayReturn, _bytes32ArrayReturn);
+    }
+
+/***********************************************************************************************************************
+                                                        委托投票
+ **********************************************************************************************************************/
+
+    // 委托关系 vote_id => delegator_id => delegate_id, vote_id为0表示全局委托
+    mapping (bytes32 => mapping (bytes32 => bytes32)) _delegation;
+
+    // 票权已计入的委托人 vote_id => delegator_id
+    mapping (bytes32 => mapping (bytes32 => bool)) _delegationResolved;
+
+    // 委托链最大长度, 超过视为存在环
+    uint constant MAX_DELEGATION_DEPTH = 32;
+
+    /**
+     * @dev 设置或撤销委托, 委托人在该投票活动中投票后不能修改
+     *
+     * @param vote_id 投票活动ID, 为0表示全局委托
+     * @param delegator_id 委托人ID
+     * @param delegate_id 受托人ID, 为0表示撤销委托
+     *
+     * @return int32 返回代码
+     * @return bytes 返回消息
+     */
+    function setDelegation(bytes32 vote_id, bytes32 delegator_id, bytes32 delegate_id) public returns(int32, bytes) {
+
+        if (delegator_id == 0 || delegator_id == delegate_id) {
+            return (ERROR, "委托人无效");
+        }
+        if (vote_id != 0) {
+            if (_id2Vote[vote_id].id == 0) {
+                return (ERROR, "投票活动不存在");
+            }
+            if (_finalizedVote[vote_id]) {
+                return (ERROR, "投票结果已确认");
+            }
+            if (hasVoted(delegator_id, vote_id)) {
+                return (ERROR, "已投票，无法修改委托");
+            }
+        }
+        _delegation[vote_id][delegator_id] = delegate_id;
+
+        DelegationSet(vote_id, delegator_id, delegate_id);
+        return (SUCCESS, "设置成功");
+    }
+
+    /**
+     * @dev 沿委托链找到第一个已投票的受托人, 将未投票委托人的票权计入其所投选项,
+     *      每一跳优先使用该投票活动的委托, 其次使用全局委托
+     *
+     * @param vote_id 投票活动ID
+     * @param delegator_id 委托人ID
+     *
+     * @return int32 返回代码
+     * @return bytes 返回消息
+     */
+    function resolveDelegation(bytes32 vote_id, bytes32 delegator_id) public returns(int32, bytes) {
+
+        if (_id2Vote[vote_id].id == 0) {
+            return (ERROR, "投票活动不存在");
+        }
+        if (_finalizedVote[vote_id]) {
+            return (ERROR, "投票结果已确认");
+        }
+        if (_delegationResolved[vote_id][delegator_id]) {
+            return (ERROR, "票权已计入");
+        }
+        if (hasVoted(delegator_id, vote_id)) {
+            return (ERROR, "委托人已投票");
+        }
+        bytes32 delegate_id = delegator_id;
+        for (uint depth = 0; depth < MAX_DELEGATION_DEPTH; depth++) {
+            bytes32 next = _delegation[vote_id][delegate_id];
+            if (next == 0) {
+                next = _delegation[0][delegate_id];
+            }
+            if (next == 0 || next == delegator_id) {
+                return (ERROR, "委托链中无投票人");
+            }
+            delegate_id = next;
+            if (hasVoted(delegate_id, vote_id)) {
+                _delegationResolved[vote_id][delegator_id] = true;
+                bytes32[] storage resultIds = _userId2VoteResult[delegate_id];
+                for (uint i = 0; i < resultIds.length; i++) {
+                    VoteResult memory voteResult = _id2VoteResult[resultIds[i]];
+                    if (voteResult.vote_id == vote_id) {
+                        _id2VoteOption[voteResult.option_id].total += 1;
+                        DelegationResolved(vote_id, delegator_id, delegate_id, voteResult.option_id,
+                            _id2VoteOption[voteResult.option_id].total);
+                    }
+                }
+                return (SUCCESS, "计入成功");
+            }
+        }
+        return (ERROR, "委托链过长");
+    }
+
+    /**
+     * @dev 查询委托
+     *
+     * @param vote_id 投票活动ID, 为0表示全局委托
+     * @param delegator_id 委托人ID
+     *
+     * @return int32 返回代码
+     * @return bytes32 返回受托人ID
+     */
+    function queryDelegation(bytes32 vote_id, bytes32 delegator_id) public returns(int32, bytes32) {
+
+        return (SUCCESS, _delegation[vote_id][delegator_id]);
+    }
+
+    function hasVoted(bytes32 user_id, bytes32 vote_id) internal returns(bool) {
+        bytes32[] storage resultIds = _userId2VoteResult[user_id];
+        for (uint i = 0; i < resultIds.length; i++) {
+            if (_id2VoteResult[resultIds[i]].vote_id == vote_id) {
+                return true;
+            }
+        }
+        return false;
+    }
+
 /***********************************************************************************************************************
                                                         全局常量
  **********************************************************************************************************************/
@@ -0,0 +1,38 @@
+package middleware
+
+import (
+	"FunnyVoteGo/src/api/vm"
+	"FunnyVoteGo/src/service"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Auth is a middleware function that resolves the caller
+// from the bearer token and aborts if it is invalid.
+func Auth(c *gin.Context) {
+	access := BearerToken(c)
+	if access == "" {
+		vm.MakeFail(c, http.StatusUnauthorized, "未登录")
+		c.Abort()
+		return
+	}
+	user, b := service.ParseAccessToken(access)
+	if !b {
+		vm.MakeFail(c, http.StatusUnauthorized, "登录已失效")
+		c.Abort()
+		return
+	}
+	c.Set(vm.UserInfoKey, user)
+	c.Next()
+}
+
+// BearerToken returns token in Authorization header
+func BearerToken(c *gin.Context) string {
+	auth := c.GetHeader("Authorization")
+	if len(auth) > 7 && strings.EqualFold(auth[:7], "Bearer ") {
+		return strings.TrimSpace(auth[7:])
+	}
+	return ""
+}
@@ -0,0 +1,23 @@
+package middleware
+
+import (
+	"FunnyVoteGo/src/api/vm"
+	"FunnyVoteGo/src/service"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Authorize returns a middleware function that checks whether
+// the login user can do act on obj by rbac policies.
+func Authorize(obj, act string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user := vm.GetUserInfo(c)
+		if user == nil || !service.Enforce(user.ID, obj, act) {
+			vm.MakeFail(c, http.StatusForbidden, "无权限")
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
@@ -31,10 +31,78 @@ func Load(g *gin.Engine, mg *melody.Melody, mw ...gin.HandlerFunc) *gin.Engine {
 	//main router
 	apiv1 := g.Group("/api/v1")
 	apiv1.GET("/info", v1.GetContractInfo)
-	apiv1.POST("/startvote", v1.StartVote)
-	apiv1.POST("/chooseoption", v1.Vote)
-	apiv1.POST("/status", v1.VoteStatus)
-	apiv1.POST("/record", v1.GetVoteRecord)
+	apiv1.POST("/user/register", v1.Register)
+	apiv1.POST("/login", v1.Login)
+	apiv1.POST("/refresh", v1.Refresh)
+	apiv1.POST("/ballot/token", v1.TokenVote)
+	apiv1.GET("/asset/:hash", v1.GetAsset)
+	if mg != nil {
+		apiv1.GET("/live", v1.LiveStream(mg))
+	}
+
+	// routes of login user, authorized by rbac policies
+	auth := apiv1.Group("", middleware.Auth)
+	auth.POST("/logout", v1.Logout)
+	auth.POST("/user/info", v1.GetUserInfo)
+	auth.POST("/startvote", middleware.Authorize("vote", "create"), v1.StartVote)
+	auth.POST("/chooseoption", middleware.Authorize("vote", "cast"), v1.Vote)
+	auth.POST("/survey/submit", middleware.Authorize("vote", "cast"), v1.SubmitSurvey)
+	auth.POST("/budget/cast", middleware.Authorize("vote", "cast"), v1.CastBudgetBallot)
+	auth.POST("/petition/sign", middleware.Authorize("vote", "cast"), v1.SignPetition)
+	auth.POST("/pairwise/next", middleware.Authorize("vote", "cast"), v1.NextPair)
+	auth.POST("/pairwise/compare", middleware.Authorize("vote", "cast"), v1.ComparePair)
+	auth.POST("/status", middleware.Authorize("vote", "read"), v1.VoteStatus)
+	auth.POST("/record", middleware.Authorize("record", "read"), v1.GetVoteRecord)
+	auth.POST("/vote/export", middleware.Authorize("record", "read"), v1.ExportVote)
+	auth.POST("/finalize", middleware.Authorize("vote", "close"), v1.FinalizeVote)
+	auth.POST("/vote/certificate", middleware.Authorize("vote", "read"), v1.GetResultCertificate)
+	auth.POST("/vote/certificate/verify", middleware.Authorize("vote", "read"), v1.VerifyResultCertificate)
+	auth.POST("/vote/voters", middleware.Authorize("vote", "manage"), v1.SetVoteVoters)
+	auth.POST("/vote/voters/proof", middleware.Authorize("vote", "read"), v1.GetVoterProof)
+	auth.POST("/vote/eligibility", middleware.Authorize("vote", "manage"), v1.SetVoteEligibility)
+	auth.POST("/vote/invite/generate", middleware.Authorize("vote", "manage"), v1.GenerateInviteCodes)
+	auth.POST("/vote/invite/list", middleware.Authorize("vote", "manage"), v1.GetInviteCodes)
+	auth.POST("/vote/tokens/generate", middleware.Authorize("vote", "manage"), v1.GenerateBallotTokens)
+	auth.POST("/vote/tokens/list", middleware.Authorize("vote", "manage"), v1.GetBallotTokens)
+	auth.POST("/delegation/set", middleware.Authorize("vote", "cast"), v1.Delegate)
+	auth.POST("/delegation/revoke", middleware.Authorize("vote", "cast"), v1.RevokeDelegation)
+	auth.POST("/delegation/list", middleware.Authorize("vote", "cast"), v1.GetDelegations)
+	auth.POST("/vote/delegation", middleware.Authorize("vote", "read"), v1.GetDelegationTally)
+	auth.POST("/vote/options/add", middleware.Authorize("vote", "manage"), v1.AddOptions)
+	auth.POST("/vote/writein/propose", middleware.Authorize("vote", "cast"), v1.ProposeWriteIn)
+	auth.POST("/vote/writein/list", middleware.Authorize("vote", "manage"), v1.GetWriteIns)
+	auth.POST("/vote/writein/review", middleware.Authorize("vote", "manage"), v1.ReviewWriteIn)
+	auth.POST("/vote/rounds", middleware.Authorize("vote", "read"), v1.GetVoteRounds)
+	auth.POST("/vote/budget/tally", middleware.Authorize("vote", "read"), v1.GetBudgetTally)
+	auth.POST("/vote/petition/progress", middleware.Authorize("vote", "read"), v1.GetPetitionProgress)
+	auth.POST("/vote/pairwise/ranking", middleware.Authorize("vote", "read"), v1.GetPairwiseRanking)
+	auth.POST("/vote/template/create", middleware.Authorize("vote", "create"), v1.CreateVoteTemplate)
+	auth.POST("/vote/template/clone", middleware.Authorize("vote", "create"), v1.CloneVoteTemplate)
+	auth.POST("/vote/template/list", middleware.Authorize("vote", "create"), v1.GetVoteTemplates)
+	auth.POST("/vote/template/start", middleware.Authorize("vote", "create"), v1.StartVoteTemplate)
+	auth.POST("/vote/template/recurrence", middleware.Authorize("vote", "create"), v1.SetTemplateRecurrence)
+	auth.POST("/vote/template/delete", middleware.Authorize("vote", "create"), v1.DeleteVoteTemplate)
+	auth.POST("/vote/template/history", middleware.Authorize("vote", "read"), v1.GetTemplateHistory)
+	auth.POST("/vote/import", middleware.Authorize("vote", "import"), v1.ImportVotes)
+	auth.POST("/vote/import/template", middleware.Authorize("vote", "import"), v1.GetImportTemplate)
+	auth.POST("/notification/list", middleware.Authorize("notification", "read"), v1.GetNotifications)
+	auth.POST("/webhook/create", middleware.Authorize("webhook", "write"), v1.CreateWebhook)
+	auth.POST("/webhook/list", middleware.Authorize("webhook", "read"), v1.GetWebhooks)
+	auth.POST("/webhook/delete", middleware.Authorize("webhook", "write"), v1.DeleteWebhook)
+	auth.POST("/webhook/deliveries", middleware.Authorize("webhook", "read"), v1.GetWebhookDeliveries)
+	auth.POST("/webhook/redeliver", middleware.Authorize("webhook", "write"), v1.RedeliverWebhook)
+	auth.POST("/contract/deploy", middleware.Authorize("contract", "deploy"), v1.DeployContract)
+
+	// policy management of admins
+	rbac := auth.Group("/rbac", middleware.Authorize("policy", "manage"))
+	rbac.POST("/policy/list", v1.GetPolicies)
+	rbac.POST("/policy/add", v1.AddPolicy)
+	rbac.POST("/policy/remove", v1.RemovePolicy)
+	rbac.POST("/role/list", v1.GetUserRoles)
+	rbac.POST("/role/add", v1.AddUserRole)
+	rbac.POST("/role/remove", v1.RemoveUserRole)
+	rbac.POST("/user/attributes", v1.GetUserAttributes)
+	rbac.POST("/user/attributes/set", v1.SetUserAttributes)
 
 	return g
 }
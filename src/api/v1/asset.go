@@ -0,0 +1,27 @@
+package v1
+
+import (
+	"FunnyVoteGo/src/api/vm"
+	"FunnyVoteGo/src/lib/blobstore"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetAsset get content-addressed asset such as option image
+func GetAsset(c *gin.Context) {
+	hash := c.Param("hash")
+	if !blobstore.ValidHash(hash) {
+		vm.MakeFail(c, http.StatusBadRequest, "参数错误")
+		return
+	}
+	data, err := blobstore.Get(hash)
+	if err != nil {
+		vm.MakeFail(c, http.StatusNotFound, "资源不存在")
+		return
+	}
+	// 内容寻址, 内容不会变化
+	c.Header("Cache-Control", "public, max-age=31536000, immutable")
+	c.Data(http.StatusOK, http.DetectContentType(data), data)
+	return
+}
@@ -0,0 +1,54 @@
+package v1
+
+import (
+	"FunnyVoteGo/src/api/vm"
+	"FunnyVoteGo/src/service"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/glog"
+)
+
+// CastBudgetBallot choose options within the budget of a participatory budgeting vote
+func CastBudgetBallot(c *gin.Context) {
+	var bb vm.BudgetBallot
+	if err := c.ShouldBind(&bb); err != nil {
+		glog.Error(err)
+		vm.MakeFail(c, http.StatusBadRequest, "参数错误")
+		return
+	}
+	bb.UserID = vm.GetUserInfo(c).ID
+	if err := service.CastBudgetBallot(&bb); err != nil {
+		budgetFail(c, err)
+		return
+	}
+	vm.MakeSuccess(c, http.StatusOK, "success")
+	return
+}
+
+// GetBudgetTally allocate budget of a vote by greedy or equal shares method
+func GetBudgetTally(c *gin.Context) {
+	var req vm.BudgetTallyReq
+	if err := c.ShouldBind(&req); err != nil {
+		vm.MakeFail(c, http.StatusBadRequest, "参数错误")
+		return
+	}
+	tally, err := service.GetBudgetTally(&req, vm.GetUserInfo(c).ID)
+	if err != nil {
+		budgetFail(c, err)
+		return
+	}
+	vm.MakeSuccess(c, http.StatusOK, tally)
+	return
+}
+
+func budgetFail(c *gin.Context, err error) {
+	switch err.(type) {
+	case *service.EligibilityError:
+		vm.MakeFail(c, http.StatusForbidden, err.Error())
+	case *service.ContentError:
+		vm.MakeFail(c, http.StatusBadRequest, err.Error())
+	default:
+		vm.MakeFail(c, http.StatusInternalServerError, err.Error())
+	}
+}
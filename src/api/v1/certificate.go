@@ -0,0 +1,56 @@
+package v1
+
+import (
+	"FunnyVoteGo/src/api/vm"
+	"FunnyVoteGo/src/service"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/glog"
+)
+
+// GetResultCertificate download the signed result certificate of a finalized vote
+func GetResultCertificate(c *gin.Context) {
+	var voteid vm.VoteID
+	if err := c.ShouldBind(&voteid); err != nil {
+		vm.MakeFail(c, http.StatusBadRequest, "参数错误")
+		return
+	}
+	cert, err := service.GetResultCertificate(voteid.VoteID, vm.GetUserInfo(c).ID)
+	if err != nil {
+		if _, ok := err.(*service.EligibilityError); ok {
+			vm.MakeFail(c, http.StatusForbidden, err.Error())
+		} else {
+			vm.MakeFail(c, http.StatusInternalServerError, err.Error())
+		}
+		return
+	}
+	c.Header("Content-Disposition", "attachment; filename=certificate_"+voteid.VoteID+".json")
+	c.IndentedJSON(http.StatusOK, cert)
+	return
+}
+
+// VerifyResultCertificate verify a certificate posted as body is signed by signer, the service key by default,
+// against chain unless offline=true
+func VerifyResultCertificate(c *gin.Context) {
+	data, err := ioutil.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, 1<<20))
+	if err != nil {
+		glog.Error(err)
+		vm.MakeFail(c, http.StatusBadRequest, "参数错误")
+		return
+	}
+	offline, _ := strconv.ParseBool(c.Query("offline"))
+	check, err := service.VerifyCertificate(data, c.Query("signer"), !offline)
+	if err != nil {
+		if _, ok := err.(*service.ContentError); ok {
+			vm.MakeFail(c, http.StatusBadRequest, err.Error())
+		} else {
+			vm.MakeFail(c, http.StatusInternalServerError, err.Error())
+		}
+		return
+	}
+	vm.MakeSuccess(c, http.StatusOK, check)
+	return
+}
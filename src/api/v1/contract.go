@@ -25,3 +25,14 @@ func GetContractInfo(c *gin.Context) {
 	}
 	return
 }
+
+// DeployContract deploy vote contract
+func DeployContract(c *gin.Context) {
+	result, err := service.DeployContract()
+	if err != nil {
+		vm.MakeFail(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+	vm.MakeSuccess(c, http.StatusOK, result)
+	return
+}
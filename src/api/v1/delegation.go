@@ -0,0 +1,68 @@
+package v1
+
+import (
+	"FunnyVoteGo/src/api/vm"
+	"FunnyVoteGo/src/service"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Delegate delegate voting power of login user, globally or in a vote
+func Delegate(c *gin.Context) {
+	var delegation vm.DelegationInit
+	if err := c.ShouldBind(&delegation); err != nil {
+		vm.MakeFail(c, http.StatusBadRequest, "参数错误")
+		return
+	}
+	txhash, b := service.Delegate(&delegation, vm.GetUserInfo(c).ID)
+	if !b {
+		vm.MakeFail(c, http.StatusInternalServerError, "fail")
+		return
+	}
+	vm.MakeSuccess(c, http.StatusOK, txhash)
+	return
+}
+
+// RevokeDelegation revoke delegation of login user
+func RevokeDelegation(c *gin.Context) {
+	var revoke vm.DelegationRevoke
+	if err := c.ShouldBind(&revoke); err != nil {
+		vm.MakeFail(c, http.StatusBadRequest, "参数错误")
+		return
+	}
+	txhash, b := service.RevokeDelegation(revoke.VoteID, vm.GetUserInfo(c).ID)
+	if !b {
+		vm.MakeFail(c, http.StatusInternalServerError, "fail")
+		return
+	}
+	vm.MakeSuccess(c, http.StatusOK, txhash)
+	return
+}
+
+// GetDelegations get delegations of login user
+func GetDelegations(c *gin.Context) {
+	ds, b := service.GetDelegations(vm.GetUserInfo(c).ID)
+	if !b {
+		vm.MakeFail(c, http.StatusInternalServerError, "fail")
+		return
+	}
+	vm.MakeSuccess(c, http.StatusOK, ds)
+	return
+}
+
+// GetDelegationTally get resolved delegation chains and delegated weights of a vote
+func GetDelegationTally(c *gin.Context) {
+	var voteid vm.VoteID
+	if err := c.ShouldBind(&voteid); err != nil {
+		vm.MakeFail(c, http.StatusBadRequest, "参数错误")
+		return
+	}
+	tally, b := service.GetDelegationTally(voteid.VoteID)
+	if !b {
+		vm.MakeFail(c, http.StatusInternalServerError, "fail")
+		return
+	}
+	vm.MakeSuccess(c, http.StatusOK, tally)
+	return
+}
@@ -0,0 +1,136 @@
+package v1
+
+import (
+	"FunnyVoteGo/src/api/vm"
+	"FunnyVoteGo/src/service"
+	"FunnyVoteGo/src/util"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/glog"
+)
+
+// SetVoteVoters set eligibility list of a vote, inline user ids and/or xlsx/csv file
+func SetVoteVoters(c *gin.Context) {
+	var voters vm.VoteVoters
+	if err := c.ShouldBind(&voters); err != nil {
+		vm.MakeFail(c, http.StatusBadRequest, "参数错误")
+		return
+	}
+	if f, err := c.FormFile("file"); err == nil {
+		rows, err := util.FileToRows(f)
+		if err != nil {
+			glog.Error(err)
+			vm.MakeFail(c, http.StatusBadRequest, "文件解析失败")
+			return
+		}
+		ids, b := service.ParseVoterRows(rows)
+		if !b {
+			vm.MakeFail(c, http.StatusBadRequest, "存在未知用户")
+			return
+		}
+		voters.UserIDs = append(voters.UserIDs, ids...)
+	}
+	txhash, b := service.SetVoteVoters(voters.VoteID, voters.UserIDs, vm.GetUserInfo(c).ID)
+	if !b {
+		vm.MakeFail(c, http.StatusInternalServerError, "fail")
+		return
+	}
+	vm.MakeSuccess(c, http.StatusOK, txhash)
+	return
+}
+
+// GetVoterProof get merkle proof of login user in eligibility list
+func GetVoterProof(c *gin.Context) {
+	var voteid vm.VoteID
+	if err := c.ShouldBind(&voteid); err != nil {
+		vm.MakeFail(c, http.StatusBadRequest, "参数错误")
+		return
+	}
+	proof, b := service.GetVoterProof(voteid.VoteID, vm.GetUserInfo(c).ID)
+	if !b {
+		vm.MakeFail(c, http.StatusInternalServerError, "fail")
+		return
+	}
+	vm.MakeSuccess(c, http.StatusOK, proof)
+	return
+}
+
+// GenerateInviteCodes generate invite codes of a vote
+func GenerateInviteCodes(c *gin.Context) {
+	var codeinit vm.InviteCodeInit
+	if err := c.ShouldBind(&codeinit); err != nil {
+		vm.MakeFail(c, http.StatusBadRequest, "参数错误")
+		return
+	}
+	codes, b := service.GenerateInviteCodes(&codeinit, vm.GetUserInfo(c).ID)
+	if !b {
+		vm.MakeFail(c, http.StatusInternalServerError, "fail")
+		return
+	}
+	vm.MakeSuccess(c, http.StatusOK, codes)
+	return
+}
+
+// GetInviteCodes get usage of invite codes of a vote
+func GetInviteCodes(c *gin.Context) {
+	var voteid vm.VoteID
+	if err := c.ShouldBind(&voteid); err != nil {
+		vm.MakeFail(c, http.StatusBadRequest, "参数错误")
+		return
+	}
+	codes, b := service.GetInviteCodes(voteid.VoteID, vm.GetUserInfo(c).ID)
+	if !b {
+		vm.MakeFail(c, http.StatusInternalServerError, "fail")
+		return
+	}
+	vm.MakeSuccess(c, http.StatusOK, codes)
+	return
+}
+
+// SetVoteEligibility set eligibility expression of a vote
+func SetVoteEligibility(c *gin.Context) {
+	var eligibility vm.VoteEligibility
+	if err := c.ShouldBind(&eligibility); err != nil {
+		vm.MakeFail(c, http.StatusBadRequest, "参数错误")
+		return
+	}
+	txhash, b := service.SetVoteEligibility(eligibility.VoteID, eligibility.Expression, vm.GetUserInfo(c).ID)
+	if !b {
+		vm.MakeFail(c, http.StatusInternalServerError, "fail")
+		return
+	}
+	vm.MakeSuccess(c, http.StatusOK, txhash)
+	return
+}
+
+// GetUserAttributes get attributes of a user
+func GetUserAttributes(c *gin.Context) {
+	var userid vm.RBACUserID
+	if err := c.ShouldBind(&userid); err != nil {
+		vm.MakeFail(c, http.StatusBadRequest, "参数错误")
+		return
+	}
+	attrs, b := service.GetUserAttributes(userid.UserID)
+	if !b {
+		vm.MakeFail(c, http.StatusInternalServerError, "fail")
+		return
+	}
+	vm.MakeSuccess(c, http.StatusOK, attrs)
+	return
+}
+
+// SetUserAttributes set attributes of a user
+func SetUserAttributes(c *gin.Context) {
+	var attrs vm.UserAttributes
+	if err := c.ShouldBindJSON(&attrs); err != nil {
+		vm.MakeFail(c, http.StatusBadRequest, "参数错误")
+		return
+	}
+	if !service.SetUserAttributes(&attrs) {
+		vm.MakeFail(c, http.StatusInternalServerError, "fail")
+		return
+	}
+	vm.MakeSuccess(c, http.StatusOK, "success")
+	return
+}
@@ -0,0 +1,52 @@
+package v1
+
+import (
+	"FunnyVoteGo/src/api/vm"
+	"FunnyVoteGo/src/service"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/glog"
+)
+
+var exportContentType = map[string]string{
+	service.ExportCSV:  "text/csv; charset=utf-8",
+	service.ExportXLSX: xlsxContentType,
+}
+
+// ExportVote export results and ballots of a vote to xlsx or csv, the file is streamed
+// and its signed digest is sent in trailers
+func ExportVote(c *gin.Context) {
+	var req vm.ExportReq
+	if err := c.ShouldBind(&req); err != nil {
+		vm.MakeFail(c, http.StatusBadRequest, "参数错误")
+		return
+	}
+	export, err := service.PrepareExport(req.VoteID, vm.GetUserInfo(c).ID, req.Format)
+	if err != nil {
+		switch err.(type) {
+		case *service.EligibilityError:
+			vm.MakeFail(c, http.StatusForbidden, err.Error())
+		case *service.ContentError:
+			vm.MakeFail(c, http.StatusBadRequest, err.Error())
+		default:
+			vm.MakeFail(c, http.StatusInternalServerError, err.Error())
+		}
+		return
+	}
+
+	c.Header("Content-Disposition", "attachment; filename="+export.FileName())
+	c.Header("Content-Type", exportContentType[export.Format])
+	c.Header("Trailer", "X-Export-Digest, X-Export-Signature, X-Export-Signer")
+	c.Status(http.StatusOK)
+	proof, err := export.Write(c.Writer)
+	if err != nil {
+		// 文件已开始传输, 只能中断
+		glog.Errorf("export vote %s fail: %v", req.VoteID, err)
+		return
+	}
+	c.Writer.Header().Set("X-Export-Digest", proof.Digest)
+	c.Writer.Header().Set("X-Export-Signature", proof.Signature)
+	c.Writer.Header().Set("X-Export-Signer", proof.Signer)
+	return
+}
@@ -0,0 +1,69 @@
+package v1
+
+import (
+	"FunnyVoteGo/src/api/vm"
+	"FunnyVoteGo/src/service"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/glog"
+)
+
+const xlsxContentType = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+
+// ImportVotes import votes from an uploaded xlsx sheet, returns result of every row
+// as json or as a result sheet
+func ImportVotes(c *gin.Context) {
+	var req vm.ImportReq
+	if err := c.ShouldBind(&req); err != nil {
+		vm.MakeFail(c, http.StatusBadRequest, "参数错误")
+		return
+	}
+	fh, err := c.FormFile("file")
+	if err != nil {
+		vm.MakeFail(c, http.StatusBadRequest, "请上传表格")
+		return
+	}
+	if fh.Size > service.MaxImportSize {
+		vm.MakeFail(c, http.StatusBadRequest, "表格超过10MB")
+		return
+	}
+	f, err := fh.Open()
+	if err != nil {
+		glog.Error(err)
+		vm.MakeFail(c, http.StatusBadRequest, "表格读取失败")
+		return
+	}
+	defer f.Close()
+	result, err := service.ImportVotes(f, fh.Size, vm.GetUserInfo(c).ID, req.DryRun)
+	if err != nil {
+		if _, ok := err.(*service.ContentError); ok {
+			vm.MakeFail(c, http.StatusBadRequest, err.Error())
+		} else {
+			vm.MakeFail(c, http.StatusInternalServerError, err.Error())
+		}
+		return
+	}
+	if req.Format != "xlsx" {
+		vm.MakeSuccess(c, http.StatusOK, result)
+		return
+	}
+	c.Header("Content-Disposition", "attachment; filename=import_result.xlsx")
+	c.Header("Content-Type", xlsxContentType)
+	c.Status(http.StatusOK)
+	if err := service.WriteImportResult(c.Writer, result); err != nil {
+		glog.Errorf("write import result fail: %v", err)
+	}
+	return
+}
+
+// GetImportTemplate download the sheet template of vote import
+func GetImportTemplate(c *gin.Context) {
+	c.Header("Content-Disposition", "attachment; filename=vote_import.xlsx")
+	c.Header("Content-Type", xlsxContentType)
+	c.Status(http.StatusOK)
+	if err := service.WriteImportTemplate(c.Writer); err != nil {
+		glog.Errorf("write import template fail: %v", err)
+	}
+	return
+}
@@ -0,0 +1,35 @@
+package v1
+
+import (
+	"FunnyVoteGo/src/api/router/middleware"
+	"FunnyVoteGo/src/api/vm"
+	"FunnyVoteGo/src/service"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/glog"
+	melody "gopkg.in/olahol/melody.v1"
+)
+
+// LiveStream upgrades the request to websocket which pushes live results,
+// access token in header or token query is optional and used to check result visibility
+func LiveStream(mg *melody.Melody) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var userid uint
+		access := middleware.BearerToken(c)
+		if access == "" {
+			access = c.Query("token")
+		}
+		if access != "" {
+			user, b := service.ParseAccessToken(access)
+			if !b {
+				vm.MakeFail(c, http.StatusUnauthorized, "登录已失效")
+				return
+			}
+			userid = user.ID
+		}
+		if err := mg.HandleRequestWithKeys(c.Writer, c.Request, service.NewLiveKeys(userid)); err != nil {
+			glog.Error(err)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package v1
+
+import (
+	"FunnyVoteGo/src/api/vm"
+	"FunnyVoteGo/src/service"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/glog"
+)
+
+// NextPair get a random pair of options of a pairwise poll to compare
+func NextPair(c *gin.Context) {
+	var pn vm.PairwiseNext
+	if err := c.ShouldBind(&pn); err != nil {
+		glog.Error(err)
+		vm.MakeFail(c, http.StatusBadRequest, "参数错误")
+		return
+	}
+	pn.UserID = vm.GetUserInfo(c).ID
+	pair, err := service.NextPair(&pn)
+	if err != nil {
+		pairwiseFail(c, err)
+		return
+	}
+	vm.MakeSuccess(c, http.StatusOK, pair)
+	return
+}
+
+// ComparePair pick one option of a served pair
+func ComparePair(c *gin.Context) {
+	var pc vm.PairwiseChoice
+	if err := c.ShouldBind(&pc); err != nil {
+		glog.Error(err)
+		vm.MakeFail(c, http.StatusBadRequest, "参数错误")
+		return
+	}
+	pc.UserID = vm.GetUserInfo(c).ID
+	if err := service.ComparePair(&pc); err != nil {
+		pairwiseFail(c, err)
+		return
+	}
+	vm.MakeSuccess(c, http.StatusOK, "success")
+	return
+}
+
+// GetPairwiseRanking rank options of a pairwise poll by Bradley-Terry model
+func GetPairwiseRanking(c *gin.Context) {
+	var voteid vm.VoteID
+	if err := c.ShouldBind(&voteid); err != nil {
+		vm.MakeFail(c, http.StatusBadRequest, "参数错误")
+		return
+	}
+	ranking, err := service.GetPairwiseRanking(voteid.VoteID, vm.GetUserInfo(c).ID)
+	if err != nil {
+		pairwiseFail(c, err)
+		return
+	}
+	vm.MakeSuccess(c, http.StatusOK, ranking)
+	return
+}
+
+func pairwiseFail(c *gin.Context, err error) {
+	switch err.(type) {
+	case *service.EligibilityError:
+		vm.MakeFail(c, http.StatusForbidden, err.Error())
+	case *service.ContentError:
+		vm.MakeFail(c, http.StatusBadRequest, err.Error())
+	default:
+		vm.MakeFail(c, http.StatusInternalServerError, err.Error())
+	}
+}
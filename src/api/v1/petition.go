@@ -0,0 +1,54 @@
+package v1
+
+import (
+	"FunnyVoteGo/src/api/vm"
+	"FunnyVoteGo/src/service"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/glog"
+)
+
+// SignPetition sign a petition, it closes when the signature target is reached
+func SignPetition(c *gin.Context) {
+	var ps vm.PetitionSign
+	if err := c.ShouldBind(&ps); err != nil {
+		glog.Error(err)
+		vm.MakeFail(c, http.StatusBadRequest, "参数错误")
+		return
+	}
+	ps.UserID = vm.GetUserInfo(c).ID
+	if err := service.SignPetition(&ps); err != nil {
+		petitionFail(c, err)
+		return
+	}
+	vm.MakeSuccess(c, http.StatusOK, "success")
+	return
+}
+
+// GetPetitionProgress get signatures of a petition against its target
+func GetPetitionProgress(c *gin.Context) {
+	var voteid vm.VoteID
+	if err := c.ShouldBind(&voteid); err != nil {
+		vm.MakeFail(c, http.StatusBadRequest, "参数错误")
+		return
+	}
+	progress, err := service.GetPetitionProgress(voteid.VoteID, vm.GetUserInfo(c).ID)
+	if err != nil {
+		petitionFail(c, err)
+		return
+	}
+	vm.MakeSuccess(c, http.StatusOK, progress)
+	return
+}
+
+func petitionFail(c *gin.Context, err error) {
+	switch err.(type) {
+	case *service.EligibilityError:
+		vm.MakeFail(c, http.StatusForbidden, err.Error())
+	case *service.ContentError:
+		vm.MakeFail(c, http.StatusBadRequest, err.Error())
+	default:
+		vm.MakeFail(c, http.StatusInternalServerError, err.Error())
+	}
+}
@@ -0,0 +1,86 @@
+package v1
+
+import (
+	"FunnyVoteGo/src/api/vm"
+	"FunnyVoteGo/src/service"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetPolicies get all policies and role assignments
+func GetPolicies(c *gin.Context) {
+	vm.MakeSuccess(c, http.StatusOK, service.GetPolicies())
+	return
+}
+
+// AddPolicy add a policy
+func AddPolicy(c *gin.Context) {
+	var policy vm.RBACPolicy
+	if err := c.ShouldBind(&policy); err != nil {
+		vm.MakeFail(c, http.StatusBadRequest, "参数错误")
+		return
+	}
+	if !service.AddPolicy(&policy) {
+		vm.MakeFail(c, http.StatusInternalServerError, "fail")
+		return
+	}
+	vm.MakeSuccess(c, http.StatusOK, "success")
+	return
+}
+
+// RemovePolicy remove a policy
+func RemovePolicy(c *gin.Context) {
+	var policy vm.RBACPolicy
+	if err := c.ShouldBind(&policy); err != nil {
+		vm.MakeFail(c, http.StatusBadRequest, "参数错误")
+		return
+	}
+	if !service.RemovePolicy(&policy) {
+		vm.MakeFail(c, http.StatusInternalServerError, "fail")
+		return
+	}
+	vm.MakeSuccess(c, http.StatusOK, "success")
+	return
+}
+
+// GetUserRoles get roles of a user
+func GetUserRoles(c *gin.Context) {
+	var userid vm.RBACUserID
+	if err := c.ShouldBind(&userid); err != nil {
+		vm.MakeFail(c, http.StatusBadRequest, "参数错误")
+		return
+	}
+	vm.MakeSuccess(c, http.StatusOK, service.GetUserRoles(userid.UserID))
+	return
+}
+
+// AddUserRole assign a role to user
+func AddUserRole(c *gin.Context) {
+	var userrole vm.RBACUserRole
+	if err := c.ShouldBind(&userrole); err != nil {
+		vm.MakeFail(c, http.StatusBadRequest, "参数错误")
+		return
+	}
+	if !service.AddUserRole(&userrole) {
+		vm.MakeFail(c, http.StatusInternalServerError, "fail")
+		return
+	}
+	vm.MakeSuccess(c, http.StatusOK, "success")
+	return
+}
+
+// RemoveUserRole remove a role of user
+func RemoveUserRole(c *gin.Context) {
+	var userrole vm.RBACUserRole
+	if err := c.ShouldBind(&userrole); err != nil {
+		vm.MakeFail(c, http.StatusBadRequest, "参数错误")
+		return
+	}
+	if !service.RemoveUserRole(&userrole) {
+		vm.MakeFail(c, http.StatusInternalServerError, "fail")
+		return
+	}
+	vm.MakeSuccess(c, http.StatusOK, "success")
+	return
+}
@@ -0,0 +1,34 @@
+package v1
+
+import (
+	"FunnyVoteGo/src/api/vm"
+	"FunnyVoteGo/src/service"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/glog"
+)
+
+// SubmitSurvey submit answers to all questions of a survey
+func SubmitSurvey(c *gin.Context) {
+	var sb vm.SurveyBallot
+	if err := c.ShouldBindJSON(&sb); err != nil {
+		glog.Error(err)
+		vm.MakeFail(c, http.StatusBadRequest, "参数错误")
+		return
+	}
+	sb.UserID = vm.GetUserInfo(c).ID
+	if err := service.SubmitSurvey(&sb); err != nil {
+		switch err.(type) {
+		case *service.EligibilityError:
+			vm.MakeFail(c, http.StatusForbidden, err.Error())
+		case *service.ContentError:
+			vm.MakeFail(c, http.StatusBadRequest, err.Error())
+		default:
+			vm.MakeFail(c, http.StatusInternalServerError, err.Error())
+		}
+		return
+	}
+	vm.MakeSuccess(c, http.StatusOK, "success")
+	return
+}
@@ -0,0 +1,131 @@
+package v1
+
+import (
+	"FunnyVoteGo/src/api/vm"
+	"FunnyVoteGo/src/service"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/glog"
+)
+
+// CreateVoteTemplate create a reusable vote template, optionally started on a cron rule
+func CreateVoteTemplate(c *gin.Context) {
+	var ti vm.TemplateInit
+	if err := c.ShouldBindJSON(&ti); err != nil {
+		glog.Error(err)
+		vm.MakeFail(c, http.StatusBadRequest, "参数错误")
+		return
+	}
+	ti.CreatorID = vm.GetUserInfo(c).ID
+	t, err := service.CreateVoteTemplate(&ti)
+	if err != nil {
+		templateFail(c, err)
+		return
+	}
+	vm.MakeSuccess(c, http.StatusOK, t)
+	return
+}
+
+// CloneVoteTemplate create a vote template from an existing vote
+func CloneVoteTemplate(c *gin.Context) {
+	var tc vm.TemplateClone
+	if err := c.ShouldBind(&tc); err != nil {
+		glog.Error(err)
+		vm.MakeFail(c, http.StatusBadRequest, "参数错误")
+		return
+	}
+	tc.CreatorID = vm.GetUserInfo(c).ID
+	t, err := service.CloneVoteTemplate(&tc)
+	if err != nil {
+		templateFail(c, err)
+		return
+	}
+	vm.MakeSuccess(c, http.StatusOK, t)
+	return
+}
+
+// GetVoteTemplates get vote templates of login user
+func GetVoteTemplates(c *gin.Context) {
+	ts, b := service.GetVoteTemplates(vm.GetUserInfo(c).ID)
+	if !b {
+		vm.MakeFail(c, http.StatusInternalServerError, "fail")
+		return
+	}
+	vm.MakeSuccess(c, http.StatusOK, ts)
+	return
+}
+
+// StartVoteTemplate start a vote from a template now
+func StartVoteTemplate(c *gin.Context) {
+	var id vm.TemplateID
+	if err := c.ShouldBind(&id); err != nil {
+		vm.MakeFail(c, http.StatusBadRequest, "参数错误")
+		return
+	}
+	voteid, err := service.StartVoteTemplate(id.ID, vm.GetUserInfo(c).ID)
+	if err != nil {
+		templateFail(c, err)
+		return
+	}
+	vm.MakeSuccess(c, http.StatusOK, voteid)
+	return
+}
+
+// SetTemplateRecurrence change or stop recurrence of a template
+func SetTemplateRecurrence(c *gin.Context) {
+	var tr vm.TemplateRecurrence
+	if err := c.ShouldBind(&tr); err != nil {
+		vm.MakeFail(c, http.StatusBadRequest, "参数错误")
+		return
+	}
+	t, err := service.SetTemplateRecurrence(&tr, vm.GetUserInfo(c).ID)
+	if err != nil {
+		templateFail(c, err)
+		return
+	}
+	vm.MakeSuccess(c, http.StatusOK, t)
+	return
+}
+
+// DeleteVoteTemplate delete a template, votes started from it are kept
+func DeleteVoteTemplate(c *gin.Context) {
+	var id vm.TemplateID
+	if err := c.ShouldBind(&id); err != nil {
+		vm.MakeFail(c, http.StatusBadRequest, "参数错误")
+		return
+	}
+	if err := service.DeleteVoteTemplate(id.ID, vm.GetUserInfo(c).ID); err != nil {
+		templateFail(c, err)
+		return
+	}
+	vm.MakeSuccess(c, http.StatusOK, "success")
+	return
+}
+
+// GetTemplateHistory get all votes started from a template with their results
+func GetTemplateHistory(c *gin.Context) {
+	var id vm.TemplateID
+	if err := c.ShouldBind(&id); err != nil {
+		vm.MakeFail(c, http.StatusBadRequest, "参数错误")
+		return
+	}
+	history, err := service.GetTemplateHistory(id.ID, vm.GetUserInfo(c).ID)
+	if err != nil {
+		templateFail(c, err)
+		return
+	}
+	vm.MakeSuccess(c, http.StatusOK, history)
+	return
+}
+
+func templateFail(c *gin.Context, err error) {
+	switch err.(type) {
+	case *service.EligibilityError:
+		vm.MakeFail(c, http.StatusForbidden, err.Error())
+	case *service.ContentError:
+		vm.MakeFail(c, http.StatusBadRequest, err.Error())
+	default:
+		vm.MakeFail(c, http.StatusInternalServerError, err.Error())
+	}
+}
@@ -0,0 +1,88 @@
+package v1
+
+import (
+	"FunnyVoteGo/src/api/vm"
+	"FunnyVoteGo/src/service"
+	"bytes"
+	"encoding/csv"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/glog"
+)
+
+// GenerateBallotTokens generate one-time ballot tokens of a vote, as json or csv file
+func GenerateBallotTokens(c *gin.Context) {
+	var tokeninit vm.BallotTokenInit
+	if err := c.ShouldBind(&tokeninit); err != nil {
+		vm.MakeFail(c, http.StatusBadRequest, "参数错误")
+		return
+	}
+	tokens, b := service.GenerateBallotTokens(&tokeninit, vm.GetUserInfo(c).ID)
+	if !b {
+		vm.MakeFail(c, http.StatusInternalServerError, "fail")
+		return
+	}
+	if tokeninit.Format != "csv" {
+		vm.MakeSuccess(c, http.StatusOK, tokens)
+		return
+	}
+
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	w.Write([]string{"vote_id", "email", "token"})
+	for i, token := range tokens {
+		email := ""
+		if i < len(tokeninit.Emails) {
+			email = tokeninit.Emails[i]
+		}
+		w.Write([]string{tokeninit.VoteID, email, token})
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		glog.Error(err)
+		vm.MakeFail(c, http.StatusInternalServerError, "fail")
+		return
+	}
+	c.Header("Content-Disposition", "attachment; filename=tokens_"+tokeninit.VoteID+".csv")
+	c.Data(http.StatusOK, "text/csv; charset=utf-8", buf.Bytes())
+	return
+}
+
+// GetBallotTokens get usage of ballot tokens of a vote
+func GetBallotTokens(c *gin.Context) {
+	var voteid vm.VoteID
+	if err := c.ShouldBind(&voteid); err != nil {
+		vm.MakeFail(c, http.StatusBadRequest, "参数错误")
+		return
+	}
+	tokens, b := service.GetBallotTokens(voteid.VoteID, vm.GetUserInfo(c).ID)
+	if !b {
+		vm.MakeFail(c, http.StatusInternalServerError, "fail")
+		return
+	}
+	vm.MakeSuccess(c, http.StatusOK, tokens)
+	return
+}
+
+// TokenVote cast a ballot with one-time token, no login required
+func TokenVote(c *gin.Context) {
+	var ballot vm.TokenBallot
+	if err := c.ShouldBind(&ballot); err != nil {
+		vm.MakeFail(c, http.StatusBadRequest, "参数错误")
+		return
+	}
+	if err := service.CastTokenBallot(&ballot); err != nil {
+		switch err.(type) {
+		case *service.EligibilityError:
+			vm.MakeFail(c, http.StatusForbidden, err.Error())
+		case *service.ContentError:
+			vm.MakeFail(c, http.StatusBadRequest, err.Error())
+		default:
+			vm.MakeFail(c, http.StatusInternalServerError, err.Error())
+		}
+		return
+	}
+	vm.MakeSuccess(c, http.StatusOK, "success")
+	return
+}
@@ -0,0 +1,79 @@
+package v1
+
+import (
+	"FunnyVoteGo/src/api/router/middleware"
+	"FunnyVoteGo/src/api/vm"
+	"FunnyVoteGo/src/service"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Register register a user
+func Register(c *gin.Context) {
+	var register vm.UserRegister
+	if err := c.ShouldBind(&register); err != nil {
+		vm.MakeFail(c, http.StatusBadRequest, "参数错误")
+		return
+	}
+	user, b := service.Register(&register)
+	if !b {
+		vm.MakeFail(c, http.StatusInternalServerError, "fail")
+		return
+	}
+	vm.MakeSuccess(c, http.StatusOK, user)
+	return
+}
+
+// Login login by user name and password
+func Login(c *gin.Context) {
+	var login vm.Login
+	if err := c.ShouldBind(&login); err != nil {
+		vm.MakeFail(c, http.StatusBadRequest, "参数错误")
+		return
+	}
+	result, b := service.Login(&login)
+	if !b {
+		vm.MakeFail(c, http.StatusUnauthorized, "用户名或密码错误")
+		return
+	}
+	vm.MakeSuccess(c, http.StatusOK, result)
+	return
+}
+
+// Refresh get new tokens by refresh token
+func Refresh(c *gin.Context) {
+	var refresh vm.RefreshToken
+	if err := c.ShouldBind(&refresh); err != nil {
+		vm.MakeFail(c, http.StatusBadRequest, "参数错误")
+		return
+	}
+	result, b := service.Refresh(refresh.RefreshToken)
+	if !b {
+		vm.MakeFail(c, http.StatusUnauthorized, "登录已失效")
+		return
+	}
+	vm.MakeSuccess(c, http.StatusOK, result)
+	return
+}
+
+// Logout revoke tokens of login user
+func Logout(c *gin.Context) {
+	var logout vm.Logout
+	if err := c.ShouldBind(&logout); err != nil {
+		vm.MakeFail(c, http.StatusBadRequest, "参数错误")
+		return
+	}
+	if !service.Logout(middleware.BearerToken(c), logout.RefreshToken) {
+		vm.MakeFail(c, http.StatusInternalServerError, "fail")
+		return
+	}
+	vm.MakeSuccess(c, http.StatusOK, "success")
+	return
+}
+
+// GetUserInfo returns login user
+func GetUserInfo(c *gin.Context) {
+	vm.MakeSuccess(c, http.StatusOK, vm.GetUserInfo(c))
+	return
+}
@@ -4,7 +4,10 @@ import (
 	"FunnyVoteGo/src/api/vm"
 	"FunnyVoteGo/src/model"
 	"FunnyVoteGo/src/service"
+	"io"
+	"io/ioutil"
 	"net/http"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
 	"github.com/glog"
@@ -13,12 +16,35 @@ import (
 // StartVote start a vote
 func StartVote(c *gin.Context) {
 	var voteinit vm.VoteInit
-	if err := c.ShouldBindJSON(&voteinit); err != nil {
+	if c.ContentType() == "multipart/form-data" {
+		// 选项附件通过multipart上传
+		if err := c.ShouldBind(&voteinit); err != nil {
+			glog.Error(err)
+			vm.MakeFail(c, http.StatusBadRequest, "参数错误")
+			return
+		}
+		images, err := optionImages(c, len(voteinit.Options))
+		if err != nil {
+			glog.Error(err)
+			if _, ok := err.(*service.ContentError); ok {
+				vm.MakeFail(c, http.StatusBadRequest, err.Error())
+			} else {
+				vm.MakeFail(c, http.StatusBadRequest, "图片读取失败")
+			}
+			return
+		}
+		voteinit.OptionImages = images
+	} else if err := c.ShouldBindJSON(&voteinit); err != nil {
 		glog.Error(err)
 		vm.MakeFail(c, http.StatusBadRequest, "参数错误")
 		return
 	}
+	voteinit.CreatorID = vm.GetUserInfo(c).ID
 	glog.Info(voteinit)
+	if err := service.ValidateVoteInit(&voteinit); err != nil {
+		vm.MakeFail(c, http.StatusBadRequest, err.Error())
+		return
+	}
 
 	//vm.MakeSuccess(c, http.StatusOK, "oo")
 	//return
@@ -43,6 +69,7 @@ func VoteStatus(c *gin.Context) {
 		vm.MakeFail(c, http.StatusBadRequest, "参数错误")
 		return
 	}
+	getvotestatus.UserID = vm.GetUserInfo(c).ID
 	vote, b := service.GetVoteStatus(&getvotestatus)
 	if !b {
 		vm.MakeFail(c, http.StatusInternalServerError, "fail")
@@ -60,9 +87,16 @@ func Vote(c *gin.Context) {
 		vm.MakeFail(c, http.StatusBadRequest, "参数错误")
 		return
 	}
-	b := service.ChooseOption(&chooseoption)
-	if !b {
-		vm.MakeFail(c, http.StatusInternalServerError, "fail")
+	chooseoption.UserID = vm.GetUserInfo(c).ID
+	if err := service.ChooseOption(&chooseoption); err != nil {
+		switch err.(type) {
+		case *service.EligibilityError:
+			vm.MakeFail(c, http.StatusForbidden, err.Error())
+		case *service.ContentError:
+			vm.MakeFail(c, http.StatusBadRequest, err.Error())
+		default:
+			vm.MakeFail(c, http.StatusInternalServerError, err.Error())
+		}
 		return
 	}
 
@@ -77,6 +111,10 @@ func GetVoteRecord(c *gin.Context) {
 		vm.MakeFail(c, http.StatusBadRequest, "参数错误")
 		return
 	}
+	if !service.ResultVisible(voteid.VoteID, vm.GetUserInfo(c).ID) {
+		vm.MakeFail(c, http.StatusForbidden, "结果暂不可见")
+		return
+	}
 	records, b := service.GetVoteRecord(voteid.VoteID)
 	if !b {
 		vm.MakeSuccess(c, http.StatusOK, []model.VoteRecord{})
@@ -85,3 +123,84 @@ func GetVoteRecord(c *gin.Context) {
 	vm.MakeSuccess(c, http.StatusOK, records)
 	return
 }
+
+// FinalizeVote finalize outcome of an ended vote
+func FinalizeVote(c *gin.Context) {
+	var voteid vm.VoteID
+	if err := c.ShouldBind(&voteid); err != nil {
+		vm.MakeFail(c, http.StatusBadRequest, "参数错误")
+		return
+	}
+	txhash, b := service.FinalizeVote(voteid.VoteID, vm.GetUserInfo(c).ID)
+	if !b {
+		vm.MakeFail(c, http.StatusInternalServerError, "fail")
+		return
+	}
+	vm.MakeSuccess(c, http.StatusOK, txhash)
+	return
+}
+
+// GetVoteRounds get runoff rounds of a vote and the combined outcome
+func GetVoteRounds(c *gin.Context) {
+	var voteid vm.VoteID
+	if err := c.ShouldBind(&voteid); err != nil {
+		vm.MakeFail(c, http.StatusBadRequest, "参数错误")
+		return
+	}
+	rounds, b := service.GetVoteRounds(voteid.VoteID, vm.GetUserInfo(c).ID)
+	if !b {
+		vm.MakeFail(c, http.StatusInternalServerError, "fail")
+		return
+	}
+	vm.MakeSuccess(c, http.StatusOK, rounds)
+	return
+}
+
+// GetNotifications get email notifications of a vote
+func GetNotifications(c *gin.Context) {
+	var voteid vm.VoteID
+	if err := c.ShouldBind(&voteid); err != nil {
+		vm.MakeFail(c, http.StatusBadRequest, "参数错误")
+		return
+	}
+	notifications, b := service.GetNotifications(voteid.VoteID, vm.GetUserInfo(c).ID)
+	if !b {
+		vm.MakeFail(c, http.StatusInternalServerError, "fail")
+		return
+	}
+	vm.MakeSuccess(c, http.StatusOK, notifications)
+	return
+}
+
+// optionImages read uploaded images of options, field option_image_<i> for the i-th option,
+// images larger than the limit are rejected without being read into memory
+func optionImages(c *gin.Context, n int) ([][]byte, error) {
+	maxSize := service.MaxImageSize()
+	images := make([][]byte, n)
+	for i := 0; i < n; i++ {
+		field := "option_image_" + strconv.Itoa(i)
+		f, err := c.FormFile(field)
+		if err == http.ErrMissingFile {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if f.Size > maxSize {
+			return nil, &service.ContentError{Field: field, Reason: "图片过大"}
+		}
+		file, err := f.Open()
+		if err != nil {
+			return nil, err
+		}
+		images[i], err = ioutil.ReadAll(io.LimitReader(file, maxSize+1))
+		file.Close()
+		if err != nil {
+			return nil, err
+		}
+		if int64(len(images[i])) > maxSize {
+			return nil, &service.ContentError{Field: field, Reason: "图片过大"}
+		}
+	}
+	return images, nil
+}
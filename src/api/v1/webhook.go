@@ -0,0 +1,88 @@
+package v1
+
+import (
+	"FunnyVoteGo/src/api/vm"
+	"FunnyVoteGo/src/service"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CreateWebhook register a webhook of vote events
+func CreateWebhook(c *gin.Context) {
+	var webhookinit vm.WebhookInit
+	if err := c.ShouldBindJSON(&webhookinit); err != nil {
+		vm.MakeFail(c, http.StatusBadRequest, "参数错误")
+		return
+	}
+	webhookinit.CreatorID = vm.GetUserInfo(c).ID
+	webhook, b := service.CreateWebhook(&webhookinit)
+	if !b {
+		vm.MakeFail(c, http.StatusInternalServerError, "fail")
+		return
+	}
+	vm.MakeSuccess(c, http.StatusOK, webhook)
+	return
+}
+
+// GetWebhooks get webhooks of a vote
+func GetWebhooks(c *gin.Context) {
+	var query vm.WebhookQuery
+	if err := c.ShouldBind(&query); err != nil {
+		vm.MakeFail(c, http.StatusBadRequest, "参数错误")
+		return
+	}
+	webhooks, b := service.GetWebhooks(query.VoteID, vm.GetUserInfo(c).ID)
+	if !b {
+		vm.MakeFail(c, http.StatusInternalServerError, "fail")
+		return
+	}
+	vm.MakeSuccess(c, http.StatusOK, webhooks)
+	return
+}
+
+// DeleteWebhook delete a webhook
+func DeleteWebhook(c *gin.Context) {
+	var webhookid vm.WebhookID
+	if err := c.ShouldBind(&webhookid); err != nil {
+		vm.MakeFail(c, http.StatusBadRequest, "参数错误")
+		return
+	}
+	if !service.DeleteWebhook(webhookid.ID, vm.GetUserInfo(c).ID) {
+		vm.MakeFail(c, http.StatusInternalServerError, "fail")
+		return
+	}
+	vm.MakeSuccess(c, http.StatusOK, "success")
+	return
+}
+
+// GetWebhookDeliveries get delivery log of a webhook
+func GetWebhookDeliveries(c *gin.Context) {
+	var webhookid vm.WebhookID
+	if err := c.ShouldBind(&webhookid); err != nil {
+		vm.MakeFail(c, http.StatusBadRequest, "参数错误")
+		return
+	}
+	deliveries, b := service.GetWebhookDeliveries(webhookid.ID, vm.GetUserInfo(c).ID)
+	if !b {
+		vm.MakeFail(c, http.StatusInternalServerError, "fail")
+		return
+	}
+	vm.MakeSuccess(c, http.StatusOK, deliveries)
+	return
+}
+
+// RedeliverWebhook send a delivery again
+func RedeliverWebhook(c *gin.Context) {
+	var deliveryid vm.DeliveryID
+	if err := c.ShouldBind(&deliveryid); err != nil {
+		vm.MakeFail(c, http.StatusBadRequest, "参数错误")
+		return
+	}
+	if !service.RedeliverWebhook(deliveryid.ID, vm.GetUserInfo(c).ID) {
+		vm.MakeFail(c, http.StatusInternalServerError, "fail")
+		return
+	}
+	vm.MakeSuccess(c, http.StatusOK, "success")
+	return
+}
@@ -0,0 +1,84 @@
+package v1
+
+import (
+	"FunnyVoteGo/src/api/vm"
+	"FunnyVoteGo/src/service"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AddOptions add options to a running vote by its creator
+func AddOptions(c *gin.Context) {
+	var oa vm.OptionsAdd
+	if err := c.ShouldBind(&oa); err != nil {
+		vm.MakeFail(c, http.StatusBadRequest, "参数错误")
+		return
+	}
+	added, err := service.AddOptions(&oa, vm.GetUserInfo(c).ID)
+	if err != nil {
+		writeInFail(c, err)
+		return
+	}
+	vm.MakeSuccess(c, http.StatusOK, added)
+	return
+}
+
+// ProposeWriteIn propose a write-in option by login user
+func ProposeWriteIn(c *gin.Context) {
+	var wp vm.WriteInPropose
+	if err := c.ShouldBind(&wp); err != nil {
+		vm.MakeFail(c, http.StatusBadRequest, "参数错误")
+		return
+	}
+	wi, err := service.ProposeWriteIn(&wp, vm.GetUserInfo(c).ID)
+	if err != nil {
+		writeInFail(c, err)
+		return
+	}
+	vm.MakeSuccess(c, http.StatusOK, wi)
+	return
+}
+
+// ReviewWriteIn approve or reject a pending write-in
+func ReviewWriteIn(c *gin.Context) {
+	var wr vm.WriteInReview
+	if err := c.ShouldBind(&wr); err != nil {
+		vm.MakeFail(c, http.StatusBadRequest, "参数错误")
+		return
+	}
+	wi, err := service.ReviewWriteIn(&wr, vm.GetUserInfo(c).ID)
+	if err != nil {
+		writeInFail(c, err)
+		return
+	}
+	vm.MakeSuccess(c, http.StatusOK, wi)
+	return
+}
+
+// GetWriteIns get write-ins of a vote
+func GetWriteIns(c *gin.Context) {
+	var voteid vm.VoteID
+	if err := c.ShouldBind(&voteid); err != nil {
+		vm.MakeFail(c, http.StatusBadRequest, "参数错误")
+		return
+	}
+	wis, b := service.GetWriteIns(voteid.VoteID, vm.GetUserInfo(c).ID)
+	if !b {
+		vm.MakeFail(c, http.StatusInternalServerError, "fail")
+		return
+	}
+	vm.MakeSuccess(c, http.StatusOK, wis)
+	return
+}
+
+func writeInFail(c *gin.Context, err error) {
+	switch err.(type) {
+	case *service.EligibilityError:
+		vm.MakeFail(c, http.StatusForbidden, err.Error())
+	case *service.ContentError:
+		vm.MakeFail(c, http.StatusBadRequest, err.Error())
+	default:
+		vm.MakeFail(c, http.StatusInternalServerError, err.Error())
+	}
+}
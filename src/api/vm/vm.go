@@ -15,3 +15,16 @@ func MakeSuccess(c *gin.Context, code int, content interface{}) {
 func MakeFail(c *gin.Context, code int, message string) {
 	c.JSON(http.StatusOK, gin.H{"statusCode": code, "message": message})
 }
+
+// UserInfoKey is the key of login user in gin context
+const UserInfoKey = "user_info"
+
+// GetUserInfo returns login user set by auth middleware
+func GetUserInfo(c *gin.Context) *UserInfo {
+	v, ok := c.Get(UserInfoKey)
+	if !ok {
+		return nil
+	}
+	user, _ := v.(*UserInfo)
+	return user
+}
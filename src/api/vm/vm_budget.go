@@ -0,0 +1,36 @@
+package vm
+
+// BudgetBallot is for choosing options within the budget of a participatory budgeting vote
+type BudgetBallot struct {
+	VoteID     string   `json:"vote_id" form:"vote_id" binding:"required"`
+	OptionIDs  []string `json:"option_ids" form:"option_ids" binding:"required"`
+	UserID     uint     `json:"-" form:"-" des:"登录用户"`
+	InviteCode string   `json:"invite_code" form:"invite_code"`
+}
+
+// BudgetTallyReq is for allocating budget of a vote
+type BudgetTallyReq struct {
+	VoteID string `json:"vote_id" form:"vote_id" binding:"required"`
+	Method string `json:"method" form:"method" des:"greedy:按票数贪心 mes:等额分配法, 默认greedy"`
+}
+
+// BudgetProject is an option with its cost and votes
+type BudgetProject struct {
+	OptionID string `json:"option_id"`
+	Content  string `json:"content"`
+	Cost     int64  `json:"cost"`
+	Votes    uint   `json:"votes"`
+}
+
+// BudgetTally is allocation of budget of a vote
+type BudgetTally struct {
+	VoteID   string          `json:"vote_id"`
+	Method   string          `json:"method"`
+	Budget   int64           `json:"budget"`
+	Ballots  int             `json:"ballots"`
+	Funded   []BudgetProject `json:"funded"`
+	Unfunded []BudgetProject `json:"unfunded"`
+	Spent    int64           `json:"spent"`
+	Leftover int64           `json:"leftover"`
+	Tampered []string        `json:"tampered,omitempty" des:"链下内容与链上哈希不一致而未计入的选票"`
+}
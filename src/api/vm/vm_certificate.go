@@ -0,0 +1,66 @@
+package vm
+
+// ResultCertificate is result of a finalized vote, signed by the service
+type ResultCertificate struct {
+	Version         int                   `json:"version"`
+	VoteID          string                `json:"vote_id"`
+	Title           string                `json:"title"`
+	Description     string                `json:"description"`
+	SelectType      int                   `json:"select_type"`
+	StartTime       string                `json:"start_time"`
+	EndTime         string                `json:"end_time"`
+	CreatorID       uint                  `json:"creator_id"`
+	Options         []CertificateOption   `json:"options,omitempty"`
+	Questions       []CertificateQuestion `json:"questions,omitempty" des:"问卷的问题"`
+	Outcome         string                `json:"outcome"`
+	WinnerID        string                `json:"winner_id,omitempty" des:"得票最多的选项, 并列时为空"`
+	ContractAddress string                `json:"contract_address"`
+	BlockHeight     uint64                `json:"block_height" des:"确认交易所在区块"`
+	BlockHash       string                `json:"block_hash"`
+	FinalizeTxHash  string                `json:"finalize_tx_hash"`
+	Ballots         int                   `json:"ballots"`
+	BallotRoot      string                `json:"ballot_root" des:"选票Merkle根, 叶子为sha256(用户:选项ID:投票时间)"`
+	IssuedAt        string                `json:"issued_at"`
+}
+
+// CertificateOption is an option with its final tally
+type CertificateOption struct {
+	ID      string `json:"id"`
+	Content string `json:"content"`
+	Total   uint   `json:"total"`
+	Score   int    `json:"score,omitempty"`
+	Cost    int64  `json:"cost,omitempty"`
+}
+
+// CertificateQuestion is a question of survey with its final tallies
+type CertificateQuestion struct {
+	ID         string              `json:"id"`
+	Title      string              `json:"title"`
+	SelectType int                 `json:"select_type"`
+	Options    []CertificateOption `json:"options"`
+	Outcome    string              `json:"outcome"`
+}
+
+// SignedCertificate is a certificate with the signature of its digest,
+// the digest is sha256 of the certificate encoded as compact json
+type SignedCertificate struct {
+	Certificate ResultCertificate `json:"certificate"`
+	Digest      string            `json:"digest"`
+	Signature   string            `json:"signature" des:"服务密钥对摘要的secp256k1签名"`
+	Signer      string            `json:"signer" des:"服务密钥地址"`
+}
+
+// CertificateCheck is result of verifying a certificate
+type CertificateCheck struct {
+	Valid  bool              `json:"valid" des:"所有检查均通过"`
+	Signer string            `json:"signer" des:"从签名恢复的地址"`
+	Online bool              `json:"online" des:"是否对照链上数据检查"`
+	Checks []CertificateItem `json:"checks"`
+}
+
+// CertificateItem is a check of a certificate
+type CertificateItem struct {
+	Name   string `json:"name"`
+	OK     bool   `json:"ok"`
+	Detail string `json:"detail,omitempty"`
+}
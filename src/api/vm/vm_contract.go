@@ -30,3 +30,9 @@ type ReqCompileParam struct {
 	ContractCode string `json:"contract_code" form:"contract_code"`
 	ChainID      uint   `json:"chain_id" form:"chain_id"`
 }
+
+//DeployResult result of deploying contract
+type DeployResult struct {
+	ContractAddr string `json:"contract_addr"`
+	TxHash       string `json:"txhash"`
+}
@@ -0,0 +1,29 @@
+package vm
+
+// DelegationInit is for delegating voting power of login user, vote_id empty for global delegation
+type DelegationInit struct {
+	VoteID     string `json:"vote_id" form:"vote_id"`
+	DelegateID uint   `json:"delegate_id" form:"delegate_id" binding:"required"`
+}
+
+// DelegationRevoke is for revoking delegation of login user, vote_id empty for global delegation
+type DelegationRevoke struct {
+	VoteID string `json:"vote_id" form:"vote_id"`
+}
+
+// DelegationResult is resolution of a delegator, path is the delegation chain from the delegator
+type DelegationResult struct {
+	DelegatorID uint   `json:"delegator_id"`
+	DelegateID  uint   `json:"delegate_id" des:"最终受托人, 为0表示未计入"`
+	Path        []uint `json:"path"`
+	Counted     bool   `json:"counted" des:"票权是否已计入链上"`
+	TxHash      string `json:"tx_hash"`
+	Reason      string `json:"reason"`
+}
+
+// DelegationTally is delegated weights of a vote
+type DelegationTally struct {
+	VoteID  string             `json:"vote_id"`
+	Weights map[uint]int       `json:"weights" des:"受托人获得的委托票数"`
+	Results []DelegationResult `json:"results"`
+}
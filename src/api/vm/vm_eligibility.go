@@ -0,0 +1,35 @@
+package vm
+
+// VoteVoters is for setting eligibility list of a vote, user ids inline or uploaded as file
+type VoteVoters struct {
+	VoteID  string `json:"vote_id" form:"vote_id" binding:"required"`
+	UserIDs []uint `json:"user_ids" form:"user_ids"`
+}
+
+// VoterProof is merkle proof of a voter in eligibility list
+type VoterProof struct {
+	VoteID   string   `json:"vote_id"`
+	Root     string   `json:"root"`
+	Leaf     string   `json:"leaf"`
+	Proof    []string `json:"proof"`
+	Eligible bool     `json:"eligible"`
+}
+
+// InviteCodeInit is for generating invite codes, one code for each email if emails given
+type InviteCodeInit struct {
+	VoteID string   `json:"vote_id" form:"vote_id" binding:"required"`
+	Count  int      `json:"count" form:"count"`
+	Emails []string `json:"emails" form:"emails"`
+}
+
+// VoteEligibility is for setting eligibility expression of a vote, empty to remove
+type VoteEligibility struct {
+	VoteID     string `json:"vote_id" form:"vote_id" binding:"required"`
+	Expression string `json:"expression" form:"expression"`
+}
+
+// UserAttributes is attributes of a user used by eligibility expressions
+type UserAttributes struct {
+	UserID     uint                   `json:"user_id" form:"user_id" binding:"required"`
+	Attributes map[string]interface{} `json:"attributes"`
+}
@@ -0,0 +1,17 @@
+package vm
+
+// ExportReq is for exporting results of a vote
+type ExportReq struct {
+	VoteID string `json:"vote_id" form:"vote_id" binding:"required"`
+	Format string `json:"format" form:"format" des:"xlsx或csv, 默认xlsx"`
+}
+
+// ExportProof is digest of an export signed by the service
+type ExportProof struct {
+	VoteID    string `json:"vote_id"`
+	Format    string `json:"format"`
+	Ballots   int    `json:"ballots"`
+	Digest    string `json:"digest" des:"导出文件的SHA-256, CSV文件不含末尾签名行"`
+	Signature string `json:"signature" des:"服务密钥对摘要的secp256k1签名"`
+	Signer    string `json:"signer" des:"服务密钥地址"`
+}
@@ -0,0 +1,34 @@
+package vm
+
+// ImportReq is for importing votes from an xlsx sheet, the sheet is uploaded as multipart field file
+type ImportReq struct {
+	DryRun bool   `json:"dry_run" form:"dry_run" des:"只校验不创建"`
+	Format string `json:"format" form:"format" des:"json或xlsx, xlsx时返回结果表格"`
+}
+
+// ImportResult is result of an import, rows are in order of the sheet
+type ImportResult struct {
+	Total    int         `json:"total"`
+	Created  int         `json:"created"`
+	Existing int         `json:"existing" des:"此前已导入的行"`
+	Invalid  int         `json:"invalid"`
+	Failed   int         `json:"failed"`
+	Rows     []ImportRow `json:"rows"`
+}
+
+// ImportRow is result of a row of the sheet
+type ImportRow struct {
+	Row    int           `json:"row" des:"表格中的行号"`
+	Key    string        `json:"key" des:"编号, 为空时为行内容的哈希"`
+	Title  string        `json:"title"`
+	Status string        `json:"status" des:"valid:校验通过 created:已创建 existing:此前已导入 running:导入中 invalid:校验失败 failed:创建失败"`
+	VoteID string        `json:"vote_id"`
+	TxHash string        `json:"tx_hash"`
+	Errors []ImportError `json:"errors"`
+}
+
+// ImportError is an invalid field of a row
+type ImportError struct {
+	Field  string `json:"field"`
+	Reason string `json:"reason"`
+}
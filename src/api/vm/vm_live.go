@@ -0,0 +1,32 @@
+package vm
+
+// LiveRequest is message sent by websocket client
+type LiveRequest struct {
+	Action  string   `json:"action" des:"subscribe:订阅 unsubscribe:取消订阅 resync:重新同步"`
+	VoteIDs []string `json:"vote_ids"`
+}
+
+// LiveMessage is message pushed to websocket client
+type LiveMessage struct {
+	Type    string      `json:"type" des:"result:投票结果 heartbeat:心跳 error:错误"`
+	VoteID  string      `json:"vote_id,omitempty"`
+	Seq     uint64      `json:"seq,omitempty"`
+	Time    int64       `json:"time"`
+	Data    interface{} `json:"data,omitempty"`
+	Message string      `json:"message,omitempty"`
+}
+
+// LiveResult is totals and turnout of a vote
+type LiveResult struct {
+	Status  int          `json:"status" des:"1:未开始 2:进行中 3:已结束"`
+	Turnout int          `json:"turnout"`
+	Hidden  bool         `json:"hidden"`
+	Options []LiveOption `json:"options"`
+}
+
+// LiveOption is total of an option
+type LiveOption struct {
+	ID      string `json:"id"`
+	Content string `json:"content"`
+	Total   uint   `json:"total"`
+}
@@ -0,0 +1,56 @@
+package vm
+
+// PairwiseNext is for getting the next pair of options of a pairwise poll
+type PairwiseNext struct {
+	VoteID     string `json:"vote_id" form:"vote_id" binding:"required"`
+	UserID     uint   `json:"-" form:"-" des:"登录用户"`
+	InviteCode string `json:"invite_code" form:"invite_code"`
+}
+
+// PairwiseChoice is for picking one option of a pair
+type PairwiseChoice struct {
+	ComparisonID uint   `json:"comparison_id" form:"comparison_id" binding:"required"`
+	WinnerID     string `json:"winner_id" form:"winner_id" binding:"required"`
+	UserID       uint   `json:"-" form:"-" des:"登录用户"`
+}
+
+// PairwiseOption is an option of a pair
+type PairwiseOption struct {
+	OptionID string `json:"option_id"`
+	Content  string `json:"content"`
+}
+
+// PairwisePair is a pair of options served to a voter
+type PairwisePair struct {
+	ComparisonID uint           `json:"comparison_id"`
+	VoteID       string         `json:"vote_id"`
+	OptionA      PairwiseOption `json:"option_a"`
+	OptionB      PairwiseOption `json:"option_b"`
+	Answered     int            `json:"answered" des:"已完成的比较数"`
+	Quota        int            `json:"quota" des:"每人最多比较数"`
+	Done         bool           `json:"done" des:"已完成全部比较, 无选项对"`
+}
+
+// PairwiseRank is an option with its Bradley-Terry rating
+type PairwiseRank struct {
+	Rank        int     `json:"rank"`
+	OptionID    string  `json:"option_id"`
+	Content     string  `json:"content"`
+	Rating      float64 `json:"rating" des:"Elo尺度的评分, 1500为基准"`
+	Low         float64 `json:"low" des:"评分95%置信区间下限"`
+	High        float64 `json:"high" des:"评分95%置信区间上限"`
+	Wins        int     `json:"wins"`
+	Comparisons int     `json:"comparisons"`
+	WinRate     float64 `json:"win_rate"`
+	AboveNext   float64 `json:"above_next" des:"强于下一名的置信度"`
+}
+
+// PairwiseRanking is global ranking of a pairwise poll
+type PairwiseRanking struct {
+	VoteID      string         `json:"vote_id"`
+	Model       string         `json:"model"`
+	Comparisons int            `json:"comparisons"`
+	Ranking     []PairwiseRank `json:"ranking"`
+	Ignored     int            `json:"ignored,omitempty" des:"选项不存在而未计入的比较"`
+	Hidden      bool           `json:"hidden" des:"按结果可见性隐藏排名"`
+}
@@ -0,0 +1,23 @@
+package vm
+
+// PetitionSign is for signing a petition
+type PetitionSign struct {
+	VoteID     string `json:"vote_id" form:"vote_id" binding:"required"`
+	UserID     uint   `json:"-" form:"-" des:"登录用户"`
+	InviteCode string `json:"invite_code" form:"invite_code"`
+}
+
+// PetitionProgress is progress of a petition towards its signature target
+type PetitionProgress struct {
+	VoteID      string `json:"vote_id"`
+	Target      int    `json:"target"`
+	Signatures  int    `json:"signatures"`
+	Remaining   int    `json:"remaining"`
+	Percent     int    `json:"percent" des:"完成百分比, 最多100"`
+	Completed   bool   `json:"completed" des:"已达到签名目标"`
+	CompletedAt string `json:"completed_at" des:"链上记录的完成时间"`
+	TxHash      string `json:"tx_hash" des:"链上写入完成记录的交易哈希"`
+	Deadline    int64  `json:"deadline" des:"截止时间, 0为不设截止时间"`
+	Status      int    `json:"status" des:"1:未开始 2:进行中 3:已结束"`
+	Hidden      bool   `json:"hidden" des:"按结果可见性隐藏签名数"`
+}
@@ -0,0 +1,25 @@
+package vm
+
+// RBACPolicy is a policy of role
+type RBACPolicy struct {
+	Role string `json:"role" form:"role" binding:"required"`
+	Obj  string `json:"obj" form:"obj" binding:"required"`
+	Act  string `json:"act" form:"act" binding:"required"`
+}
+
+// RBACUserRole is a role of user
+type RBACUserRole struct {
+	UserID uint   `json:"user_id" form:"user_id" binding:"required"`
+	Role   string `json:"role" form:"role" binding:"required"`
+}
+
+// RBACUserID is for querying roles of user
+type RBACUserID struct {
+	UserID uint `json:"user_id" form:"user_id" binding:"required"`
+}
+
+// RBACPolicies is all policies and role assignments
+type RBACPolicies struct {
+	Policies [][]string `json:"policies"`
+	Roles    [][]string `json:"roles"`
+}
@@ -0,0 +1,37 @@
+package vm
+
+import "FunnyVoteGo/src/model"
+
+// VoteRound is result of a round of a vote
+type VoteRound struct {
+	VoteID          string         `json:"vote_id"`
+	Round           int            `json:"round"`
+	Title           string         `json:"title"`
+	Status          int            `json:"status" des:"1:未开始 2:进行中 3:已结束"`
+	Options         []model.Option `json:"options"`
+	Total           uint           `json:"total"`
+	WinnerID        string         `json:"winner_id" des:"得票最多的选项, 平票时为空"`
+	WinnerContent   string         `json:"winner_content"`
+	MajorityReached bool           `json:"majority_reached"`
+	RunoffMajority  int            `json:"runoff_majority"`
+	RunoffVoteID    string         `json:"runoff_vote_id" des:"下一轮投票ID"`
+	RunoffTxHash    string         `json:"runoff_tx_hash" des:"链上关联下一轮的交易哈希"`
+	Linked          bool           `json:"linked" des:"轮次关联与链上一致"`
+	Hidden          bool           `json:"hidden" des:"按结果可见性隐藏票数"`
+}
+
+// RoundOutcome is combined outcome of all rounds, decided by the last round
+type RoundOutcome struct {
+	VoteID        string `json:"vote_id" des:"决定结果的轮次"`
+	Decided       bool   `json:"decided"`
+	WinnerID      string `json:"winner_id"`
+	WinnerContent string `json:"winner_content"`
+	Reason        string `json:"reason" des:"未决定的原因"`
+}
+
+// VoteRounds is rounds linked from the first round of a vote
+type VoteRounds struct {
+	FirstVoteID string       `json:"first_vote_id"`
+	Rounds      []VoteRound  `json:"rounds"`
+	Outcome     RoundOutcome `json:"outcome"`
+}
@@ -0,0 +1,36 @@
+package vm
+
+// TallyReport is result of replaying transactions of vote contract
+type TallyReport struct {
+	VoteID       string            `json:"vote_id" des:"为空时检查所有投票"`
+	From         uint64            `json:"from"`
+	To           uint64            `json:"to"`
+	Transactions int               `json:"transactions" des:"发往投票合约的交易数"`
+	Replayed     int               `json:"replayed" des:"影响票数的交易数"`
+	Votes        []TallyVote       `json:"votes"`
+	Divergences  []TallyDivergence `json:"divergences"`
+	Valid        bool              `json:"valid"`
+}
+
+// TallyVote is recomputed totals of a vote against queryVoteOption
+type TallyVote struct {
+	VoteID  string        `json:"vote_id"`
+	Options []TallyOption `json:"options"`
+	Match   bool          `json:"match"`
+}
+
+// TallyOption is recomputed total of an option against queryVoteOption
+type TallyOption struct {
+	ID       string `json:"id"`
+	Replayed int    `json:"replayed"`
+	OnChain  int    `json:"on_chain" des:"-1为链上无此选项"`
+}
+
+// TallyDivergence is a transaction whose receipt differs from replay
+type TallyDivergence struct {
+	TxHash      string `json:"tx_hash"`
+	BlockNumber uint64 `json:"block_number"`
+	Method      string `json:"method"`
+	VoteID      string `json:"vote_id"`
+	Reason      string `json:"reason"`
+}
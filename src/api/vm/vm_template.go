@@ -0,0 +1,80 @@
+package vm
+
+import "FunnyVoteGo/src/model"
+
+// TemplateInit is for creating a vote template
+type TemplateInit struct {
+	Name             string   `json:"name" form:"name" des:"模板名称, 默认为标题"`
+	Title            string   `json:"title" form:"title" binding:"required"`
+	Description      string   `json:"description" form:"description" binding:"required"`
+	Options          []string `json:"options" form:"options" binding:"required"`
+	SelectType       int      `json:"select_type" form:"select_type" des:"1:单选 2:多选"`
+	ResultVisibility int      `json:"result_visibility" form:"result_visibility"`
+	Invitees         []uint   `json:"invitees" form:"invitees"`
+	Restricted       bool     `json:"restricted" form:"restricted"`
+	InviteOnly       bool     `json:"invite_only" form:"invite_only"`
+	Eligibility      string   `json:"eligibility" form:"eligibility"`
+	Duration         int64    `json:"duration" form:"duration" binding:"required" des:"每期时长, 秒"`
+	Recurrence       string   `json:"recurrence" form:"recurrence" des:"cron表达式, 如 0 9 * * 1 为每周一9点, 空为不重复"`
+	CreatorID        uint     `json:"-" form:"-" des:"登录用户"`
+}
+
+// TemplateClone is for creating a vote template from an existing vote
+type TemplateClone struct {
+	VoteID     string `json:"vote_id" form:"vote_id" binding:"required"`
+	Name       string `json:"name" form:"name"`
+	Recurrence string `json:"recurrence" form:"recurrence"`
+	CreatorID  uint   `json:"-" form:"-" des:"登录用户"`
+}
+
+// TemplateID is for operating a vote template
+type TemplateID struct {
+	ID uint `json:"id" form:"id" binding:"required"`
+}
+
+// TemplateRecurrence is for changing recurrence rule of a vote template
+type TemplateRecurrence struct {
+	ID         uint   `json:"id" form:"id" binding:"required"`
+	Recurrence string `json:"recurrence" form:"recurrence" des:"空为停止重复"`
+}
+
+// VoteTemplate is a vote template with options and invitees decoded
+type VoteTemplate struct {
+	ID               uint     `json:"id"`
+	Name             string   `json:"name"`
+	Title            string   `json:"title"`
+	Description      string   `json:"description"`
+	Options          []string `json:"options"`
+	SelectType       int      `json:"select_type"`
+	ResultVisibility int      `json:"result_visibility"`
+	Invitees         []uint   `json:"invitees"`
+	Restricted       bool     `json:"restricted"`
+	InviteOnly       bool     `json:"invite_only"`
+	Eligibility      string   `json:"eligibility"`
+	Duration         int64    `json:"duration"`
+	Recurrence       string   `json:"recurrence"`
+	NextRun          int64    `json:"next_run" des:"下次创建投票的时间, 0为不重复"`
+	SourceVoteID     string   `json:"source_vote_id" des:"克隆自的投票ID"`
+	CreatorID        uint     `json:"creator_id"`
+}
+
+// TemplateOccurrence is a vote started from a template
+type TemplateOccurrence struct {
+	VoteID        string         `json:"vote_id"`
+	Occurrence    int            `json:"occurrence" des:"第几期, 克隆来源为0"`
+	Title         string         `json:"title"`
+	StartTime     int64          `json:"start_time"`
+	EndTime       int64          `json:"end_time"`
+	Status        int            `json:"status" des:"1:未开始 2:进行中 3:已结束"`
+	Options       []model.Option `json:"options"`
+	Total         uint           `json:"total"`
+	WinnerID      string         `json:"winner_id" des:"已结束时得票最多的选项, 平票时为空"`
+	WinnerContent string         `json:"winner_content"`
+	Hidden        bool           `json:"hidden" des:"按结果可见性隐藏票数"`
+}
+
+// TemplateHistory is all occurrences of a template
+type TemplateHistory struct {
+	Template    VoteTemplate         `json:"template"`
+	Occurrences []TemplateOccurrence `json:"occurrences"`
+}
@@ -0,0 +1,18 @@
+package vm
+
+// BallotTokenInit is for generating one-time ballot tokens, one token for each email if emails given,
+// format csv to download tokens as file
+type BallotTokenInit struct {
+	VoteID string   `json:"vote_id" form:"vote_id" binding:"required"`
+	Count  int      `json:"count" form:"count"`
+	Emails []string `json:"emails" form:"emails"`
+	Format string   `json:"format" form:"format" des:"json或csv"`
+}
+
+// TokenBallot is for casting ballot with a one-time token, no login required
+type TokenBallot struct {
+	VoteID        string `json:"vote_id" form:"vote_id" binding:"required"`
+	OptionID      string `json:"option_id" form:"option_id" binding:"required"`
+	OptionContent string `json:"option_content" form:"option_content" binding:"required"`
+	Token         string `json:"token" form:"token" binding:"required"`
+}
@@ -5,3 +5,34 @@ type UserInfo struct {
 	ID       uint   `json:"id"`
 	UserName string `json:"user_name"`
 }
+
+// UserRegister is for registering a user
+type UserRegister struct {
+	UserName string `json:"user_name" form:"user_name" binding:"required"`
+	Password string `json:"password" form:"password" binding:"required"`
+	Email    string `json:"email" form:"email"`
+}
+
+// Login is for logging in by password
+type Login struct {
+	UserName string `json:"user_name" form:"user_name" binding:"required"`
+	Password string `json:"password" form:"password" binding:"required"`
+}
+
+// RefreshToken is for refreshing access token
+type RefreshToken struct {
+	RefreshToken string `json:"refresh_token" form:"refresh_token" binding:"required"`
+}
+
+// Logout is for logging out, refresh token is revoked too if given
+type Logout struct {
+	RefreshToken string `json:"refresh_token" form:"refresh_token"`
+}
+
+// LoginResult is tokens of a login user
+type LoginResult struct {
+	Token        string   `json:"token"`
+	RefreshToken string   `json:"refresh_token"`
+	ExpiresIn    int      `json:"expires_in"`
+	User         UserInfo `json:"user"`
+}
@@ -2,13 +2,60 @@ package vm
 
 // VoteInit  is for initializing a vote
 type VoteInit struct {
-	Title       string   `json:"title" form:"title" binding:"required"`
-	Description string   `json:"description" form:"description" binding:"required"`
-	Options     []string `json:"options" form:"options" binding:"required"`
-	SelectType  int      `json:"select_type" form:"select_type" des:"1:单选 2:多选"`
-	StartTime   string   `json:"start_time" form:"start_time" binding:"required"`
-	EndTime     string   `json:"end_time" form:"end_time" binding:"required"`
-	CreatorID   uint     `json:"creator_id" form:"creator_id" binding:"required"`
+	Title            string   `json:"title" form:"title" binding:"required"`
+	Description      string   `json:"description" form:"description" binding:"required"`
+	Options          []string `json:"options" form:"options" des:"选项, 问卷时为空"`
+	SelectType       int      `json:"select_type" form:"select_type" des:"1:单选 2:多选"`
+	StartTime        string   `json:"start_time" form:"start_time" binding:"required"`
+	EndTime          string   `json:"end_time" form:"end_time" des:"请愿可为空, 即不设截止时间"`
+	CreatorID        uint     `json:"-" form:"-" des:"登录用户"`
+	ResultVisibility int      `json:"result_visibility" form:"result_visibility" des:"1:实时可见 2:结束后可见 3:投票后可见 4:仅创建者可见"`
+	Invitees         []uint   `json:"invitees" form:"invitees" des:"受邀用户ID, 结束前提醒"`
+	Restricted       bool     `json:"restricted" form:"restricted" des:"仅受邀用户可投票"`
+	InviteOnly       bool     `json:"invite_only" form:"invite_only" des:"投票需要邀请码"`
+	Eligibility      string   `json:"eligibility" form:"eligibility" des:"投票资格表达式, 如 department == \"R&D\" && tenure_months >= 6"`
+	AllowWriteIn     bool     `json:"allow_write_in" form:"allow_write_in" des:"投票人可提议新选项"`
+	WriteInApproval  bool     `json:"write_in_approval" form:"write_in_approval" des:"提议的选项须创建者批准"`
+	RunoffMajority   int      `json:"runoff_majority" form:"runoff_majority" des:"胜出须超过的得票百分比, 未超过时自动产生决选轮, 0为不决选"`
+	RunoffTopN       int      `json:"runoff_top_n" form:"runoff_top_n" des:"进入决选的选项数, 默认2"`
+	RunoffDuration   int64    `json:"runoff_duration" form:"runoff_duration" des:"决选轮时长, 秒, 默认1天"`
+	Budget           int64    `json:"budget" form:"budget" des:"参与式预算的总预算, 不为0时投票为参与式预算"`
+	OptionCosts      []int64  `json:"option_costs" form:"option_costs" des:"选项成本, 与选项顺序对应"`
+	SignatureTarget  int      `json:"signature_target" form:"signature_target" des:"请愿的签名目标, 不为0时投票为请愿, 签名选项自动创建"`
+	Pairwise         bool     `json:"pairwise" form:"pairwise" des:"两两比较投票, 服务端随机给出选项对"`
+	PairsPerVoter    int      `json:"pairs_per_voter" form:"pairs_per_voter" des:"每个投票人最多比较的选项对数, 默认20"`
+
+	OptionDescriptions []string `json:"option_descriptions" form:"option_descriptions" des:"选项描述, 与选项顺序对应"`
+	OptionLinks        []string `json:"option_links" form:"option_links" des:"选项外部链接, 与选项顺序对应"`
+	OptionImages       [][]byte `json:"-" form:"-" des:"选项图片, multipart字段option_image_<序号>"`
+
+	Questions []QuestionInit `json:"questions" form:"-" des:"问卷的问题, 不为空时投票为问卷"`
+
+	TemplateID uint `json:"-" form:"-" des:"创建该投票的模板"`
+	Occurrence int  `json:"-" form:"-" des:"模板的第几期"`
+}
+
+// QuestionInit  is for initializing a question of survey
+type QuestionInit struct {
+	Title      string   `json:"title" binding:"required"`
+	SelectType int      `json:"select_type" binding:"required" des:"1:单选 2:多选 3:排序 4:打分"`
+	Options    []string `json:"options" binding:"required"`
+	MaxScore   int      `json:"max_score" des:"打分题最高分"`
+}
+
+// SurveyBallot  is for submitting answers to all questions of a survey
+type SurveyBallot struct {
+	VoteID     string         `json:"vote_id" binding:"required"`
+	Answers    []SurveyAnswer `json:"answers" binding:"required"`
+	UserID     uint           `json:"-" des:"登录用户"`
+	InviteCode string         `json:"invite_code"`
+}
+
+// SurveyAnswer  is answer to a question, options of ranked question are ordered by rank
+type SurveyAnswer struct {
+	QuestionID string   `json:"question_id"`
+	OptionIDs  []string `json:"option_ids"`
+	Values     []int    `json:"values" des:"打分题的分数, 与选项顺序对应"`
 }
 
 // ChooseOption  is for select one option
@@ -16,13 +63,14 @@ type ChooseOption struct {
 	VoteID        string `json:"vote_id" form:"voteid" binding:"required"`
 	OptionID      string `json:"option_id" form:"option_id" binding:"required"`
 	OptionContent string `json:"option_content" form:"option_content" binding:"required"`
-	UserID        uint   `json:"user_id" form:"user_id" binding:"required"`
+	UserID        uint   `json:"-" form:"-" des:"登录用户"`
+	InviteCode    string `json:"invite_code" form:"invite_code"`
 }
 
 // GetVoteStatus  is for getting status of vote
 type GetVoteStatus struct {
 	VoteID    string `json:"vote_id" form:"vote_id" binding:"required"`
-	UserID    uint   `json:"user_id" form:"user_id" binding:"required"`
+	UserID    uint   `json:"-" form:"-" des:"登录用户"`
 	Publickey string `json:"public_bkey" form:"public_key"`
 }
 
@@ -0,0 +1,24 @@
+package vm
+
+// WebhookInit is for registering a webhook
+type WebhookInit struct {
+	VoteID    string   `json:"vote_id" form:"vote_id" des:"为空表示全局"`
+	URL       string   `json:"url" form:"url" binding:"required"`
+	Events    []string `json:"events" form:"events" des:"为空表示全部事件"`
+	CreatorID uint     `json:"-" form:"-" des:"登录用户"`
+}
+
+// WebhookQuery is for listing webhooks
+type WebhookQuery struct {
+	VoteID string `json:"vote_id" form:"vote_id" des:"为空表示全局"`
+}
+
+// WebhookID model
+type WebhookID struct {
+	ID uint `json:"id" form:"id" binding:"required"`
+}
+
+// DeliveryID model
+type DeliveryID struct {
+	ID uint `json:"id" form:"id" binding:"required"`
+}
@@ -0,0 +1,19 @@
+package vm
+
+// OptionsAdd is for adding options to a vote by its creator
+type OptionsAdd struct {
+	VoteID  string   `json:"vote_id" form:"vote_id" binding:"required"`
+	Options []string `json:"options" form:"options" binding:"required"`
+}
+
+// WriteInPropose is for proposing a write-in option by a voter
+type WriteInPropose struct {
+	VoteID  string `json:"vote_id" form:"vote_id" binding:"required"`
+	Content string `json:"content" form:"content" binding:"required"`
+}
+
+// WriteInReview is for approving or rejecting a pending write-in
+type WriteInReview struct {
+	ID      uint `json:"id" form:"id" binding:"required"`
+	Approve bool `json:"approve" form:"approve"`
+}
@@ -0,0 +1,214 @@
+package main
+
+import (
+	"FunnyVoteGo/src/api/vm"
+	"FunnyVoteGo/src/lib/worker"
+	"FunnyVoteGo/src/model"
+	"FunnyVoteGo/src/service"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+
+	"github.com/glog"
+)
+
+// command a subcommand, commands with store need database and worker pool
+type command struct {
+	run   func(args []string) error
+	store bool
+}
+
+var commands = map[string]command{
+	"export": {exportCommand, true},
+	"import": {importCommand, true},
+	"verify": {verifyCommand, false},
+	"replay": {replayCommand, false},
+}
+
+// runCommand run a subcommand given after flags instead of serving, reports whether one ran
+func runCommand(args []string) bool {
+	if len(args) == 0 {
+		return false
+	}
+	cmd, ok := commands[args[0]]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "unknown command %q\n", args[0])
+		os.Exit(2)
+	}
+	if cmd.store {
+		model.InitDataBase()
+		worker.InitWorker()
+		worker.SetErrorHandle(func(err *worker.ExecJobError) {
+			glog.Error(err.Err)
+		})
+	}
+	service.InitContractAddress(cmd.store)
+	if err := cmd.run(args[1:]); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	return true
+}
+
+// exportCommand export results of a vote to a file and print its signed digest
+func exportCommand(args []string) error {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	voteid := fs.String("vote", "", "vote id to export.")
+	userid := fs.Uint("user", 0, "user the results must be visible to.")
+	format := fs.String("format", service.ExportXLSX, "xlsx or csv.")
+	out := fs.String("out", "", "output file, vote_<id>.<format> by default.")
+	fs.Parse(args)
+	if *voteid == "" {
+		fs.Usage()
+		return fmt.Errorf("-vote is required")
+	}
+
+	export, err := service.PrepareExport(*voteid, *userid, *format)
+	if err != nil {
+		return err
+	}
+	if *out == "" {
+		*out = export.FileName()
+	}
+	var proof *vm.ExportProof
+	if err := writeFile(*out, func(w io.Writer) (err error) {
+		proof, err = export.Write(w)
+		return err
+	}); err != nil {
+		return err
+	}
+	fmt.Printf("file:      %s\nballots:   %d\nsha256:    %s\nsignature: %s\nsigner:    %s\n",
+		*out, proof.Ballots, proof.Digest, proof.Signature, proof.Signer)
+	return nil
+}
+
+// importCommand import votes from an xlsx sheet, print result of every row and write the result sheet
+func importCommand(args []string) error {
+	fs := flag.NewFlagSet("import", flag.ExitOnError)
+	path := fs.String("file", "", "xlsx sheet of votes.")
+	userid := fs.Uint("user", 0, "creator of the votes.")
+	dryrun := fs.Bool("dry-run", false, "validate rows without creating votes.")
+	out := fs.String("out", "", "result sheet, not written by default.")
+	template := fs.String("template", "", "write an empty import sheet to the file and exit.")
+	fs.Parse(args)
+	if *template != "" {
+		return writeFile(*template, service.WriteImportTemplate)
+	}
+	if *path == "" || *userid == 0 {
+		fs.Usage()
+		return fmt.Errorf("-file and -user are required")
+	}
+
+	f, err := os.Open(*path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	// 命令退出前总线监听者可能未运行, VoteCreated记为待投递的webhook, 由服务端重试任务投递
+	service.RecordLocalEvents()
+	result, err := service.ImportVotes(f, info.Size(), *userid, *dryrun)
+	if err != nil {
+		return err
+	}
+	for _, row := range result.Rows {
+		fmt.Printf("%d\t%s\t%s\t%s\t%s\n", row.Row, row.Status, row.Title, row.VoteID, row.TxHash)
+		for _, e := range row.Errors {
+			fmt.Printf("\t%s: %s\n", e.Field, e.Reason)
+		}
+	}
+	fmt.Printf("total %d, created %d, existing %d, invalid %d, failed %d\n",
+		result.Total, result.Created, result.Existing, result.Invalid, result.Failed)
+	if *out != "" {
+		return writeFile(*out, func(w io.Writer) error { return service.WriteImportResult(w, result) })
+	}
+	return nil
+}
+
+// verifyCommand verify a result certificate, against chain unless offline
+func verifyCommand(args []string) error {
+	fs := flag.NewFlagSet("verify", flag.ExitOnError)
+	path := fs.String("file", "", "certificate json.")
+	signer := fs.String("signer", "", "expected address of the service key, the key in ./conf/key/key.json by default.")
+	offline := fs.Bool("offline", false, "check signature only, without chain.")
+	fs.Parse(args)
+	if *path == "" {
+		fs.Usage()
+		return fmt.Errorf("-file is required")
+	}
+	data, err := ioutil.ReadFile(*path)
+	if err != nil {
+		return err
+	}
+	check, err := service.VerifyCertificate(data, *signer, !*offline)
+	if err != nil {
+		return err
+	}
+	for _, item := range check.Checks {
+		result := "ok"
+		if !item.OK {
+			result = "FAIL"
+		}
+		fmt.Printf("%-4s %s %s\n", result, item.Name, item.Detail)
+	}
+	if !check.Valid {
+		return fmt.Errorf("certificate is not valid")
+	}
+	fmt.Println("certificate is valid")
+	return nil
+}
+
+// replayCommand replay raw contract transactions and compare the recomputed tallies with the contract
+func replayCommand(args []string) error {
+	fs := flag.NewFlagSet("replay", flag.ExitOnError)
+	voteid := fs.String("vote", "", "vote id to check, all votes by default.")
+	from := fs.Uint64("from", 1, "first block, must not be after the contract was deployed.")
+	to := fs.Uint64("to", 0, "last block, latest by default.")
+	fs.Parse(args)
+	report, err := service.ReplayTally(*voteid, *from, *to)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("blocks %d-%d: %d transactions, %d replayed\n", report.From, report.To, report.Transactions, report.Replayed)
+	for _, d := range report.Divergences {
+		fmt.Printf("FAIL %s block %d %s %s: %s\n", d.TxHash, d.BlockNumber, d.Method, d.VoteID, d.Reason)
+	}
+	for _, v := range report.Votes {
+		result := "ok"
+		if !v.Match {
+			result = "FAIL"
+		}
+		fmt.Printf("%-4s vote %s\n", result, v.VoteID)
+		for _, o := range v.Options {
+			if o.Replayed != o.OnChain {
+				fmt.Printf("     option %s replayed %d, on chain %d\n", o.ID, o.Replayed, o.OnChain)
+			}
+		}
+	}
+	if !report.Valid {
+		return fmt.Errorf("tallies diverge from transactions")
+	}
+	fmt.Println("tallies match transactions")
+	return nil
+}
+
+// writeFile create the file with content written by write, removed if write fails
+func writeFile(path string, write func(w io.Writer) error) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	err = write(f)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(path)
+	}
+	return err
+}
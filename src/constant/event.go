@@ -0,0 +1,33 @@
+package constant
+
+// events of vote contract
+const (
+	// EventVoteCreated a vote is created
+	EventVoteCreated = "VoteCreated"
+	// EventBallotCast a ballot is confirmed
+	EventBallotCast = "BallotCast"
+	// EventVoteFinalized outcome of a vote is finalized
+	EventVoteFinalized = "VoteFinalized"
+	// EventVoterRootSet eligibility list of a vote is committed
+	EventVoterRootSet = "VoterRootSet"
+	// EventEligibilitySet eligibility expression of a vote is committed
+	EventEligibilitySet = "EligibilitySet"
+	// EventDelegationSet a delegation is set or revoked
+	EventDelegationSet = "DelegationSet"
+	// EventDelegationResolved weight of a delegator is counted for the final delegate
+	EventDelegationResolved = "DelegationResolved"
+	// EventSurveySubmitted answers to all questions of a survey are submitted
+	EventSurveySubmitted = "SurveySubmitted"
+	// EventOptionAdded an option is added after the vote is created
+	EventOptionAdded = "OptionAdded"
+	// EventRunoffLinked a runoff round is created and linked to the previous round
+	EventRunoffLinked = "RunoffLinked"
+	// EventPetitionCompleted a petition reached its signature target and closed
+	EventPetitionCompleted = "PetitionCompleted"
+	// EventOptionsCompared a voter of a pairwise poll picked one of a pair of options
+	EventOptionsCompared = "OptionsCompared"
+	// EventVoteOpened start time of a vote passed, published by service
+	EventVoteOpened = "VoteOpened"
+	// EventVoteClosed end time of a vote passed, published by service
+	EventVoteClosed = "VoteClosed"
+)
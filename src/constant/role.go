@@ -0,0 +1,13 @@
+package constant
+
+// roles of rbac
+const (
+	// RoleAdmin manage contracts, policies and everything else
+	RoleAdmin = "admin"
+	// RoleCreator create and close own votes
+	RoleCreator = "creator"
+	// RoleVoter cast ballots
+	RoleVoter = "voter"
+	// RoleAuditor read only access to votes and raw records
+	RoleAuditor = "auditor"
+)
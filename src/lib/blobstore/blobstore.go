@@ -0,0 +1,154 @@
+// Package blobstore store content-addressed blobs, on local disk by default,
+// other stores can be registered and selected by blob.driver in config
+package blobstore
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/spf13/viper"
+)
+
+// Store save and load blobs by sha256 hex of their content
+type Store interface {
+	Put(hash string, data []byte) error
+	Get(hash string) ([]byte, error)
+}
+
+// Factory create a store by config
+type Factory func() (Store, error)
+
+var (
+	mu        sync.Mutex
+	factories = map[string]Factory{"disk": newDiskStore}
+	current   Store
+)
+
+// Register add a store driver
+func Register(name string, f Factory) {
+	mu.Lock()
+	defer mu.Unlock()
+	factories[name] = f
+}
+
+// Default returns store of the configured driver
+func Default() (Store, error) {
+	mu.Lock()
+	defer mu.Unlock()
+	if current != nil {
+		return current, nil
+	}
+	driver := viper.GetString("blob.driver")
+	if driver == "" {
+		driver = "disk"
+	}
+	f, ok := factories[driver]
+	if !ok {
+		return nil, fmt.Errorf("unknown blob driver: %s", driver)
+	}
+	s, err := f()
+	if err != nil {
+		return nil, err
+	}
+	current = s
+	return current, nil
+}
+
+// Put store data, returns its hash
+func Put(data []byte) (string, error) {
+	s, err := Default()
+	if err != nil {
+		return "", err
+	}
+	hash := Hash(data)
+	return hash, s.Put(hash, data)
+}
+
+// Get load data by hash
+func Get(hash string) ([]byte, error) {
+	if !ValidHash(hash) {
+		return nil, fmt.Errorf("invalid blob hash: %s", hash)
+	}
+	s, err := Default()
+	if err != nil {
+		return nil, err
+	}
+	return s.Get(hash)
+}
+
+// URL returns public url of the blob
+func URL(hash string) string {
+	prefix := viper.GetString("blob.url")
+	if prefix == "" {
+		prefix = "/api/v1/asset"
+	}
+	return prefix + "/" + hash
+}
+
+// Hash returns sha256 hex of data
+func Hash(data []byte) string {
+	h := sha256.Sum256(data)
+	return hex.EncodeToString(h[:])
+}
+
+// ValidHash check hash is sha256 hex, also keeps it safe as file name
+func ValidHash(hash string) bool {
+	if len(hash) != sha256.Size*2 {
+		return false
+	}
+	_, err := hex.DecodeString(hash)
+	return err == nil
+}
+
+// diskStore store blobs in dir/<first 2 chars of hash>/<hash>
+type diskStore struct {
+	dir string
+}
+
+func newDiskStore() (Store, error) {
+	dir := viper.GetString("blob.dir")
+	if dir == "" {
+		dir = "./data/blobs"
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &diskStore{dir: dir}, nil
+}
+
+func (s *diskStore) path(hash string) string {
+	return filepath.Join(s.dir, hash[:2], hash)
+}
+
+func (s *diskStore) Put(hash string, data []byte) error {
+	p := s.path(hash)
+	if _, err := os.Stat(p); err == nil {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(p), hash+".tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), p)
+}
+
+func (s *diskStore) Get(hash string) ([]byte, error) {
+	return ioutil.ReadFile(s.path(hash))
+}
@@ -0,0 +1,74 @@
+// Package eventbus dispatch vote events to internal listeners
+package eventbus
+
+import (
+	"strconv"
+	"sync"
+	"time"
+)
+
+// keep handled tx events for a while to drop duplicates
+const dedupWindow = 10 * time.Minute
+
+// Event of a vote
+type Event struct {
+	Name        string                 `json:"name"`
+	VoteID      string                 `json:"vote_id"`
+	TxHash      string                 `json:"tx_hash"`
+	BlockNumber uint64                 `json:"block_number"`
+	LogIndex    uint64                 `json:"log_index,omitempty"`
+	Time        int64                  `json:"time"`
+	Data        map[string]interface{} `json:"data"`
+}
+
+// Listener handle an event
+type Listener func(e Event)
+
+var (
+	mu        sync.RWMutex
+	listeners = make(map[string][]Listener)
+	seen      = make(map[string]time.Time)
+)
+
+// Subscribe add listener of event name, "*" for all events
+func Subscribe(name string, l Listener) {
+	mu.Lock()
+	defer mu.Unlock()
+	listeners[name] = append(listeners[name], l)
+}
+
+// Publish send event to its listeners, the same log of a tx delivered again by chain is dispatched once,
+// while logs of the same event emitted more than once by a tx are told apart by log index
+func Publish(e Event) {
+	if e.Time == 0 {
+		e.Time = time.Now().Unix()
+	}
+	if e.TxHash != "" && duplicated(e.Name+e.TxHash+":"+strconv.FormatUint(e.LogIndex, 10)) {
+		return
+	}
+
+	mu.RLock()
+	ls := append([]Listener{}, listeners[e.Name]...)
+	ls = append(ls, listeners["*"]...)
+	mu.RUnlock()
+
+	for _, l := range ls {
+		go l(e)
+	}
+}
+
+func duplicated(key string) bool {
+	mu.Lock()
+	defer mu.Unlock()
+	now := time.Now()
+	for k, t := range seen {
+		if now.Sub(t) > dedupWindow {
+			delete(seen, k)
+		}
+	}
+	if _, ok := seen[key]; ok {
+		return true
+	}
+	seen[key] = now
+	return false
+}
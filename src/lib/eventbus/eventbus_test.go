@@ -0,0 +1,36 @@
+package eventbus
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPublishDedup(t *testing.T) {
+	got := make(chan Event, 10)
+	Subscribe("TestCast", func(e Event) { got <- e })
+
+	events := []struct {
+		e    Event
+		want bool
+	}{
+		{Event{Name: "TestCast", TxHash: "0x1", LogIndex: 0}, true},
+		{Event{Name: "TestCast", TxHash: "0x1", LogIndex: 1}, true},
+		{Event{Name: "TestCast", TxHash: "0x1", LogIndex: 1}, false},
+		{Event{Name: "TestCast", TxHash: "0x2", LogIndex: 1}, true},
+		{Event{Name: "TestCast"}, true},
+		{Event{Name: "TestCast"}, true},
+	}
+	for i, tt := range events {
+		Publish(tt.e)
+		select {
+		case <-got:
+			if !tt.want {
+				t.Errorf("event %d: duplicate dispatched", i)
+			}
+		case <-time.After(100 * time.Millisecond):
+			if tt.want {
+				t.Errorf("event %d: not dispatched", i)
+			}
+		}
+	}
+}
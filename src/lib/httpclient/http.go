@@ -3,6 +3,7 @@ package httpclient
 import (
 	"bytes"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net/http"
 	"net/url"
@@ -30,6 +31,26 @@ func HTTPPost(url string, json []byte) (*http.Response, error) {
 	return resp, nil
 }
 
+// HTTPPostWithHeader do http post with headers by the client, returns status code and body of response
+func HTTPPostWithHeader(client *http.Client, url string, body []byte, header map[string]string) (int, []byte, error) {
+	req, err := http.NewRequest("POST", url, bytes.NewReader(body))
+	if err != nil {
+		return 0, nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range header {
+		req.Header.Set(k, v)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		glog.Errorf("client.do err: %v", err)
+		return 0, nil, err
+	}
+	defer resp.Body.Close()
+	respBody, err := ioutil.ReadAll(io.LimitReader(resp.Body, 4096))
+	return resp.StatusCode, respBody, err
+}
+
 // Client defines the interface for go client that wants to connect to a hyperchain RPC endpoint
 type client interface {
 	Send(header http.Header, body []byte) ([]byte, error)
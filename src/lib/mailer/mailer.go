@@ -0,0 +1,28 @@
+// Package mailer send mails by smtp
+package mailer
+
+import (
+	"github.com/spf13/viper"
+	gomail "gopkg.in/gomail.v2"
+)
+
+// Send send a mail with text body and optional html alternative by smtp settings in config
+func Send(to, subject, text, html string) error {
+	m := gomail.NewMessage()
+	m.SetHeader("From", viper.GetString("mail.from"))
+	m.SetHeader("To", to)
+	m.SetHeader("Subject", subject)
+	m.SetBody("text/plain", text)
+	if html != "" {
+		m.AddAlternative("text/html", html)
+	}
+
+	d := gomail.NewDialer(
+		viper.GetString("mail.host"),
+		viper.GetInt("mail.port"),
+		viper.GetString("mail.username"),
+		viper.GetString("mail.password"),
+	)
+	d.SSL = viper.GetBool("mail.ssl")
+	return d.DialAndSend(m)
+}
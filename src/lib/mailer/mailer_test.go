@@ -0,0 +1,117 @@
+package mailer
+
+import (
+	"bufio"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+// smtpStub a minimal smtp server accepting one mail without auth or tls
+type smtpStub struct {
+	ln   net.Listener
+	rcpt []string
+	data chan string
+}
+
+func newSMTPStub(t *testing.T) *smtpStub {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	s := &smtpStub{ln: ln, data: make(chan string, 1)}
+	t.Cleanup(func() { ln.Close() })
+	go s.serve()
+	return s
+}
+
+func (s *smtpStub) port() int {
+	return s.ln.Addr().(*net.TCPAddr).Port
+}
+
+func (s *smtpStub) serve() {
+	conn, err := s.ln.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	reply := func(line string) { conn.Write([]byte(line + "\r\n")) }
+	reply("220 stub ESMTP")
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		cmd := strings.ToUpper(strings.TrimSpace(line))
+		switch {
+		case strings.HasPrefix(cmd, "EHLO"), strings.HasPrefix(cmd, "HELO"):
+			reply("250 stub")
+		case strings.HasPrefix(cmd, "MAIL FROM"):
+			reply("250 ok")
+		case strings.HasPrefix(cmd, "RCPT TO"):
+			s.rcpt = append(s.rcpt, strings.TrimSpace(line[len("RCPT TO:"):]))
+			reply("250 ok")
+		case cmd == "DATA":
+			reply("354 end with .")
+			var body strings.Builder
+			for {
+				l, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if l == ".\r\n" {
+					break
+				}
+				body.WriteString(l)
+			}
+			s.data <- body.String()
+			reply("250 queued")
+		case cmd == "QUIT":
+			reply("221 bye")
+			return
+		default:
+			reply("250 ok")
+		}
+	}
+}
+
+func TestSend(t *testing.T) {
+	stub := newSMTPStub(t)
+	viper.Set("mail.host", "127.0.0.1")
+	viper.Set("mail.port", strconv.Itoa(stub.port()))
+	viper.Set("mail.from", "vote@example.com")
+	defer viper.Reset()
+
+	if err := Send("alice@example.com", "投票已开始", "plain body", "<p>html body</p>"); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	msg := <-stub.data
+	if len(stub.rcpt) != 1 || !strings.Contains(stub.rcpt[0], "alice@example.com") {
+		t.Errorf("recipients %v", stub.rcpt)
+	}
+	for _, want := range []string{"From: vote@example.com", "To: alice@example.com", "text/plain", "text/html", "plain body"} {
+		if !strings.Contains(msg, want) {
+			t.Errorf("mail does not contain %q:\n%s", want, msg)
+		}
+	}
+}
+
+func TestSendUnreachable(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	port := ln.Addr().(*net.TCPAddr).Port
+	ln.Close()
+	viper.Set("mail.host", "127.0.0.1")
+	viper.Set("mail.port", port)
+	defer viper.Reset()
+
+	if err := Send("alice@example.com", "subject", "body", ""); err == nil {
+		t.Error("Send to closed port returned nil error")
+	}
+}
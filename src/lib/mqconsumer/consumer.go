@@ -0,0 +1,92 @@
+// Package mqconsumer consume an amqp queue with manual acknowledgements
+package mqconsumer
+
+import (
+	"time"
+
+	"github.com/glog"
+	"github.com/streadway/amqp"
+)
+
+const maxBackoff = time.Minute
+
+// Handler handle a delivery, the delivery is requeued when error returned
+type Handler func(d amqp.Delivery) error
+
+// Consumer of an amqp queue
+type Consumer struct {
+	URL      string
+	Queue    string
+	Tag      string
+	Prefetch int
+	Handler  Handler
+}
+
+// Run consume the queue until stop closed, reconnect with backoff
+func (c *Consumer) Run(stop <-chan struct{}) {
+	backoff := time.Second
+	for {
+		begin := time.Now()
+		err := c.consume(stop)
+		select {
+		case <-stop:
+			return
+		default:
+		}
+		glog.Errorf("consume queue %s stopped: %v", c.Queue, err)
+		if time.Since(begin) > maxBackoff {
+			backoff = time.Second
+		}
+		time.Sleep(backoff)
+		if backoff *= 2; backoff > maxBackoff {
+			backoff = maxBackoff
+		}
+	}
+}
+
+func (c *Consumer) consume(stop <-chan struct{}) error {
+	conn, err := amqp.Dial(c.URL)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	ch, err := conn.Channel()
+	if err != nil {
+		return err
+	}
+	defer ch.Close()
+
+	prefetch := c.Prefetch
+	if prefetch <= 0 {
+		prefetch = 1
+	}
+	if err := ch.Qos(prefetch, 0, false); err != nil {
+		return err
+	}
+	deliveries, err := ch.Consume(c.Queue, c.Tag, false, false, false, false, nil)
+	if err != nil {
+		return err
+	}
+	closed := conn.NotifyClose(make(chan *amqp.Error, 1))
+
+	for {
+		select {
+		case <-stop:
+			return nil
+		case err := <-closed:
+			return err
+		case d, ok := <-deliveries:
+			if !ok {
+				return amqp.ErrClosed
+			}
+			if err := c.Handler(d); err != nil {
+				glog.Errorf("handle delivery %d of queue %s fail: %v", d.DeliveryTag, c.Queue, err)
+				d.Nack(false, true)
+				time.Sleep(time.Second)
+				continue
+			}
+			d.Ack(false)
+		}
+	}
+}
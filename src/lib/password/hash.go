@@ -0,0 +1,71 @@
+package password
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const (
+	hashPrefix = "pbkdf2_sha256"
+	hashIter   = 10000
+	hashLen    = 32
+)
+
+// Hash hash login password by PBKDF2-SHA256 with random salt
+func Hash(pw string) (string, error) {
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	dk := pbkdf2([]byte(pw), salt, hashIter)
+	return fmt.Sprintf("%s$%d$%s$%s", hashPrefix, hashIter, hex.EncodeToString(salt), hex.EncodeToString(dk)), nil
+}
+
+// Verify check login password against hash
+func Verify(pw, hashed string) bool {
+	parts := strings.Split(hashed, "$")
+	if len(parts) != 4 || parts[0] != hashPrefix {
+		return false
+	}
+	iter, err := strconv.Atoi(parts[1])
+	if err != nil || iter <= 0 {
+		return false
+	}
+	salt, err := hex.DecodeString(parts[2])
+	if err != nil {
+		return false
+	}
+	want, err := hex.DecodeString(parts[3])
+	if err != nil {
+		return false
+	}
+	return subtle.ConstantTimeCompare(pbkdf2([]byte(pw), salt, iter), want) == 1
+}
+
+// pbkdf2 derive a key of hashLen, which is one block of sha256
+func pbkdf2(pw, salt []byte, iter int) []byte {
+	prf := hmac.New(sha256.New, pw)
+	prf.Write(salt)
+	var idx [4]byte
+	binary.BigEndian.PutUint32(idx[:], 1)
+	prf.Write(idx[:])
+	u := prf.Sum(nil)
+	dk := make([]byte, len(u))
+	copy(dk, u)
+	for i := 1; i < iter; i++ {
+		prf.Reset()
+		prf.Write(u)
+		u = prf.Sum(u[:0])
+		for j := range dk {
+			dk[j] ^= u[j]
+		}
+	}
+	return dk[:hashLen]
+}
@@ -0,0 +1,73 @@
+// Package token sign and parse jwt of login users
+package token
+
+import (
+	"fmt"
+	"time"
+
+	jwt "github.com/dgrijalva/jwt-go"
+	"github.com/spf13/viper"
+)
+
+// types of token
+const (
+	// TypeAccess used by api requests
+	TypeAccess = "access"
+	// TypeRefresh used to get a new access token
+	TypeRefresh = "refresh"
+)
+
+// Claims of login token
+type Claims struct {
+	UserID   uint   `json:"user_id"`
+	UserName string `json:"user_name"`
+	Type     string `json:"type"`
+	jwt.StandardClaims
+}
+
+// Sign sign claims by secret in config, expires after ttl
+func Sign(claims Claims, ttl time.Duration) (string, error) {
+	now := time.Now()
+	claims.IssuedAt = now.Unix()
+	claims.ExpiresAt = now.Add(ttl).Unix()
+	claims.Issuer = viper.GetString("name")
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(secret())
+}
+
+// Parse verify signature and expiry of token
+func Parse(tokenString string) (*Claims, error) {
+	var claims Claims
+	t, err := jwt.ParseWithClaims(tokenString, &claims, func(t *jwt.Token) (interface{}, error) {
+		if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method: %v", t.Header["alg"])
+		}
+		return secret(), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !t.Valid {
+		return nil, fmt.Errorf("invalid token")
+	}
+	return &claims, nil
+}
+
+// defaultSecret placeholder shipped in config, never accepted
+const defaultSecret = "funnyvotego_jwt_secret_change_me"
+
+// CheckSecret check the secret is set, usually by env APISERVER_LOGIN_JWT_SECRET, and is not the placeholder.
+// Anyone knowing the secret can sign tokens for any user
+func CheckSecret() error {
+	s := viper.GetString("login.jwt_secret")
+	if s == "" {
+		return fmt.Errorf("login.jwt_secret is empty, set it by env APISERVER_LOGIN_JWT_SECRET")
+	}
+	if s == defaultSecret {
+		return fmt.Errorf("login.jwt_secret is the shipped placeholder, set a random one by env APISERVER_LOGIN_JWT_SECRET")
+	}
+	return nil
+}
+
+func secret() []byte {
+	return []byte(viper.GetString("login.jwt_secret"))
+}
@@ -0,0 +1,49 @@
+package token
+
+import (
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+func TestCheckSecret(t *testing.T) {
+	defer viper.Reset()
+	tests := []struct {
+		secret  string
+		wantErr bool
+	}{
+		{"", true},
+		{defaultSecret, true},
+		{"a6b1f0c0e3d54e6f9a1b2c3d4e5f6a7b", false},
+	}
+	for _, tt := range tests {
+		viper.Set("login.jwt_secret", tt.secret)
+		if err := CheckSecret(); (err != nil) != tt.wantErr {
+			t.Errorf("CheckSecret(%q) = %v, want err %v", tt.secret, err, tt.wantErr)
+		}
+	}
+}
+
+func TestSignParse(t *testing.T) {
+	defer viper.Reset()
+	viper.Set("login.jwt_secret", "secret-a")
+	s, err := Sign(Claims{UserID: 7, Type: TypeAccess}, time.Minute)
+	if err != nil {
+		t.Fatalf("Sign: %v", err)
+	}
+	claims, err := Parse(s)
+	if err != nil || claims.UserID != 7 || claims.Type != TypeAccess {
+		t.Fatalf("Parse = %+v, %v", claims, err)
+	}
+
+	viper.Set("login.jwt_secret", "secret-b")
+	if _, err := Parse(s); err == nil {
+		t.Error("token signed by another secret accepted")
+	}
+	viper.Set("login.jwt_secret", "secret-a")
+	expired, _ := Sign(Claims{UserID: 7}, -time.Minute)
+	if _, err := Parse(expired); err == nil {
+		t.Error("expired token accepted")
+	}
+}
@@ -43,9 +43,9 @@ func InitWorker() {
 	nodeActionDispatcher.run()
 }
 
-// SubmitFunc push func to worker
-func SubmitFunc(f func() (err error)) {
-	Submit(newFuncJob(f))
+// SubmitFunc push func to worker, the returned channel receives when func is done
+func SubmitFunc(f func() (err error)) chan bool {
+	return Submit(newFuncJob(f))
 }
 
 // Submit a job to job queue
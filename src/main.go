@@ -3,7 +3,10 @@ package main
 import (
 	"FunnyVoteGo/src/api/router"
 	"FunnyVoteGo/src/config"
+	"FunnyVoteGo/src/lib/token"
+	"FunnyVoteGo/src/lib/worker"
 	"FunnyVoteGo/src/model"
+	"FunnyVoteGo/src/service"
 	"flag"
 	"net/http"
 	"runtime"
@@ -11,6 +14,7 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/glog"
 	"github.com/spf13/viper"
+	melody "gopkg.in/olahol/melody.v1"
 )
 
 var (
@@ -18,6 +22,7 @@ var (
 )
 
 func main() {
+	flag.Parse()
 
 	if cpu := runtime.NumCPU(); cpu == 1 {
 		runtime.GOMAXPROCS(2)
@@ -28,6 +33,14 @@ func main() {
 	if err := config.Init(*cfg); err != nil {
 		panic(err)
 	}
+	// subcommands such as export run without serving
+	if runCommand(flag.Args()) {
+		return
+	}
+	// refuse to sign tokens by an empty or published secret
+	if err := token.CheckSecret(); err != nil {
+		panic(err)
+	}
 	// set gin mode
 	gin.SetMode(viper.GetString("runmode"))
 
@@ -37,12 +50,32 @@ func main() {
 	middlewares := []gin.HandlerFunc{}
 
 	model.InitDataBase()
+	service.InitContractAddress(true)
+	service.InitRBAC()
+	worker.InitWorker()
+	worker.SetErrorHandle(func(err *worker.ExecJobError) {
+		glog.Error(err.Err)
+	})
+
+	// live result stream
+	mg := melody.New()
+	service.InitLiveStream(mg)
+	// contract events
+	service.StartEventSubscriber()
+	service.StartMqConsumer()
+	service.StartScheduler()
+	// outbound webhooks
+	service.InitWebhook()
+	// email notifications
+	service.InitNotification()
+	// runoff rounds of closed votes
+	service.InitRunoff()
 
 	// Routes.
 	router.Load(
 		// Cores.
 		g,
-		nil,
+		mg,
 
 		// Middlwares.
 		middlewares...,
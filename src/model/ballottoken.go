@@ -0,0 +1,69 @@
+package model
+
+import (
+	"time"
+
+	"github.com/glog"
+)
+
+// BallotToken model, one-time token to cast a ballot without account, only hash of token is stored
+type BallotToken struct {
+	ID        uint   `json:"id"`
+	VoteID    string `json:"vote_id" gorm:"index"`
+	TokenHash string `json:"token_hash" gorm:"unique_index"`
+	Email     string `json:"email"`
+	Used      bool   `json:"used"`
+	UsedAt    string `json:"used_at"`
+	TxHash    string `json:"tx_hash"`
+	CreatedAt string `json:"created_at"`
+}
+
+// CreateBallotTokens create ballot tokens
+func CreateBallotTokens(bts []BallotToken) bool {
+	tx := db.Begin()
+	for i := range bts {
+		if err := tx.Create(&bts[i]).Error; err != nil {
+			tx.Rollback()
+			glog.Errorf("CreateBallotTokens : %v", err)
+			return false
+		}
+	}
+	if err := tx.Commit().Error; err != nil {
+		glog.Errorf("CreateBallotTokens : %v", err)
+		return false
+	}
+	return true
+}
+
+// GetBallotTokens get ballot tokens of the vote
+func GetBallotTokens(voteid string) ([]BallotToken, bool) {
+	var bts []BallotToken
+	err := db.Model(&BallotToken{}).Where("vote_id = ?", voteid).Find(&bts).Error
+	if err != nil {
+		glog.Errorf("GetBallotTokens : %v", err)
+		return nil, false
+	}
+	return bts, true
+}
+
+// UseBallotToken mark an unused token as used
+func UseBallotToken(voteid, tokenhash string) bool {
+	ret := db.Model(&BallotToken{}).
+		Where("vote_id = ? AND token_hash = ? AND used = ?", voteid, tokenhash, false).
+		Updates(map[string]interface{}{"used": true, "used_at": time.Now().Format("2006-01-02 15:04:05")})
+	if ret.Error != nil {
+		glog.Errorf("UseBallotToken : %v", ret.Error)
+		return false
+	}
+	return ret.RowsAffected == 1
+}
+
+// UpdateBallotToken update ballot token by hash
+func UpdateBallotToken(tokenhash string, attrs map[string]interface{}) bool {
+	err := db.Model(&BallotToken{}).Where("token_hash = ?", tokenhash).Updates(attrs).Error
+	if err != nil {
+		glog.Errorf("UpdateBallotToken : %v", err)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,40 @@
+package model
+
+import "github.com/glog"
+
+// ChainCursor model, the last block handled by a chain consumer
+type ChainCursor struct {
+	ID          uint   `json:"id"`
+	Name        string `json:"name"`
+	BlockNumber uint64 `json:"block_number"`
+}
+
+// GetChainCursor get cursor by name
+func GetChainCursor(name string) (*ChainCursor, bool) {
+	var cc ChainCursor
+	err := db.Model(&ChainCursor{}).Where("name = ?", name).Find(&cc).Error
+	if err != nil {
+		glog.Errorf("GetChainCursor : %v", err)
+		return nil, false
+	}
+	return &cc, true
+}
+
+// SaveChainCursor move cursor forward to block number
+func SaveChainCursor(name string, blocknumber uint64) bool {
+	var cc ChainCursor
+	err := db.Where(ChainCursor{Name: name}).FirstOrCreate(&cc).Error
+	if err != nil {
+		glog.Errorf("SaveChainCursor : %v", err)
+		return false
+	}
+	if cc.BlockNumber >= blocknumber {
+		return true
+	}
+	err = db.Model(&cc).Update("block_number", blocknumber).Error
+	if err != nil {
+		glog.Errorf("SaveChainCursor : %v", err)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,73 @@
+package model
+
+import "github.com/glog"
+
+// Comparison model, a pair of options served to a voter of pairwise poll
+type Comparison struct {
+	ID        uint   `json:"id"`
+	VoteID    string `json:"vote_id" gorm:"index"`
+	UserID    uint   `json:"user_id" gorm:"index"`
+	OptionA   string `json:"option_a"`
+	OptionB   string `json:"option_b"`
+	Answered  bool   `json:"answered"`
+	WinnerID  string `json:"winner_id"`
+	TxHash    string `json:"tx_hash"`
+	CreatedAt string `json:"created_at"`
+	UpdatedAt string `json:"updated_at"`
+}
+
+// CreateComparison create comparison
+func CreateComparison(c *Comparison) (*Comparison, bool) {
+	err := db.Create(c).Error
+	if err != nil {
+		glog.Errorf("CreateComparison : %v", err)
+		return nil, false
+	}
+	return c, true
+}
+
+// GetComparison get comparison, nil if not found
+func GetComparison(id uint) (*Comparison, bool) {
+	var cs []Comparison
+	err := db.Model(&Comparison{}).Where("id = ?", id).Find(&cs).Error
+	if err != nil {
+		glog.Errorf("GetComparison : %v", err)
+		return nil, false
+	}
+	if len(cs) == 0 {
+		return nil, true
+	}
+	return &cs[0], true
+}
+
+// GetComparisons get comparisons served to the user in the vote
+func GetComparisons(voteid string, userid uint) ([]Comparison, bool) {
+	var cs []Comparison
+	err := db.Model(&Comparison{}).Where("vote_id = ? AND user_id = ?", voteid, userid).Order("id").Find(&cs).Error
+	if err != nil {
+		glog.Errorf("GetComparisons : %v", err)
+		return nil, false
+	}
+	return cs, true
+}
+
+// AnswerComparison record winner of an unanswered comparison, false if it was answered
+func AnswerComparison(id uint, winnerid string) bool {
+	ret := db.Model(&Comparison{}).Where("id = ? AND answered = ?", id, false).
+		Updates(map[string]interface{}{"answered": true, "winner_id": winnerid})
+	if ret.Error != nil {
+		glog.Errorf("AnswerComparison : %v", ret.Error)
+		return false
+	}
+	return ret.RowsAffected == 1
+}
+
+// UpdateComparison update comparison
+func UpdateComparison(id uint, maps map[string]interface{}) bool {
+	err := db.Model(&Comparison{}).Where("id = ?", id).Updates(maps).Error
+	if err != nil {
+		glog.Errorf("UpdateComparison : %v", err)
+		return false
+	}
+	return true
+}
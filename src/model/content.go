@@ -0,0 +1,35 @@
+package model
+
+import "github.com/glog"
+
+// Content model, off-chain content addressed by sha256, only the hash is written on chain
+type Content struct {
+	ID        uint   `json:"id"`
+	Hash      string `json:"hash" gorm:"unique_index"`
+	Body      string `json:"body" gorm:"type:mediumtext"`
+	CreatedAt string `json:"created_at"`
+}
+
+// SaveContent save content if its hash not exists
+func SaveContent(hash, body string) bool {
+	err := db.Where(Content{Hash: hash}).FirstOrCreate(&Content{Hash: hash, Body: body}).Error
+	if err != nil {
+		glog.Errorf("SaveContent : %v", err)
+		return false
+	}
+	return true
+}
+
+// GetContent get content by hash, nil if not found
+func GetContent(hash string) (*Content, bool) {
+	var cs []Content
+	err := db.Model(&Content{}).Where("hash = ?", hash).Find(&cs).Error
+	if err != nil {
+		glog.Errorf("GetContent : %v", err)
+		return nil, false
+	}
+	if len(cs) == 0 {
+		return nil, true
+	}
+	return &cs[0], true
+}
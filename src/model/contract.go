@@ -1,5 +1,7 @@
 package model
 
+import "github.com/glog"
+
 // ContractInfo model
 type ContractInfo struct {
 	ID      uint   `json:"id"`
@@ -7,3 +9,35 @@ type ContractInfo struct {
 	Address string `json:"address"`
 	Code    string `json:"code"`
 }
+
+// ContractDeployment model, a deployment of vote contract, the latest one is used by service
+type ContractDeployment struct {
+	ID        uint   `json:"id"`
+	Address   string `json:"address"`
+	TxHash    string `json:"txhash"`
+	CreatedAt string `json:"created_at"`
+}
+
+// CreateContractDeployment create contract deployment
+func CreateContractDeployment(cd *ContractDeployment) (*ContractDeployment, bool) {
+	err := db.Create(cd).Error
+	if err != nil {
+		glog.Errorf("CreateContractDeployment : %v", err)
+		return nil, false
+	}
+	return cd, true
+}
+
+// GetLatestContractDeployment get the latest deployment, empty address if never deployed
+func GetLatestContractDeployment() (*ContractDeployment, bool) {
+	var cds []ContractDeployment
+	err := db.Model(&ContractDeployment{}).Order("id desc").Limit(1).Find(&cds).Error
+	if err != nil {
+		glog.Errorf("GetLatestContractDeployment : %v", err)
+		return nil, false
+	}
+	if len(cds) == 0 {
+		return &ContractDeployment{}, true
+	}
+	return &cds[0], true
+}
@@ -0,0 +1,112 @@
+package model
+
+import "github.com/glog"
+
+// Delegation model, user delegates voting power to another user, VoteID is empty for global delegation
+type Delegation struct {
+	ID           uint   `json:"id"`
+	VoteID       string `json:"vote_id" gorm:"index" des:"为空表示全局委托"`
+	DelegatorID  uint   `json:"delegator_id" gorm:"index"`
+	DelegateID   uint   `json:"delegate_id"`
+	Revoked      bool   `json:"revoked"`
+	TxHash       string `json:"tx_hash"`
+	RevokeTxHash string `json:"revoke_tx_hash"`
+	CreatedAt    string `json:"created_at"`
+	UpdatedAt    string `json:"updated_at"`
+}
+
+// DelegationResolution model, weight of a delegator counted for the final delegate of the chain
+type DelegationResolution struct {
+	ID          uint   `json:"id"`
+	VoteID      string `json:"vote_id" gorm:"index"`
+	DelegatorID uint   `json:"delegator_id"`
+	DelegateID  uint   `json:"delegate_id"`
+	Path        string `json:"path" des:"委托链, 逗号分隔的用户ID"`
+	TxHash      string `json:"tx_hash"`
+	CreatedAt   string `json:"created_at"`
+}
+
+// CreateDelegation create delegation
+func CreateDelegation(d *Delegation) (*Delegation, bool) {
+	err := db.Create(d).Error
+	if err != nil {
+		glog.Errorf("CreateDelegation : %v", err)
+		return nil, false
+	}
+	return d, true
+}
+
+// GetActiveDelegation get active delegation of the user in the scope, vote id empty for global,
+// nil if the user has no delegation
+func GetActiveDelegation(voteid string, delegatorid uint) (*Delegation, bool) {
+	var ds []Delegation
+	err := db.Model(&Delegation{}).
+		Where("vote_id = ? AND delegator_id = ? AND revoked = ?", voteid, delegatorid, false).
+		Find(&ds).Error
+	if err != nil {
+		glog.Errorf("GetActiveDelegation : %v", err)
+		return nil, false
+	}
+	if len(ds) == 0 {
+		return nil, true
+	}
+	return &ds[0], true
+}
+
+// GetActiveDelegations get active delegations of the vote and global ones
+func GetActiveDelegations(voteid string) ([]Delegation, bool) {
+	var ds []Delegation
+	err := db.Model(&Delegation{}).
+		Where("vote_id IN (?) AND revoked = ?", []string{voteid, ""}, false).
+		Find(&ds).Error
+	if err != nil {
+		glog.Errorf("GetActiveDelegations : %v", err)
+		return nil, false
+	}
+	return ds, true
+}
+
+// GetUserDelegations get delegations made by the user
+func GetUserDelegations(delegatorid uint) ([]Delegation, bool) {
+	var ds []Delegation
+	err := db.Model(&Delegation{}).Where("delegator_id = ?", delegatorid).Order("id desc").Find(&ds).Error
+	if err != nil {
+		glog.Errorf("GetUserDelegations : %v", err)
+		return nil, false
+	}
+	return ds, true
+}
+
+// RevokeDelegation revoke delegation
+func RevokeDelegation(id uint, txhash string) bool {
+	err := db.Model(&Delegation{ID: id}).Updates(map[string]interface{}{
+		"revoked":        true,
+		"revoke_tx_hash": txhash,
+	}).Error
+	if err != nil {
+		glog.Errorf("RevokeDelegation : %v", err)
+		return false
+	}
+	return true
+}
+
+// CreateDelegationResolution create delegation resolution
+func CreateDelegationResolution(dr *DelegationResolution) bool {
+	err := db.Create(dr).Error
+	if err != nil {
+		glog.Errorf("CreateDelegationResolution : %v", err)
+		return false
+	}
+	return true
+}
+
+// GetDelegationResolutions get delegation resolutions of the vote
+func GetDelegationResolutions(voteid string) ([]DelegationResolution, bool) {
+	var drs []DelegationResolution
+	err := db.Model(&DelegationResolution{}).Where("vote_id = ?", voteid).Find(&drs).Error
+	if err != nil {
+		glog.Errorf("GetDelegationResolutions : %v", err)
+		return nil, false
+	}
+	return drs, true
+}
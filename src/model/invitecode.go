@@ -0,0 +1,70 @@
+package model
+
+import (
+	"time"
+
+	"github.com/glog"
+)
+
+// InviteCode model, single-use code to cast a ballot, only hash of code is stored
+type InviteCode struct {
+	ID        uint   `json:"id"`
+	VoteID    string `json:"vote_id" gorm:"index"`
+	CodeHash  string `json:"-" gorm:"unique_index"`
+	Email     string `json:"email"`
+	UserID    uint   `json:"user_id" des:"使用者, 为0表示未使用"`
+	UsedAt    string `json:"used_at"`
+	CreatedAt string `json:"created_at"`
+}
+
+// CreateInviteCodes create invite codes
+func CreateInviteCodes(ics []InviteCode) bool {
+	tx := db.Begin()
+	for i := range ics {
+		if err := tx.Create(&ics[i]).Error; err != nil {
+			tx.Rollback()
+			glog.Errorf("CreateInviteCodes : %v", err)
+			return false
+		}
+	}
+	if err := tx.Commit().Error; err != nil {
+		glog.Errorf("CreateInviteCodes : %v", err)
+		return false
+	}
+	return true
+}
+
+// GetInviteCodes get invite codes of the vote
+func GetInviteCodes(voteid string) ([]InviteCode, bool) {
+	var ics []InviteCode
+	err := db.Model(&InviteCode{}).Where("vote_id = ?", voteid).Find(&ics).Error
+	if err != nil {
+		glog.Errorf("GetInviteCodes : %v", err)
+		return nil, false
+	}
+	return ics, true
+}
+
+// RedeemInviteCode mark an unused code as used by user
+func RedeemInviteCode(voteid, codehash string, userid uint) bool {
+	ret := db.Model(&InviteCode{}).
+		Where("vote_id = ? AND code_hash = ? AND user_id = 0", voteid, codehash).
+		Updates(map[string]interface{}{"user_id": userid, "used_at": time.Now().Format("2006-01-02 15:04:05")})
+	if ret.Error != nil {
+		glog.Errorf("RedeemInviteCode : %v", ret.Error)
+		return false
+	}
+	return ret.RowsAffected == 1
+}
+
+// ReleaseInviteCode make a redeemed code unused again, used when ballot fails
+func ReleaseInviteCode(voteid, codehash string) bool {
+	err := db.Model(&InviteCode{}).
+		Where("vote_id = ? AND code_hash = ?", voteid, codehash).
+		Updates(map[string]interface{}{"user_id": 0, "used_at": ""}).Error
+	if err != nil {
+		glog.Errorf("ReleaseInviteCode : %v", err)
+		return false
+	}
+	return true
+}
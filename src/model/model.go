@@ -41,7 +41,18 @@ func updateTimeStampForUpdateCallback(scope *gorm.Scope) {
 
 // database migrate func
 func migrate() {
-	db.AutoMigrate(&HashRecord{})
+	db.AutoMigrate(&HashRecord{}, &VoteSetting{}, &ChainCursor{})
+	db.AutoMigrate(&Webhook{}, &WebhookDelivery{})
+	db.AutoMigrate(&User{}, &VoteInvitee{}, &Notification{}, &RevokedToken{})
+	db.AutoMigrate(&InviteCode{}, &BallotToken{})
+	db.AutoMigrate(&Delegation{}, &DelegationResolution{})
+	db.AutoMigrate(&Content{}, &OptionAsset{}, &WriteIn{}, &Comparison{})
+	db.AutoMigrate(&VoteTemplate{}, &VoteImport{}, &ContractDeployment{})
+}
+
+// DataSourceName returns mysql dsn
+func DataSourceName(user, password, host, dbName string) string {
+	return user + ":" + password + "@tcp(" + host + ")/" + dbName + "?charset=utf8&parseTime=True&loc=Local"
 }
 
 // InitDataBase init mysql
@@ -63,7 +74,7 @@ func InitDataBase() {
 	//	password,
 	//	host,
 	//	dbName))
-	db, err = gorm.Open("mysql", user+":"+password+"@tcp("+host+")/"+dbName+"?charset=utf8&parseTime=True&loc=Local")
+	db, err = gorm.Open("mysql", DataSourceName(user, password, host, dbName))
 
 	if err != nil {
 		glog.Errorf("open database err: %v", err)
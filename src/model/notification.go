@@ -0,0 +1,48 @@
+package model
+
+import "github.com/glog"
+
+// Notification model, an email sent to a user, status is the same as webhook delivery
+type Notification struct {
+	ID        uint   `json:"id"`
+	Kind      string `json:"kind"`
+	VoteID    string `json:"vote_id"`
+	UserID    uint   `json:"user_id"`
+	Email     string `json:"email"`
+	Subject   string `json:"subject"`
+	Status    int    `json:"status" des:"1:待发送 2:成功 3:失败"`
+	Error     string `json:"error"`
+	CreatedAt string `json:"created_at"`
+	UpdatedAt string `json:"updated_at"`
+}
+
+// CreateNotification create notification
+func CreateNotification(n *Notification) (*Notification, bool) {
+	err := db.Create(n).Error
+	if err != nil {
+		glog.Errorf("CreateNotification : %v", err)
+		return nil, false
+	}
+	return n, true
+}
+
+// GetNotifications get notifications, latest first
+func GetNotifications(maps interface{}) ([]Notification, bool) {
+	var ns []Notification
+	err := db.Model(&Notification{}).Where(maps).Order("id desc").Find(&ns).Error
+	if err != nil {
+		glog.Errorf("GetNotifications : %v", err)
+		return nil, false
+	}
+	return ns, true
+}
+
+// UpdateNotification update notification
+func UpdateNotification(id uint, attrs map[string]interface{}) bool {
+	err := db.Model(&Notification{ID: id}).Updates(attrs).Error
+	if err != nil {
+		glog.Errorf("UpdateNotification : %v", err)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,50 @@
+package model
+
+import "github.com/glog"
+
+// OptionAsset model, attachment of an option, hash of it is committed on chain with the option
+type OptionAsset struct {
+	ID          uint   `json:"-"`
+	VoteID      string `json:"-" gorm:"index"`
+	OptionID    string `json:"option_id" gorm:"index"`
+	Description string `json:"description" gorm:"type:text"`
+	Link        string `json:"link"`
+	ImageHash   string `json:"image_hash"`
+	ThumbHash   string `json:"thumb_hash"`
+	Hash        string `json:"hash" des:"附件哈希, 写入链上"`
+	TxHash      string `json:"tx_hash"`
+	ImageURL    string `json:"image_url" gorm:"-"`
+	ThumbURL    string `json:"thumb_url" gorm:"-"`
+	CreatedAt   string `json:"created_at"`
+}
+
+// CreateOptionAsset create option asset
+func CreateOptionAsset(oa *OptionAsset) (*OptionAsset, bool) {
+	err := db.Create(oa).Error
+	if err != nil {
+		glog.Errorf("CreateOptionAsset : %v", err)
+		return nil, false
+	}
+	return oa, true
+}
+
+// UpdateOptionAsset update option asset
+func UpdateOptionAsset(id uint, attrs map[string]interface{}) bool {
+	err := db.Model(&OptionAsset{ID: id}).Updates(attrs).Error
+	if err != nil {
+		glog.Errorf("UpdateOptionAsset : %v", err)
+		return false
+	}
+	return true
+}
+
+// GetOptionAssets get assets of options of the vote
+func GetOptionAssets(voteid string) ([]OptionAsset, bool) {
+	var oas []OptionAsset
+	err := db.Model(&OptionAsset{}).Where("vote_id = ?", voteid).Find(&oas).Error
+	if err != nil {
+		glog.Errorf("GetOptionAssets : %v", err)
+		return nil, false
+	}
+	return oas, true
+}
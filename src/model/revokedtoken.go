@@ -0,0 +1,41 @@
+package model
+
+import "github.com/glog"
+
+// RevokedToken model, token logged out before expiry
+type RevokedToken struct {
+	ID        uint   `json:"id"`
+	TokenID   string `json:"token_id" gorm:"unique_index"`
+	ExpiresAt int64  `json:"expires_at"`
+}
+
+// CreateRevokedToken revoke a token
+func CreateRevokedToken(rt *RevokedToken) bool {
+	err := db.Create(rt).Error
+	if err != nil {
+		glog.Errorf("CreateRevokedToken : %v", err)
+		return false
+	}
+	return true
+}
+
+// IsTokenRevoked check whether token is revoked
+func IsTokenRevoked(tokenid string) bool {
+	var count int
+	err := db.Model(&RevokedToken{}).Where("token_id = ?", tokenid).Count(&count).Error
+	if err != nil {
+		glog.Errorf("IsTokenRevoked : %v", err)
+		return true
+	}
+	return count > 0
+}
+
+// DeleteExpiredTokens remove revoked tokens which are expired anyway
+func DeleteExpiredTokens(now int64) bool {
+	err := db.Where("expires_at < ?", now).Delete(&RevokedToken{}).Error
+	if err != nil {
+		glog.Errorf("DeleteExpiredTokens : %v", err)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,31 @@
+package model
+
+// select type of a vote or a question of survey
+const (
+	// SelectSingle one option
+	SelectSingle = 1
+	// SelectMulti one or more options
+	SelectMulti = 2
+	// SelectRanked options ordered by preference
+	SelectRanked = 3
+	// SelectScore each option given a score
+	SelectScore = 4
+	// SelectSurvey vote holding several questions, used by vote only
+	SelectSurvey = 5
+	// SelectBudget options with costs chosen within a total budget, used by vote only
+	SelectBudget = 6
+	// SelectPetition a single sign option with a signature target, used by vote only
+	SelectPetition = 7
+	// SelectPairwise pairs of options compared one by one, used by vote only
+	SelectPairwise = 8
+)
+
+// Question  model, a question of survey
+type Question struct {
+	ID         string   `json:"id"`
+	Title      string   `json:"title"`
+	SelectType int      `json:"select_type" des:"1:单选 2:多选 3:排序 4:打分"`
+	MaxScore   int      `json:"max_score" des:"打分题最高分"`
+	Options    []Option `json:"options"`
+	Tampered   bool     `json:"tampered,omitempty" des:"链下内容与链上哈希不一致"`
+}
@@ -0,0 +1,58 @@
+package model
+
+import "github.com/glog"
+
+// User model
+type User struct {
+	ID        uint   `json:"id"`
+	UserName  string `json:"user_name" gorm:"unique_index"`
+	Email     string `json:"email"`
+	Password  string `json:"-"`
+	Attrs     string `json:"-" gorm:"type:text" des:"用户属性, json"`
+	CreatedAt string `json:"created_at"`
+}
+
+// CreateUser create user
+func CreateUser(u *User) (*User, bool) {
+	err := db.Create(u).Error
+	if err != nil {
+		glog.Errorf("CreateUser : %v", err)
+		return nil, false
+	}
+	return u, true
+}
+
+// GetUser get user
+func GetUser(maps interface{}) (*User, bool) {
+	var u User
+	err := db.Model(&User{}).Where(maps).Find(&u).Error
+	if err != nil {
+		glog.Errorf("GetUser : %v", err)
+		return nil, false
+	}
+	return &u, true
+}
+
+// GetUsersByIDs get users by ids
+func GetUsersByIDs(ids []uint) ([]User, bool) {
+	var us []User
+	if len(ids) == 0 {
+		return us, true
+	}
+	err := db.Model(&User{}).Where("id in (?)", ids).Find(&us).Error
+	if err != nil {
+		glog.Errorf("GetUsersByIDs : %v", err)
+		return nil, false
+	}
+	return us, true
+}
+
+// UpdateUser update user
+func UpdateUser(id uint, attrs map[string]interface{}) bool {
+	err := db.Model(&User{ID: id}).Updates(attrs).Error
+	if err != nil {
+		glog.Errorf("UpdateUser : %v", err)
+		return false
+	}
+	return true
+}
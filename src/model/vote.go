@@ -4,17 +4,22 @@ import "github.com/glog"
 
 // Vote model
 type Vote struct {
-	ID          string   `json:"id"`
-	Title       string   `json:"title"`
-	Description string   `json:"description"`
-	SelectType  int      `json:"select_type" des:"1:单选 2:多选"`
-	StartTime   string   `json:"start_time"`
-	EndTime     string   `json:"end_time"`
-	CreateTime  string   `json:"create_time"`
-	CreatorID   uint     `json:"creator_id"`
-	Options     []Option `json:"options"`
-	Status      int      `json:"status" des:"1:未开始 2:进行中 3:已结束"`
-	UserVoted   int      `json:"user_voted" des:"1:未投票 2:已投票"`
+	ID          string     `json:"id"`
+	Title       string     `json:"title"`
+	Description string     `json:"description"`
+	SelectType  int        `json:"select_type" des:"1:单选 2:多选 5:问卷 6:参与式预算 7:请愿 8:两两比较"`
+	StartTime   string     `json:"start_time"`
+	EndTime     string     `json:"end_time"`
+	CreateTime  string     `json:"create_time"`
+	CreatorID   uint       `json:"creator_id"`
+	Options     []Option   `json:"options"`
+	Questions   []Question `json:"questions,omitempty" des:"问卷的问题"`
+	Budget      int64      `json:"budget,omitempty" des:"参与式预算的总预算"`
+	Target      int        `json:"signature_target,omitempty" des:"请愿的签名目标"`
+	Status      int        `json:"status" des:"1:未开始 2:进行中 3:已结束"`
+	UserVoted   int        `json:"user_voted" des:"1:未投票 2:已投票"`
+	Hidden      bool       `json:"hidden" des:"按结果可见性隐藏票数"`
+	Tampered    []string   `json:"tampered,omitempty" des:"链下内容与链上哈希不一致的字段"`
 }
 
 // Vote2 model
@@ -35,10 +40,44 @@ type Vote2 struct {
 
 // Option  model
 type Option struct {
-	ID      string `json:"id"`
-	Content string `json:"content"`
-	Total   uint   `json:"total"`
-	VoteID  string `json:"vote_id"`
+	ID       string `json:"id"`
+	Content  string `json:"content"`
+	Total    uint   `json:"total"`
+	Score    int    `json:"score,omitempty" des:"问卷排序题、打分题的得分"`
+	Cost     int64  `json:"cost,omitempty" des:"参与式预算的选项成本"`
+	VoteID   string `json:"vote_id"`
+	Tampered bool   `json:"tampered,omitempty" des:"链下内容与链上哈希不一致"`
+
+	Asset *OptionAsset `json:"asset,omitempty" des:"选项附件"`
+}
+
+// TokenOption  model, ballot of token holder, user_id is 0x prefixed token hash
+type TokenOption struct {
+	ID            string `json:"id"`
+	VoteID        string `json:"vote_id"`
+	OptionID      string `json:"option_id"`
+	OptionContent string `json:"option_content"`
+	UserID        string `json:"user_id"`
+	Publickey     string `json:"public_key"`
+	CreateTime    string `json:"create_time"`
+}
+
+// VoterRoot  model
+type VoterRoot struct {
+	ID   string `json:"id"`
+	Root string `json:"root"`
+}
+
+// OptionAssetHash  model
+type OptionAssetHash struct {
+	ID   string `json:"id"`
+	Hash string `json:"hash"`
+}
+
+// EligibilityHash  model
+type EligibilityHash struct {
+	ID   string `json:"id"`
+	Hash string `json:"hash"`
 }
 
 // UserOption  model
@@ -57,6 +96,7 @@ type VoteRecord struct {
 	UserID        string `json:"user_id"`
 	OptionContent string `json:"option_content"`
 	TxHash        string `json:"tx_hash"`
+	Tampered      bool   `json:"tampered,omitempty" des:"链下内容与链上哈希不一致"`
 }
 
 // HashRecord  model
@@ -64,9 +104,12 @@ type HashRecord struct {
 	ID            uint   `json:"id"`
 	VoteID        string `json:"vote_id"`
 	UserID        uint   `json:"user_id"`
+	TokenHash     string `json:"token_hash" des:"使用投票令牌时为令牌哈希, user_id为0"`
 	OptionID      string `json:"option_id"`
 	OptionContent string `json:"option_content"`
+	BallotID      string `json:"ballot_id" gorm:"index" des:"链上投票记录ID"`
 	TxHash        string `json:"tx_hash"`
+	BlockNumber   uint64 `json:"block_number" des:"交易所在区块, 导出时查询并缓存"`
 }
 
 // CreateHashRecord create hash record
@@ -90,3 +133,62 @@ func GetHashRecord(maps interface{}) (*HashRecord, bool) {
 	return &hr, true
 
 }
+
+// GetHashRecords get hash records in order of creation
+func GetHashRecords(query interface{}, args ...interface{}) ([]HashRecord, bool) {
+	var hrs []HashRecord
+	err := db.Model(&HashRecord{}).Where(query, args...).Order("id").Find(&hrs).Error
+	if err != nil {
+		glog.Errorf("GetHashRecords : %v", err)
+		return nil, false
+	}
+	return hrs, true
+}
+
+// UpdateHashRecord update hash record
+func UpdateHashRecord(id uint, maps map[string]interface{}) bool {
+	err := db.Model(&HashRecord{}).Where("id = ?", id).Updates(maps).Error
+	if err != nil {
+		glog.Errorf("UpdateHashRecord : %v", err)
+		return false
+	}
+	return true
+}
+
+// CountVoteUsers count users and tokens which voted in the vote
+func CountVoteUsers(voteid string) (int, bool) {
+	var users, tokens int
+	err := db.Model(&HashRecord{}).Where("vote_id = ? AND token_hash = ''", voteid).Select("count(distinct(user_id))").Count(&users).Error
+	if err != nil {
+		glog.Errorf("CountVoteUsers : %v", err)
+		return 0, false
+	}
+	err = db.Model(&HashRecord{}).Where("vote_id = ? AND token_hash <> ''", voteid).Count(&tokens).Error
+	if err != nil {
+		glog.Errorf("CountVoteUsers : %v", err)
+		return 0, false
+	}
+	return users + tokens, true
+}
+
+// GetVotedUserIDs get ids of users who voted in the vote
+func GetVotedUserIDs(voteid string) ([]uint, bool) {
+	var ids []uint
+	err := db.Model(&HashRecord{}).Where("vote_id = ? AND token_hash = ''", voteid).Pluck("distinct(user_id)", &ids).Error
+	if err != nil {
+		glog.Errorf("GetVotedUserIDs : %v", err)
+		return nil, false
+	}
+	return ids, true
+}
+
+// HasUserVoted check the user cast a ballot in the vote
+func HasUserVoted(voteid string, userid uint) (bool, bool) {
+	var count int
+	err := db.Model(&HashRecord{}).Where("vote_id = ? AND user_id = ? AND token_hash = ''", voteid, userid).Count(&count).Error
+	if err != nil {
+		glog.Errorf("HasUserVoted : %v", err)
+		return false, false
+	}
+	return count > 0, true
+}
@@ -0,0 +1,72 @@
+package model
+
+import "github.com/glog"
+
+// status of imported rows
+const (
+	ImportRunning = 1
+	ImportDone    = 2
+	ImportFailed  = 3
+)
+
+// VoteImport model, a row of an import sheet, rows are keyed by creator so a sheet can be imported again
+type VoteImport struct {
+	ID        uint   `json:"id"`
+	CreatorID uint   `json:"creator_id" gorm:"unique_index:idx_vote_import_row"`
+	RowKey    string `json:"row_key" gorm:"unique_index:idx_vote_import_row" des:"表格中的编号, 为空时为行内容的哈希"`
+	Row       int    `json:"row"`
+	Title     string `json:"title" gorm:"type:text"`
+	Status    int    `json:"status" des:"1:导入中 2:已导入 3:失败"`
+	VoteID    string `json:"vote_id"`
+	TxHash    string `json:"tx_hash"`
+	Error     string `json:"error" gorm:"type:text"`
+	CreatedAt string `json:"created_at"`
+	UpdatedAt string `json:"updated_at"`
+}
+
+// CreateVoteImport create vote import
+func CreateVoteImport(vi *VoteImport) (*VoteImport, bool) {
+	err := db.Create(vi).Error
+	if err != nil {
+		glog.Errorf("CreateVoteImport : %v", err)
+		return nil, false
+	}
+	return vi, true
+}
+
+// GetVoteImport get vote import of a row, nil if not found
+func GetVoteImport(creatorid uint, rowkey string) (*VoteImport, bool) {
+	var vis []VoteImport
+	err := db.Model(&VoteImport{}).Where("creator_id = ? AND row_key = ?", creatorid, rowkey).Find(&vis).Error
+	if err != nil {
+		glog.Errorf("GetVoteImport : %v", err)
+		return nil, false
+	}
+	if len(vis) == 0 {
+		return nil, true
+	}
+	return &vis[0], true
+}
+
+// ClaimVoteImport mark a failed row, or a running row not updated since staleBefore, as running again,
+// false if another import claimed it
+func ClaimVoteImport(id uint, staleBefore string) bool {
+	ret := db.Model(&VoteImport{}).
+		Where("id = ? AND (status = ? OR (status = ? AND updated_at < ?))", id, ImportFailed, ImportRunning, staleBefore).
+		Updates(map[string]interface{}{"status": ImportRunning, "error": ""})
+	if ret.Error != nil {
+		glog.Errorf("ClaimVoteImport : %v", ret.Error)
+		return false
+	}
+	return ret.RowsAffected == 1
+}
+
+// UpdateVoteImport update vote import
+func UpdateVoteImport(id uint, maps map[string]interface{}) bool {
+	err := db.Model(&VoteImport{}).Where("id = ?", id).Updates(maps).Error
+	if err != nil {
+		glog.Errorf("UpdateVoteImport : %v", err)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,71 @@
+package model
+
+import "github.com/glog"
+
+// VoteInvitee model, user invited to a vote, also the eligibility list of restricted vote
+type VoteInvitee struct {
+	ID     uint   `json:"id"`
+	VoteID string `json:"vote_id" gorm:"index"`
+	UserID uint   `json:"user_id"`
+}
+
+// CreateVoteInvitees create invitees of the vote
+func CreateVoteInvitees(voteid string, userids []uint) bool {
+	tx := db.Begin()
+	for _, id := range userids {
+		if err := tx.Create(&VoteInvitee{VoteID: voteid, UserID: id}).Error; err != nil {
+			tx.Rollback()
+			glog.Errorf("CreateVoteInvitees : %v", err)
+			return false
+		}
+	}
+	if err := tx.Commit().Error; err != nil {
+		glog.Errorf("CreateVoteInvitees : %v", err)
+		return false
+	}
+	return true
+}
+
+// GetVoteInviteeIDs get user ids invited to the vote
+func GetVoteInviteeIDs(voteid string) ([]uint, bool) {
+	var ids []uint
+	err := db.Model(&VoteInvitee{}).Where("vote_id = ?", voteid).Pluck("user_id", &ids).Error
+	if err != nil {
+		glog.Errorf("GetVoteInviteeIDs : %v", err)
+		return nil, false
+	}
+	return ids, true
+}
+
+// ReplaceVoteInvitees replace invitees of the vote
+func ReplaceVoteInvitees(voteid string, userids []uint) bool {
+	tx := db.Begin()
+	if err := tx.Where("vote_id = ?", voteid).Delete(&VoteInvitee{}).Error; err != nil {
+		tx.Rollback()
+		glog.Errorf("ReplaceVoteInvitees : %v", err)
+		return false
+	}
+	for _, id := range userids {
+		if err := tx.Create(&VoteInvitee{VoteID: voteid, UserID: id}).Error; err != nil {
+			tx.Rollback()
+			glog.Errorf("ReplaceVoteInvitees : %v", err)
+			return false
+		}
+	}
+	if err := tx.Commit().Error; err != nil {
+		glog.Errorf("ReplaceVoteInvitees : %v", err)
+		return false
+	}
+	return true
+}
+
+// IsVoteInvitee check whether user is invited to the vote
+func IsVoteInvitee(voteid string, userid uint) bool {
+	var count int
+	err := db.Model(&VoteInvitee{}).Where("vote_id = ? AND user_id = ?", voteid, userid).Count(&count).Error
+	if err != nil {
+		glog.Errorf("IsVoteInvitee : %v", err)
+		return false
+	}
+	return count > 0
+}
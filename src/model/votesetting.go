@@ -0,0 +1,136 @@
+package model
+
+import "github.com/glog"
+
+// result visibility of a vote
+const (
+	// VisibilityAlways totals are always visible
+	VisibilityAlways = 1
+	// VisibilityAfterClose totals are visible after the vote closed
+	VisibilityAfterClose = 2
+	// VisibilityAfterVoted totals are visible to users who voted, and to everyone after close
+	VisibilityAfterVoted = 3
+	// VisibilityCreatorOnly totals are visible to creator only
+	VisibilityCreatorOnly = 4
+)
+
+// VoteSetting model, off-chain settings of a vote
+type VoteSetting struct {
+	ID               uint   `json:"id"`
+	VoteID           string `json:"vote_id"`
+	Title            string `json:"title"`
+	CreatorID        uint   `json:"creator_id"`
+	StartTime        int64  `json:"start_time"`
+	EndTime          int64  `json:"end_time"`
+	ResultVisibility int    `json:"result_visibility" des:"1:实时可见 2:结束后可见 3:投票后可见 4:仅创建者可见"`
+	Restricted       bool   `json:"restricted" des:"仅选民名单中的用户可投票"`
+	VoterRoot        string `json:"voter_root" des:"选民名单Merkle根"`
+	InviteOnly       bool   `json:"invite_only" des:"投票需要邀请码"`
+	Survey           bool   `json:"survey" des:"问卷, 答案一次提交"`
+	AllowWriteIn     bool   `json:"allow_write_in" des:"投票人可提议新选项"`
+	WriteInApproval  bool   `json:"write_in_approval" des:"提议的选项须创建者批准"`
+	RunoffMajority   int    `json:"runoff_majority" des:"胜出须超过的得票百分比, 未超过时自动产生决选轮, 0为不决选"`
+	RunoffTopN       int    `json:"runoff_top_n" des:"进入决选的选项数"`
+	RunoffDuration   int64  `json:"runoff_duration" des:"决选轮时长, 秒"`
+	RunoffChecked    bool   `json:"runoff_checked" des:"决选已处理完成"`
+	RunoffClaimedAt  int64  `json:"runoff_claimed_at" des:"开始处理决选的时间, 超时后可重试"`
+	RunoffVoteID     string `json:"runoff_vote_id" des:"下一轮投票ID"`
+	RunoffTxHash     string `json:"runoff_tx_hash" des:"链上关联下一轮的交易哈希"`
+	Round            int    `json:"round" des:"轮次, 首轮为0或1"`
+	PreviousVoteID   string `json:"previous_vote_id" des:"上一轮投票ID"`
+	Budget           int64  `json:"budget" des:"参与式预算的总预算, 0为普通投票"`
+	SignatureTarget  int    `json:"signature_target" des:"请愿的签名目标, 0为普通投票"`
+	Pairwise         bool   `json:"pairwise" des:"两两比较投票"`
+	PairsPerVoter    int    `json:"pairs_per_voter" des:"每个投票人最多比较的选项对数"`
+	TemplateID       uint   `json:"template_id" gorm:"index" des:"创建该投票的模板"`
+	Occurrence       int    `json:"occurrence" des:"模板的第几期"`
+	OpenEnded        bool   `json:"open_ended" des:"请愿不设截止时间"`
+	PetitionDone     bool   `json:"petition_done" des:"请愿达到签名目标"`
+	PetitionTxHash   string `json:"petition_tx_hash" des:"链上写入请愿完成记录的交易哈希"`
+	FinalizeTxHash   string `json:"finalize_tx_hash" des:"链上确认结果的交易哈希"`
+	Eligibility      string `json:"eligibility" gorm:"type:text" des:"投票资格表达式"`
+	EligibilityHash  string `json:"eligibility_hash"`
+	Opened           bool   `json:"opened"`
+	Closed           bool   `json:"closed"`
+	Reminded         bool   `json:"reminded"`
+}
+
+// CreateVoteSetting create vote setting
+func CreateVoteSetting(vs *VoteSetting) (*VoteSetting, bool) {
+	err := db.Create(vs).Error
+	if err != nil {
+		glog.Errorf("CreateVoteSetting : %v", err)
+		return nil, false
+	}
+	return vs, true
+}
+
+// DeleteVoteSetting delete vote setting
+func DeleteVoteSetting(id uint) bool {
+	err := db.Where("id = ?", id).Delete(&VoteSetting{}).Error
+	if err != nil {
+		glog.Errorf("DeleteVoteSetting : %v", err)
+		return false
+	}
+	return true
+}
+
+// GetVoteSetting get vote setting
+func GetVoteSetting(maps interface{}) (*VoteSetting, bool) {
+	var vs VoteSetting
+	err := db.Model(&VoteSetting{}).Where(maps).Find(&vs).Error
+	if err != nil {
+		glog.Errorf("GetVoteSetting : %v", err)
+		return nil, false
+	}
+	return &vs, true
+}
+
+// GetVoteSettings get vote settings
+func GetVoteSettings(query interface{}, args ...interface{}) ([]VoteSetting, bool) {
+	var vss []VoteSetting
+	err := db.Model(&VoteSetting{}).Where(query, args...).Find(&vss).Error
+	if err != nil {
+		glog.Errorf("GetVoteSettings : %v", err)
+		return nil, false
+	}
+	return vss, true
+}
+
+// ClaimRunoffCheck claim checking runoff of the vote for lease seconds,
+// false if it was checked already or another claim has not expired
+func ClaimRunoffCheck(id uint, now, lease int64) bool {
+	ret := db.Model(&VoteSetting{}).Where("id = ? AND runoff_checked = ? AND runoff_claimed_at <= ?", id, false, now-lease).
+		Update("runoff_claimed_at", now)
+	if ret.Error != nil {
+		glog.Errorf("ClaimRunoffCheck : %v", ret.Error)
+		return false
+	}
+	return ret.RowsAffected == 1
+}
+
+// ClaimPetitionDone mark the petition reaching its target and close it now,
+// false if it was marked already
+func ClaimPetitionDone(id uint, txhash string, closetime int64) bool {
+	ret := db.Model(&VoteSetting{}).Where("id = ? AND petition_done = ?", id, false).Updates(map[string]interface{}{
+		"petition_done":    true,
+		"petition_tx_hash": txhash,
+		"closed":           true,
+		"end_time":         closetime,
+	})
+	if ret.Error != nil {
+		glog.Errorf("ClaimPetitionDone : %v", ret.Error)
+		return false
+	}
+	return ret.RowsAffected == 1
+}
+
+// UpdateVoteSetting update vote setting
+func UpdateVoteSetting(id uint, attrs map[string]interface{}) bool {
+	err := db.Model(&VoteSetting{ID: id}).Updates(attrs).Error
+	if err != nil {
+		glog.Errorf("UpdateVoteSetting : %v", err)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,101 @@
+package model
+
+import "github.com/glog"
+
+// VoteTemplate model, a reusable vote which can be started on a recurrence rule
+type VoteTemplate struct {
+	ID               uint   `json:"id"`
+	CreatorID        uint   `json:"creator_id" gorm:"index"`
+	Name             string `json:"name"`
+	Title            string `json:"title" gorm:"type:text"`
+	Description      string `json:"description" gorm:"type:text"`
+	Options          string `json:"options" gorm:"type:text" des:"选项json数组"`
+	SelectType       int    `json:"select_type" des:"1:单选 2:多选"`
+	ResultVisibility int    `json:"result_visibility"`
+	Restricted       bool   `json:"restricted"`
+	InviteOnly       bool   `json:"invite_only"`
+	Invitees         string `json:"invitees" gorm:"type:text" des:"受邀用户ID json数组"`
+	Eligibility      string `json:"eligibility" gorm:"type:text"`
+	Duration         int64  `json:"duration" des:"每期时长, 秒"`
+	Recurrence       string `json:"recurrence" des:"cron表达式, 空为不重复"`
+	NextRun          int64  `json:"next_run" gorm:"index" des:"下次创建投票的时间, 0为不重复"`
+	SourceVoteID     string `json:"source_vote_id" des:"克隆自的投票ID"`
+	Occurrences      int    `json:"occurrences" des:"已编号的期数"`
+	CreatedAt        string `json:"created_at"`
+	UpdatedAt        string `json:"updated_at"`
+}
+
+// CreateVoteTemplate create vote template
+func CreateVoteTemplate(t *VoteTemplate) (*VoteTemplate, bool) {
+	err := db.Create(t).Error
+	if err != nil {
+		glog.Errorf("CreateVoteTemplate : %v", err)
+		return nil, false
+	}
+	return t, true
+}
+
+// GetVoteTemplate get vote template, nil if not found
+func GetVoteTemplate(id uint) (*VoteTemplate, bool) {
+	var ts []VoteTemplate
+	err := db.Model(&VoteTemplate{}).Where("id = ?", id).Find(&ts).Error
+	if err != nil {
+		glog.Errorf("GetVoteTemplate : %v", err)
+		return nil, false
+	}
+	if len(ts) == 0 {
+		return nil, true
+	}
+	return &ts[0], true
+}
+
+// GetVoteTemplates get vote templates
+func GetVoteTemplates(query interface{}, args ...interface{}) ([]VoteTemplate, bool) {
+	var ts []VoteTemplate
+	err := db.Model(&VoteTemplate{}).Where(query, args...).Order("id").Find(&ts).Error
+	if err != nil {
+		glog.Errorf("GetVoteTemplates : %v", err)
+		return nil, false
+	}
+	return ts, true
+}
+
+// ClaimVoteTemplateRun move next run of the template forward, false if another run claimed it
+func ClaimVoteTemplateRun(id uint, run, next int64) bool {
+	ret := db.Model(&VoteTemplate{}).Where("id = ? AND next_run = ?", id, run).Update("next_run", next)
+	if ret.Error != nil {
+		glog.Errorf("ClaimVoteTemplateRun : %v", ret.Error)
+		return false
+	}
+	return ret.RowsAffected == 1
+}
+
+// ClaimTemplateOccurrence number the next vote of the template, false if another run numbered it
+func ClaimTemplateOccurrence(id uint, last, next int) bool {
+	ret := db.Model(&VoteTemplate{}).Where("id = ? AND occurrences = ?", id, last).Update("occurrences", next)
+	if ret.Error != nil {
+		glog.Errorf("ClaimTemplateOccurrence : %v", ret.Error)
+		return false
+	}
+	return ret.RowsAffected == 1
+}
+
+// UpdateVoteTemplate update vote template
+func UpdateVoteTemplate(id uint, maps map[string]interface{}) bool {
+	err := db.Model(&VoteTemplate{}).Where("id = ?", id).Updates(maps).Error
+	if err != nil {
+		glog.Errorf("UpdateVoteTemplate : %v", err)
+		return false
+	}
+	return true
+}
+
+// DeleteVoteTemplate delete vote template, votes started from it are kept
+func DeleteVoteTemplate(id uint) bool {
+	err := db.Where("id = ?", id).Delete(&VoteTemplate{}).Error
+	if err != nil {
+		glog.Errorf("DeleteVoteTemplate : %v", err)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,158 @@
+package model
+
+import "github.com/glog"
+
+// status of webhook delivery
+const (
+	// DeliveryPending waiting for (re)delivery
+	DeliveryPending = 1
+	// DeliverySuccess delivered
+	DeliverySuccess = 2
+	// DeliveryFailed gave up after retries
+	DeliveryFailed = 3
+)
+
+// Webhook model
+type Webhook struct {
+	ID        uint   `json:"id"`
+	VoteID    string `json:"vote_id" des:"为空表示全局"`
+	URL       string `json:"url"`
+	Secret    string `json:"secret,omitempty"`
+	Events    string `json:"events" des:"逗号分隔, 为空表示全部事件"`
+	CreatorID uint   `json:"creator_id"`
+	CreatedAt string `json:"created_at"`
+}
+
+// WebhookDelivery model
+type WebhookDelivery struct {
+	ID           uint   `json:"id"`
+	WebhookID    uint   `json:"webhook_id"`
+	Event        string `json:"event"`
+	VoteID       string `json:"vote_id"`
+	Payload      string `json:"payload" gorm:"type:text"`
+	Status       int    `json:"status" des:"1:待发送 2:成功 3:失败"`
+	Attempts     int    `json:"attempts"`
+	ResponseCode int    `json:"response_code"`
+	Error        string `json:"error"`
+	NextAttempt  int64  `json:"next_attempt" gorm:"index" des:"下次发送时间"`
+	CreatedAt    string `json:"created_at"`
+	UpdatedAt    string `json:"updated_at"`
+}
+
+// CreateWebhook create webhook
+func CreateWebhook(wh *Webhook) (*Webhook, bool) {
+	err := db.Create(wh).Error
+	if err != nil {
+		glog.Errorf("CreateWebhook : %v", err)
+		return nil, false
+	}
+	return wh, true
+}
+
+// GetWebhook get webhook
+func GetWebhook(maps interface{}) (*Webhook, bool) {
+	var wh Webhook
+	err := db.Model(&Webhook{}).Where(maps).Find(&wh).Error
+	if err != nil {
+		glog.Errorf("GetWebhook : %v", err)
+		return nil, false
+	}
+	return &wh, true
+}
+
+// GetVoteWebhooks get webhooks of the vote and global webhooks
+func GetVoteWebhooks(voteid string) ([]Webhook, bool) {
+	var whs []Webhook
+	err := db.Model(&Webhook{}).Where("vote_id = ? OR vote_id = ''", voteid).Find(&whs).Error
+	if err != nil {
+		glog.Errorf("GetVoteWebhooks : %v", err)
+		return nil, false
+	}
+	return whs, true
+}
+
+// GetWebhooks get webhooks
+func GetWebhooks(maps interface{}) ([]Webhook, bool) {
+	var whs []Webhook
+	err := db.Model(&Webhook{}).Where(maps).Find(&whs).Error
+	if err != nil {
+		glog.Errorf("GetWebhooks : %v", err)
+		return nil, false
+	}
+	return whs, true
+}
+
+// DeleteWebhook delete webhook
+func DeleteWebhook(id uint) bool {
+	err := db.Where("id = ?", id).Delete(&Webhook{}).Error
+	if err != nil {
+		glog.Errorf("DeleteWebhook : %v", err)
+		return false
+	}
+	return true
+}
+
+// CreateWebhookDelivery create webhook delivery
+func CreateWebhookDelivery(wd *WebhookDelivery) (*WebhookDelivery, bool) {
+	err := db.Create(wd).Error
+	if err != nil {
+		glog.Errorf("CreateWebhookDelivery : %v", err)
+		return nil, false
+	}
+	return wd, true
+}
+
+// GetWebhookDelivery get webhook delivery
+func GetWebhookDelivery(maps interface{}) (*WebhookDelivery, bool) {
+	var wd WebhookDelivery
+	err := db.Model(&WebhookDelivery{}).Where(maps).Find(&wd).Error
+	if err != nil {
+		glog.Errorf("GetWebhookDelivery : %v", err)
+		return nil, false
+	}
+	return &wd, true
+}
+
+// GetWebhookDeliveries get deliveries, latest first
+func GetWebhookDeliveries(maps interface{}) ([]WebhookDelivery, bool) {
+	var wds []WebhookDelivery
+	err := db.Model(&WebhookDelivery{}).Where(maps).Order("id desc").Find(&wds).Error
+	if err != nil {
+		glog.Errorf("GetWebhookDeliveries : %v", err)
+		return nil, false
+	}
+	return wds, true
+}
+
+// GetDueWebhookDeliveries get pending deliveries whose next attempt is not after now
+func GetDueWebhookDeliveries(now int64) ([]WebhookDelivery, bool) {
+	var wds []WebhookDelivery
+	err := db.Model(&WebhookDelivery{}).Where("status = ? AND next_attempt <= ?", DeliveryPending, now).
+		Order("next_attempt").Find(&wds).Error
+	if err != nil {
+		glog.Errorf("GetDueWebhookDeliveries : %v", err)
+		return nil, false
+	}
+	return wds, true
+}
+
+// ClaimWebhookDelivery move next attempt of a due pending delivery to lease, false if it is not due or claimed
+func ClaimWebhookDelivery(id uint, now, lease int64) bool {
+	ret := db.Model(&WebhookDelivery{}).Where("id = ? AND status = ? AND next_attempt <= ?", id, DeliveryPending, now).
+		Updates(map[string]interface{}{"next_attempt": lease})
+	if ret.Error != nil {
+		glog.Errorf("ClaimWebhookDelivery : %v", ret.Error)
+		return false
+	}
+	return ret.RowsAffected == 1
+}
+
+// UpdateWebhookDelivery update webhook delivery
+func UpdateWebhookDelivery(id uint, attrs map[string]interface{}) bool {
+	err := db.Model(&WebhookDelivery{ID: id}).Updates(attrs).Error
+	if err != nil {
+		glog.Errorf("UpdateWebhookDelivery : %v", err)
+		return false
+	}
+	return true
+}
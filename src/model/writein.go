@@ -0,0 +1,109 @@
+package model
+
+import "github.com/glog"
+
+// status of a write-in
+const (
+	// WriteInPending waiting for approval of the creator
+	WriteInPending = 1
+	// WriteInApproved added to options of the vote
+	WriteInApproved = 2
+	// WriteInRejected rejected by the creator
+	WriteInRejected = 3
+)
+
+// WriteIn model, option proposed by a voter or added by the creator after the vote starts
+type WriteIn struct {
+	ID         uint   `json:"id"`
+	VoteID     string `json:"vote_id" gorm:"index"`
+	ProposerID uint   `json:"proposer_id"`
+	Content    string `json:"content" gorm:"type:text" des:"规范化后的内容"`
+	DedupKey   string `json:"-" gorm:"index" des:"去重用的内容哈希"`
+	Status     int    `json:"status" des:"1:待审核 2:已添加 3:已拒绝"`
+	ReviewerID uint   `json:"reviewer_id"`
+	OptionID   string `json:"option_id"`
+	TxHash     string `json:"tx_hash"`
+	CreatedAt  string `json:"created_at"`
+	UpdatedAt  string `json:"updated_at"`
+}
+
+// CreateWriteIn create write-in
+func CreateWriteIn(wi *WriteIn) (*WriteIn, bool) {
+	err := db.Create(wi).Error
+	if err != nil {
+		glog.Errorf("CreateWriteIn : %v", err)
+		return nil, false
+	}
+	return wi, true
+}
+
+// GetWriteIn get write-in, nil if not found
+func GetWriteIn(id uint) (*WriteIn, bool) {
+	var wis []WriteIn
+	err := db.Model(&WriteIn{}).Where("id = ?", id).Find(&wis).Error
+	if err != nil {
+		glog.Errorf("GetWriteIn : %v", err)
+		return nil, false
+	}
+	if len(wis) == 0 {
+		return nil, true
+	}
+	return &wis[0], true
+}
+
+// GetWriteIns get write-ins of the vote
+func GetWriteIns(voteid string) ([]WriteIn, bool) {
+	var wis []WriteIn
+	err := db.Model(&WriteIn{}).Where("vote_id = ?", voteid).Order("id").Find(&wis).Error
+	if err != nil {
+		glog.Errorf("GetWriteIns : %v", err)
+		return nil, false
+	}
+	return wis, true
+}
+
+// CountWriteIns count write-ins proposed by the user in the vote, rejected ones excluded
+func CountWriteIns(voteid string, proposerid uint) (int, bool) {
+	var count int
+	err := db.Model(&WriteIn{}).
+		Where("vote_id = ? AND proposer_id = ? AND status <> ?", voteid, proposerid, WriteInRejected).
+		Count(&count).Error
+	if err != nil {
+		glog.Errorf("CountWriteIns : %v", err)
+		return 0, false
+	}
+	return count, true
+}
+
+// HasPendingWriteIn check a pending write-in with the same content exists in the vote
+func HasPendingWriteIn(voteid, dedupkey string) (bool, bool) {
+	var count int
+	err := db.Model(&WriteIn{}).
+		Where("vote_id = ? AND dedup_key = ? AND status = ?", voteid, dedupkey, WriteInPending).
+		Count(&count).Error
+	if err != nil {
+		glog.Errorf("HasPendingWriteIn : %v", err)
+		return false, false
+	}
+	return count > 0, true
+}
+
+// ReviewWriteIn change status of a pending write-in, false if it is not pending
+func ReviewWriteIn(id uint, maps map[string]interface{}) bool {
+	ret := db.Model(&WriteIn{}).Where("id = ? AND status = ?", id, WriteInPending).Updates(maps)
+	if ret.Error != nil {
+		glog.Errorf("ReviewWriteIn : %v", ret.Error)
+		return false
+	}
+	return ret.RowsAffected == 1
+}
+
+// UpdateWriteIn update write-in
+func UpdateWriteIn(id uint, maps map[string]interface{}) bool {
+	err := db.Model(&WriteIn{}).Where("id = ?", id).Updates(maps).Error
+	if err != nil {
+		glog.Errorf("UpdateWriteIn : %v", err)
+		return false
+	}
+	return true
+}
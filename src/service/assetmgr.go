@@ -0,0 +1,262 @@
+package service
+
+import (
+	"FunnyVoteGo/src/api/vm"
+	"FunnyVoteGo/src/lib/blobstore"
+	"FunnyVoteGo/src/model"
+	"FunnyVoteGo/src/util"
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"image"
+	"image/png"
+	"net/url"
+	"strconv"
+	"strings"
+
+	// decoders of uploaded images
+	_ "image/gif"
+	_ "image/jpeg"
+
+	"github.com/disintegration/gift"
+	"github.com/glog"
+	"github.com/hyperchain/gosdk/abi"
+	"github.com/hyperchain/gosdk/utils/ecdsa"
+	"github.com/spf13/viper"
+)
+
+// maxImagePixels largest width times height of uploaded images, checked before the image is decoded
+const maxImagePixels = 4096 * 4096
+
+// MaxImageSize largest size in bytes of an uploaded image
+func MaxImageSize() int64 {
+	maxSize := viper.GetInt64("blob.max_image_size")
+	if maxSize <= 0 {
+		maxSize = 5 << 20
+	}
+	return maxSize
+}
+
+// validateOptionAttachments check attachments of options before the vote is created
+func validateOptionAttachments(voteinit *vm.VoteInit) error {
+	n := len(voteinit.Options)
+	if len(voteinit.OptionDescriptions) > n || len(voteinit.OptionLinks) > n || len(voteinit.OptionImages) > n {
+		return &ContentError{Field: "options", Reason: "附件数量多于选项"}
+	}
+	for i, desc := range voteinit.OptionDescriptions {
+		if desc != "" {
+			if err := validateText("option_descriptions["+strconv.Itoa(i)+"]", desc); err != nil {
+				return err
+			}
+		}
+	}
+	for i, link := range voteinit.OptionLinks {
+		if link == "" {
+			continue
+		}
+		u, err := url.Parse(link)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return &ContentError{Field: "option_links[" + strconv.Itoa(i) + "]", Reason: "链接格式错误"}
+		}
+	}
+	maxSize := MaxImageSize()
+	for i, data := range voteinit.OptionImages {
+		if len(data) == 0 {
+			continue
+		}
+		field := "option_image_" + strconv.Itoa(i)
+		if int64(len(data)) > maxSize {
+			return &ContentError{Field: field, Reason: "图片过大"}
+		}
+		cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+		if err != nil {
+			return &ContentError{Field: field, Reason: "不支持的图片格式"}
+		}
+		if cfg.Width <= 0 || cfg.Height <= 0 || int64(cfg.Width)*int64(cfg.Height) > maxImagePixels {
+			return &ContentError{Field: field, Reason: "图片尺寸过大"}
+		}
+	}
+	return nil
+}
+
+// commitOptionAssets store attachments of options and commit their hashes on chain
+func commitOptionAssets(voteid string, optionids []string, voteinit *vm.VoteInit, key *ecdsa.Key) bool {
+	for i, optionid := range optionids {
+		// 链上选项ID为bytes32, 与查询返回的选项ID保持一致
+		oa := model.OptionAsset{
+			VoteID:   voteid,
+			OptionID: chainID(optionid),
+		}
+		if i < len(voteinit.OptionDescriptions) {
+			oa.Description = voteinit.OptionDescriptions[i]
+		}
+		if i < len(voteinit.OptionLinks) {
+			oa.Link = voteinit.OptionLinks[i]
+		}
+		if i < len(voteinit.OptionImages) && len(voteinit.OptionImages[i]) > 0 {
+			imageHash, thumbHash, b := storeOptionImage(voteinit.OptionImages[i])
+			if !b {
+				return false
+			}
+			oa.ImageHash, oa.ThumbHash = imageHash, thumbHash
+		}
+		if oa.Description == "" && oa.Link == "" && oa.ImageHash == "" {
+			continue
+		}
+		oa.Hash = optionAssetHash(&oa)
+		if _, b := model.CreateOptionAsset(&oa); !b {
+			return false
+		}
+		txhash, b := commitOptionAssetHash(optionid, oa.Hash, key)
+		if !b {
+			return false
+		}
+		if !model.UpdateOptionAsset(oa.ID, map[string]interface{}{"tx_hash": txhash}) {
+			return false
+		}
+	}
+	return true
+}
+
+// attachOptionAssets add assets to options, assets not matching hashes on chain are flagged
+func attachOptionAssets(voteid string, options []model.Option, key *ecdsa.Key) []string {
+	oas, b := model.GetOptionAssets(voteid)
+	if !b || len(oas) == 0 {
+		return nil
+	}
+	hashes, b := queryOptionAssetHashes(voteid, key)
+	if !b {
+		hashes = make(map[string]string)
+	}
+	assets := make(map[string]*model.OptionAsset)
+	for i := range oas {
+		oa := &oas[i]
+		if oa.ImageHash != "" {
+			oa.ImageURL = blobstore.URL(oa.ImageHash)
+		}
+		if oa.ThumbHash != "" {
+			oa.ThumbURL = blobstore.URL(oa.ThumbHash)
+		}
+		assets[oa.OptionID] = oa
+	}
+
+	var tampered []string
+	for i := range options {
+		oa, ok := assets[options[i].ID]
+		if !ok {
+			continue
+		}
+		options[i].Asset = oa
+		if optionAssetHash(oa) != oa.Hash || hashes[oa.OptionID] != oa.Hash {
+			glog.Errorf("asset of option %s does not match its hash on chain", oa.OptionID)
+			options[i].Tampered = true
+			tampered = append(tampered, "asset:"+oa.OptionID)
+		}
+	}
+	return tampered
+}
+
+// storeOptionImage store image and its thumbnail, returns their hashes
+func storeOptionImage(data []byte) (string, string, bool) {
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		glog.Error(err)
+		return "", "", false
+	}
+	size := viper.GetInt("blob.thumb_size")
+	if size <= 0 {
+		size = 256
+	}
+	g := gift.New(gift.ResizeToFit(size, size, gift.LanczosResampling))
+	dst := image.NewNRGBA(g.Bounds(img.Bounds()))
+	g.Draw(dst, img)
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, dst); err != nil {
+		glog.Error(err)
+		return "", "", false
+	}
+
+	imageHash, err := blobstore.Put(data)
+	if err != nil {
+		glog.Errorf("store image fail: %v", err)
+		return "", "", false
+	}
+	thumbHash, err := blobstore.Put(buf.Bytes())
+	if err != nil {
+		glog.Errorf("store thumbnail fail: %v", err)
+		return "", "", false
+	}
+	return imageHash, thumbHash, true
+}
+
+// optionAssetHash sha256 of description, link and image of the asset,
+// thumbnail is derived from image so not included
+func optionAssetHash(oa *model.OptionAsset) string {
+	bs, _ := json.Marshal(struct {
+		OptionID    string `json:"option_id"`
+		Description string `json:"description"`
+		Link        string `json:"link"`
+		Image       string `json:"image"`
+	}{oa.OptionID, oa.Description, oa.Link, oa.ImageHash})
+	return contentHash(string(bs))
+}
+
+// chainID id as stored in bytes32 on chain
+func chainID(id string) string {
+	if len(id) > 32 {
+		return id[:32]
+	}
+	return id
+}
+
+func commitOptionAssetHash(optionid, hash string, key *ecdsa.Key) (string, bool) {
+	retu, err := InvokeContract(vm.ReqInvokeCon{
+		ContractAddr: ContractAddress(),
+		ContractCode: GetContractCode(),
+		MethodName:   "setOptionAsset",
+		MethodParams: util.Struct2String(model.OptionAssetHash{
+			ID:   optionid,
+			Hash: "0x" + hash,
+		}),
+	}, key)
+	if err != nil {
+		return "", false
+	}
+	ABI, _ := abi.JSON(strings.NewReader(retu.Abi))
+	p1, msg, err := constructOutput(ABI, retu.Methods, retu.Result)
+	if p1 != 0 {
+		glog.Errorf("set asset of option %s fail: %s", optionid, msg)
+		return "", false
+	}
+	return retu.TxHash, true
+}
+
+// queryOptionAssetHashes query asset hashes of options of the vote, keyed by option id
+func queryOptionAssetHashes(voteid string, key *ecdsa.Key) (map[string]string, bool) {
+	retu, err := InvokeContract(vm.ReqInvokeCon{
+		ContractAddr: ContractAddress(),
+		ContractCode: GetContractCode(),
+		MethodName:   "queryOptionAssets",
+		MethodParams: util.Struct2String(model.Vote{ID: voteid}),
+	}, key)
+	if err != nil {
+		return nil, false
+	}
+	ABI, _ := abi.JSON(strings.NewReader(retu.Abi))
+	var p_ok int32
+	var p_oarray [][32]byte
+	var p_harray [][32]byte
+	res := []interface{}{&p_ok, &p_oarray, &p_harray}
+	if sysErr := ABI.UnpackResult(&res, "queryOptionAssets", retu.Result); sysErr != nil {
+		glog.Info(sysErr)
+		return nil, false
+	}
+	hashes := make(map[string]string)
+	for i := range p_oarray {
+		if p_harray[i] == [32]byte{} {
+			continue
+		}
+		hashes[util.ByteToString(p_oarray[i][:])] = hex.EncodeToString(p_harray[i][:])
+	}
+	return hashes, true
+}
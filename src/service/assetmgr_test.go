@@ -0,0 +1,51 @@
+package service
+
+import (
+	"FunnyVoteGo/src/api/vm"
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/png"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+// gifHeader header of a gif image of the size, enough for image.DecodeConfig
+func gifHeader(w, h uint16) []byte {
+	b := []byte("GIF89a")
+	b = binary.LittleEndian.AppendUint16(b, w)
+	b = binary.LittleEndian.AppendUint16(b, h)
+	return append(b, 0, 0, 0)
+}
+
+func TestValidateOptionImages(t *testing.T) {
+	var small bytes.Buffer
+	if err := png.Encode(&small, image.NewNRGBA(image.Rect(0, 0, 4, 4))); err != nil {
+		t.Fatal(err)
+	}
+	viper.Set("blob.max_image_size", 1024)
+	defer viper.Reset()
+
+	tests := []struct {
+		name    string
+		image   []byte
+		wantErr bool
+	}{
+		{"small png", small.Bytes(), false},
+		{"no image", nil, false},
+		{"not an image", []byte("hello"), true},
+		{"within pixel budget", gifHeader(4096, 4096), false},
+		{"wide", gifHeader(65535, 16), false},
+		{"over pixel budget", gifHeader(4097, 4096), true},
+		{"huge", gifHeader(60000, 60000), true},
+		{"empty dimension", gifHeader(0, 100), true},
+		{"too large", append(small.Bytes(), make([]byte, 1024)...), true},
+	}
+	for _, tt := range tests {
+		voteinit := &vm.VoteInit{Options: []string{"a"}, OptionImages: [][]byte{tt.image}}
+		if err := validateOptionAttachments(voteinit); (err != nil) != tt.wantErr {
+			t.Errorf("%s: validateOptionAttachments = %v, want err %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
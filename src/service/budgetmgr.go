@@ -0,0 +1,391 @@
+package service
+
+import (
+	"FunnyVoteGo/src/api/vm"
+	"FunnyVoteGo/src/constant"
+	"FunnyVoteGo/src/lib/eventbus"
+	"FunnyVoteGo/src/model"
+	"FunnyVoteGo/src/util"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/glog"
+	"github.com/hyperchain/gosdk/abi"
+	"github.com/hyperchain/gosdk/utils/ecdsa"
+)
+
+// methods to allocate budget
+const (
+	// budgetGreedy fund options by votes while they fit in the leftover budget
+	budgetGreedy = "greedy"
+	// budgetEqualShares method of equal shares, budget is split equally among voters
+	budgetEqualShares = "mes"
+)
+
+// budgetBallot ballot of a budgeting vote stored off-chain, only its hash is written on chain
+type budgetBallot struct {
+	VoteID    string   `json:"vote_id"`
+	OptionIDs []string `json:"option_ids"`
+}
+
+// CastBudgetBallot cast a ballot choosing options within the budget of the vote,
+// budget rules are checked here and again by contract
+func CastBudgetBallot(bb *vm.BudgetBallot) (err error) {
+	vs, b := model.GetVoteSetting(map[string]interface{}{"vote_id": bb.VoteID})
+	if !b || vs.Budget == 0 {
+		return fmt.Errorf("该投票不是预算投票")
+	}
+	// 合约不检查投票时间
+	if vs.Closed || voteStatus(vs.StartTime, vs.EndTime) != 2 {
+		return &EligibilityError{Reason: "投票未在进行中"}
+	}
+	release, err := admitBallot(bb.VoteID, bb.UserID, bb.InviteCode)
+	if err != nil {
+		return err
+	}
+	var txhash string
+	defer func() {
+		// 仅在选票未上链时释放邀请码
+		if err != nil && txhash == "" {
+			release()
+		}
+	}()
+
+	key, err := InitKey()
+	if err != nil {
+		return err
+	}
+	budget, costs, b := queryBudget(bb.VoteID, key)
+	if !b {
+		return errBallotFail
+	}
+	ballot, err := normalizeBudgetBallot(bb, budget, costs)
+	if err != nil {
+		return err
+	}
+	bs, _ := json.Marshal(ballot)
+	hash := contentHash(string(bs))
+	if !model.SaveContent(hash, string(bs)) {
+		return errBallotFail
+	}
+	ballotid := chainID(util.StringUUID())
+	txhash, err = invokeMethod("castBudgetBallot", map[string]interface{}{
+		"id":          ballotid,
+		"vote_id":     bb.VoteID,
+		"user_id":     strconv.Itoa(int(bb.UserID)),
+		"ballot_hash": "0x" + hash,
+		"create_time": util.GetNowTimeString(),
+		"option_ids":  ballot.OptionIDs,
+	})
+	if err != nil {
+		glog.Errorf("cast budget ballot of user %d in vote %s fail: %v", bb.UserID, bb.VoteID, err)
+		return errBallotFail
+	}
+
+	if _, b := model.CreateHashRecord(&model.HashRecord{
+		VoteID:        bb.VoteID,
+		UserID:        bb.UserID,
+		OptionContent: "0x" + hash,
+		BallotID:      ballotid,
+		TxHash:        txhash,
+	}); !b {
+		// 选票已上链, 不再返回失败
+		glog.Errorf("create hash record of budget ballot %s in vote %s fail", ballotid, bb.VoteID)
+	}
+	publishLocalEvent(eventbus.Event{
+		Name:   constant.EventBallotCast,
+		VoteID: bb.VoteID,
+		TxHash: txhash,
+		Data: map[string]interface{}{
+			"vote_id":    bb.VoteID,
+			"option_ids": strings.Join(ballot.OptionIDs, ","),
+			"user_id":    strconv.Itoa(int(bb.UserID)),
+		},
+	})
+	return nil
+}
+
+// GetBudgetTally allocate budget of the vote by ballots on chain, ballots not matching their hashes are skipped
+func GetBudgetTally(req *vm.BudgetTallyReq, userid uint) (*vm.BudgetTally, error) {
+	method := req.Method
+	if method == "" {
+		method = budgetGreedy
+	}
+	if method != budgetGreedy && method != budgetEqualShares {
+		return nil, &ContentError{Field: "method", Reason: "不支持的分配方法"}
+	}
+	vs, b := model.GetVoteSetting(map[string]interface{}{"vote_id": req.VoteID})
+	if !b || vs.Budget == 0 {
+		return nil, fmt.Errorf("该投票不是预算投票")
+	}
+	if !resultVisible(vs, userid) {
+		return nil, &EligibilityError{Reason: "结果暂不可见"}
+	}
+	key, err := InitKey()
+	if err != nil {
+		return nil, err
+	}
+	budget, costs, b := queryBudget(req.VoteID, key)
+	if !b {
+		return nil, errBallotFail
+	}
+	options, b := queryVoteOptions(req.VoteID, key)
+	if !b {
+		return nil, errBallotFail
+	}
+	records, b := GetVoteRecord(req.VoteID)
+	if !b {
+		return nil, errBallotFail
+	}
+
+	tally := vm.BudgetTally{VoteID: req.VoteID, Method: method, Budget: budget}
+	var ballots [][]string
+	for _, record := range records {
+		var ballot budgetBallot
+		if record.Tampered || json.Unmarshal([]byte(record.OptionContent), &ballot) != nil {
+			glog.Errorf("budget ballot of %s in vote %s does not match its hash", record.UserID, req.VoteID)
+			tally.Tampered = append(tally.Tampered, record.UserID)
+			continue
+		}
+		ballots = append(ballots, ballot.OptionIDs)
+	}
+	tally.Ballots = len(ballots)
+
+	var projects []vm.BudgetProject
+	for _, option := range options {
+		projects = append(projects, vm.BudgetProject{
+			OptionID: option.ID,
+			Content:  option.Content,
+			Cost:     costs[option.ID],
+			Votes:    option.Total,
+		})
+	}
+	var funded map[string]bool
+	if method == budgetEqualShares {
+		funded = equalSharesAllocation(projects, ballots, budget)
+	} else {
+		funded = greedyAllocation(projects, budget)
+	}
+	sortProjects(projects)
+	for _, p := range projects {
+		if funded[p.OptionID] {
+			tally.Funded = append(tally.Funded, p)
+			tally.Spent += p.Cost
+		} else {
+			tally.Unfunded = append(tally.Unfunded, p)
+		}
+	}
+	tally.Leftover = budget - tally.Spent
+	return &tally, nil
+}
+
+// greedyAllocation fund options in order of votes while they fit in the leftover budget
+func greedyAllocation(projects []vm.BudgetProject, budget int64) map[string]bool {
+	sorted := make([]vm.BudgetProject, len(projects))
+	copy(sorted, projects)
+	sortProjects(sorted)
+	funded := make(map[string]bool)
+	left := budget
+	for _, p := range sorted {
+		if p.Votes > 0 && p.Cost <= left {
+			funded[p.OptionID] = true
+			left -= p.Cost
+		}
+	}
+	return funded
+}
+
+// equalSharesAllocation method of equal shares with approval ballots, each voter gets an equal share
+// of the budget, an option is funded if its supporters can pay for it, the option costing its
+// supporters least per head is funded first
+func equalSharesAllocation(projects []vm.BudgetProject, ballots [][]string, budget int64) map[string]bool {
+	funded := make(map[string]bool)
+	if len(ballots) == 0 {
+		return funded
+	}
+	share := float64(budget) / float64(len(ballots))
+	balances := make([]float64, len(ballots))
+	for i := range balances {
+		balances[i] = share
+	}
+	supporters := make(map[string][]int)
+	for i, ballot := range ballots {
+		for _, id := range ballot {
+			supporters[id] = append(supporters[id], i)
+		}
+	}
+
+	for {
+		var best *vm.BudgetProject
+		bestRho := 0.0
+		for i := range projects {
+			p := &projects[i]
+			if funded[p.OptionID] {
+				continue
+			}
+			rho, ok := equalSharesPayment(float64(p.Cost), supporters[p.OptionID], balances)
+			if !ok {
+				continue
+			}
+			if best == nil || rho < bestRho || (rho == bestRho && projectBefore(*p, *best)) {
+				best, bestRho = p, rho
+			}
+		}
+		if best == nil {
+			return funded
+		}
+		funded[best.OptionID] = true
+		for _, i := range supporters[best.OptionID] {
+			if balances[i] < bestRho {
+				balances[i] = 0
+			} else {
+				balances[i] -= bestRho
+			}
+		}
+	}
+}
+
+// equalSharesPayment least payment per supporter to fund the cost, supporters with less
+// balance pay all they have, false if supporters can not afford it
+func equalSharesPayment(cost float64, supporters []int, balances []float64) (float64, bool) {
+	if len(supporters) == 0 || cost <= 0 {
+		return 0, false
+	}
+	bs := make([]float64, 0, len(supporters))
+	var total float64
+	for _, i := range supporters {
+		bs = append(bs, balances[i])
+		total += balances[i]
+	}
+	// 允许浮点误差
+	if total+1e-9 < cost {
+		return 0, false
+	}
+	sort.Float64s(bs)
+	left := cost
+	for k, b := range bs {
+		rho := left / float64(len(bs)-k)
+		if b >= rho {
+			return rho, true
+		}
+		left -= b
+	}
+	return bs[len(bs)-1], true
+}
+
+// sortProjects order by votes desc, then cost asc, then option id
+func sortProjects(projects []vm.BudgetProject) {
+	sort.SliceStable(projects, func(i, j int) bool { return projectBefore(projects[i], projects[j]) })
+}
+
+func projectBefore(a, b vm.BudgetProject) bool {
+	if a.Votes != b.Votes {
+		return a.Votes > b.Votes
+	}
+	if a.Cost != b.Cost {
+		return a.Cost < b.Cost
+	}
+	return a.OptionID < b.OptionID
+}
+
+// normalizeBudgetBallot check options of the ballot and their total cost, options are sorted
+// so the same choice always has the same hash
+func normalizeBudgetBallot(bb *vm.BudgetBallot, budget int64, costs map[string]int64) (*budgetBallot, error) {
+	if len(bb.OptionIDs) == 0 {
+		return nil, &ContentError{Field: "option_ids", Reason: "须至少选择一个选项"}
+	}
+	ballot := budgetBallot{VoteID: bb.VoteID}
+	seen := make(map[string]bool)
+	var total int64
+	for _, id := range bb.OptionIDs {
+		id = chainID(id)
+		cost, ok := costs[id]
+		if !ok || cost <= 0 || seen[id] {
+			return nil, &ContentError{Field: "option_ids", Reason: "选项错误"}
+		}
+		seen[id] = true
+		total += cost
+		ballot.OptionIDs = append(ballot.OptionIDs, id)
+	}
+	if total > budget {
+		return nil, &ContentError{Field: "option_ids", Reason: fmt.Sprintf("所选选项总成本%d超出预算%d", total, budget)}
+	}
+	sort.Strings(ballot.OptionIDs)
+	return &ballot, nil
+}
+
+// validateBudget every option of a budgeting vote has a positive cost within the budget
+func validateBudget(voteinit *vm.VoteInit) error {
+	if voteinit.Budget == 0 {
+		if len(voteinit.OptionCosts) > 0 {
+			return &ContentError{Field: "option_costs", Reason: "未设置总预算"}
+		}
+		return nil
+	}
+	switch {
+	case voteinit.Budget < 0:
+		return &ContentError{Field: "budget", Reason: "总预算须大于0"}
+	case len(voteinit.Questions) > 0 || voteinit.RunoffMajority != 0:
+		return &ContentError{Field: "budget", Reason: "预算投票不支持问卷和决选"}
+	case len(voteinit.OptionCosts) != len(voteinit.Options):
+		return &ContentError{Field: "option_costs", Reason: "选项成本与选项数量不一致"}
+	}
+	for i, cost := range voteinit.OptionCosts {
+		if cost <= 0 || cost > voteinit.Budget {
+			return &ContentError{Field: "option_costs[" + strconv.Itoa(i) + "]", Reason: "成本须大于0且不超过总预算"}
+		}
+	}
+	return nil
+}
+
+// commitBudget set budget and costs of options of the vote on chain
+func commitBudget(voteid string, budget int64, optionids []string, costs []int64) bool {
+	var cs []string
+	for _, cost := range costs {
+		cs = append(cs, strconv.FormatInt(cost, 10))
+	}
+	if _, err := invokeMethod("setBudget", map[string]interface{}{
+		"vote_id":    voteid,
+		"budget":     strconv.FormatInt(budget, 10),
+		"option_ids": optionids,
+		"costs":      cs,
+	}); err != nil {
+		glog.Errorf("set budget of vote %s fail: %v", voteid, err)
+		return false
+	}
+	return true
+}
+
+// queryBudget query budget of the vote and costs of its options keyed by option id
+func queryBudget(voteid string, key *ecdsa.Key) (int64, map[string]int64, bool) {
+	retu, err := InvokeContract(vm.ReqInvokeCon{
+		ContractAddr: ContractAddress(),
+		ContractCode: GetContractCode(),
+		MethodName:   "queryBudget",
+		MethodParams: util.Struct2String(model.Vote{ID: voteid}),
+	}, key)
+	if err != nil {
+		return 0, nil, false
+	}
+	ABI, _ := abi.JSON(strings.NewReader(retu.Abi))
+	var p_ok int32
+	var p_budget int64
+	var p_oarray [][32]byte
+	var p_carray []int64
+	res := []interface{}{&p_ok, &p_budget, &p_oarray, &p_carray}
+	if sysErr := ABI.UnpackResult(&res, "queryBudget", retu.Result); sysErr != nil {
+		glog.Info(sysErr)
+		return 0, nil, false
+	}
+	if p_ok != 0 {
+		return 0, nil, false
+	}
+	costs := make(map[string]int64)
+	for i := range p_oarray {
+		costs[util.ByteToString(p_oarray[i][:])] = p_carray[i]
+	}
+	return p_budget, costs, true
+}
@@ -0,0 +1,152 @@
+package service
+
+import (
+	"FunnyVoteGo/src/api/vm"
+	"math"
+	"reflect"
+	"testing"
+)
+
+// budgetProjects projects with votes counted from the ballots
+func budgetProjects(costs map[string]int64, ballots [][]string) []vm.BudgetProject {
+	votes := make(map[string]uint)
+	for _, ballot := range ballots {
+		for _, id := range ballot {
+			votes[id]++
+		}
+	}
+	var projects []vm.BudgetProject
+	for id, cost := range costs {
+		projects = append(projects, vm.BudgetProject{OptionID: id, Cost: cost, Votes: votes[id]})
+	}
+	sortProjects(projects)
+	return projects
+}
+
+func fundedIDs(funded map[string]bool) map[string]bool {
+	got := make(map[string]bool)
+	for id, ok := range funded {
+		if ok {
+			got[id] = true
+		}
+	}
+	return got
+}
+
+func TestBudgetAllocation(t *testing.T) {
+	tests := []struct {
+		name       string
+		costs      map[string]int64
+		ballots    [][]string
+		budget     int64
+		wantGreedy []string
+		wantMES    []string
+	}{
+		{
+			name:       "minority gets its share",
+			costs:      map[string]int64{"A": 50, "B": 50, "C": 25},
+			ballots:    [][]string{{"A", "B"}, {"A", "B"}, {"A", "B"}, {"C"}},
+			budget:     100,
+			wantGreedy: []string{"A", "B"},
+			wantMES:    []string{"A", "C"},
+		},
+		{
+			name:       "cheapest per head first",
+			costs:      map[string]int64{"A": 200, "B": 100, "C": 150},
+			ballots:    [][]string{{"A", "C"}, {"A", "C"}, {"B", "C"}},
+			budget:     300,
+			wantGreedy: []string{"C", "B"},
+			wantMES:    []string{"C"},
+		},
+		{
+			name:       "all affordable",
+			costs:      map[string]int64{"A": 10, "B": 20},
+			ballots:    [][]string{{"A"}, {"B"}},
+			budget:     100,
+			wantGreedy: []string{"A", "B"},
+			wantMES:    []string{"A", "B"},
+		},
+		{
+			name:       "option without votes",
+			costs:      map[string]int64{"A": 10, "B": 10},
+			ballots:    [][]string{{"A"}},
+			budget:     100,
+			wantGreedy: []string{"A"},
+			wantMES:    []string{"A"},
+		},
+		{
+			name:    "no ballots",
+			costs:   map[string]int64{"A": 10},
+			budget:  100,
+			wantMES: nil,
+		},
+	}
+	for _, tt := range tests {
+		projects := budgetProjects(tt.costs, tt.ballots)
+		want := func(ids []string) map[string]bool {
+			m := make(map[string]bool)
+			for _, id := range ids {
+				m[id] = true
+			}
+			return m
+		}
+		if got := fundedIDs(greedyAllocation(projects, tt.budget)); !reflect.DeepEqual(got, want(tt.wantGreedy)) {
+			t.Errorf("%s: greedy funded %v, want %v", tt.name, got, tt.wantGreedy)
+		}
+		if got := fundedIDs(equalSharesAllocation(projects, tt.ballots, tt.budget)); !reflect.DeepEqual(got, want(tt.wantMES)) {
+			t.Errorf("%s: equal shares funded %v, want %v", tt.name, got, tt.wantMES)
+		}
+	}
+}
+
+func TestEqualSharesPayment(t *testing.T) {
+	tests := []struct {
+		name       string
+		cost       float64
+		supporters []int
+		balances   []float64
+		want       float64
+		wantOK     bool
+	}{
+		{"equal split", 30, []int{0, 1, 2}, []float64{20, 20, 20}, 10, true},
+		{"poor supporter pays all", 30, []int{0, 1, 2}, []float64{5, 20, 20}, 12.5, true},
+		{"exact balance", 45, []int{0, 1, 2}, []float64{5, 20, 20}, 20, true},
+		{"unaffordable", 100, []int{0, 1, 2}, []float64{5, 20, 20}, 0, false},
+		{"only some supporters", 10, []int{2}, []float64{0, 0, 20}, 10, true},
+		{"no supporters", 10, nil, []float64{20}, 0, false},
+		{"free option", 0, []int{0}, []float64{20}, 0, false},
+	}
+	for _, tt := range tests {
+		got, ok := equalSharesPayment(tt.cost, tt.supporters, tt.balances)
+		if ok != tt.wantOK || math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("%s: got %v %v, want %v %v", tt.name, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+func TestNormalizeBudgetBallot(t *testing.T) {
+	costs := map[string]int64{"A": 40, "B": 50, "C": 20}
+	tests := []struct {
+		name    string
+		ids     []string
+		want    []string
+		wantErr bool
+	}{
+		{"sorted", []string{"C", "A"}, []string{"A", "C"}, false},
+		{"within budget", []string{"A", "B"}, []string{"A", "B"}, false},
+		{"over budget", []string{"A", "B", "C"}, nil, true},
+		{"duplicate", []string{"A", "A"}, nil, true},
+		{"unknown option", []string{"D"}, nil, true},
+		{"empty", nil, nil, true},
+	}
+	for _, tt := range tests {
+		ballot, err := normalizeBudgetBallot(&vm.BudgetBallot{VoteID: "vote1", OptionIDs: tt.ids}, 100, costs)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: err %v, want err %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if err == nil && !reflect.DeepEqual(ballot.OptionIDs, tt.want) {
+			t.Errorf("%s: options %v, want %v", tt.name, ballot.OptionIDs, tt.want)
+		}
+	}
+}
@@ -0,0 +1,403 @@
+package service
+
+import (
+	"FunnyVoteGo/src/api/vm"
+	"FunnyVoteGo/src/model"
+	"FunnyVoteGo/src/util"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/glog"
+	"github.com/hyperchain/gosdk/abi"
+	"github.com/hyperchain/gosdk/common"
+	"github.com/hyperchain/gosdk/rpc"
+	"github.com/hyperchain/gosdk/utils/ecdsa"
+)
+
+// certificateVersion version of the certificate format
+const certificateVersion = 1
+
+// GetResultCertificate build the result certificate of a finalized vote and sign it by the service key
+func GetResultCertificate(voteid string, userid uint) (*vm.SignedCertificate, error) {
+	vs, b := model.GetVoteSetting(map[string]interface{}{"vote_id": voteid})
+	if !b || vs.VoteID == "" {
+		return nil, fmt.Errorf("投票不存在")
+	}
+	if !resultVisible(vs, userid) {
+		return nil, &EligibilityError{Reason: "结果暂不可见"}
+	}
+	if vs.FinalizeTxHash == "" {
+		return nil, &EligibilityError{Reason: "投票结果未确认"}
+	}
+	vote, b := GetVoteStatus(&vm.GetVoteStatus{VoteID: voteid, UserID: userid})
+	if !b {
+		return nil, fmt.Errorf("查询投票失败")
+	}
+	key, err := InitKey()
+	if err != nil {
+		return nil, err
+	}
+	hpc := rpc.NewRPCWithPath("./conf/chain_SDK/conf")
+	if hpc == nil {
+		return nil, fmt.Errorf("连接区块链失败")
+	}
+	tx, stdErr := hpc.GetTransactionByHash(vs.FinalizeTxHash)
+	if stdErr != nil {
+		glog.Errorf("query finalize transaction %s fail: %v", vs.FinalizeTxHash, stdErr)
+		return nil, fmt.Errorf("查询确认交易失败")
+	}
+	finalized, err := queryFinalizeTx(hpc, tx)
+	if err != nil {
+		glog.Errorf("decode finalize transaction %s fail: %v", vs.FinalizeTxHash, err)
+		return nil, fmt.Errorf("查询确认交易失败")
+	}
+	ballots, root, b := ballotRoot(voteid, key)
+	if !b {
+		return nil, fmt.Errorf("查询投票记录失败")
+	}
+
+	cert := vm.ResultCertificate{
+		Version:         certificateVersion,
+		VoteID:          vote.ID,
+		Title:           vote.Title,
+		Description:     vote.Description,
+		SelectType:      vote.SelectType,
+		StartTime:       vote.StartTime,
+		EndTime:         vote.EndTime,
+		CreatorID:       vote.CreatorID,
+		Options:         certificateOptions(vote.Options),
+		ContractAddress: ContractAddress(),
+		BlockHeight:     tx.BlockNumber,
+		BlockHash:       tx.BlockHash,
+		FinalizeTxHash:  vs.FinalizeTxHash,
+		Ballots:         ballots,
+		BallotRoot:      root,
+		IssuedAt:        util.GetNowTimeString(),
+	}
+	if vote.SelectType == model.SelectSurvey {
+		for _, q := range vote.Questions {
+			cert.Questions = append(cert.Questions, vm.CertificateQuestion{
+				ID:         q.ID,
+				Title:      q.Title,
+				SelectType: q.SelectType,
+				Options:    certificateOptions(q.Options),
+				Outcome:    questionOutcome(&q),
+			})
+		}
+		cert.Outcome = fmt.Sprintf("问卷共%d题, 结果见各问题", len(cert.Questions))
+	} else {
+		if cert.Outcome, err = voteOutcome(vote, vs, userid); err != nil {
+			return nil, err
+		}
+		if vs.Budget == 0 && vs.SignatureTarget == 0 && !vs.Pairwise {
+			if winner, ok := pluralityWinner(vote.Options); ok {
+				cert.WinnerID = winner.ID
+			}
+		}
+	}
+
+	// 确认后链上票数不再变化, 证书票数须与确认时计入委托票权的结果一致
+	if reason := finalizeMismatch(&cert, finalized); reason != "" {
+		glog.Errorf("certificate of vote %s differs from finalization: %s", voteid, reason)
+		return nil, fmt.Errorf("票数与确认结果不一致")
+	}
+
+	digest, err := certificateDigest(&cert)
+	if err != nil {
+		return nil, err
+	}
+	signed := vm.SignedCertificate{Certificate: cert, Digest: hex.EncodeToString(digest)}
+	if signed.Signature, signed.Signer, err = signDigest(digest); err != nil {
+		glog.Errorf("sign certificate of vote %s fail: %v", voteid, err)
+		return nil, err
+	}
+	return &signed, nil
+}
+
+// VerifyCertificate check digest and signature of a certificate, and that it is signed by signer,
+// the address of the service key by default. Online, the finalize transaction, finalization, tallies
+// and ballot root are also checked against chain
+func VerifyCertificate(data []byte, signer string, online bool) (*vm.CertificateCheck, error) {
+	var signed vm.SignedCertificate
+	if err := json.Unmarshal(data, &signed); err != nil || signed.Certificate.VoteID == "" {
+		return nil, &ContentError{Field: "certificate", Reason: "证书格式错误"}
+	}
+	cert := &signed.Certificate
+	check := vm.CertificateCheck{Online: online}
+	add := func(name string, ok bool, detail string) {
+		check.Checks = append(check.Checks, vm.CertificateItem{Name: name, OK: ok, Detail: detail})
+	}
+
+	digest, err := certificateDigest(cert)
+	if err != nil {
+		return nil, err
+	}
+	add("摘要", hex.EncodeToString(digest) == strings.ToLower(signed.Digest), "")
+	recovered, err := recoverSigner(digest, signed.Signature)
+	if err != nil {
+		add("签名", false, err.Error())
+	} else {
+		check.Signer = recovered
+		add("签名", strings.EqualFold(recovered, signed.Signer), "签名者 "+recovered)
+	}
+	// 证书自带的signer不可信, 须与预期的服务密钥地址比较
+	if signer == "" {
+		if key, err := InitKey(); err == nil {
+			signer = key.GetAddress()
+		}
+	}
+	if signer == "" {
+		add("签名者", false, "未指定签名者, 且无法读取服务密钥")
+	} else {
+		add("签名者", check.Signer != "" && strings.EqualFold(check.Signer, signer), "应为 "+signer)
+	}
+	if online {
+		verifyCertificateOnChain(cert, add)
+	}
+
+	check.Valid = true
+	for _, item := range check.Checks {
+		check.Valid = check.Valid && item.OK
+	}
+	return &check, nil
+}
+
+// verifyCertificateOnChain compare the certificate with the finalize transaction and the contract
+func verifyCertificateOnChain(cert *vm.ResultCertificate, add func(name string, ok bool, detail string)) {
+	if !strings.EqualFold(cert.ContractAddress, ContractAddress()) {
+		add("合约地址", false, "服务合约为 "+ContractAddress())
+		return
+	}
+	hpc := rpc.NewRPCWithPath("./conf/chain_SDK/conf")
+	if hpc == nil {
+		add("确认交易", false, "连接区块链失败")
+		return
+	}
+	tx, stdErr := hpc.GetTransactionByHash(cert.FinalizeTxHash)
+	switch {
+	case stdErr != nil:
+		add("确认交易", false, stdErr.String())
+	case tx.Invalid:
+		add("确认交易", false, "交易无效: "+tx.InvalidMsg)
+	case !strings.EqualFold(tx.To, cert.ContractAddress):
+		add("确认交易", false, "交易不是调用服务合约")
+	case tx.BlockNumber != cert.BlockHeight || tx.BlockHash != cert.BlockHash:
+		add("确认交易", false, fmt.Sprintf("交易在区块%d %s", tx.BlockNumber, tx.BlockHash))
+	default:
+		finalized, err := queryFinalizeTx(hpc, tx)
+		if err != nil {
+			add("确认交易", false, err.Error())
+		} else if reason := finalizeMismatch(cert, finalized); reason != "" {
+			add("确认交易", false, reason)
+		} else {
+			add("确认交易", true, "")
+		}
+	}
+
+	key, err := InitKey()
+	if err != nil {
+		add("链上结果", false, "无法查询合约: "+err.Error())
+		return
+	}
+	finalized, b := queryFinalized(cert.VoteID, key)
+	add("链上确认", b && finalized, "")
+
+	var options []model.Option
+	if len(cert.Questions) > 0 {
+		questions, ok := querySurveyQuestions(cert.VoteID, key)
+		for _, q := range questions {
+			options = append(options, q.Options...)
+		}
+		b = ok
+	} else {
+		options, b = queryVoteOptions(cert.VoteID, key)
+	}
+	if !b {
+		add("票数", false, "查询选项失败")
+	} else {
+		add("票数", sameTallies(cert, options), "")
+	}
+
+	ballots, root, b := ballotRoot(cert.VoteID, key)
+	if !b {
+		add("选票Merkle根", false, "查询投票记录失败")
+	} else {
+		add("选票Merkle根", ballots == cert.Ballots && root == cert.BallotRoot,
+			fmt.Sprintf("链上%d张选票, 根为%s", ballots, root))
+	}
+}
+
+// finalizeTx vote of a finalizeVote transaction and the winner in its VoteFinalized log
+type finalizeTx struct {
+	vote   [32]byte
+	winner [32]byte
+	total  int32
+}
+
+// queryFinalizeTx receipt of the transaction and decode it as finalizeVote
+func queryFinalizeTx(hpc *rpc.RPC, tx *rpc.TransactionInfo) (*finalizeTx, error) {
+	ABI, err := GetContractABI()
+	if err != nil {
+		return nil, err
+	}
+	receipt, stdErr := hpc.GetTxReceipt(tx.Hash)
+	if stdErr != nil {
+		return nil, fmt.Errorf("查询交易回执失败: %s", stdErr.String())
+	}
+	return decodeFinalizeTx(ABI, tx.Payload, receipt.Log)
+}
+
+// decodeFinalizeTx decode payload of a transaction which must call finalizeVote, and the VoteFinalized log of it
+func decodeFinalizeTx(ABI *abi.ABI, payload string, logs []rpc.TxLog) (*finalizeTx, error) {
+	data := common.FromHex(payload)
+	if len(data) < 4 {
+		return nil, fmt.Errorf("交易不是finalizeVote")
+	}
+	method, err := ABI.MethodById(data)
+	if err != nil || method.Name != "finalizeVote" {
+		return nil, fmt.Errorf("交易不是finalizeVote")
+	}
+	values, err := method.Inputs.UnpackValues(data[4:])
+	if err != nil || len(values) != 1 {
+		return nil, fmt.Errorf("无法解析finalizeVote参数")
+	}
+	var f finalizeTx
+	f.vote, _ = values[0].([32]byte)
+	event, ok := ABI.Events["VoteFinalized"]
+	if !ok {
+		return nil, fmt.Errorf("合约没有VoteFinalized事件")
+	}
+	for _, l := range logs {
+		if len(l.Topics) == 0 || !strings.EqualFold(event.Id().Hex(), l.Topics[0]) {
+			continue
+		}
+		values, err := event.Inputs.NonIndexed().UnpackValues(common.FromHex(l.Data))
+		if err != nil {
+			continue
+		}
+		for i, arg := range event.Inputs.NonIndexed() {
+			switch arg.Name {
+			case "winner_id":
+				f.winner, _ = values[i].([32]byte)
+			case "winner_total":
+				f.total, _ = values[i].(int32)
+			}
+		}
+		return &f, nil
+	}
+	return nil, fmt.Errorf("交易没有VoteFinalized日志")
+}
+
+// finalizeMismatch why the certificate is not the result finalized by the transaction, empty if it is.
+// Tallies are compared by the winner total, questions of survey are not finalized by option
+func finalizeMismatch(cert *vm.ResultCertificate, f *finalizeTx) string {
+	if util.ByteToString(f.vote[:]) != chainID(cert.VoteID) {
+		return "交易确认的是投票 " + util.Byte32ToDisplay(f.vote)
+	}
+	if len(cert.Questions) > 0 {
+		return ""
+	}
+	top := int64(-1)
+	for _, o := range cert.Options {
+		if int64(o.Total) > top {
+			top = int64(o.Total)
+		}
+	}
+	if top != int64(f.total) {
+		return fmt.Sprintf("确认时最高票数为%d, 证书为%d", f.total, top)
+	}
+	if cert.WinnerID != "" && cert.WinnerID != util.ByteToString(f.winner[:]) {
+		return "确认时得票最多的选项为 " + util.Byte32ToDisplay(f.winner)
+	}
+	return ""
+}
+
+// sameTallies every option on chain has the total of the certificate
+func sameTallies(cert *vm.ResultCertificate, options []model.Option) bool {
+	totals := make(map[string]uint)
+	for _, o := range cert.Options {
+		totals[o.ID] = o.Total
+	}
+	for _, q := range cert.Questions {
+		for _, o := range q.Options {
+			totals[o.ID] = o.Total
+		}
+	}
+	if len(totals) != len(options) {
+		return false
+	}
+	for _, o := range options {
+		if total, ok := totals[o.ID]; !ok || total != o.Total {
+			return false
+		}
+	}
+	return true
+}
+
+// certificateDigest sha256 of the certificate as compact json without html escaping
+func certificateDigest(cert *vm.ResultCertificate) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(cert); err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(bytes.TrimSuffix(buf.Bytes(), []byte("\n")))
+	return sum[:], nil
+}
+
+func certificateOptions(options []model.Option) []vm.CertificateOption {
+	var cos []vm.CertificateOption
+	for _, o := range options {
+		cos = append(cos, vm.CertificateOption{ID: o.ID, Content: o.Content, Total: o.Total, Score: o.Score, Cost: o.Cost})
+	}
+	return cos
+}
+
+// ballotRoot number of ballots of the vote on chain and their merkle root,
+// leaves are sha256 of user:option_id:create_time
+func ballotRoot(voteid string, key *ecdsa.Key) (int, string, bool) {
+	var leaves [][]byte
+	for offset := 0; ; offset += exportPageSize {
+		page, total, b := queryVoteRecordPage(voteid, offset, exportPageSize, key)
+		if !b {
+			return 0, "", false
+		}
+		for _, ballot := range page {
+			leaves = append(leaves, util.MerkleLeaf(ballot.user+":"+ballot.optionID+":"+ballot.created))
+		}
+		if len(page) == 0 || offset+len(page) >= total {
+			break
+		}
+	}
+	return len(leaves), hex.EncodeToString(util.MerkleRoot(leaves)), true
+}
+
+// queryFinalized query whether result of the vote is finalized on chain
+func queryFinalized(voteid string, key *ecdsa.Key) (bool, bool) {
+	retu, err := InvokeContract(vm.ReqInvokeCon{
+		ContractAddr: ContractAddress(),
+		ContractCode: GetContractCode(),
+		MethodName:   "queryFinalized",
+		MethodParams: util.Struct2String(model.Vote{ID: voteid}),
+	}, key)
+	if err != nil {
+		return false, false
+	}
+	ABI, _ := abi.JSON(strings.NewReader(retu.Abi))
+	var p_ok int32
+	var p_finalized bool
+	res := []interface{}{&p_ok, &p_finalized}
+	if sysErr := ABI.UnpackResult(&res, "queryFinalized", retu.Result); sysErr != nil {
+		glog.Info(sysErr)
+		return false, false
+	}
+	if p_ok != 0 {
+		return false, false
+	}
+	return p_finalized, true
+}
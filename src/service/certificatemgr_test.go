@@ -0,0 +1,79 @@
+package service
+
+import (
+	"FunnyVoteGo/src/api/vm"
+	"testing"
+
+	"github.com/hyperchain/gosdk/common"
+	"github.com/hyperchain/gosdk/rpc"
+)
+
+func TestDecodeFinalizeTx(t *testing.T) {
+	ABI := testABI(t)
+	finalize, err := ABI.Pack("finalizeVote", b32("vote1"))
+	if err != nil {
+		t.Fatalf("pack finalizeVote: %v", err)
+	}
+	query, err := ABI.Pack("queryFinalized", b32("vote1"))
+	if err != nil {
+		t.Fatalf("pack queryFinalized: %v", err)
+	}
+	finalized := testLog(t, ABI, "VoteFinalized", "vote1", b32("option2"), int32(5))
+	cast := testLog(t, ABI, "BallotCast", "vote1", b32("option2"), b32("user1"), int32(5))
+
+	f, err := decodeFinalizeTx(ABI, common.ToHex(finalize), []rpc.TxLog{cast, finalized})
+	if err != nil {
+		t.Fatalf("decode finalizeVote: %v", err)
+	}
+	if f.vote != b32("vote1") || f.winner != b32("option2") || f.total != 5 {
+		t.Errorf("got vote %q winner %q total %d", f.vote, f.winner, f.total)
+	}
+
+	tests := []struct {
+		name    string
+		payload string
+		logs    []rpc.TxLog
+	}{
+		{"other method", common.ToHex(query), []rpc.TxLog{finalized}},
+		{"no method", "0x", []rpc.TxLog{finalized}},
+		{"unknown method", "0x12345678", []rpc.TxLog{finalized}},
+		{"no VoteFinalized log", common.ToHex(finalize), []rpc.TxLog{cast}},
+	}
+	for _, tt := range tests {
+		if _, err := decodeFinalizeTx(ABI, tt.payload, tt.logs); err == nil {
+			t.Errorf("%s: decoded as finalizeVote", tt.name)
+		}
+	}
+}
+
+func TestFinalizeMismatch(t *testing.T) {
+	options := []vm.CertificateOption{{ID: "option1", Total: 3}, {ID: "option2", Total: 5}}
+	tests := []struct {
+		name     string
+		cert     vm.ResultCertificate
+		f        finalizeTx
+		mismatch bool
+	}{
+		{"same", vm.ResultCertificate{VoteID: "vote1", Options: options, WinnerID: "option2"},
+			finalizeTx{vote: b32("vote1"), winner: b32("option2"), total: 5}, false},
+		{"other vote", vm.ResultCertificate{VoteID: "vote1", Options: options, WinnerID: "option2"},
+			finalizeTx{vote: b32("vote2"), winner: b32("option2"), total: 5}, true},
+		{"tallies changed", vm.ResultCertificate{VoteID: "vote1", Options: options, WinnerID: "option2"},
+			finalizeTx{vote: b32("vote1"), winner: b32("option2"), total: 4}, true},
+		{"other winner", vm.ResultCertificate{VoteID: "vote1", Options: options, WinnerID: "option2"},
+			finalizeTx{vote: b32("vote1"), winner: b32("option1"), total: 5}, true},
+		{"tie has no winner", vm.ResultCertificate{VoteID: "vote1", Options: []vm.CertificateOption{{ID: "option1", Total: 5}, {ID: "option2", Total: 5}}},
+			finalizeTx{vote: b32("vote1"), winner: b32("option1"), total: 5}, false},
+		{"no options", vm.ResultCertificate{VoteID: "vote1"},
+			finalizeTx{vote: b32("vote1"), total: -1}, false},
+		{"survey", vm.ResultCertificate{VoteID: "vote1", Questions: []vm.CertificateQuestion{{ID: "question1"}}},
+			finalizeTx{vote: b32("vote1"), total: -1}, false},
+		{"long vote id", vm.ResultCertificate{VoteID: "0123456789abcdef0123456789abcdef-extra"},
+			finalizeTx{vote: b32("0123456789abcdef0123456789abcdef"), total: -1}, false},
+	}
+	for _, tt := range tests {
+		if reason := finalizeMismatch(&tt.cert, &tt.f); (reason != "") != tt.mismatch {
+			t.Errorf("%s: got %q, want mismatch %v", tt.name, reason, tt.mismatch)
+		}
+	}
+}
@@ -0,0 +1,147 @@
+package service
+
+import (
+	"FunnyVoteGo/src/api/vm"
+	"FunnyVoteGo/src/model"
+	"FunnyVoteGo/src/util"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/glog"
+)
+
+const (
+	// maxChainText longest text written on chain as is, longer text is stored off-chain
+	maxChainText = 32
+	// maxContentSize longest text stored off-chain
+	maxContentSize = 64 * 1024
+)
+
+// ContentError input can not be stored on chain
+type ContentError struct {
+	Field  string
+	Reason string
+}
+
+func (e *ContentError) Error() string {
+	return e.Field + ": " + e.Reason
+}
+
+// ValidateVoteInit reject inputs exceeding limits of contract before anything is written
+func ValidateVoteInit(voteinit *vm.VoteInit) error {
+	if err := validateText("title", voteinit.Title); err != nil {
+		return err
+	}
+	if err := validateText("description", voteinit.Description); err != nil {
+		return err
+	}
+	if len(voteinit.Questions) > 0 {
+		if len(voteinit.Options) > 0 {
+			return &ContentError{Field: "options", Reason: "问卷的选项须在问题中设置"}
+		}
+		if err := validateQuestions(voteinit.Questions); err != nil {
+			return err
+		}
+	} else if len(voteinit.Options) == 0 && voteinit.SignatureTarget == 0 {
+		return &ContentError{Field: "options", Reason: "选项不能为空"}
+	}
+	for i, option := range voteinit.Options {
+		if err := validateText("options["+strconv.Itoa(i)+"]", option); err != nil {
+			return err
+		}
+	}
+	// 时间以秒级时间戳写入链上
+	start, err := strconv.ParseInt(voteinit.StartTime, 10, 64)
+	if err != nil || len(voteinit.StartTime) > maxChainText {
+		return &ContentError{Field: "start_time", Reason: "时间格式错误"}
+	}
+	// 请愿可以不设截止时间
+	if voteinit.EndTime != "" || voteinit.SignatureTarget == 0 {
+		end, err := strconv.ParseInt(voteinit.EndTime, 10, 64)
+		if err != nil || len(voteinit.EndTime) > maxChainText {
+			return &ContentError{Field: "end_time", Reason: "时间格式错误"}
+		}
+		if end <= start {
+			return &ContentError{Field: "end_time", Reason: "结束时间须晚于开始时间"}
+		}
+	}
+	if voteinit.Restricted && len(voteinit.Invitees) == 0 {
+		return &ContentError{Field: "invitees", Reason: "仅受邀用户可投票时须设置受邀用户"}
+	}
+	if err := validateRunoff(voteinit); err != nil {
+		return err
+	}
+	if err := validateBudget(voteinit); err != nil {
+		return err
+	}
+	if err := validatePetition(voteinit); err != nil {
+		return err
+	}
+	if err := validatePairwise(voteinit); err != nil {
+		return err
+	}
+	return validateOptionAttachments(voteinit)
+}
+
+// validateText text must be valid utf8 without NUL, which ends bytes32 text on chain,
+// short text is written as is so it can not look like a hash or hex when read back
+func validateText(field, s string) error {
+	switch {
+	case s == "":
+		return &ContentError{Field: field, Reason: "内容不能为空"}
+	case len(s) > maxContentSize:
+		return &ContentError{Field: field, Reason: fmt.Sprintf("内容超过%d字节", maxContentSize)}
+	case !utf8.ValidString(s) || strings.IndexByte(s, 0) >= 0:
+		return &ContentError{Field: field, Reason: "内容包含非法字符"}
+	case len(s) <= maxChainText && strings.IndexFunc(s, unicode.IsControl) >= 0:
+		// 短文本原样上链, 含控制字符时链上读回会被当作十六进制
+		return &ContentError{Field: field, Reason: "内容包含控制字符"}
+	case len(s) <= maxChainText && strings.HasPrefix(s, "0x"):
+		// 0x开头的链上文本表示链下内容的哈希
+		return &ContentError{Field: field, Reason: "内容不能以0x开头"}
+	}
+	return nil
+}
+
+// toChainText returns text written on chain, text longer than bytes32 is stored off-chain
+// and replaced by 0x prefixed sha256 of it
+func toChainText(s string) (string, bool) {
+	if len(s) <= maxChainText {
+		return s, true
+	}
+	hash := contentHash(s)
+	if !model.SaveContent(hash, s) {
+		return "", false
+	}
+	return "0x" + hash, true
+}
+
+// fromChainText returns text of bytes32 on chain, hash is resolved to off-chain content,
+// false if content is missing or does not match the hash
+func fromChainText(b32 [32]byte) (string, bool) {
+	s := util.Byte32ToDisplay(b32)
+	if !strings.HasPrefix(s, "0x") {
+		return s, true
+	}
+	hash := strings.TrimPrefix(s, "0x")
+	content, b := model.GetContent(hash)
+	if !b || content == nil {
+		glog.Errorf("content %s not found", hash)
+		return s, false
+	}
+	if contentHash(content.Body) != hash {
+		glog.Errorf("content %s does not match its hash", hash)
+		return content.Body, false
+	}
+	return content.Body, true
+}
+
+func contentHash(s string) string {
+	h := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(h[:])
+}
@@ -0,0 +1,48 @@
+package service
+
+import (
+	"FunnyVoteGo/src/util"
+	"strings"
+	"testing"
+)
+
+func TestValidateText(t *testing.T) {
+	tests := []struct {
+		name    string
+		s       string
+		wantErr bool
+	}{
+		{"short", "午餐吃什么", false},
+		{"empty", "", true},
+		{"nul", "a\x00b", true},
+		{"invalid utf8", "\xff\xfe", true},
+		{"newline in short text", "a\nb", true},
+		{"tab in short text", "a\tb", true},
+		{"hash like short text", "0x1234", true},
+		{"0x inside short text", "a0x1234", false},
+		{"newline in long text", strings.Repeat("长", 20) + "\n" + strings.Repeat("文", 20), false},
+		{"0x prefixed long text", "0x" + strings.Repeat("a", maxChainText), false},
+		{"too large", strings.Repeat("a", maxContentSize+1), true},
+	}
+	for _, tt := range tests {
+		if err := validateText("title", tt.s); (err != nil) != tt.wantErr {
+			t.Errorf("%s: validateText = %v, want err %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+// TestShortTextRoundTrip accepted short text reads back from bytes32 unchanged
+func TestShortTextRoundTrip(t *testing.T) {
+	for _, s := range []string{"午餐", "a b-c_d", "12345678901234567890123456789012", "投票选项一二三四五六"} {
+		if err := validateText("title", s); err != nil {
+			t.Errorf("validateText(%q) = %v", s, err)
+			continue
+		}
+		var b [32]byte
+		copy(b[:], s)
+		got := util.Byte32ToDisplay(b)
+		if got != s || strings.HasPrefix(got, "0x") {
+			t.Errorf("%q read back as %q", s, got)
+		}
+	}
+}
@@ -7,15 +7,22 @@ import (
 	"encoding/json"
 	"fmt"
 	"strings"
+	"sync"
 
 	"github.com/glog"
 	"github.com/hyperchain/gosdk/abi"
 	"github.com/hyperchain/gosdk/account"
 	"github.com/hyperchain/gosdk/rpc"
 	"github.com/hyperchain/gosdk/utils/ecdsa"
+	"github.com/spf13/viper"
 	"github.com/tealeg/xlsx"
 )
 
+var (
+	contractABIMu sync.Mutex
+	contractABI   *abi.ABI
+)
+
 // InitKey create key by key file
 func InitKey() (*ecdsa.Key, error) {
 	m, err := util.FilePathToMap("./conf/key/key.json")
@@ -153,6 +160,93 @@ func CompileContract(contractcode string) (*vm.CompileResult, error) {
 	return &result, nil
 }
 
+// contractAddress address of vote contract in use
+var (
+	contractAddress   string
+	contractAddressMu sync.RWMutex
+)
+
+// ContractAddress address of vote contract in use
+func ContractAddress() string {
+	contractAddressMu.RLock()
+	defer contractAddressMu.RUnlock()
+	return contractAddress
+}
+
+// InitContractAddress use the latest deployment recorded in store, or contract.address in config
+// (env APISERVER_CONTRACT_ADDRESS) if never deployed or store is not used. Event subscriptions keep
+// the address they started with, so restart after deploying
+func InitContractAddress(store bool) {
+	address := viper.GetString("contract.address")
+	if store {
+		if cd, b := model.GetLatestContractDeployment(); b && cd.Address != "" {
+			address = cd.Address
+		}
+	}
+	if address == "" {
+		glog.Error("contract address is not set, deploy the contract or set contract.address")
+	}
+	contractAddressMu.Lock()
+	contractAddress = address
+	contractAddressMu.Unlock()
+}
+
+// DeployContract deploy vote contract, record the deployment and use its address
+func DeployContract() (*vm.DeployResult, error) {
+	key, err := InitKey()
+	if err != nil {
+		return nil, err
+	}
+	cr, err := CompileContract(GetContractCode())
+	if err != nil {
+		return nil, err
+	}
+	hpc := rpc.NewRPCWithPath("./conf/chain_SDK/conf")
+	if hpc == nil {
+		return nil, fmt.Errorf("初始化rpc失败")
+	}
+	tranDeploy := rpc.NewTransaction(key.GetAddress()).Deploy(cr.Bin[0])
+	tranDeploy.Sign(key)
+	txDeploy, stdErr := hpc.DeployContract(tranDeploy)
+	if stdErr != nil {
+		glog.Error(stdErr)
+		return nil, fmt.Errorf("合约部署失败")
+	}
+	if _, b := model.CreateContractDeployment(&model.ContractDeployment{
+		Address: txDeploy.ContractAddress,
+		TxHash:  txDeploy.TxHash,
+	}); !b {
+		glog.Errorf("record deployment %s of contract %s fail", txDeploy.TxHash, txDeploy.ContractAddress)
+		return nil, fmt.Errorf("合约已部署但记录失败, 地址为%s", txDeploy.ContractAddress)
+	}
+	contractAddressMu.Lock()
+	contractAddress = txDeploy.ContractAddress
+	contractAddressMu.Unlock()
+	return &vm.DeployResult{
+		ContractAddr: txDeploy.ContractAddress,
+		TxHash:       txDeploy.TxHash,
+	}, nil
+}
+
+// GetContractABI compile vote contract once and return its abi
+func GetContractABI() (*abi.ABI, error) {
+	contractABIMu.Lock()
+	defer contractABIMu.Unlock()
+	if contractABI != nil {
+		return contractABI, nil
+	}
+	cr, err := CompileContract(GetContractCode())
+	if err != nil {
+		return nil, err
+	}
+	ABI, err := abi.JSON(strings.NewReader(cr.Abi[0]))
+	if err != nil {
+		return nil, err
+	}
+	contractABI = &ABI
+	return contractABI, nil
+}
+
 // GetContractInfo get contract info by contract name
 func GetContractInfo(contractname string) (*model.ContractInfo, error) {
 	var contractinfo model.ContractInfo
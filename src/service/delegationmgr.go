@@ -0,0 +1,329 @@
+package service
+
+import (
+	"FunnyVoteGo/src/api/vm"
+	"FunnyVoteGo/src/constant"
+	"FunnyVoteGo/src/lib/eventbus"
+	"FunnyVoteGo/src/model"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/glog"
+)
+
+// maxDelegationDepth longest delegation chain, same as MAX_DELEGATION_DEPTH of contract
+const maxDelegationDepth = 32
+
+// reasons a delegator is not counted
+const (
+	delegationNoVoter    = "委托链中无投票人"
+	delegationCycle      = "委托链存在环"
+	delegationTooLong    = "委托链过长"
+	delegationVoted      = "委托人已投票"
+	delegationInviteOnly = "邀请制投票不支持委托"
+)
+
+// Delegate delegate voting power of the user to another user, globally or in a vote,
+// a delegation of the same scope is replaced
+func Delegate(di *vm.DelegationInit, userid uint) (string, bool) {
+	if di.DelegateID == userid {
+		return "", false
+	}
+	if _, b := model.GetUser(map[string]interface{}{"id": di.DelegateID}); !b {
+		return "", false
+	}
+	if !canChangeDelegation(di.VoteID, userid) {
+		return "", false
+	}
+	ds, b := model.GetActiveDelegations(di.VoteID)
+	if !b {
+		return "", false
+	}
+	graph := delegationGraph(ds)
+	graph[userid] = di.DelegateID
+	if _, _, reason := walkDelegation(graph, userid, func(uint) bool { return false }); reason == delegationCycle {
+		glog.Errorf("delegation of user %d to %d makes a cycle", userid, di.DelegateID)
+		return "", false
+	}
+	old, b := model.GetActiveDelegation(di.VoteID, userid)
+	if !b {
+		return "", false
+	}
+
+	txhash, b := commitDelegation(di.VoteID, userid, di.DelegateID)
+	if !b {
+		return "", false
+	}
+	if old != nil && !model.RevokeDelegation(old.ID, txhash) {
+		return "", false
+	}
+	if _, b := model.CreateDelegation(&model.Delegation{
+		VoteID:      di.VoteID,
+		DelegatorID: userid,
+		DelegateID:  di.DelegateID,
+		TxHash:      txhash,
+	}); !b {
+		return "", false
+	}
+	publishDelegationSet(di.VoteID, userid, di.DelegateID, txhash)
+	return txhash, true
+}
+
+// RevokeDelegation revoke delegation of the user, only before the user votes in the vote
+func RevokeDelegation(voteid string, userid uint) (string, bool) {
+	if !canChangeDelegation(voteid, userid) {
+		return "", false
+	}
+	old, b := model.GetActiveDelegation(voteid, userid)
+	if !b || old == nil {
+		return "", false
+	}
+	txhash, b := commitDelegation(voteid, userid, 0)
+	if !b {
+		return "", false
+	}
+	if !model.RevokeDelegation(old.ID, txhash) {
+		return "", false
+	}
+	publishDelegationSet(voteid, userid, 0, txhash)
+	return txhash, true
+}
+
+// GetDelegations get delegations made by the user
+func GetDelegations(userid uint) ([]model.Delegation, bool) {
+	return model.GetUserDelegations(userid)
+}
+
+// GetDelegationTally resolve delegation chains of the vote, counted resolutions are read from records
+func GetDelegationTally(voteid string) (*vm.DelegationTally, bool) {
+	vs, b := model.GetVoteSetting(map[string]interface{}{"vote_id": voteid})
+	if !b {
+		return nil, false
+	}
+	results, b := resolveDelegations(vs)
+	if !b {
+		return nil, false
+	}
+	tally := vm.DelegationTally{
+		VoteID:  voteid,
+		Weights: make(map[uint]int),
+		Results: results,
+	}
+	for _, r := range results {
+		if r.DelegateID != 0 {
+			tally.Weights[r.DelegateID]++
+		}
+	}
+	return &tally, true
+}
+
+// countDelegatedWeight count weight of delegators who did not vote for their final delegates on chain,
+// called before the vote is finalized, counted delegators are skipped so it can be retried
+func countDelegatedWeight(vs *model.VoteSetting) bool {
+	results, b := resolveDelegations(vs)
+	if !b {
+		return false
+	}
+	for _, r := range results {
+		if r.DelegateID == 0 || r.Counted {
+			continue
+		}
+		txhash, b := commitDelegationResolution(vs.VoteID, r.DelegatorID)
+		if !b {
+			return false
+		}
+		if !model.CreateDelegationResolution(&model.DelegationResolution{
+			VoteID:      vs.VoteID,
+			DelegatorID: r.DelegatorID,
+			DelegateID:  r.DelegateID,
+			Path:        joinUserIDs(r.Path),
+			TxHash:      txhash,
+		}) {
+			return false
+		}
+	}
+	return true
+}
+
+// resolveDelegations resolve chain of each delegator in the vote to the first delegate who voted,
+// delegation of the vote takes precedence over global one at each hop
+func resolveDelegations(vs *model.VoteSetting) ([]vm.DelegationResult, bool) {
+	ds, b := model.GetActiveDelegations(vs.VoteID)
+	if !b {
+		return nil, false
+	}
+	votedIDs, b := model.GetVotedUserIDs(vs.VoteID)
+	if !b {
+		return nil, false
+	}
+	drs, b := model.GetDelegationResolutions(vs.VoteID)
+	if !b {
+		return nil, false
+	}
+	voted := make(map[uint]bool)
+	for _, id := range votedIDs {
+		voted[id] = true
+	}
+	counted := make(map[uint]model.DelegationResolution)
+	for _, dr := range drs {
+		counted[dr.DelegatorID] = dr
+	}
+
+	graph := delegationGraph(ds)
+	var results []vm.DelegationResult
+	for _, d := range sortedDelegators(graph) {
+		r := vm.DelegationResult{DelegatorID: d}
+		if dr, ok := counted[d]; ok {
+			r.DelegateID = dr.DelegateID
+			r.Path = splitUserIDs(dr.Path)
+			r.Counted = true
+			r.TxHash = dr.TxHash
+			results = append(results, r)
+			continue
+		}
+		switch {
+		case voted[d]:
+			r.Reason = delegationVoted
+		case vs.InviteOnly:
+			r.Reason = delegationInviteOnly
+		default:
+			if err := checkEligibility(vs, d); err != nil {
+				r.Reason = err.Error()
+				break
+			}
+			r.DelegateID, r.Path, r.Reason = walkDelegation(graph, d, func(id uint) bool { return voted[id] })
+		}
+		results = append(results, r)
+	}
+	return results, true
+}
+
+// walkDelegation follow delegation chain from the delegator until a user who voted,
+// returns the final delegate and the path, or reason the chain is not resolved
+func walkDelegation(graph map[uint]uint, delegator uint, voted func(uint) bool) (uint, []uint, string) {
+	path := []uint{delegator}
+	visited := map[uint]bool{delegator: true}
+	cur := delegator
+	for depth := 0; depth < maxDelegationDepth; depth++ {
+		next, ok := graph[cur]
+		if !ok {
+			return 0, path, delegationNoVoter
+		}
+		if visited[next] {
+			return 0, path, delegationCycle
+		}
+		visited[next] = true
+		path = append(path, next)
+		if voted(next) {
+			return next, path, ""
+		}
+		cur = next
+	}
+	return 0, path, delegationTooLong
+}
+
+// delegationGraph map delegator to delegate, delegation of a vote overrides global one
+func delegationGraph(ds []model.Delegation) map[uint]uint {
+	graph := make(map[uint]uint)
+	for _, d := range ds {
+		if d.VoteID == "" {
+			if _, ok := graph[d.DelegatorID]; !ok {
+				graph[d.DelegatorID] = d.DelegateID
+			}
+			continue
+		}
+		graph[d.DelegatorID] = d.DelegateID
+	}
+	return graph
+}
+
+// canChangeDelegation delegation of a vote can only change before the user votes and the vote ends
+func canChangeDelegation(voteid string, userid uint) bool {
+	if voteid == "" {
+		return true
+	}
+	vs, b := model.GetVoteSetting(map[string]interface{}{"vote_id": voteid})
+	if !b || vs.Closed || voteStatus(vs.StartTime, vs.EndTime) == 3 {
+		glog.Errorf("delegation of vote %s can not change", voteid)
+		return false
+	}
+	voted, b := model.HasUserVoted(voteid, userid)
+	if !b || voted {
+		glog.Errorf("user %d voted in vote %s, delegation can not change", userid, voteid)
+		return false
+	}
+	return true
+}
+
+// commitDelegation set delegation on chain, delegate 0 to revoke
+func commitDelegation(voteid string, delegatorid, delegateid uint) (string, bool) {
+	delegate := ""
+	if delegateid != 0 {
+		delegate = strconv.Itoa(int(delegateid))
+	}
+	return invokeDelegation("setDelegation", map[string]string{
+		"vote_id":      voteid,
+		"delegator_id": strconv.Itoa(int(delegatorid)),
+		"delegate_id":  delegate,
+	})
+}
+
+// commitDelegationResolution count weight of the delegator on chain
+func commitDelegationResolution(voteid string, delegatorid uint) (string, bool) {
+	return invokeDelegation("resolveDelegation", map[string]string{
+		"vote_id":      voteid,
+		"delegator_id": strconv.Itoa(int(delegatorid)),
+	})
+}
+
+// invokeDelegation invoke delegation method of contract,
+// params are passed as json map since empty vote id (global) and delegate id (revoke) are meaningful
+func invokeDelegation(method string, params map[string]string) (string, bool) {
+	txhash, err := invokeMethod(method, params)
+	if err != nil {
+		glog.Errorf("%s of user %s in vote %s fail: %v", method, params["delegator_id"], params["vote_id"], err)
+		return "", false
+	}
+	return txhash, true
+}
+
+func publishDelegationSet(voteid string, delegatorid, delegateid uint, txhash string) {
+	publishLocalEvent(eventbus.Event{
+		Name:   constant.EventDelegationSet,
+		VoteID: voteid,
+		TxHash: txhash,
+		Data: map[string]interface{}{
+			"vote_id":      voteid,
+			"delegator_id": strconv.Itoa(int(delegatorid)),
+			"delegate_id":  strconv.Itoa(int(delegateid)),
+		},
+	})
+}
+
+func sortedDelegators(graph map[uint]uint) []uint {
+	var ids []uint
+	for id := range graph {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
+
+func joinUserIDs(ids []uint) string {
+	var ss []string
+	for _, id := range ids {
+		ss = append(ss, strconv.Itoa(int(id)))
+	}
+	return strings.Join(ss, ",")
+}
+
+func splitUserIDs(s string) []uint {
+	var ids []uint
+	for _, v := range strings.Split(s, ",") {
+		if id, err := strconv.Atoi(v); err == nil {
+			ids = append(ids, uint(id))
+		}
+	}
+	return ids
+}
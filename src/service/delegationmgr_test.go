@@ -0,0 +1,110 @@
+package service
+
+import (
+	"FunnyVoteGo/src/model"
+	"reflect"
+	"testing"
+)
+
+func TestWalkDelegation(t *testing.T) {
+	long := make(map[uint]uint)
+	for i := uint(1); i <= maxDelegationDepth+1; i++ {
+		long[i] = i + 1
+	}
+	tests := []struct {
+		name         string
+		graph        map[uint]uint
+		voted        []uint
+		delegator    uint
+		wantDelegate uint
+		wantPath     []uint
+		wantReason   string
+	}{
+		{"direct", map[uint]uint{1: 2}, []uint{2}, 1, 2, []uint{1, 2}, ""},
+		{"chain stops at first voter", map[uint]uint{1: 2, 2: 3, 3: 4}, []uint{3, 4}, 1, 3, []uint{1, 2, 3}, ""},
+		{"no voter", map[uint]uint{1: 2, 2: 3}, nil, 1, 0, []uint{1, 2, 3}, delegationNoVoter},
+		{"no delegation", map[uint]uint{}, nil, 1, 0, []uint{1}, delegationNoVoter},
+		{"cycle", map[uint]uint{1: 2, 2: 3, 3: 1}, nil, 1, 0, []uint{1, 2, 3}, delegationCycle},
+		{"cycle after delegator", map[uint]uint{1: 2, 2: 3, 3: 2}, nil, 1, 0, []uint{1, 2, 3}, delegationCycle},
+		{"self delegation", map[uint]uint{1: 1}, nil, 1, 0, []uint{1}, delegationCycle},
+		{"too long", long, []uint{maxDelegationDepth + 2}, 1, 0, nil, delegationTooLong},
+		{"longest allowed", long, []uint{maxDelegationDepth + 1}, 1, maxDelegationDepth + 1, nil, ""},
+	}
+	for _, tt := range tests {
+		voted := make(map[uint]bool)
+		for _, id := range tt.voted {
+			voted[id] = true
+		}
+		delegate, path, reason := walkDelegation(tt.graph, tt.delegator, func(id uint) bool { return voted[id] })
+		if delegate != tt.wantDelegate || reason != tt.wantReason {
+			t.Errorf("%s: got %d %q, want %d %q", tt.name, delegate, reason, tt.wantDelegate, tt.wantReason)
+		}
+		if tt.wantPath != nil && !reflect.DeepEqual(path, tt.wantPath) {
+			t.Errorf("%s: path %v, want %v", tt.name, path, tt.wantPath)
+		}
+		if len(path) > maxDelegationDepth+1 {
+			t.Errorf("%s: path of %d hops exceeds depth limit", tt.name, len(path)-1)
+		}
+	}
+}
+
+func TestDelegationGraph(t *testing.T) {
+	tests := []struct {
+		name string
+		ds   []model.Delegation
+		want map[uint]uint
+	}{
+		{"global", []model.Delegation{{DelegatorID: 1, DelegateID: 2}}, map[uint]uint{1: 2}},
+		{
+			"vote overrides global",
+			[]model.Delegation{{DelegatorID: 1, DelegateID: 2}, {VoteID: "vote1", DelegatorID: 1, DelegateID: 3}},
+			map[uint]uint{1: 3},
+		},
+		{
+			"global does not override vote",
+			[]model.Delegation{{VoteID: "vote1", DelegatorID: 1, DelegateID: 3}, {DelegatorID: 1, DelegateID: 2}},
+			map[uint]uint{1: 3},
+		},
+		{
+			"first global kept",
+			[]model.Delegation{{DelegatorID: 1, DelegateID: 2}, {DelegatorID: 1, DelegateID: 4}, {DelegatorID: 5, DelegateID: 1}},
+			map[uint]uint{1: 2, 5: 1},
+		},
+		{"empty", nil, map[uint]uint{}},
+	}
+	for _, tt := range tests {
+		if got := delegationGraph(tt.ds); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestSortedDelegators(t *testing.T) {
+	got := sortedDelegators(map[uint]uint{9: 1, 3: 1, 5: 9, 1: 3})
+	if want := []uint{1, 3, 5, 9}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestJoinSplitUserIDs(t *testing.T) {
+	tests := []struct {
+		ids  []uint
+		want string
+	}{
+		{[]uint{1}, "1"},
+		{[]uint{1, 22, 333}, "1,22,333"},
+		{nil, ""},
+	}
+	for _, tt := range tests {
+		s := joinUserIDs(tt.ids)
+		if s != tt.want {
+			t.Errorf("joinUserIDs(%v) = %q, want %q", tt.ids, s, tt.want)
+		}
+		if got := splitUserIDs(s); !reflect.DeepEqual(got, tt.ids) {
+			t.Errorf("splitUserIDs(%q) = %v, want %v", s, got, tt.ids)
+		}
+	}
+	if got := splitUserIDs("1,x,,3"); !reflect.DeepEqual(got, []uint{1, 3}) {
+		t.Errorf("splitUserIDs skips invalid ids, got %v", got)
+	}
+}
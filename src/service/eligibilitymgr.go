@@ -0,0 +1,442 @@
+package service
+
+import (
+	"FunnyVoteGo/src/api/vm"
+	"FunnyVoteGo/src/model"
+	"FunnyVoteGo/src/util"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/mail"
+	"strconv"
+	"strings"
+
+	"github.com/Knetic/govaluate"
+	"github.com/glog"
+	"github.com/hyperchain/gosdk/abi"
+)
+
+// maxInviteCodes codes generated in one request
+const maxInviteCodes = 1000
+
+// SetVoteVoters replace eligibility list of the vote and commit its merkle root on chain,
+// the list can not change after ballots are cast
+func SetVoteVoters(voteid string, userids []uint, userid uint) (string, bool) {
+	if !canManageVote(voteid, userid) {
+		glog.Errorf("user %d can not set voters of vote %s", userid, voteid)
+		return "", false
+	}
+	ids := uniqueUserIDs(userids)
+	if len(ids) == 0 {
+		return "", false
+	}
+	if count, b := model.CountVoteUsers(voteid); !b || count > 0 {
+		glog.Errorf("vote %s has ballots, voters can not change", voteid)
+		return "", false
+	}
+	users, b := model.GetUsersByIDs(ids)
+	if !b || len(users) != len(ids) {
+		glog.Errorf("unknown users in voters of vote %s", voteid)
+		return "", false
+	}
+	vs, b := model.GetVoteSetting(map[string]interface{}{"vote_id": voteid})
+	if !b {
+		return "", false
+	}
+
+	root := "0x" + hex.EncodeToString(util.MerkleRoot(voterLeaves(voteid, ids)))
+	txhash, b := commitVoterRoot(voteid, root)
+	if !b {
+		return "", false
+	}
+	if !model.ReplaceVoteInvitees(voteid, ids) {
+		return "", false
+	}
+	if !model.UpdateVoteSetting(vs.ID, map[string]interface{}{
+		"restricted": true,
+		"voter_root": root,
+	}) {
+		return "", false
+	}
+	return txhash, true
+}
+
+// ParseVoterRows resolve user ids or user names in the first column, a header row is skipped
+func ParseVoterRows(rows [][]string) ([]uint, bool) {
+	var ids []uint
+	for i, row := range rows {
+		if len(row) == 0 {
+			continue
+		}
+		cell := strings.TrimSpace(row[0])
+		if cell == "" {
+			continue
+		}
+		if id, err := strconv.ParseUint(cell, 10, 64); err == nil {
+			ids = append(ids, uint(id))
+			continue
+		}
+		user, b := model.GetUser(map[string]interface{}{"user_name": cell})
+		if b {
+			ids = append(ids, user.ID)
+			continue
+		}
+		if i == 0 {
+			continue
+		}
+		glog.Errorf("unknown voter: %s", cell)
+		return nil, false
+	}
+	return ids, true
+}
+
+// GetVoterProof merkle proof of user in eligibility list of the vote
+func GetVoterProof(voteid string, userid uint) (*vm.VoterProof, bool) {
+	vs, b := model.GetVoteSetting(map[string]interface{}{"vote_id": voteid})
+	if !b || !vs.Restricted {
+		return nil, false
+	}
+	ids, b := model.GetVoteInviteeIDs(voteid)
+	if !b {
+		return nil, false
+	}
+	leaf := voterLeaf(voteid, userid)
+	vp := vm.VoterProof{
+		VoteID: voteid,
+		Root:   vs.VoterRoot,
+		Leaf:   "0x" + hex.EncodeToString(leaf),
+		Proof:  []string{},
+	}
+	proof := util.MerkleProof(voterLeaves(voteid, ids), leaf)
+	if proof == nil {
+		return &vp, true
+	}
+	vp.Eligible = true
+	for _, p := range proof {
+		vp.Proof = append(vp.Proof, "0x"+hex.EncodeToString(p))
+	}
+	return &vp, true
+}
+
+// GenerateInviteCodes generate single-use invite codes of the vote, codes are mailed if emails given
+func GenerateInviteCodes(ic *vm.InviteCodeInit, userid uint) ([]string, bool) {
+	if !canManageVote(ic.VoteID, userid) {
+		glog.Errorf("user %d can not invite to vote %s", userid, ic.VoteID)
+		return nil, false
+	}
+	count := ic.Count
+	if len(ic.Emails) > 0 {
+		count = len(ic.Emails)
+	}
+	if count <= 0 || count > maxInviteCodes {
+		return nil, false
+	}
+	for _, email := range ic.Emails {
+		if _, err := mail.ParseAddress(email); err != nil {
+			glog.Errorf("invalid email: %s", email)
+			return nil, false
+		}
+	}
+	vs, b := model.GetVoteSetting(map[string]interface{}{"vote_id": ic.VoteID})
+	if !b {
+		return nil, false
+	}
+
+	var codes []string
+	var ics []model.InviteCode
+	for i := 0; i < count; i++ {
+		code, err := util.SecureRandString(12)
+		if err != nil {
+			glog.Error(err)
+			return nil, false
+		}
+		invite := model.InviteCode{
+			VoteID:   ic.VoteID,
+			CodeHash: inviteCodeHash(code),
+		}
+		if len(ic.Emails) > 0 {
+			invite.Email = ic.Emails[i]
+		}
+		codes = append(codes, code)
+		ics = append(ics, invite)
+	}
+	if !model.CreateInviteCodes(ics) {
+		return nil, false
+	}
+	if !vs.InviteOnly && !model.UpdateVoteSetting(vs.ID, map[string]interface{}{"invite_only": true}) {
+		return nil, false
+	}
+
+	data := newMailData(vs)
+	for i, email := range ic.Emails {
+		data.Code = codes[i]
+		sendMail(mailVoteInvite, 0, email, data)
+	}
+	return codes, true
+}
+
+// GetInviteCodes get invite codes of the vote, codes themselves are not stored
+func GetInviteCodes(voteid string, userid uint) ([]model.InviteCode, bool) {
+	if !canManageVote(voteid, userid) {
+		return nil, false
+	}
+	return model.GetInviteCodes(voteid)
+}
+
+// SetVoteEligibility set eligibility expression of the vote and commit its hash on chain,
+// the expression can not change after ballots are cast
+func SetVoteEligibility(voteid, expr string, userid uint) (string, bool) {
+	if !canManageVote(voteid, userid) {
+		glog.Errorf("user %d can not set eligibility of vote %s", userid, voteid)
+		return "", false
+	}
+	if err := validateEligibility(expr); err != nil {
+		glog.Errorf("invalid eligibility expression %q: %v", expr, err)
+		return "", false
+	}
+	if count, b := model.CountVoteUsers(voteid); !b || count > 0 {
+		glog.Errorf("vote %s has ballots, eligibility can not change", voteid)
+		return "", false
+	}
+	vs, b := model.GetVoteSetting(map[string]interface{}{"vote_id": voteid})
+	if !b {
+		return "", false
+	}
+	hash := eligibilityHash(expr)
+	txhash, b := commitEligibilityHash(voteid, hash)
+	if !b {
+		return "", false
+	}
+	if !model.UpdateVoteSetting(vs.ID, map[string]interface{}{
+		"eligibility":      expr,
+		"eligibility_hash": hash,
+	}) {
+		return "", false
+	}
+	return txhash, true
+}
+
+// GetUserAttributes get attributes of user
+func GetUserAttributes(userid uint) (map[string]interface{}, bool) {
+	user, b := model.GetUser(map[string]interface{}{"id": userid})
+	if !b {
+		return nil, false
+	}
+	return userAttributes(user), true
+}
+
+// SetUserAttributes replace attributes of user
+func SetUserAttributes(ua *vm.UserAttributes) bool {
+	data, err := json.Marshal(ua.Attributes)
+	if err != nil {
+		glog.Error(err)
+		return false
+	}
+	return model.UpdateUser(ua.UserID, map[string]interface{}{"attrs": string(data)})
+}
+
+// EligibilityError ballot rejected by eligibility of vote
+type EligibilityError struct {
+	Reason string
+}
+
+func (e *EligibilityError) Error() string {
+	return "不满足投票资格: " + e.Reason
+}
+
+// checkEligibility check user can vote in the vote by its setting
+func checkEligibility(vs *model.VoteSetting, userid uint) error {
+	if vs.Restricted && !model.IsVoteInvitee(vs.VoteID, userid) {
+		glog.Errorf("user %d not in voters of vote %s", userid, vs.VoteID)
+		return &EligibilityError{Reason: "不在选民名单中"}
+	}
+	if vs.Eligibility == "" {
+		return nil
+	}
+	user, b := model.GetUser(map[string]interface{}{"id": userid})
+	if !b {
+		return &EligibilityError{Reason: "用户不存在"}
+	}
+	params := userAttributes(user)
+	for _, clause := range splitAndClauses(vs.Eligibility) {
+		expr, err := govaluate.NewEvaluableExpression(clause)
+		if err != nil {
+			return &EligibilityError{Reason: clause}
+		}
+		ret, err := expr.Evaluate(params)
+		if pass, ok := ret.(bool); err != nil || !ok || !pass {
+			glog.Errorf("user %d fail eligibility clause %q of vote %s: %v", userid, clause, vs.VoteID, err)
+			return &EligibilityError{Reason: clause}
+		}
+	}
+	return nil
+}
+
+// validateEligibility check expression and each of its clauses can be parsed
+func validateEligibility(expr string) error {
+	if expr == "" {
+		return nil
+	}
+	if _, err := govaluate.NewEvaluableExpression(expr); err != nil {
+		return err
+	}
+	for _, clause := range splitAndClauses(expr) {
+		if _, err := govaluate.NewEvaluableExpression(clause); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// splitAndClauses split expression by top level &&, so the failing clause can be reported.
+// expression with top level || is kept as a whole since && binds tighter
+func splitAndClauses(expr string) []string {
+	var clauses []string
+	var quote byte
+	depth, start := 0, 0
+	for i := 0; i < len(expr); i++ {
+		ch := expr[i]
+		switch {
+		case quote != 0:
+			if ch == '\\' {
+				i++
+			} else if ch == quote {
+				quote = 0
+			}
+		case ch == '"' || ch == '\'':
+			quote = ch
+		case ch == '(' || ch == '[':
+			depth++
+		case ch == ')' || ch == ']':
+			depth--
+		case depth == 0 && i+1 < len(expr) && expr[i:i+2] == "||":
+			return []string{strings.TrimSpace(expr)}
+		case depth == 0 && i+1 < len(expr) && expr[i:i+2] == "&&":
+			clauses = append(clauses, strings.TrimSpace(expr[start:i]))
+			start = i + 2
+			i++
+		}
+	}
+	return append(clauses, strings.TrimSpace(expr[start:]))
+}
+
+// userAttributes parameters of eligibility expression, user_id and user_name are always present
+func userAttributes(user *model.User) map[string]interface{} {
+	params := make(map[string]interface{})
+	if user.Attrs != "" {
+		if err := json.Unmarshal([]byte(user.Attrs), &params); err != nil {
+			glog.Errorf("invalid attributes of user %d: %v", user.ID, err)
+		}
+	}
+	params["user_id"] = float64(user.ID)
+	params["user_name"] = user.UserName
+	return params
+}
+
+func eligibilityHash(expr string) string {
+	if expr == "" {
+		return "0x" + strings.Repeat("0", 64)
+	}
+	h := sha256.Sum256([]byte(expr))
+	return "0x" + hex.EncodeToString(h[:])
+}
+
+// commitEligibilityHash set hash of eligibility expression on chain
+func commitEligibilityHash(voteid, hash string) (string, bool) {
+	key, err := InitKey()
+	if err != nil {
+		return "", false
+	}
+	retu, err := InvokeContract(vm.ReqInvokeCon{
+		ContractAddr: ContractAddress(),
+		ContractCode: GetContractCode(),
+		MethodName:   "setEligibilityHash",
+		MethodParams: util.Struct2String(model.EligibilityHash{
+			ID:   voteid,
+			Hash: hash,
+		}),
+	}, key)
+	if err != nil {
+		return "", false
+	}
+	ABI, _ := abi.JSON(strings.NewReader(retu.Abi))
+	p1, msg, err := constructOutput(ABI, retu.Methods, retu.Result)
+	if p1 != 0 {
+		glog.Errorf("set eligibility hash of vote %s fail: %s", voteid, msg)
+		return "", false
+	}
+	return retu.TxHash, true
+}
+
+func redeemInviteCode(voteid, code string, userid uint) bool {
+	if code == "" {
+		glog.Errorf("invite code required by vote %s", voteid)
+		return false
+	}
+	if !model.RedeemInviteCode(voteid, inviteCodeHash(code), userid) {
+		glog.Errorf("invalid invite code of vote %s", voteid)
+		return false
+	}
+	return true
+}
+
+func releaseInviteCode(voteid, code string) {
+	model.ReleaseInviteCode(voteid, inviteCodeHash(code))
+}
+
+// commitVoterRoot set merkle root of eligibility list on chain
+func commitVoterRoot(voteid, root string) (string, bool) {
+	key, err := InitKey()
+	if err != nil {
+		return "", false
+	}
+	retu, err := InvokeContract(vm.ReqInvokeCon{
+		ContractAddr: ContractAddress(),
+		ContractCode: GetContractCode(),
+		MethodName:   "setVoterRoot",
+		MethodParams: util.Struct2String(model.VoterRoot{
+			ID:   voteid,
+			Root: root,
+		}),
+	}, key)
+	if err != nil {
+		return "", false
+	}
+	ABI, _ := abi.JSON(strings.NewReader(retu.Abi))
+	p1, msg, err := constructOutput(ABI, retu.Methods, retu.Result)
+	if p1 != 0 {
+		glog.Errorf("set voter root of vote %s fail: %s", voteid, msg)
+		return "", false
+	}
+	return retu.TxHash, true
+}
+
+func voterLeaf(voteid string, userid uint) []byte {
+	return util.MerkleLeaf(voteid + ":" + strconv.FormatUint(uint64(userid), 10))
+}
+
+func voterLeaves(voteid string, userids []uint) [][]byte {
+	var leaves [][]byte
+	for _, id := range userids {
+		leaves = append(leaves, voterLeaf(voteid, id))
+	}
+	return leaves
+}
+
+func uniqueUserIDs(userids []uint) []uint {
+	seen := make(map[uint]bool)
+	var ids []uint
+	for _, id := range userids {
+		if id == 0 || seen[id] {
+			continue
+		}
+		seen[id] = true
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+func inviteCodeHash(code string) string {
+	h := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(h[:])
+}
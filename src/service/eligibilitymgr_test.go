@@ -0,0 +1,61 @@
+package service
+
+import (
+	"FunnyVoteGo/src/model"
+	"reflect"
+	"testing"
+)
+
+func TestSplitAndClauses(t *testing.T) {
+	tests := []struct {
+		expr string
+		want []string
+	}{
+		{"age >= 18", []string{"age >= 18"}},
+		{"age >= 18 && dept == 'eng'", []string{"age >= 18", "dept == 'eng'"}},
+		{"a && b && c", []string{"a", "b", "c"}},
+		{"age >= 18 || admin", []string{"age >= 18 || admin"}},
+		{"(a || b) && c", []string{"(a || b)", "c"}},
+		{"name == 'x && y' && c", []string{"name == 'x && y'", "c"}},
+		{`name == "a\" && b" && c`, []string{`name == "a\" && b"`, "c"}},
+		{"dept IN ('a', 'b') && (x && y)", []string{"dept IN ('a', 'b')", "(x && y)"}},
+	}
+	for _, tt := range tests {
+		if got := splitAndClauses(tt.expr); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("splitAndClauses(%q) = %q, want %q", tt.expr, got, tt.want)
+		}
+	}
+}
+
+func TestValidateEligibility(t *testing.T) {
+	tests := []struct {
+		expr    string
+		wantErr bool
+	}{
+		{"", false},
+		{"age >= 18 && dept == 'eng'", false},
+		{"age >= ", true},
+		{"(age >= 18", true},
+		{"age >= 18 && )", true},
+	}
+	for _, tt := range tests {
+		if err := validateEligibility(tt.expr); (err != nil) != tt.wantErr {
+			t.Errorf("validateEligibility(%q) = %v, want err %v", tt.expr, err, tt.wantErr)
+		}
+	}
+}
+
+func TestUserAttributes(t *testing.T) {
+	user := &model.User{ID: 3, UserName: "alice", Attrs: `{"age": 20, "user_id": 99, "dept": "eng"}`}
+	params := userAttributes(user)
+	if params["user_id"] != float64(3) || params["user_name"] != "alice" {
+		t.Errorf("user_id and user_name must not be overridden by attributes: %v", params)
+	}
+	if params["age"] != float64(20) || params["dept"] != "eng" {
+		t.Errorf("attributes lost: %v", params)
+	}
+	broken := userAttributes(&model.User{ID: 4, Attrs: "{"})
+	if broken["user_id"] != float64(4) {
+		t.Errorf("invalid attributes drop user_id: %v", broken)
+	}
+}
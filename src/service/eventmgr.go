@@ -0,0 +1,276 @@
+package service
+
+import (
+	"FunnyVoteGo/src/lib/eventbus"
+	"FunnyVoteGo/src/model"
+	"FunnyVoteGo/src/util"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/glog"
+	"github.com/hyperchain/gosdk/abi"
+	"github.com/hyperchain/gosdk/common"
+	"github.com/hyperchain/gosdk/rpc"
+	"github.com/spf13/viper"
+)
+
+const (
+	eventCursorName = "contract_event"
+	// blocks fetched per request when backfilling
+	backfillBatch = 50
+	maxBackoff    = time.Minute
+)
+
+// chainLog log pushed by node websocket or mq
+type chainLog struct {
+	Address     string   `json:"address"`
+	Topics      []string `json:"topics"`
+	Data        string   `json:"data"`
+	BlockNumber uint64   `json:"blockNumber"`
+	TxHash      string   `json:"txHash"`
+	TxIndex     uint64   `json:"txIndex"`
+	Index       uint64   `json:"index"`
+}
+
+// contractEventHandler implements rpc.WsEventHandler
+type contractEventHandler struct {
+	ABI    *abi.ABI
+	closed chan struct{}
+	once   sync.Once
+}
+
+func (h *contractEventHandler) OnSubscribe() {
+	glog.Info("subscribe contract events success")
+}
+
+func (h *contractEventHandler) OnUnSubscribe() {
+	h.OnClose()
+}
+
+func (h *contractEventHandler) OnMessage(data []byte) {
+	logs, err := parseChainLogs(data)
+	if err != nil {
+		glog.Errorf("unknown contract event: %s", string(data))
+		return
+	}
+	for _, l := range logs {
+		handleContractLog(h.ABI, l, eventCursorName)
+	}
+}
+
+func (h *contractEventHandler) OnClose() {
+	h.once.Do(func() {
+		close(h.closed)
+	})
+}
+
+// StartEventSubscriber keep a websocket subscription to events of vote contract
+func StartEventSubscriber() {
+	if !viper.GetBool("chain.subscribe") {
+		return
+	}
+	go func() {
+		backoff := time.Second
+		for {
+			begin := time.Now()
+			err := subscribeContractEvents()
+			glog.Errorf("contract event subscription stopped: %v", err)
+			if time.Since(begin) > maxBackoff {
+				backoff = time.Second
+			}
+			time.Sleep(backoff)
+			if backoff *= 2; backoff > maxBackoff {
+				backoff = maxBackoff
+			}
+		}
+	}()
+}
+
+// recordLocalEvents set by commands, which exit before listeners of the bus run
+var recordLocalEvents bool
+
+// RecordLocalEvents queue webhook deliveries of local events instead of publishing them,
+// they are delivered by the retry job of the running server
+func RecordLocalEvents() {
+	recordLocalEvents = true
+}
+
+// publishLocalEvent publish event known by service itself,
+// the same event comes from chain when subscriber or mq consumer is running
+func publishLocalEvent(e eventbus.Event) {
+	if viper.GetBool("chain.subscribe") || viper.GetBool("mq.enable") {
+		return
+	}
+	if recordLocalEvents {
+		if e.Time == 0 {
+			e.Time = time.Now().Unix()
+		}
+		queueWebhookDeliveries(e)
+		return
+	}
+	eventbus.Publish(e)
+}
+
+// subscribeContractEvents backfill missed events then subscribe, blocks until connection closed
+func subscribeContractEvents() error {
+	hpc := rpc.NewRPCWithPath("./conf/chain_SDK/conf")
+	if hpc == nil {
+		return fmt.Errorf("初始化rpc失败")
+	}
+	ABI, err := GetContractABI()
+	if err != nil {
+		return err
+	}
+	if err := backfillContractEvents(hpc, ABI, eventCursorName); err != nil {
+		return err
+	}
+
+	handler := &contractEventHandler{
+		ABI:    ABI,
+		closed: make(chan struct{}),
+	}
+	filter := rpc.NewLogsFilter().AddAddress(ContractAddress())
+	node := viper.GetInt("chain.ws_node")
+	if node == 0 {
+		node = 1
+	}
+	subID, stdErr := hpc.GetWebSocketClient().Subscribe(node, filter, handler)
+	if stdErr != nil {
+		return stdErr
+	}
+	// events between backfill and subscribe
+	if err := backfillContractEvents(hpc, ABI, eventCursorName); err != nil {
+		glog.Error(err)
+	}
+	<-handler.closed
+	return fmt.Errorf("subscription %s closed", subID)
+}
+
+// backfillContractEvents replay contract logs from cursor to the latest block
+func backfillContractEvents(hpc *rpc.RPC, ABI *abi.ABI, cursorName string) error {
+	latest, stdErr := hpc.GetLatestBlock()
+	if stdErr != nil {
+		return stdErr
+	}
+	cursor, b := model.GetChainCursor(cursorName)
+	if !b || cursor.BlockNumber == 0 {
+		// first run, start from now
+		model.SaveChainCursor(cursorName, latest.Number)
+		return nil
+	}
+	for from := cursor.BlockNumber + 1; from <= latest.Number; from += backfillBatch {
+		to := from + backfillBatch - 1
+		if to > latest.Number {
+			to = latest.Number
+		}
+		txs, stdErr := hpc.GetTransactionsByBlkNum(from, to)
+		if stdErr != nil && stdErr.Code() != rpc.DataNotExistCode {
+			return stdErr
+		}
+		for _, tx := range txs {
+			if !strings.EqualFold(tx.To, ContractAddress()) || tx.Invalid {
+				continue
+			}
+			receipt, stdErr := hpc.GetTxReceipt(tx.Hash)
+			if stdErr != nil {
+				return stdErr
+			}
+			for _, l := range receipt.Log {
+				if l.BlockNumber == 0 {
+					l.BlockNumber = tx.BlockNumber
+				}
+				handleContractLog(ABI, l, cursorName)
+			}
+		}
+		model.SaveChainCursor(cursorName, to)
+	}
+	return nil
+}
+
+// handleContractLog decode log and publish it to event bus
+func handleContractLog(ABI *abi.ABI, l rpc.TxLog, cursorName string) {
+	e, b := decodeContractLog(ABI, l)
+	if !b {
+		return
+	}
+	eventbus.Publish(*e)
+	model.SaveChainCursor(cursorName, l.BlockNumber)
+}
+
+// parseChainLogs parse one log or log array
+func parseChainLogs(data []byte) ([]rpc.TxLog, error) {
+	var cls []chainLog
+	if err := json.Unmarshal(data, &cls); err != nil {
+		var cl chainLog
+		if err := json.Unmarshal(data, &cl); err != nil {
+			return nil, err
+		}
+		cls = append(cls, cl)
+	}
+	var logs []rpc.TxLog
+	for _, l := range cls {
+		logs = append(logs, rpc.TxLog{
+			Address:     l.Address,
+			Topics:      l.Topics,
+			Data:        l.Data,
+			BlockNumber: l.BlockNumber,
+			TxHash:      l.TxHash,
+			TxIndex:     l.TxIndex,
+			Index:       l.Index,
+		})
+	}
+	return logs, nil
+}
+
+// decodeContractLog decode log of vote contract to event
+func decodeContractLog(ABI *abi.ABI, l rpc.TxLog) (*eventbus.Event, bool) {
+	if len(l.Topics) == 0 {
+		return nil, false
+	}
+	for name, event := range ABI.Events {
+		if !strings.EqualFold(event.Id().Hex(), l.Topics[0]) {
+			continue
+		}
+		e := eventbus.Event{
+			Name:        name,
+			TxHash:      l.TxHash,
+			BlockNumber: l.BlockNumber,
+			LogIndex:    l.Index,
+			Data:        make(map[string]interface{}),
+		}
+		// indexed arguments are in topics
+		for i, arg := range event.Inputs.Indexed() {
+			if i+1 >= len(l.Topics) {
+				break
+			}
+			e.Data[arg.Name] = util.ByteToString(common.FromHex(l.Topics[i+1]))
+		}
+		values, err := event.Inputs.NonIndexed().UnpackValues(common.FromHex(l.Data))
+		if err != nil {
+			glog.Errorf("decode event %s fail: %v", name, err)
+			return nil, false
+		}
+		for i, arg := range event.Inputs.NonIndexed() {
+			e.Data[arg.Name] = eventValue(values[i])
+		}
+		if voteid, ok := e.Data["vote_id"].(string); ok {
+			e.VoteID = voteid
+		}
+		return &e, true
+	}
+	return nil, false
+}
+
+func eventValue(v interface{}) interface{} {
+	switch t := v.(type) {
+	case [32]byte:
+		return util.Byte32ToDisplay(t)
+	case int32:
+		return int(t)
+	default:
+		return v
+	}
+}
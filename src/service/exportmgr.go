@@ -0,0 +1,482 @@
+package service
+
+import (
+	"FunnyVoteGo/src/api/vm"
+	"FunnyVoteGo/src/model"
+	"FunnyVoteGo/src/util"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/glog"
+	"github.com/hyperchain/gosdk/abi"
+	"github.com/hyperchain/gosdk/rpc"
+	"github.com/hyperchain/gosdk/utils/ecdsa"
+	"github.com/tealeg/xlsx"
+)
+
+const (
+	// ExportXLSX and ExportCSV formats of export
+	ExportXLSX = "xlsx"
+	ExportCSV  = "csv"
+	// exportPageSize ballots read from chain at a time
+	exportPageSize = 200
+)
+
+var (
+	summaryHeader = []string{"问题", "选项ID", "选项", "票数", "百分比"}
+	ballotHeader  = []string{"用户", "选项ID", "选项", "时间", "交易哈希", "区块号"}
+)
+
+// VoteExport results of a vote to be exported, the summary is built when the export is prepared
+// so that nothing is written for a vote the user can not see
+type VoteExport struct {
+	VoteID  string
+	Format  string
+	summary [][]string
+}
+
+// PrepareExport check results of the vote are visible to the user and build the summary
+func PrepareExport(voteid string, userid uint, format string) (*VoteExport, error) {
+	if format == "" {
+		format = ExportXLSX
+	}
+	if format != ExportXLSX && format != ExportCSV {
+		return nil, &ContentError{Field: "format", Reason: "仅支持xlsx和csv"}
+	}
+	vs, b := model.GetVoteSetting(map[string]interface{}{"vote_id": voteid})
+	if !b || vs.VoteID == "" {
+		return nil, fmt.Errorf("投票不存在")
+	}
+	if !resultVisible(vs, userid) {
+		return nil, &EligibilityError{Reason: "结果暂不可见"}
+	}
+	vote, b := GetVoteStatus(&vm.GetVoteStatus{VoteID: voteid, UserID: userid})
+	if !b {
+		return nil, fmt.Errorf("查询投票失败")
+	}
+	summary, err := exportSummary(vote, vs, userid)
+	if err != nil {
+		return nil, err
+	}
+	return &VoteExport{VoteID: voteid, Format: format, summary: summary}, nil
+}
+
+// FileName name of the exported file
+func (e *VoteExport) FileName() string {
+	return "vote_" + e.VoteID + "." + e.Format
+}
+
+// Write stream the summary sheet and the ballot sheet to w, ballots are read from chain page by page.
+// The digest is sha256 of the bytes written, csv files end with the digest and its signature which
+// are not part of the digest, xlsx files are signed as a whole so the proof is only returned
+func (e *VoteExport) Write(w io.Writer) (*vm.ExportProof, error) {
+	digest := sha256.New()
+	var sheets exportSheets
+	if e.Format == ExportCSV {
+		sheets = newCSVSheets(w, digest)
+	} else {
+		xs, err := newXLSXSheets(w, digest)
+		if err != nil {
+			return nil, err
+		}
+		sheets = xs
+	}
+
+	if err := sheets.WriteHeader(summaryHeader); err != nil {
+		return nil, err
+	}
+	for _, row := range e.summary {
+		if err := sheets.WriteRow(row); err != nil {
+			return nil, err
+		}
+	}
+	if err := sheets.NextSheet(ballotHeader); err != nil {
+		return nil, err
+	}
+	ballots, err := e.writeBallots(sheets)
+	if err != nil {
+		return nil, err
+	}
+
+	proof := vm.ExportProof{VoteID: e.VoteID, Format: e.Format, Ballots: ballots}
+	sum, err := sheets.Finish(digest)
+	if err != nil {
+		return nil, err
+	}
+	proof.Digest = hex.EncodeToString(sum)
+	if proof.Signature, proof.Signer, err = signDigest(sum); err != nil {
+		glog.Errorf("sign export of vote %s fail: %v", e.VoteID, err)
+		return nil, err
+	}
+	if err := sheets.Close(&proof); err != nil {
+		return nil, err
+	}
+	return &proof, nil
+}
+
+// writeBallots write ballots of the vote with tx hashes and block numbers, returns number of ballots
+func (e *VoteExport) writeBallots(sheets exportSheets) (int, error) {
+	key, err := InitKey()
+	if err != nil {
+		return 0, err
+	}
+	hpc := rpc.NewRPCWithPath("./conf/chain_SDK/conf")
+	if hpc == nil {
+		glog.Error("init rpc fail, block numbers not exported")
+	}
+	count := 0
+	for offset := 0; ; offset += exportPageSize {
+		page, total, b := queryVoteRecordPage(e.VoteID, offset, exportPageSize, key)
+		if !b {
+			return count, fmt.Errorf("查询投票记录失败")
+		}
+		records, b := pageHashRecords(e.VoteID, page)
+		if !b {
+			return count, fmt.Errorf("查询哈希记录失败")
+		}
+		for _, ballot := range page {
+			row := []string{ballot.user, ballot.optionID, ballot.content, ballot.displayTime(), "", ""}
+			if hr, ok := records[ballot.id]; ok {
+				row[4] = hr.TxHash
+				if number := blockNumber(hpc, &hr); number != 0 {
+					row[5] = strconv.FormatUint(number, 10)
+				}
+			}
+			if err := sheets.WriteRow(row); err != nil {
+				return count, err
+			}
+			count++
+		}
+		if len(page) == 0 || offset+len(page) >= total {
+			return count, nil
+		}
+	}
+}
+
+// exportSummary totals and percentages of options with the outcome, surveys by questions
+func exportSummary(vote *model.Vote, vs *model.VoteSetting, userid uint) ([][]string, error) {
+	var rows [][]string
+	if vote.SelectType == model.SelectSurvey {
+		for _, q := range vote.Questions {
+			rows = append(rows, optionRows(q.Title, q.Options)...)
+			rows = append(rows, []string{q.Title, "", "结果: " + questionOutcome(&q), "", ""})
+		}
+		return rows, nil
+	}
+	rows = optionRows("", vote.Options)
+	outcome, err := voteOutcome(vote, vs, userid)
+	if err != nil {
+		return nil, err
+	}
+	return append(rows, []string{"", "", "结果: " + outcome, "", ""}), nil
+}
+
+// optionRows rows of options, percentages are of all votes to the options
+func optionRows(question string, options []model.Option) [][]string {
+	var total uint
+	for _, option := range options {
+		total += option.Total
+	}
+	var rows [][]string
+	for _, option := range options {
+		percent := 0.0
+		if total > 0 {
+			percent = float64(option.Total) * 100 / float64(total)
+		}
+		rows = append(rows, []string{question, option.ID, option.Content,
+			strconv.Itoa(int(option.Total)), fmt.Sprintf("%.2f%%", percent)})
+	}
+	return rows
+}
+
+// voteOutcome outcome of the vote by its type
+func voteOutcome(vote *model.Vote, vs *model.VoteSetting, userid uint) (string, error) {
+	switch {
+	case vs.Budget != 0:
+		tally, err := GetBudgetTally(&vm.BudgetTallyReq{VoteID: vote.ID}, userid)
+		if err != nil {
+			return "", err
+		}
+		var funded []string
+		for _, p := range tally.Funded {
+			funded = append(funded, p.Content)
+		}
+		return fmt.Sprintf("按票数分配预算, 资助%s, 花费%d/%d", strings.Join(funded, "、"), tally.Spent, tally.Budget), nil
+	case vs.SignatureTarget != 0:
+		progress, err := GetPetitionProgress(vote.ID, userid)
+		if err != nil {
+			return "", err
+		}
+		if progress.Completed {
+			return fmt.Sprintf("已达到签名目标%d, 完成于%s", progress.Target, progress.CompletedAt), nil
+		}
+		return fmt.Sprintf("签名%d/%d, 未达到目标", progress.Signatures, progress.Target), nil
+	case vs.Pairwise:
+		ranking, err := GetPairwiseRanking(vote.ID, userid)
+		if err != nil {
+			return "", err
+		}
+		if ranking.Comparisons == 0 {
+			return "无比较", nil
+		}
+		top := ranking.Ranking[0]
+		return fmt.Sprintf("Bradley-Terry排名第一: %s, 评分%.2f", top.Content, top.Rating), nil
+	}
+	status := "进行中"
+	if vote.Status == 3 {
+		status = "已结束"
+	}
+	if winner, ok := pluralityWinner(vote.Options); ok {
+		return fmt.Sprintf("%s, 得票最多: %s", status, winner.Content), nil
+	}
+	return status + ", 无得票最多的选项", nil
+}
+
+// questionOutcome leading option of a question of survey, by score for ranked and score questions
+func questionOutcome(q *model.Question) string {
+	var best *model.Option
+	tied := false
+	for i := range q.Options {
+		o := &q.Options[i]
+		value, bestValue := o.Total, uint(0)
+		if q.SelectType == model.SelectRanked || q.SelectType == model.SelectScore {
+			value = uint(o.Score)
+		}
+		if best != nil {
+			bestValue = best.Total
+			if q.SelectType == model.SelectRanked || q.SelectType == model.SelectScore {
+				bestValue = uint(best.Score)
+			}
+		}
+		switch {
+		case best == nil || value > bestValue:
+			best, tied = o, false
+		case value == bestValue:
+			tied = true
+		}
+	}
+	if best == nil || tied {
+		return "无领先选项"
+	}
+	return "领先: " + best.Content
+}
+
+// exportBallot a ballot read from chain
+type exportBallot struct {
+	id       string
+	user     string
+	optionID string
+	content  string
+	created  string
+}
+
+// displayTime create time of the ballot in local time
+func (b *exportBallot) displayTime() string {
+	if t, err := strconv.ParseInt(b.created, 10, 64); err == nil {
+		return time.Unix(t, 0).Format("2006-01-02 15:04:05")
+	}
+	return b.created
+}
+
+// queryVoteRecordPage query a page of ballots of the vote, returns ballots and number of all ballots
+func queryVoteRecordPage(voteid string, offset, limit int, key *ecdsa.Key) ([]exportBallot, int, bool) {
+	retu, err := InvokeContract(vm.ReqInvokeCon{
+		ContractAddr: ContractAddress(),
+		ContractCode: GetContractCode(),
+		MethodName:   "queryVoteRecordPage",
+		MethodParams: fmt.Sprintf(`{"id":%q,"offset":"%d","limit":"%d"}`, voteid, offset, limit),
+	}, key)
+	if err != nil {
+		return nil, 0, false
+	}
+	ABI, _ := abi.JSON(strings.NewReader(retu.Abi))
+	var p_ok int32
+	var p_total int32
+	var p_uarray [][32]byte
+	var p_oarray [][32]byte
+	var p_carray [][32]byte
+	var p_tarray [][32]byte
+	var p_iarray [][32]byte
+	res := []interface{}{&p_ok, &p_total, &p_uarray, &p_oarray, &p_carray, &p_tarray, &p_iarray}
+	if sysErr := ABI.UnpackResult(&res, "queryVoteRecordPage", retu.Result); sysErr != nil {
+		glog.Info(sysErr)
+		return nil, 0, false
+	}
+	if p_ok != 0 {
+		return nil, 0, false
+	}
+	var ballots []exportBallot
+	for i := range p_uarray {
+		content, ok := fromChainText(p_carray[i])
+		if !ok {
+			content = "[内容与链上哈希不一致] " + content
+		}
+		ballots = append(ballots, exportBallot{
+			id:       util.ByteToString(p_iarray[i][:]),
+			user:     util.Byte32ToDisplay(p_uarray[i]),
+			optionID: util.ByteToString(p_oarray[i][:]),
+			content:  content,
+			created:  util.Byte32ToString(p_tarray[i]),
+		})
+	}
+	return ballots, int(p_total), true
+}
+
+// pageHashRecords hash records of ballots in the page, by id of the ballot on chain
+func pageHashRecords(voteid string, page []exportBallot) (map[string]model.HashRecord, bool) {
+	records := make(map[string]model.HashRecord)
+	var ids []string
+	for _, ballot := range page {
+		ids = append(ids, ballot.id)
+	}
+	if len(ids) == 0 {
+		return records, true
+	}
+	hrs, b := model.GetHashRecords("vote_id = ? AND ballot_id IN (?)", voteid, ids)
+	if !b {
+		return nil, false
+	}
+	for _, hr := range hrs {
+		records[hr.BallotID] = hr
+	}
+	return records, true
+}
+
+// blockNumber block of the ballot transaction, queried once and cached in hash record
+func blockNumber(hpc *rpc.RPC, hr *model.HashRecord) uint64 {
+	if hr.BlockNumber != 0 || hpc == nil || hr.TxHash == "" {
+		return hr.BlockNumber
+	}
+	tx, stdErr := hpc.GetTransactionByHash(hr.TxHash)
+	if stdErr != nil {
+		glog.Errorf("query transaction %s fail: %v", hr.TxHash, stdErr)
+		return 0
+	}
+	model.UpdateHashRecord(hr.ID, map[string]interface{}{"block_number": tx.BlockNumber})
+	return tx.BlockNumber
+}
+
+// exportSheets sheets of an export, rows are written in the order of sheets
+type exportSheets interface {
+	WriteHeader(header []string) error
+	WriteRow(row []string) error
+	NextSheet(header []string) error
+	// Finish end the content and return sum of the bytes written
+	Finish(h hash.Hash) ([]byte, error)
+	// Close write the proof after the content if the format has room for it
+	Close(proof *vm.ExportProof) error
+}
+
+// csvSheets sheets written one after another to a csv file, separated by an empty line
+type csvSheets struct {
+	w  io.Writer
+	cw *csv.Writer
+}
+
+func newCSVSheets(w io.Writer, digest io.Writer) *csvSheets {
+	return &csvSheets{w: w, cw: csv.NewWriter(io.MultiWriter(w, digest))}
+}
+
+func (s *csvSheets) WriteHeader(header []string) error {
+	return s.WriteRow(header)
+}
+
+func (s *csvSheets) WriteRow(row []string) error {
+	if err := s.cw.Write(row); err != nil {
+		return err
+	}
+	// 大量选票分批写出
+	s.cw.Flush()
+	return s.cw.Error()
+}
+
+func (s *csvSheets) NextSheet(header []string) error {
+	if err := s.WriteRow(nil); err != nil {
+		return err
+	}
+	return s.WriteRow(header)
+}
+
+func (s *csvSheets) Finish(h hash.Hash) ([]byte, error) {
+	s.cw.Flush()
+	return h.Sum(nil), s.cw.Error()
+}
+
+// Close proof lines follow the content and are not part of the digest
+func (s *csvSheets) Close(proof *vm.ExportProof) error {
+	return writeProofLines(s.w, proof)
+}
+
+// xlsxSheets sheets of a streamed xlsx file, the file bytes are also written to the digest
+type xlsxSheets struct {
+	file *xlsx.StreamFile
+}
+
+func newXLSXSheets(w io.Writer, digest io.Writer) (*xlsxSheets, error) {
+	builder := xlsx.NewStreamFileBuilder(io.MultiWriter(w, digest))
+	for _, sheet := range []struct {
+		name   string
+		header []string
+	}{{"汇总", summaryHeader}, {"选票", ballotHeader}} {
+		if err := builder.AddSheet(sheet.name, sheet.header, nil); err != nil {
+			return nil, err
+		}
+	}
+	file, err := builder.Build()
+	if err != nil {
+		return nil, err
+	}
+	return &xlsxSheets{file: file}, nil
+}
+
+// WriteHeader headers of xlsx sheets are written by builder
+func (s *xlsxSheets) WriteHeader(header []string) error {
+	return nil
+}
+
+func (s *xlsxSheets) WriteRow(row []string) error {
+	return s.file.Write(row)
+}
+
+func (s *xlsxSheets) NextSheet(header []string) error {
+	return s.file.NextSheet()
+}
+
+// Finish the file is complete once closed, so the digest covers every byte of it
+func (s *xlsxSheets) Finish(h hash.Hash) ([]byte, error) {
+	if err := s.file.Close(); err != nil {
+		return nil, err
+	}
+	return h.Sum(nil), nil
+}
+
+// Close a signature inside the file would change its digest, the proof is returned instead
+func (s *xlsxSheets) Close(proof *vm.ExportProof) error {
+	return nil
+}
+
+func proofRows(proof *vm.ExportProof) [][]string {
+	return [][]string{
+		{"sha256", proof.Digest},
+		{"signature", proof.Signature},
+		{"signer", proof.Signer},
+		{"说明", "摘要为本文件去掉末尾签名行后的SHA-256, 签名为服务密钥的secp256k1签名"},
+	}
+}
+
+// writeProofLines proof as comment lines at the end of a csv file
+func writeProofLines(w io.Writer, proof *vm.ExportProof) error {
+	for _, row := range proofRows(proof) {
+		if _, err := fmt.Fprintf(w, "# %s,%s\n", row[0], row[1]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+package service
+
+import (
+	"FunnyVoteGo/src/api/vm"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+func writeTestSheets(t *testing.T, sheets exportSheets) {
+	rows := [][]string{{"", "opt1", "午餐", "3", "75.00%"}, {"", "opt2", "晚餐", "1", "25.00%"}}
+	if err := sheets.WriteHeader(summaryHeader); err != nil {
+		t.Fatal(err)
+	}
+	for _, row := range rows {
+		if err := sheets.WriteRow(row); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := sheets.NextSheet(ballotHeader); err != nil {
+		t.Fatal(err)
+	}
+	if err := sheets.WriteRow([]string{"1", "opt1", "午餐", "2026-10-19 12:00:00", "0xabc", "7"}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestExportDigest(t *testing.T) {
+	proof := &vm.ExportProof{Signature: "0xsig", Signer: "0xsigner"}
+
+	// csv: 摘要覆盖签名行之前的全部字节
+	var buf bytes.Buffer
+	digest := sha256.New()
+	cs := newCSVSheets(&buf, digest)
+	writeTestSheets(t, cs)
+	sum, err := cs.Finish(digest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	content := buf.Len()
+	proof.Digest = hex.EncodeToString(sum)
+	if err := cs.Close(proof); err != nil {
+		t.Fatal(err)
+	}
+	want := sha256.Sum256(buf.Bytes()[:content])
+	if !bytes.Equal(sum, want[:]) {
+		t.Error("csv digest does not match file content")
+	}
+	proofLines := buf.String()[content:]
+	if !strings.HasPrefix(proofLines, "# sha256,"+proof.Digest+"\n") || !strings.Contains(proofLines, "# signer,0xsigner") {
+		t.Errorf("csv proof lines:\n%s", proofLines)
+	}
+
+	// xlsx: 摘要覆盖整个文件
+	buf.Reset()
+	digest = sha256.New()
+	xs, err := newXLSXSheets(&buf, digest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeTestSheets(t, xs)
+	sum, err = xs.Finish(digest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := xs.Close(proof); err != nil {
+		t.Fatal(err)
+	}
+	want = sha256.Sum256(buf.Bytes())
+	if !bytes.Equal(sum, want[:]) {
+		t.Error("xlsx digest does not match file bytes")
+	}
+	if !bytes.HasPrefix(buf.Bytes(), []byte("PK")) {
+		t.Error("xlsx file is not a zip archive")
+	}
+}
@@ -0,0 +1,477 @@
+package service
+
+import (
+	"FunnyVoteGo/src/api/vm"
+	"FunnyVoteGo/src/lib/worker"
+	"FunnyVoteGo/src/model"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/glog"
+	"github.com/tealeg/xlsx"
+)
+
+const (
+	// MaxImportSize largest import sheet in bytes
+	MaxImportSize = 10 << 20
+	// maxImportRows most votes in a sheet
+	maxImportRows = 500
+	// importBatchSize votes created on chain at a time
+	importBatchSize = 10
+	// importSheet name of the sheet with votes, the first sheet is used if not found
+	importSheet = "投票"
+	// importClaimTimeout running rows not updated for this long are left by a crashed import and claimed again,
+	// longer than creating a batch on chain takes; a vote created right before the crash is created once more
+	importClaimTimeout = 10 * time.Minute
+)
+
+// status of imported rows
+const (
+	importValid    = "valid"
+	importCreated  = "created"
+	importExisting = "existing"
+	importRunning  = "running"
+	importInvalid  = "invalid"
+	importFailed   = "failed"
+)
+
+var importStatusText = map[string]string{
+	importValid:    "校验通过",
+	importCreated:  "已创建",
+	importExisting: "此前已导入",
+	importRunning:  "导入中",
+	importInvalid:  "校验失败",
+	importFailed:   "创建失败",
+}
+
+// importColumn a column of the import sheet and the field of vote init it fills
+type importColumn struct {
+	name     string
+	field    string
+	required bool
+	note     string
+}
+
+var importColumns = []importColumn{
+	{"编号", "key", false, "同一用户的编号唯一, 重复导入时跳过已导入的行; 为空时以行内容判断"},
+	{"标题", "title", true, ""},
+	{"描述", "description", true, ""},
+	{"类型", "select_type", false, "单选或多选, 默认单选"},
+	{"选项", "options", true, "每行一个选项, 或以 | 分隔"},
+	{"开始时间", "start_time", true, "如 2024-01-02 09:00, 或秒级时间戳"},
+	{"结束时间", "end_time", true, "如 2024-01-09 18:00, 或秒级时间戳"},
+	{"结果可见性", "result_visibility", false, "实时可见、结束后可见、投票后可见或仅创建者可见, 默认实时可见"},
+	{"受邀用户", "invitees", false, "用户ID, 以逗号分隔"},
+	{"仅受邀用户可投票", "restricted", false, "是或否"},
+	{"需要邀请码", "invite_only", false, "是或否"},
+	{"投票资格", "eligibility", false, "投票资格表达式, 如 department == \"R&D\" && tenure_months >= 6"},
+}
+
+var importTimeLayouts = []string{
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006/01/02 15:04:05",
+	"2006/01/02 15:04",
+	"2006-01-02",
+	"2006/01/02",
+}
+
+var importVisibility = map[string]int{
+	"实时可见":   model.VisibilityAlways,
+	"结束后可见":  model.VisibilityAfterClose,
+	"投票后可见":  model.VisibilityAfterVoted,
+	"仅创建者可见": model.VisibilityCreatorOnly,
+}
+
+// importRow a row of the import sheet
+type importRow struct {
+	row      int
+	key      string
+	voteinit vm.VoteInit
+	errors   []vm.ImportError
+	record   uint
+}
+
+func (r *importRow) fail(field, reason string) {
+	r.errors = append(r.errors, vm.ImportError{Field: field, Reason: reason})
+}
+
+// ImportVotes validate every row of the sheet, then create valid votes on chain in batches through
+// the worker pool. Rows are recorded by key, rows imported before are reported with their votes
+// instead of being created again, and rows failed before are retried
+func ImportVotes(r io.ReaderAt, size int64, creatorid uint, dryrun bool) (*vm.ImportResult, error) {
+	rows, err := parseImportSheet(r, size)
+	if err != nil {
+		return nil, err
+	}
+	result := vm.ImportResult{Total: len(rows), Rows: make([]vm.ImportRow, len(rows))}
+	var pending []int
+	for i, row := range rows {
+		row.voteinit.CreatorID = creatorid
+		ir := &result.Rows[i]
+		*ir = vm.ImportRow{Row: row.row, Key: row.key, Title: row.voteinit.Title, Errors: row.errors}
+		if len(row.errors) > 0 {
+			ir.Status = importInvalid
+			continue
+		}
+		vi, b := model.GetVoteImport(creatorid, row.key)
+		if !b {
+			return nil, fmt.Errorf("查询导入记录失败")
+		}
+		if vi != nil && vi.Status != model.ImportFailed && !importStale(vi, time.Now()) {
+			ir.VoteID, ir.TxHash = vi.VoteID, vi.TxHash
+			ir.Status = importExisting
+			if vi.Status == model.ImportRunning {
+				ir.Status = importRunning
+			}
+			continue
+		}
+		if dryrun {
+			ir.Status = importValid
+			continue
+		}
+		if row.record = claimImportRow(vi, creatorid, row); row.record == 0 {
+			// 同时进行的导入已认领该行
+			ir.Status = importRunning
+			continue
+		}
+		pending = append(pending, i)
+	}
+
+	for start := 0; start < len(pending); start += importBatchSize {
+		end := start + importBatchSize
+		if end > len(pending) {
+			end = len(pending)
+		}
+		var dones []chan bool
+		for _, i := range pending[start:end] {
+			row, ir := rows[i], &result.Rows[i]
+			dones = append(dones, worker.SubmitFunc(func() error {
+				return createImportRow(row, ir)
+			}))
+		}
+		for _, done := range dones {
+			<-done
+		}
+	}
+
+	for _, ir := range result.Rows {
+		switch ir.Status {
+		case importCreated:
+			result.Created++
+		case importExisting, importRunning:
+			result.Existing++
+		case importInvalid:
+			result.Invalid++
+		case importFailed:
+			result.Failed++
+		}
+	}
+	return &result, nil
+}
+
+// claimImportRow record the row as running, returns id of the record, 0 if another import has it
+func claimImportRow(vi *model.VoteImport, creatorid uint, row *importRow) uint {
+	if vi != nil {
+		if !model.ClaimVoteImport(vi.ID, importStaleBefore(time.Now())) {
+			return 0
+		}
+		model.UpdateVoteImport(vi.ID, map[string]interface{}{"row": row.row, "title": row.voteinit.Title})
+		return vi.ID
+	}
+	vi, b := model.CreateVoteImport(&model.VoteImport{
+		CreatorID: creatorid,
+		RowKey:    row.key,
+		Row:       row.row,
+		Title:     row.voteinit.Title,
+		Status:    model.ImportRunning,
+	})
+	if !b {
+		return 0
+	}
+	return vi.ID
+}
+
+// importStale the row is running but was not updated within the timeout
+func importStale(vi *model.VoteImport, now time.Time) bool {
+	return vi.Status == model.ImportRunning && vi.UpdatedAt < importStaleBefore(now)
+}
+
+// importStaleBefore update time of running rows considered stale, in the format of model timestamps
+func importStaleBefore(now time.Time) string {
+	return now.Add(-importClaimTimeout).Format("2006-01-02 15:04:05")
+}
+
+// createImportRow create the vote of a row on chain and record the result
+func createImportRow(row *importRow, ir *vm.ImportRow) error {
+	voteid, txhash, b := startVote(&row.voteinit)
+	if !b {
+		ir.Status = importFailed
+		ir.Errors = append(ir.Errors, vm.ImportError{Reason: "创建投票失败"})
+		model.UpdateVoteImport(row.record, map[string]interface{}{"status": model.ImportFailed, "error": "创建投票失败"})
+		return fmt.Errorf("import row %d of user %d fail", row.row, row.voteinit.CreatorID)
+	}
+	ir.Status, ir.VoteID, ir.TxHash = importCreated, voteid, txhash
+	if !model.UpdateVoteImport(row.record, map[string]interface{}{
+		"status":  model.ImportDone,
+		"vote_id": voteid,
+		"tx_hash": txhash,
+	}) {
+		glog.Errorf("record import of vote %s fail, row %d would be imported again", voteid, row.row)
+	}
+	return nil
+}
+
+// parseImportSheet read rows of the import sheet and validate them, blank rows are skipped
+func parseImportSheet(r io.ReaderAt, size int64) ([]*importRow, error) {
+	file, err := xlsx.OpenReaderAt(r, size)
+	if err != nil {
+		glog.Error(err)
+		return nil, &ContentError{Field: "file", Reason: "无法读取xlsx表格"}
+	}
+	sheet := file.Sheet[importSheet]
+	if sheet == nil && len(file.Sheets) > 0 {
+		sheet = file.Sheets[0]
+	}
+	if sheet == nil || len(sheet.Rows) < 2 {
+		return nil, &ContentError{Field: "file", Reason: "表格中没有投票"}
+	}
+	if len(sheet.Rows)-1 > maxImportRows {
+		return nil, &ContentError{Field: "file", Reason: fmt.Sprintf("一次最多导入%d个投票", maxImportRows)}
+	}
+
+	index := make(map[string]int)
+	for i, cell := range sheet.Rows[0].Cells {
+		index[strings.TrimSpace(cell.String())] = i
+	}
+	for _, column := range importColumns {
+		if _, ok := index[column.name]; !ok && column.required {
+			return nil, &ContentError{Field: "file", Reason: "缺少列: " + column.name}
+		}
+	}
+
+	var rows []*importRow
+	keys := make(map[string]int)
+	for i, sheetRow := range sheet.Rows[1:] {
+		cells := make(map[string]*xlsx.Cell)
+		var raw []string
+		for _, column := range importColumns {
+			var value string
+			if j, ok := index[column.name]; ok && j < len(sheetRow.Cells) {
+				cells[column.name] = sheetRow.Cells[j]
+				value = strings.TrimSpace(sheetRow.Cells[j].String())
+			}
+			raw = append(raw, value)
+		}
+		if strings.Join(raw, "") == "" {
+			continue
+		}
+		row := parseImportRow(i+2, cells, file.Date1904)
+		if row.key == "" {
+			sum := sha256.Sum256([]byte(strings.Join(raw[1:], "\x1f")))
+			row.key = hex.EncodeToString(sum[:])
+		}
+		if first, ok := keys[row.key]; ok {
+			row.fail("编号", fmt.Sprintf("与第%d行重复", first))
+		} else {
+			keys[row.key] = row.row
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// parseImportRow fill vote init from cells of a row, invalid fields are kept on the row
+func parseImportRow(n int, cells map[string]*xlsx.Cell, date1904 bool) *importRow {
+	row := &importRow{row: n}
+	text := func(name string) string {
+		if cell := cells[name]; cell != nil {
+			return strings.TrimSpace(cell.String())
+		}
+		return ""
+	}
+	vi := &row.voteinit
+	row.key = text("编号")
+	vi.Title = text("标题")
+	vi.Description = text("描述")
+	vi.Eligibility = text("投票资格")
+
+	switch text("类型") {
+	case "", "单选", "1":
+		vi.SelectType = model.SelectSingle
+	case "多选", "2":
+		vi.SelectType = model.SelectMulti
+	default:
+		row.fail("类型", "仅支持单选和多选")
+	}
+	for _, option := range strings.FieldsFunc(text("选项"), func(r rune) bool { return r == '\n' || r == '|' }) {
+		if option = strings.TrimSpace(option); option != "" {
+			vi.Options = append(vi.Options, option)
+		}
+	}
+	for _, t := range []struct {
+		name  string
+		value *string
+	}{{"开始时间", &vi.StartTime}, {"结束时间", &vi.EndTime}} {
+		unix, ok := importTime(cells[t.name], date1904)
+		if !ok {
+			row.fail(t.name, "时间格式错误, 如 2024-01-02 09:00")
+			continue
+		}
+		*t.value = strconv.FormatInt(unix, 10)
+	}
+	if visibility := text("结果可见性"); visibility != "" {
+		if vi.ResultVisibility = importVisibility[visibility]; vi.ResultVisibility == 0 {
+			row.fail("结果可见性", "须为实时可见、结束后可见、投票后可见或仅创建者可见")
+		}
+	}
+	for _, s := range strings.FieldsFunc(text("受邀用户"), func(r rune) bool {
+		return r == ',' || r == '，' || r == '、' || r == ' ' || r == '\n'
+	}) {
+		id, err := strconv.ParseUint(s, 10, 32)
+		if err != nil || id == 0 {
+			row.fail("受邀用户", "用户ID错误: "+s)
+			continue
+		}
+		vi.Invitees = append(vi.Invitees, uint(id))
+	}
+	for _, b := range []struct {
+		name  string
+		value *bool
+	}{{"仅受邀用户可投票", &vi.Restricted}, {"需要邀请码", &vi.InviteOnly}} {
+		switch text(b.name) {
+		case "", "否", "false", "FALSE", "0":
+		case "是", "true", "TRUE", "1":
+			*b.value = true
+		default:
+			row.fail(b.name, "须为是或否")
+		}
+	}
+	if err := validateEligibility(vi.Eligibility); err != nil {
+		row.fail("投票资格", err.Error())
+	}
+	if len(row.errors) > 0 {
+		return row
+	}
+	if err := ValidateVoteInit(vi); err != nil {
+		if ce, ok := err.(*ContentError); ok {
+			row.fail(importColumnName(ce.Field), ce.Reason)
+		} else {
+			row.fail("", err.Error())
+		}
+	}
+	return row
+}
+
+// importTime time of a cell, excel dates and seconds since epoch are kept as they are,
+// text is read in local time
+func importTime(cell *xlsx.Cell, date1904 bool) (int64, bool) {
+	if cell == nil {
+		return 0, false
+	}
+	if cell.Type() == xlsx.CellTypeNumeric || cell.Type() == xlsx.CellTypeDate {
+		if f, err := cell.Float(); err == nil {
+			if f > 1e8 {
+				return int64(f), true
+			}
+			t, _ := cell.GetTime(date1904)
+			local := time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), 0, time.Local)
+			return local.Unix(), true
+		}
+	}
+	s := strings.TrimSpace(cell.String())
+	if unix, err := strconv.ParseInt(s, 10, 64); err == nil && unix > 0 {
+		return unix, true
+	}
+	for _, layout := range importTimeLayouts {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return t.Unix(), true
+		}
+	}
+	return 0, false
+}
+
+// importColumnName column of a field of vote init, options[1] is in column of options
+func importColumnName(field string) string {
+	if i := strings.IndexByte(field, '['); i >= 0 {
+		field = field[:i]
+	}
+	for _, column := range importColumns {
+		if column.field == field {
+			return column.name
+		}
+	}
+	return field
+}
+
+// WriteImportTemplate write an empty import sheet with an example row and notes of columns
+func WriteImportTemplate(w io.Writer) error {
+	file := xlsx.NewFile()
+	sheet, err := file.AddSheet(importSheet)
+	if err != nil {
+		return err
+	}
+	header := sheet.AddRow()
+	for _, column := range importColumns {
+		header.AddCell().SetString(column.name)
+	}
+	example := sheet.AddRow()
+	for _, value := range []string{"2024-board-1", "理事会选举", "选举年度理事会成员", "单选", "张三\n李四\n王五",
+		"2024-01-02 09:00", "2024-01-09 18:00", "结束后可见", "", "否", "否", ""} {
+		example.AddCell().SetString(value)
+	}
+
+	notes, err := file.AddSheet("说明")
+	if err != nil {
+		return err
+	}
+	row := notes.AddRow()
+	row.AddCell().SetString("列")
+	row.AddCell().SetString("必填")
+	row.AddCell().SetString("说明")
+	for _, column := range importColumns {
+		row := notes.AddRow()
+		row.AddCell().SetString(column.name)
+		required := ""
+		if column.required {
+			required = "是"
+		}
+		row.AddCell().SetString(required)
+		row.AddCell().SetString(column.note)
+	}
+	return file.Write(w)
+}
+
+// WriteImportResult write result of an import as a sheet mapping rows to votes
+func WriteImportResult(w io.Writer, result *vm.ImportResult) error {
+	file := xlsx.NewFile()
+	sheet, err := file.AddSheet("导入结果")
+	if err != nil {
+		return err
+	}
+	header := sheet.AddRow()
+	for _, name := range []string{"行", "编号", "标题", "状态", "投票ID", "交易哈希", "错误"} {
+		header.AddCell().SetString(name)
+	}
+	for _, ir := range result.Rows {
+		var errs []string
+		for _, e := range ir.Errors {
+			if e.Field == "" {
+				errs = append(errs, e.Reason)
+			} else {
+				errs = append(errs, e.Field+": "+e.Reason)
+			}
+		}
+		row := sheet.AddRow()
+		row.AddCell().SetInt(ir.Row)
+		for _, value := range []string{ir.Key, ir.Title, importStatusText[ir.Status], ir.VoteID, ir.TxHash, strings.Join(errs, "\n")} {
+			row.AddCell().SetString(value)
+		}
+	}
+	return file.Write(w)
+}
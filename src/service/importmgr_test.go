@@ -0,0 +1,121 @@
+package service
+
+import (
+	"FunnyVoteGo/src/model"
+	"bytes"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/tealeg/xlsx"
+)
+
+// importSheetBytes xlsx file with the header of import sheet and the rows
+func importSheetBytes(t *testing.T, rows [][]string) []byte {
+	file := xlsx.NewFile()
+	sheet, err := file.AddSheet(importSheet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	header := sheet.AddRow()
+	for _, column := range importColumns {
+		header.AddCell().SetString(column.name)
+	}
+	for _, values := range rows {
+		row := sheet.AddRow()
+		for _, v := range values {
+			row.AddCell().SetString(v)
+		}
+	}
+	var buf bytes.Buffer
+	if err := file.Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestParseImportSheet(t *testing.T) {
+	data := importSheetBytes(t, [][]string{
+		{"k1", "午餐", "今天吃什么", "多选", "面\n饭|粥", "2026-10-20 09:00", "2026-10-20 18:00", "结束后可见", "1, 2、3", "是", "否", ""},
+		{"", "晚餐", "晚上吃什么", "", "面|饭", "1792400000", "1792500000", "", "", "", "", ""},
+		{"", "", "", "", "", "", "", "", "", "", "", ""},
+		{"k3", "早餐", "早上", "排序", "面|饭", "明天", "2026-10-20", "隐藏", "0,x", "也许", "", ""},
+		{"k1", "重复", "编号重复", "单选", "a|b", "2026-10-20", "2026-10-21", "", "", "", "", ""},
+		{"k5", "时间", "结束早于开始", "", "a|b", "2026-10-21", "2026-10-20", "", "", "", "", ""},
+	})
+	rows, err := parseImportSheet(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("parseImportSheet: %v", err)
+	}
+	if len(rows) != 5 {
+		t.Fatalf("%d rows, want 5 without the blank one", len(rows))
+	}
+
+	r := rows[0]
+	vi := r.voteinit
+	start := time.Date(2026, 10, 20, 9, 0, 0, 0, time.Local).Unix()
+	if len(r.errors) > 0 || r.row != 2 || r.key != "k1" {
+		t.Errorf("row 2: %+v", r)
+	}
+	if vi.SelectType != model.SelectMulti || !reflect.DeepEqual(vi.Options, []string{"面", "饭", "粥"}) ||
+		vi.StartTime != strconv.FormatInt(start, 10) || vi.ResultVisibility != model.VisibilityAfterClose ||
+		!reflect.DeepEqual(vi.Invitees, []uint{1, 2, 3}) || !vi.Restricted || vi.InviteOnly {
+		t.Errorf("row 2 vote: %+v", vi)
+	}
+
+	r = rows[1]
+	if len(r.errors) > 0 || len(r.key) != 64 || r.voteinit.SelectType != model.SelectSingle ||
+		r.voteinit.StartTime != "1792400000" {
+		t.Errorf("row 3: %+v", r)
+	}
+
+	wantFields := map[int][]string{
+		5: {"类型", "开始时间", "结果可见性", "受邀用户", "受邀用户", "仅受邀用户可投票"},
+		6: {"编号"},
+		7: {"结束时间"},
+	}
+	for _, r := range rows[2:] {
+		var fields []string
+		for _, e := range r.errors {
+			fields = append(fields, e.Field)
+		}
+		if !reflect.DeepEqual(fields, wantFields[r.row]) {
+			t.Errorf("row %d: errors %v, want fields %v", r.row, r.errors, wantFields[r.row])
+		}
+	}
+}
+
+func TestParseImportSheetInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"not xlsx", []byte("title,options\n")},
+		{"header only", importSheetBytes(t, nil)},
+	}
+	for _, tt := range tests {
+		if _, err := parseImportSheet(bytes.NewReader(tt.data), int64(len(tt.data))); err == nil {
+			t.Errorf("%s: parsed", tt.name)
+		}
+	}
+}
+
+func TestImportStale(t *testing.T) {
+	now := time.Date(2026, 10, 19, 12, 0, 0, 0, time.Local)
+	format := func(d time.Duration) string { return now.Add(-d).Format("2006-01-02 15:04:05") }
+	tests := []struct {
+		name string
+		vi   model.VoteImport
+		want bool
+	}{
+		{"running recently", model.VoteImport{Status: model.ImportRunning, UpdatedAt: format(time.Minute)}, false},
+		{"running too long", model.VoteImport{Status: model.ImportRunning, UpdatedAt: format(importClaimTimeout + time.Minute)}, true},
+		{"done long ago", model.VoteImport{Status: model.ImportDone, UpdatedAt: format(24 * time.Hour)}, false},
+	}
+	for _, tt := range tests {
+		if got := importStale(&tt.vi, now); got != tt.want {
+			t.Errorf("%s: importStale = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
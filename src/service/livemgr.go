@@ -0,0 +1,306 @@
+package service
+
+import (
+	"FunnyVoteGo/src/api/vm"
+	"FunnyVoteGo/src/constant"
+	"FunnyVoteGo/src/lib/eventbus"
+	"FunnyVoteGo/src/model"
+	"encoding/json"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/glog"
+	"github.com/hyperchain/gosdk/utils/ecdsa"
+	"github.com/spf13/viper"
+	melody "gopkg.in/olahol/melody.v1"
+)
+
+const (
+	liveSubKey  = "live_subscription"
+	liveUserKey = "live_user"
+)
+
+// liveSubscription vote ids subscribed by a session
+type liveSubscription struct {
+	mu      sync.RWMutex
+	voteIDs map[string]bool
+}
+
+func (ls *liveSubscription) add(voteids []string) {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+	for _, id := range voteids {
+		ls.voteIDs[id] = true
+	}
+}
+
+func (ls *liveSubscription) remove(voteids []string) {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+	for _, id := range voteids {
+		delete(ls.voteIDs, id)
+	}
+}
+
+func (ls *liveSubscription) has(voteid string) bool {
+	ls.mu.RLock()
+	defer ls.mu.RUnlock()
+	return ls.voteIDs[voteid]
+}
+
+func (ls *liveSubscription) list() []string {
+	ls.mu.RLock()
+	defer ls.mu.RUnlock()
+	var ids []string
+	for id := range ls.voteIDs {
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+var (
+	liveMelody *melody.Melody
+	liveSeqMu  sync.Mutex
+	liveSeq    = make(map[string]uint64)
+)
+
+// InitLiveStream bind handlers of live result stream
+func InitLiveStream(mg *melody.Melody) {
+	liveMelody = mg
+	mg.HandleConnect(handleLiveConnect)
+	mg.HandleMessage(handleLiveMessage)
+	eventbus.Subscribe(constant.EventBallotCast, func(e eventbus.Event) {
+		PublishVoteResult(e.VoteID)
+	})
+	eventbus.Subscribe(constant.EventVoteFinalized, func(e eventbus.Event) {
+		PublishVoteResult(e.VoteID)
+	})
+	// 结束后可见的结果在投票结束时推送
+	eventbus.Subscribe(constant.EventVoteClosed, func(e eventbus.Event) {
+		PublishVoteResult(e.VoteID)
+	})
+
+	interval := viper.GetInt("live.heartbeat")
+	if interval <= 0 {
+		interval = 30
+	}
+	go liveHeartbeat(time.Duration(interval) * time.Second)
+}
+
+// NewLiveKeys return keys of a new live session, userid is 0 for anonymous session
+func NewLiveKeys(userid uint) map[string]interface{} {
+	return map[string]interface{}{
+		liveSubKey:  &liveSubscription{voteIDs: make(map[string]bool)},
+		liveUserKey: userid,
+	}
+}
+
+// PublishVoteResult push the latest result of the vote to its subscribers
+func PublishVoteResult(voteid string) {
+	if liveMelody == nil {
+		return
+	}
+	key, err := InitKey()
+	if err != nil {
+		glog.Error(err)
+		return
+	}
+	result, vs, b := buildLiveResult(voteid, key)
+	if !b {
+		return
+	}
+	lm := vm.LiveMessage{
+		Type:   "result",
+		VoteID: voteid,
+		Seq:    nextLiveSeq(voteid),
+		Time:   time.Now().Unix(),
+		Data:   result,
+	}
+	msg, err := json.Marshal(lm)
+	if err != nil {
+		glog.Error(err)
+		return
+	}
+	lm.Data = hideLiveResult(result)
+	hiddenMsg, err := json.Marshal(lm)
+	if err != nil {
+		glog.Error(err)
+		return
+	}
+
+	// 按会话用户的结果可见性分别推送
+	var mu sync.Mutex
+	visible := make(map[uint]bool)
+	canSee := func(s *melody.Session) bool {
+		if vs == nil {
+			return true
+		}
+		userid := getLiveUser(s)
+		mu.Lock()
+		defer mu.Unlock()
+		v, ok := visible[userid]
+		if !ok {
+			v = resultVisible(vs, userid)
+			visible[userid] = v
+		}
+		return v
+	}
+	liveMelody.BroadcastFilter(msg, func(s *melody.Session) bool {
+		sub := getLiveSubscription(s)
+		return sub != nil && sub.has(voteid) && canSee(s)
+	})
+	liveMelody.BroadcastFilter(hiddenMsg, func(s *melody.Session) bool {
+		sub := getLiveSubscription(s)
+		return sub != nil && sub.has(voteid) && !canSee(s)
+	})
+}
+
+// handleLiveConnect subscribe vote ids in query, used by reconnecting clients
+func handleLiveConnect(s *melody.Session) {
+	voteids := s.Request.URL.Query().Get("vote_ids")
+	if voteids == "" {
+		return
+	}
+	ids := strings.Split(voteids, ",")
+	getLiveSubscription(s).add(ids)
+	sendLiveSnapshot(s, ids)
+}
+
+func handleLiveMessage(s *melody.Session, msg []byte) {
+	var req vm.LiveRequest
+	if err := json.Unmarshal(msg, &req); err != nil {
+		writeLiveMessage(s, vm.LiveMessage{Type: "error", Message: "参数错误"})
+		return
+	}
+	sub := getLiveSubscription(s)
+	switch req.Action {
+	case "subscribe":
+		sub.add(req.VoteIDs)
+		sendLiveSnapshot(s, req.VoteIDs)
+	case "unsubscribe":
+		sub.remove(req.VoteIDs)
+	case "resync":
+		sendLiveSnapshot(s, sub.list())
+	default:
+		writeLiveMessage(s, vm.LiveMessage{Type: "error", Message: "不支持的操作"})
+	}
+}
+
+// sendLiveSnapshot send current results to one session
+func sendLiveSnapshot(s *melody.Session, voteids []string) {
+	key, err := InitKey()
+	if err != nil {
+		glog.Error(err)
+		return
+	}
+	for _, voteid := range voteids {
+		result, vs, b := buildLiveResult(voteid, key)
+		if !b {
+			writeLiveMessage(s, vm.LiveMessage{Type: "error", VoteID: voteid, Message: "投票不存在"})
+			continue
+		}
+		if vs != nil && !resultVisible(vs, getLiveUser(s)) {
+			result = hideLiveResult(result)
+		}
+		writeLiveMessage(s, vm.LiveMessage{
+			Type:   "result",
+			VoteID: voteid,
+			Seq:    currentLiveSeq(voteid),
+			Data:   result,
+		})
+	}
+}
+
+func liveHeartbeat(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		if liveMelody.IsClosed() {
+			return
+		}
+		msg, _ := json.Marshal(vm.LiveMessage{Type: "heartbeat", Time: time.Now().Unix()})
+		liveMelody.Broadcast(msg)
+	}
+}
+
+// buildLiveResult query totals and turnout, returns setting of the vote to check visibility, nil if not found
+func buildLiveResult(voteid string, key *ecdsa.Key) (*vm.LiveResult, *model.VoteSetting, bool) {
+	options, b := queryVoteOptions(voteid, key)
+	if !b {
+		return nil, nil, false
+	}
+	turnout, _ := model.CountVoteUsers(voteid)
+	result := vm.LiveResult{
+		Status:  2,
+		Turnout: turnout,
+	}
+	vs, b := model.GetVoteSetting(map[string]interface{}{"vote_id": voteid})
+	if b {
+		result.Status = voteStatus(vs.StartTime, vs.EndTime)
+	} else {
+		vs = nil
+	}
+	for _, option := range options {
+		result.Options = append(result.Options, vm.LiveOption{
+			ID:      option.ID,
+			Content: option.Content,
+			Total:   option.Total,
+		})
+	}
+	return &result, vs, true
+}
+
+// hideLiveResult copy of the result without totals
+func hideLiveResult(result *vm.LiveResult) *vm.LiveResult {
+	hidden := *result
+	hidden.Hidden = true
+	hidden.Options = nil
+	for _, option := range result.Options {
+		option.Total = 0
+		hidden.Options = append(hidden.Options, option)
+	}
+	return &hidden
+}
+
+func writeLiveMessage(s *melody.Session, lm vm.LiveMessage) {
+	if lm.Time == 0 {
+		lm.Time = time.Now().Unix()
+	}
+	msg, err := json.Marshal(lm)
+	if err != nil {
+		glog.Error(err)
+		return
+	}
+	s.Write(msg)
+}
+
+func getLiveSubscription(s *melody.Session) *liveSubscription {
+	v, ok := s.Get(liveSubKey)
+	if !ok {
+		return nil
+	}
+	return v.(*liveSubscription)
+}
+
+func getLiveUser(s *melody.Session) uint {
+	v, ok := s.Get(liveUserKey)
+	if !ok {
+		return 0
+	}
+	userid, _ := v.(uint)
+	return userid
+}
+
+func nextLiveSeq(voteid string) uint64 {
+	liveSeqMu.Lock()
+	defer liveSeqMu.Unlock()
+	liveSeq[voteid]++
+	return liveSeq[voteid]
+}
+
+func currentLiveSeq(voteid string) uint64 {
+	liveSeqMu.Lock()
+	defer liveSeqMu.Unlock()
+	return liveSeq[voteid]
+}
@@ -0,0 +1,158 @@
+package service
+
+import (
+	"FunnyVoteGo/src/lib/mqconsumer"
+	"FunnyVoteGo/src/model"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/glog"
+	"github.com/hyperchain/gosdk/abi"
+	"github.com/hyperchain/gosdk/common"
+	"github.com/hyperchain/gosdk/rpc"
+	"github.com/spf13/viper"
+	"github.com/streadway/amqp"
+)
+
+const mqCursorName = "mq_event"
+
+// StartMqConsumer register a queue of contract events and blocks, then consume it.
+// used when websocket subscription is not allowed
+func StartMqConsumer() {
+	if !viper.GetBool("mq.enable") {
+		return
+	}
+	go func() {
+		ABI := prepareMqConsumer()
+		consumer := &mqconsumer.Consumer{
+			URL:      viper.GetString("mq.broker_url"),
+			Queue:    viper.GetString("mq.queue"),
+			Tag:      viper.GetString("name"),
+			Prefetch: viper.GetInt("mq.prefetch"),
+			Handler: func(d amqp.Delivery) error {
+				return handleMqDelivery(ABI, d)
+			},
+		}
+		consumer.Run(nil)
+	}()
+}
+
+// prepareMqConsumer register queue and backfill gap, retry until success
+func prepareMqConsumer() *abi.ABI {
+	backoff := time.Second
+	for {
+		ABI, err := registerMqQueue()
+		if err == nil {
+			return ABI
+		}
+		glog.Errorf("prepare mq consumer fail: %v", err)
+		time.Sleep(backoff)
+		if backoff *= 2; backoff > maxBackoff {
+			backoff = maxBackoff
+		}
+	}
+}
+
+func registerMqQueue() (*abi.ABI, error) {
+	ABI, err := GetContractABI()
+	if err != nil {
+		return nil, err
+	}
+	if !viper.GetBool("mq.register") {
+		// queue is managed outside, e.g. local broker
+		return ABI, nil
+	}
+	hpc := rpc.NewRPCWithPath("./conf/chain_SDK/conf")
+	if hpc == nil {
+		return nil, fmt.Errorf("初始化rpc失败")
+	}
+	// events before the queue exists
+	if err := backfillContractEvents(hpc, ABI, mqCursorName); err != nil {
+		return nil, err
+	}
+	client := hpc.GetMqClient()
+	if client == nil {
+		return nil, fmt.Errorf("初始化mq客户端失败")
+	}
+	node := uint(viper.GetInt("mq.node"))
+	if node == 0 {
+		node = 1
+	}
+	queue := viper.GetString("mq.queue")
+	names, stdErr := client.GetAllQueueNames(node)
+	if stdErr != nil {
+		return nil, stdErr
+	}
+	for _, name := range names {
+		if name == queue {
+			return ABI, nil
+		}
+	}
+	key, err := InitKey()
+	if err != nil {
+		return nil, err
+	}
+	meta := rpc.NewRegisterMeta(key.GetAddress(), queue, rpc.MQLog, rpc.MQBlock).
+		AddAddress(common.HexToAddress(ContractAddress()))
+	meta.Sign(key)
+	reg, stdErr := client.Register(node, meta)
+	if stdErr != nil {
+		return nil, stdErr
+	}
+	glog.Infof("register mq queue %s on exchange %s", reg.QueueName, reg.ExchangerName)
+	return ABI, nil
+}
+
+// handleMqDelivery decode a block or log message, logs before the cursor were handled already
+func handleMqDelivery(ABI *abi.ABI, d amqp.Delivery) error {
+	if d.RoutingKey == string(rpc.MQBlock) {
+		var block struct {
+			Number json.RawMessage `json:"number"`
+		}
+		if err := json.Unmarshal(d.Body, &block); err != nil {
+			glog.Errorf("unknown mq block: %s", string(d.Body))
+			return nil
+		}
+		number, err := parseBlockNumber(block.Number)
+		if err != nil {
+			glog.Errorf("unknown mq block: %s", string(d.Body))
+			return nil
+		}
+		if !model.SaveChainCursor(mqCursorName, number) {
+			return fmt.Errorf("save mq cursor fail")
+		}
+		return nil
+	}
+
+	logs, err := parseChainLogs(d.Body)
+	if err != nil {
+		glog.Errorf("unknown mq log: %s", string(d.Body))
+		return nil
+	}
+	var offset uint64
+	if cursor, b := model.GetChainCursor(mqCursorName); b {
+		offset = cursor.BlockNumber
+	}
+	for _, l := range logs {
+		if l.BlockNumber < offset {
+			continue
+		}
+		handleContractLog(ABI, l, mqCursorName)
+	}
+	return nil
+}
+
+// parseBlockNumber parse number or hex/decimal string
+func parseBlockNumber(raw json.RawMessage) (uint64, error) {
+	var n uint64
+	if err := json.Unmarshal(raw, &n); err == nil {
+		return n, nil
+	}
+	var s string
+	if err := json.Unmarshal(raw, &s); err != nil {
+		return 0, err
+	}
+	return strconv.ParseUint(s, 0, 64)
+}
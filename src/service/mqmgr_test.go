@@ -0,0 +1,143 @@
+package service
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/hyperchain/gosdk/abi"
+	"github.com/hyperchain/gosdk/common"
+	"github.com/hyperchain/gosdk/rpc"
+)
+
+// testContractABI events and methods of vote contract used by tests, the real abi is compiled by the node
+const testContractABI = `[
+{"type":"event","name":"BallotCast","inputs":[
+	{"name":"vote_id","type":"bytes32","indexed":true},
+	{"name":"option_id","type":"bytes32","indexed":false},
+	{"name":"user_id","type":"bytes32","indexed":false},
+	{"name":"total","type":"int32","indexed":false}]},
+{"type":"event","name":"DelegationResolved","inputs":[
+	{"name":"vote_id","type":"bytes32","indexed":true},
+	{"name":"delegator_id","type":"bytes32","indexed":false},
+	{"name":"delegate_id","type":"bytes32","indexed":false},
+	{"name":"option_id","type":"bytes32","indexed":false},
+	{"name":"total","type":"int32","indexed":false}]},
+{"type":"event","name":"VoteFinalized","inputs":[
+	{"name":"vote_id","type":"bytes32","indexed":true},
+	{"name":"winner_id","type":"bytes32","indexed":false},
+	{"name":"winner_total","type":"int32","indexed":false}]},
+{"type":"function","name":"finalizeVote","inputs":[{"name":"id","type":"bytes32"}],
+	"outputs":[{"name":"","type":"int32"},{"name":"","type":"bytes"}]},
+{"type":"function","name":"queryFinalized","inputs":[{"name":"id","type":"bytes32"}],
+	"outputs":[{"name":"","type":"int32"},{"name":"","type":"bool"}]}
+]`
+
+func testABI(t *testing.T) *abi.ABI {
+	ABI, err := abi.JSON(strings.NewReader(testContractABI))
+	if err != nil {
+		t.Fatalf("parse test abi: %v", err)
+	}
+	return &ABI
+}
+
+func b32(s string) [32]byte {
+	var b [32]byte
+	copy(b[:], s)
+	return b
+}
+
+// testLog log of an event of testContractABI with the vote id as topic
+func testLog(t *testing.T, ABI *abi.ABI, name, voteid string, args ...interface{}) rpc.TxLog {
+	event := ABI.Events[name]
+	data, err := event.Inputs.NonIndexed().Pack(args...)
+	if err != nil {
+		t.Fatalf("pack %s: %v", name, err)
+	}
+	vote := b32(voteid)
+	return rpc.TxLog{
+		Topics:      []string{event.Id().Hex(), common.ToHex(vote[:])},
+		Data:        common.ToHex(data),
+		BlockNumber: 7,
+		TxHash:      "0xabc",
+	}
+}
+
+func TestParseBlockNumber(t *testing.T) {
+	tests := []struct {
+		raw     string
+		want    uint64
+		wantErr bool
+	}{
+		{`12`, 12, false},
+		{`"12"`, 12, false},
+		{`"0x1f"`, 31, false},
+		{`"abc"`, 0, true},
+		{`null`, 0, false},
+		{`{}`, 0, true},
+	}
+	for _, tt := range tests {
+		got, err := parseBlockNumber(json.RawMessage(tt.raw))
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("parseBlockNumber(%s) = %d, %v, want %d, err %v", tt.raw, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestParseChainLogs(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		want    []uint64
+		wantErr bool
+	}{
+		{"single", `{"blockNumber":3,"txHash":"0x1"}`, []uint64{3}, false},
+		{"array", `[{"blockNumber":3},{"blockNumber":4}]`, []uint64{3, 4}, false},
+		{"empty array", `[]`, nil, false},
+		{"invalid", `"block"`, nil, true},
+	}
+	for _, tt := range tests {
+		logs, err := parseChainLogs([]byte(tt.data))
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: err %v, want err %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if len(logs) != len(tt.want) {
+			t.Errorf("%s: %d logs, want %d", tt.name, len(logs), len(tt.want))
+			continue
+		}
+		for i, l := range logs {
+			if l.BlockNumber != tt.want[i] {
+				t.Errorf("%s: log %d in block %d, want %d", tt.name, i, l.BlockNumber, tt.want[i])
+			}
+		}
+	}
+}
+
+func TestDecodeContractLog(t *testing.T) {
+	ABI := testABI(t)
+	l := testLog(t, ABI, "BallotCast", "vote1", b32("opt1"), b32("user1"), int32(5))
+	l.Index = 2
+	e, ok := decodeContractLog(ABI, l)
+	if !ok {
+		t.Fatal("BallotCast not decoded")
+	}
+	if e.Name != "BallotCast" || e.VoteID != "vote1" || e.BlockNumber != 7 || e.TxHash != "0xabc" || e.LogIndex != 2 {
+		t.Errorf("decoded %+v", e)
+	}
+	if e.Data["option_id"] != "opt1" || e.Data["user_id"] != "user1" || e.Data["total"] != 5 {
+		t.Errorf("decoded data %v", e.Data)
+	}
+
+	if _, ok := decodeContractLog(ABI, rpc.TxLog{Topics: []string{"0x01"}}); ok {
+		t.Error("unknown topic decoded")
+	}
+	if _, ok := decodeContractLog(ABI, rpc.TxLog{}); ok {
+		t.Error("log without topics decoded")
+	}
+	bad := l
+	bad.Data = "0x01"
+	if _, ok := decodeContractLog(ABI, bad); ok {
+		t.Error("truncated data decoded")
+	}
+}
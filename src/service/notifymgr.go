@@ -0,0 +1,211 @@
+package service
+
+import (
+	"FunnyVoteGo/src/constant"
+	"FunnyVoteGo/src/lib/eventbus"
+	"FunnyVoteGo/src/lib/mailer"
+	"FunnyVoteGo/src/lib/worker"
+	"FunnyVoteGo/src/model"
+	"bytes"
+	htmltemplate "html/template"
+	"os"
+	"path/filepath"
+	"strings"
+	texttemplate "text/template"
+	"time"
+
+	"github.com/glog"
+	"github.com/spf13/viper"
+)
+
+// kinds of notification, also names of mail templates
+const (
+	mailVoteOpened    = "vote_opened"
+	mailVoteClosed    = "vote_closed"
+	mailVoteFinalized = "vote_finalized"
+	mailPetitionDone  = "petition_completed"
+	mailVoteReminder  = "vote_reminder"
+	mailVoteInvite    = "vote_invite"
+	mailBallotToken   = "ballot_token"
+)
+
+// mailData fields used by mail templates
+type mailData struct {
+	UserName  string
+	VoteID    string
+	Title     string
+	StartTime string
+	EndTime   string
+	WinnerID  string
+	Code      string
+}
+
+// InitNotification email creators on vote events and remind invitees before end time
+func InitNotification() {
+	if !viper.GetBool("mail.enable") {
+		return
+	}
+	eventbus.Subscribe(constant.EventVoteOpened, func(e eventbus.Event) {
+		notifyCreator(mailVoteOpened, e)
+	})
+	eventbus.Subscribe(constant.EventVoteClosed, func(e eventbus.Event) {
+		notifyCreator(mailVoteClosed, e)
+	})
+	eventbus.Subscribe(constant.EventVoteFinalized, func(e eventbus.Event) {
+		notifyCreator(mailVoteFinalized, e)
+	})
+	eventbus.Subscribe(constant.EventPetitionCompleted, func(e eventbus.Event) {
+		notifyCreator(mailPetitionDone, e)
+	})
+	if err := scheduler.AddFunc("@every 1m", checkVoteReminders); err != nil {
+		glog.Errorf("add schedule job fail: %v", err)
+	}
+}
+
+// GetNotifications get notifications of the vote by its creator or admins, they contain emails of invitees
+func GetNotifications(voteid string, userid uint) ([]model.Notification, bool) {
+	if !canManageVote(voteid, userid) {
+		glog.Errorf("user %d can not read notifications of vote %s", userid, voteid)
+		return nil, false
+	}
+	return model.GetNotifications(map[string]interface{}{"vote_id": voteid})
+}
+
+func notifyCreator(kind string, e eventbus.Event) {
+	vs, b := model.GetVoteSetting(map[string]interface{}{"vote_id": e.VoteID})
+	if !b {
+		return
+	}
+	data := newMailData(vs)
+	if winner, ok := e.Data["winner_id"].(string); ok {
+		data.WinnerID = winner
+	}
+	sendVoteMail(kind, vs.CreatorID, data)
+}
+
+// checkVoteReminders remind invitees of votes ending soon once
+func checkVoteReminders() {
+	before := viper.GetInt64("mail.reminder_before")
+	if before <= 0 {
+		before = 3600
+	}
+	now := time.Now().Unix()
+	vss, b := model.GetVoteSettings("reminded = ? AND closed = ? AND end_time > ? AND end_time <= ?", false, false, now, now+before)
+	if !b {
+		return
+	}
+	for i := range vss {
+		if !model.UpdateVoteSetting(vss[i].ID, map[string]interface{}{"reminded": true}) {
+			continue
+		}
+		remindInvitees(&vss[i])
+	}
+}
+
+// remindInvitees email invitees who have not voted
+func remindInvitees(vs *model.VoteSetting) {
+	invitees, b := model.GetVoteInviteeIDs(vs.VoteID)
+	if !b || len(invitees) == 0 {
+		return
+	}
+	votedIDs, b := model.GetVotedUserIDs(vs.VoteID)
+	if !b {
+		return
+	}
+	voted := make(map[uint]bool)
+	for _, id := range votedIDs {
+		voted[id] = true
+	}
+	data := newMailData(vs)
+	for _, id := range invitees {
+		if voted[id] {
+			continue
+		}
+		sendVoteMail(mailVoteReminder, id, data)
+	}
+}
+
+// sendVoteMail send mail of the kind to a user
+func sendVoteMail(kind string, userid uint, data mailData) {
+	user, b := model.GetUser(map[string]interface{}{"id": userid})
+	if !b || user.Email == "" {
+		return
+	}
+	data.UserName = user.UserName
+	sendMail(kind, userid, user.Email, data)
+}
+
+// sendMail render mail of the kind, record it and queue sending through worker
+func sendMail(kind string, userid uint, email string, data mailData) {
+	subject, text, html, err := renderMail(kind, data)
+	if err != nil {
+		glog.Errorf("render mail %s fail: %v", kind, err)
+		return
+	}
+	n, b := model.CreateNotification(&model.Notification{
+		Kind:    kind,
+		VoteID:  data.VoteID,
+		UserID:  userid,
+		Email:   email,
+		Subject: subject,
+		Status:  model.DeliveryPending,
+	})
+	if !b {
+		return
+	}
+	worker.SubmitFunc(func() error {
+		if err := mailer.Send(n.Email, subject, text, html); err != nil {
+			model.UpdateNotification(n.ID, map[string]interface{}{
+				"status": model.DeliveryFailed,
+				"error":  err.Error(),
+			})
+			return err
+		}
+		model.UpdateNotification(n.ID, map[string]interface{}{
+			"status": model.DeliverySuccess,
+		})
+		return nil
+	})
+}
+
+// renderMail render subject and text body by <kind>.txt, html body by <kind>.html if exists
+func renderMail(kind string, data mailData) (string, string, string, error) {
+	dir := viper.GetString("mail.templates")
+	if dir == "" {
+		dir = "./conf/mail"
+	}
+	tt, err := texttemplate.ParseFiles(filepath.Join(dir, kind+".txt"))
+	if err != nil {
+		return "", "", "", err
+	}
+	var subject, text bytes.Buffer
+	if err := tt.ExecuteTemplate(&subject, "subject", data); err != nil {
+		return "", "", "", err
+	}
+	if err := tt.ExecuteTemplate(&text, "body", data); err != nil {
+		return "", "", "", err
+	}
+
+	htmlPath := filepath.Join(dir, kind+".html")
+	if _, err := os.Stat(htmlPath); err != nil {
+		return strings.TrimSpace(subject.String()), text.String(), "", nil
+	}
+	ht, err := htmltemplate.ParseFiles(htmlPath)
+	if err != nil {
+		return "", "", "", err
+	}
+	var html bytes.Buffer
+	if err := ht.Execute(&html, data); err != nil {
+		return "", "", "", err
+	}
+	return strings.TrimSpace(subject.String()), text.String(), html.String(), nil
+}
+
+func newMailData(vs *model.VoteSetting) mailData {
+	return mailData{
+		VoteID:    vs.VoteID,
+		Title:     vs.Title,
+		StartTime: time.Unix(vs.StartTime, 0).Format("2006-01-02 15:04"),
+		EndTime:   time.Unix(vs.EndTime, 0).Format("2006-01-02 15:04"),
+	}
+}
@@ -0,0 +1,44 @@
+package service
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+func TestRenderMail(t *testing.T) {
+	viper.Set("mail.templates", "../../conf/mail")
+	defer viper.Reset()
+
+	data := mailData{
+		UserName: "alice",
+		VoteID:   "vote1",
+		Title:    "<午餐>",
+		EndTime:  "2026-10-20 12:00:00",
+		WinnerID: "opt1",
+		Code:     "ABCD1234",
+	}
+	kinds := []string{mailVoteOpened, mailVoteClosed, mailVoteFinalized, mailPetitionDone, mailVoteReminder,
+		mailVoteInvite, mailBallotToken}
+	for _, kind := range kinds {
+		subject, text, html, err := renderMail(kind, data)
+		if err != nil {
+			t.Errorf("%s: %v", kind, err)
+			continue
+		}
+		if subject == "" || strings.Contains(subject, "\n") {
+			t.Errorf("%s: bad subject %q", kind, subject)
+		}
+		if !strings.Contains(text, "vote1") && !strings.Contains(text, "ABCD1234") {
+			t.Errorf("%s: text body without vote or code:\n%s", kind, text)
+		}
+		if strings.Contains(html, "<午餐>") {
+			t.Errorf("%s: html body does not escape title", kind)
+		}
+	}
+
+	if _, _, _, err := renderMail("no_such_kind", data); err == nil {
+		t.Error("missing template rendered")
+	}
+}
@@ -0,0 +1,430 @@
+package service
+
+import (
+	"FunnyVoteGo/src/api/vm"
+	"FunnyVoteGo/src/constant"
+	"FunnyVoteGo/src/lib/eventbus"
+	"FunnyVoteGo/src/model"
+	"FunnyVoteGo/src/util"
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/glog"
+	"github.com/hyperchain/gosdk/abi"
+	"github.com/hyperchain/gosdk/utils/ecdsa"
+)
+
+const (
+	// defaultPairsPerVoter comparisons a voter makes if not set
+	defaultPairsPerVoter = 20
+	// maxPairsPerVoter most comparisons a voter can be asked for
+	maxPairsPerVoter = 200
+	// btMaxIterations and btTolerance stop fitting of Bradley-Terry strengths
+	btMaxIterations = 1000
+	btTolerance     = 1e-9
+	// eloBase and eloScale map strengths to ratings, a strength 10 times greater is 400 points higher
+	eloBase  = 1500
+	eloScale = 400
+)
+
+// errPairwiseBallot pairwise poll is answered by ComparePair only
+var errPairwiseBallot = fmt.Errorf("两两比较投票须按服务端给出的选项对比较")
+
+// NextPair serve the voter a pair of options, an unanswered pair is served again, new pairs prefer
+// options the voter has seen least, pairs are never repeated for the same voter
+func NextPair(pn *vm.PairwiseNext) (_ *vm.PairwisePair, err error) {
+	vs, err := runningPairwise(pn.VoteID)
+	if err != nil {
+		return nil, err
+	}
+	comparisons, b := model.GetComparisons(pn.VoteID, pn.UserID)
+	if !b {
+		return nil, errBallotFail
+	}
+	// 首次出题时核验资格并使用邀请码
+	release := func() {}
+	if len(comparisons) == 0 {
+		if release, err = admitBallot(pn.VoteID, pn.UserID, pn.InviteCode); err != nil {
+			return nil, err
+		}
+	} else if err = checkEligibility(vs, pn.UserID); err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err != nil {
+			release()
+		}
+	}()
+
+	key, err := InitKey()
+	if err != nil {
+		return nil, err
+	}
+	options, b := queryVoteOptions(pn.VoteID, key)
+	if !b {
+		return nil, errBallotFail
+	}
+	contents := make(map[string]string)
+	for _, option := range options {
+		contents[option.ID] = option.Content
+	}
+	pair := vm.PairwisePair{VoteID: pn.VoteID, Quota: vs.PairsPerVoter}
+	for _, c := range comparisons {
+		if !c.Answered {
+			pair.ComparisonID = c.ID
+			pair.OptionA = vm.PairwiseOption{OptionID: c.OptionA, Content: contents[c.OptionA]}
+			pair.OptionB = vm.PairwiseOption{OptionID: c.OptionB, Content: contents[c.OptionB]}
+		} else {
+			pair.Answered++
+		}
+	}
+	if pair.ComparisonID != 0 {
+		return &pair, nil
+	}
+	if pair.Answered >= vs.PairsPerVoter {
+		pair.Done = true
+		return &pair, nil
+	}
+
+	a, bb, ok := pickPair(options, comparisons)
+	if !ok {
+		pair.Done = true
+		return &pair, nil
+	}
+	c := model.Comparison{VoteID: pn.VoteID, UserID: pn.UserID, OptionA: a.ID, OptionB: bb.ID}
+	if _, b := model.CreateComparison(&c); !b {
+		return nil, errBallotFail
+	}
+	pair.ComparisonID = c.ID
+	pair.OptionA = vm.PairwiseOption{OptionID: a.ID, Content: a.Content}
+	pair.OptionB = vm.PairwiseOption{OptionID: bb.ID, Content: bb.Content}
+	return &pair, nil
+}
+
+// ComparePair record the pick of the voter on a served pair on chain
+func ComparePair(pc *vm.PairwiseChoice) error {
+	c, b := model.GetComparison(pc.ComparisonID)
+	if !b {
+		return errBallotFail
+	}
+	if c == nil || c.UserID != pc.UserID {
+		return fmt.Errorf("选项对不存在")
+	}
+	if c.Answered {
+		return fmt.Errorf("该选项对已比较")
+	}
+	vs, err := runningPairwise(c.VoteID)
+	if err != nil {
+		return err
+	}
+	if err := checkEligibility(vs, pc.UserID); err != nil {
+		return err
+	}
+	winner, loser := chainID(pc.WinnerID), ""
+	switch winner {
+	case c.OptionA:
+		loser = c.OptionB
+	case c.OptionB:
+		loser = c.OptionA
+	default:
+		return &ContentError{Field: "winner_id", Reason: "须从选项对中选择"}
+	}
+	// 先占用选项对, 避免重复提交
+	if !model.AnswerComparison(c.ID, winner) {
+		return fmt.Errorf("该选项对已比较")
+	}
+
+	ballotid := chainID(util.StringUUID())
+	txhash, err := invokeMethod("compareOptions", map[string]string{
+		"id":          ballotid,
+		"vote_id":     c.VoteID,
+		"user_id":     strconv.Itoa(int(pc.UserID)),
+		"winner_id":   winner,
+		"loser_id":    loser,
+		"create_time": util.GetNowTimeString(),
+	})
+	if err != nil {
+		glog.Errorf("compare options of vote %s by user %d fail: %v", c.VoteID, pc.UserID, err)
+		// 仅在链上未记录时释放选项对, 之后的失败不影响已上链的比较
+		model.UpdateComparison(c.ID, map[string]interface{}{"answered": false, "winner_id": ""})
+		return errBallotFail
+	}
+	model.UpdateComparison(c.ID, map[string]interface{}{"tx_hash": txhash})
+	if _, b := model.CreateHashRecord(&model.HashRecord{
+		VoteID:   c.VoteID,
+		UserID:   pc.UserID,
+		OptionID: winner,
+		BallotID: ballotid,
+		TxHash:   txhash,
+	}); !b {
+		return errBallotFail
+	}
+	publishLocalEvent(eventbus.Event{
+		Name:   constant.EventOptionsCompared,
+		VoteID: c.VoteID,
+		TxHash: txhash,
+		Data: map[string]interface{}{
+			"vote_id":   c.VoteID,
+			"user_id":   strconv.Itoa(int(pc.UserID)),
+			"winner_id": winner,
+			"loser_id":  loser,
+		},
+	})
+	publishLocalEvent(eventbus.Event{
+		Name:   constant.EventBallotCast,
+		VoteID: c.VoteID,
+		TxHash: txhash,
+		Data: map[string]interface{}{
+			"vote_id":   c.VoteID,
+			"option_id": winner,
+			"user_id":   strconv.Itoa(int(pc.UserID)),
+		},
+	})
+	return nil
+}
+
+// GetPairwiseRanking rank options of a pairwise poll by Bradley-Terry model fitted on comparisons on chain,
+// the ranking is hidden by result visibility
+func GetPairwiseRanking(voteid string, userid uint) (*vm.PairwiseRanking, error) {
+	vs, b := model.GetVoteSetting(map[string]interface{}{"vote_id": voteid})
+	if !b || !vs.Pairwise {
+		return nil, fmt.Errorf("该投票不是两两比较投票")
+	}
+	ranking := vm.PairwiseRanking{VoteID: voteid, Model: "bradley-terry"}
+	if !resultVisible(vs, userid) {
+		ranking.Hidden = true
+		return &ranking, nil
+	}
+	key, err := InitKey()
+	if err != nil {
+		return nil, err
+	}
+	options, b := queryVoteOptions(voteid, key)
+	if !b {
+		return nil, fmt.Errorf("查询选项失败")
+	}
+	winners, losers, b := queryComparisons(voteid, key)
+	if !b {
+		return nil, fmt.Errorf("查询比较记录失败")
+	}
+
+	index := make(map[string]int)
+	for i, option := range options {
+		index[option.ID] = i
+	}
+	n := len(options)
+	wins := make([][]float64, n)
+	for i := range wins {
+		wins[i] = make([]float64, n)
+	}
+	for i := range winners {
+		w, ok1 := index[winners[i]]
+		l, ok2 := index[losers[i]]
+		if !ok1 || !ok2 || w == l {
+			ranking.Ignored++
+			continue
+		}
+		wins[w][l]++
+		ranking.Comparisons++
+	}
+
+	strengths := bradleyTerry(wins)
+	ses := strengthErrors(wins, strengths)
+	for i, option := range options {
+		rank := vm.PairwiseRank{
+			OptionID: option.ID,
+			Content:  option.Content,
+			Rating:   eloRating(math.Log(strengths[i])),
+			Low:      eloRating(math.Log(strengths[i]) - 1.96*ses[i]),
+			High:     eloRating(math.Log(strengths[i]) + 1.96*ses[i]),
+		}
+		for j := 0; j < n; j++ {
+			rank.Wins += int(wins[i][j])
+			rank.Comparisons += int(wins[i][j] + wins[j][i])
+		}
+		if rank.Comparisons > 0 {
+			rank.WinRate = round2(float64(rank.Wins) / float64(rank.Comparisons))
+		}
+		ranking.Ranking = append(ranking.Ranking, rank)
+	}
+	order := make([]int, n)
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool { return strengths[order[i]] > strengths[order[j]] })
+	sorted := make([]vm.PairwiseRank, n)
+	for r, i := range order {
+		sorted[r] = ranking.Ranking[i]
+		sorted[r].Rank = r + 1
+		if r+1 < n {
+			next := order[r+1]
+			diff := math.Log(strengths[i]) - math.Log(strengths[next])
+			sorted[r].AboveNext = round2(normalCDF(diff / math.Hypot(ses[i], ses[next])))
+		}
+	}
+	ranking.Ranking = sorted
+	return &ranking, nil
+}
+
+// pickPair pick an option the voter has seen least, then its least seen partner not yet compared with it
+func pickPair(options []model.Option, comparisons []model.Comparison) (model.Option, model.Option, bool) {
+	seen := make(map[string]int)
+	paired := make(map[string]bool)
+	for _, c := range comparisons {
+		seen[c.OptionA]++
+		seen[c.OptionB]++
+		paired[c.OptionA+"|"+c.OptionB] = true
+		paired[c.OptionB+"|"+c.OptionA] = true
+	}
+	// 随机打乱后稳定排序, 同样次数的选项随机出现
+	shuffled := make([]model.Option, len(options))
+	copy(shuffled, options)
+	rand.Shuffle(len(shuffled), func(i, j int) { shuffled[i], shuffled[j] = shuffled[j], shuffled[i] })
+	sort.SliceStable(shuffled, func(i, j int) bool { return seen[shuffled[i].ID] < seen[shuffled[j].ID] })
+	for i, a := range shuffled {
+		for _, b := range shuffled[i+1:] {
+			if !paired[a.ID+"|"+b.ID] {
+				return a, b, true
+			}
+		}
+	}
+	return model.Option{}, model.Option{}, false
+}
+
+// bradleyTerry fit strengths by minorization-maximization, wins[i][j] is times i beat j,
+// each option also wins and loses once against a virtual option of strength 1, which keeps
+// strengths finite for options never winning or never losing and anchors the scale
+func bradleyTerry(wins [][]float64) []float64 {
+	n := len(wins)
+	p := make([]float64, n)
+	for i := range p {
+		p[i] = 1
+	}
+	for iter := 0; iter < btMaxIterations; iter++ {
+		next := make([]float64, n)
+		change := 0.0
+		for i := 0; i < n; i++ {
+			w, d := 1.0, 2/(p[i]+1)
+			for j := 0; j < n; j++ {
+				if games := wins[i][j] + wins[j][i]; j != i && games > 0 {
+					w += wins[i][j]
+					d += games / (p[i] + p[j])
+				}
+			}
+			next[i] = w / d
+			change = math.Max(change, math.Abs(math.Log(next[i]/p[i])))
+		}
+		p = next
+		if change < btTolerance {
+			break
+		}
+	}
+	return p
+}
+
+// strengthErrors standard errors of log strengths from the fisher information of each option alone
+func strengthErrors(wins [][]float64, p []float64) []float64 {
+	ses := make([]float64, len(p))
+	for i := range p {
+		// 与虚拟选项的一胜一负
+		info := 2 * p[i] / ((p[i] + 1) * (p[i] + 1))
+		for j := range p {
+			if games := wins[i][j] + wins[j][i]; j != i && games > 0 {
+				info += games * p[i] * p[j] / ((p[i] + p[j]) * (p[i] + p[j]))
+			}
+		}
+		ses[i] = 1 / math.Sqrt(info)
+	}
+	return ses
+}
+
+// eloRating map a log strength to Elo scale
+func eloRating(logStrength float64) float64 {
+	return round2(eloBase + eloScale*logStrength/math.Ln10)
+}
+
+func normalCDF(x float64) float64 {
+	return 0.5 * (1 + math.Erf(x/math.Sqrt2))
+}
+
+func round2(x float64) float64 {
+	return math.Round(x*100) / 100
+}
+
+// runningPairwise vote setting of a running pairwise poll
+func runningPairwise(voteid string) (*model.VoteSetting, error) {
+	vs, b := model.GetVoteSetting(map[string]interface{}{"vote_id": voteid})
+	if !b || !vs.Pairwise {
+		return nil, fmt.Errorf("该投票不是两两比较投票")
+	}
+	if vs.Closed || voteStatus(vs.StartTime, vs.EndTime) != 2 {
+		return nil, &EligibilityError{Reason: "投票未在进行中"}
+	}
+	return vs, nil
+}
+
+// pairsPerVoter comparisons asked from each voter, no more than pairs of options
+func pairsPerVoter(voteinit *vm.VoteInit) int {
+	pairs := len(voteinit.Options) * (len(voteinit.Options) - 1) / 2
+	if voteinit.PairsPerVoter != 0 {
+		pairs = voteinit.PairsPerVoter
+	} else if pairs > defaultPairsPerVoter {
+		pairs = defaultPairsPerVoter
+	}
+	return pairs
+}
+
+// validatePairwise pairwise polls take plain options only
+func validatePairwise(voteinit *vm.VoteInit) error {
+	if !voteinit.Pairwise {
+		if voteinit.PairsPerVoter != 0 {
+			return &ContentError{Field: "pairs_per_voter", Reason: "不是两两比较投票"}
+		}
+		return nil
+	}
+	pairs := len(voteinit.Options) * (len(voteinit.Options) - 1) / 2
+	switch {
+	case len(voteinit.Questions) > 0 || voteinit.Budget != 0 || voteinit.SignatureTarget != 0 || voteinit.RunoffMajority != 0:
+		return &ContentError{Field: "pairwise", Reason: "两两比较投票不支持问卷、预算、请愿和决选"}
+	case len(voteinit.Options) < 2:
+		return &ContentError{Field: "options", Reason: "两两比较投票至少需要2个选项"}
+	case voteinit.PairsPerVoter < 0 || voteinit.PairsPerVoter > maxPairsPerVoter || voteinit.PairsPerVoter > pairs:
+		return &ContentError{Field: "pairs_per_voter", Reason: fmt.Sprintf("比较数须在1到%d之间", int(math.Min(maxPairsPerVoter, float64(pairs))))}
+	}
+	return nil
+}
+
+// queryComparisons query winners and losers of all comparisons of the vote on chain
+func queryComparisons(voteid string, key *ecdsa.Key) ([]string, []string, bool) {
+	retu, err := InvokeContract(vm.ReqInvokeCon{
+		ContractAddr: ContractAddress(),
+		ContractCode: GetContractCode(),
+		MethodName:   "queryComparisons",
+		MethodParams: util.Struct2String(model.Vote{ID: voteid}),
+	}, key)
+	if err != nil {
+		return nil, nil, false
+	}
+	ABI, _ := abi.JSON(strings.NewReader(retu.Abi))
+	var p_ok int32
+	var p_warray [][32]byte
+	var p_larray [][32]byte
+	res := []interface{}{&p_ok, &p_warray, &p_larray}
+	if sysErr := ABI.UnpackResult(&res, "queryComparisons", retu.Result); sysErr != nil {
+		glog.Info(sysErr)
+		return nil, nil, false
+	}
+	if p_ok != 0 {
+		return nil, nil, false
+	}
+	var winners, losers []string
+	for i := range p_warray {
+		winners = append(winners, util.ByteToString(p_warray[i][:]))
+		losers = append(losers, util.ByteToString(p_larray[i][:]))
+	}
+	return winners, losers, true
+}
@@ -0,0 +1,139 @@
+package service
+
+import (
+	"FunnyVoteGo/src/model"
+	"math"
+	"testing"
+)
+
+// btStationary fitted strengths satisfy the update of bradleyTerry, so the fit converged
+func btStationary(wins [][]float64, p []float64) bool {
+	for i := range p {
+		w, d := 1.0, 2/(p[i]+1)
+		for j := range p {
+			if games := wins[i][j] + wins[j][i]; j != i && games > 0 {
+				w += wins[i][j]
+				d += games / (p[i] + p[j])
+			}
+		}
+		if math.Abs(math.Log(w/d/p[i])) > 1e-6 {
+			return false
+		}
+	}
+	return true
+}
+
+func TestBradleyTerry(t *testing.T) {
+	tests := []struct {
+		name  string
+		wins  [][]float64
+		order []int // options from strongest to weakest, ties not listed
+		equal bool
+	}{
+		{"no comparisons", [][]float64{{0, 0}, {0, 0}}, nil, true},
+		{"cycle", [][]float64{{0, 1, 0}, {0, 0, 1}, {1, 0, 0}}, nil, true},
+		{"one sided", [][]float64{{0, 3}, {1, 0}}, []int{0, 1}, false},
+		{"never loses", [][]float64{{0, 5, 5}, {0, 0, 2}, {0, 1, 0}}, []int{0, 1, 2}, false},
+		{"chain", [][]float64{{0, 4, 0, 0}, {1, 0, 4, 0}, {0, 1, 0, 4}, {0, 0, 1, 0}}, []int{0, 1, 2, 3}, false},
+	}
+	for _, tt := range tests {
+		p := bradleyTerry(tt.wins)
+		for i, s := range p {
+			if math.IsNaN(s) || math.IsInf(s, 0) || s <= 0 {
+				t.Fatalf("%s: strength %d is %v", tt.name, i, s)
+			}
+		}
+		if !btStationary(tt.wins, p) {
+			t.Errorf("%s: fit did not converge: %v", tt.name, p)
+		}
+		if tt.equal {
+			for i := range p {
+				if math.Abs(p[i]-1) > 1e-6 {
+					t.Errorf("%s: strengths %v, want all 1", tt.name, p)
+					break
+				}
+			}
+		}
+		for k := 1; k < len(tt.order); k++ {
+			if p[tt.order[k-1]] <= p[tt.order[k]] {
+				t.Errorf("%s: strengths %v, want order %v", tt.name, p, tt.order)
+				break
+			}
+		}
+	}
+
+	// 交换胜负后对数强度取反, 虚拟选项固定了尺度
+	p := bradleyTerry([][]float64{{0, 3}, {1, 0}})
+	q := bradleyTerry([][]float64{{0, 1}, {3, 0}})
+	if math.Abs(math.Log(p[0])+math.Log(q[0])) > 1e-6 || math.Abs(p[0]*p[1]-1) > 1e-6 {
+		t.Errorf("strengths %v and %v are not symmetric", p, q)
+	}
+}
+
+func TestStrengthErrors(t *testing.T) {
+	// 无比较时只有虚拟选项的一胜一负, 信息量为1/2
+	ses := strengthErrors([][]float64{{0, 0}, {0, 0}}, []float64{1, 1})
+	for _, se := range ses {
+		if math.Abs(se-math.Sqrt2) > 1e-9 {
+			t.Errorf("standard errors %v, want sqrt(2)", ses)
+		}
+	}
+
+	few := [][]float64{{0, 2}, {1, 0}}
+	many := [][]float64{{0, 20}, {10, 0}}
+	sesFew := strengthErrors(few, bradleyTerry(few))
+	sesMany := strengthErrors(many, bradleyTerry(many))
+	for i := range sesFew {
+		if sesMany[i] >= sesFew[i] {
+			t.Errorf("option %d: error %v with more comparisons, %v with fewer", i, sesMany[i], sesFew[i])
+		}
+	}
+}
+
+func TestEloRating(t *testing.T) {
+	if got := eloRating(0); got != eloBase {
+		t.Errorf("eloRating(0) = %v, want %v", got, eloBase)
+	}
+	if got := eloRating(math.Ln10) - eloRating(0); math.Abs(got-eloScale) > 0.01 {
+		t.Errorf("strength 10 times greater is %v points higher, want %v", got, eloScale)
+	}
+}
+
+func TestPickPair(t *testing.T) {
+	options := []model.Option{{ID: "A"}, {ID: "B"}, {ID: "C"}}
+	tests := []struct {
+		name        string
+		comparisons []model.Comparison
+		wantOK      bool
+		wantPair    string
+	}{
+		{"fresh", nil, true, ""},
+		{"one pair left", []model.Comparison{{OptionA: "A", OptionB: "B"}, {OptionA: "C", OptionB: "A"}}, true, "B|C"},
+		{"all compared", []model.Comparison{{OptionA: "A", OptionB: "B"}, {OptionA: "B", OptionB: "C"}, {OptionA: "C", OptionB: "A"}}, false, ""},
+	}
+	for _, tt := range tests {
+		for i := 0; i < 20; i++ {
+			a, b, ok := pickPair(options, tt.comparisons)
+			if ok != tt.wantOK {
+				t.Fatalf("%s: ok %v, want %v", tt.name, ok, tt.wantOK)
+			}
+			if !ok {
+				break
+			}
+			if a.ID == b.ID {
+				t.Fatalf("%s: option %s paired with itself", tt.name, a.ID)
+			}
+			if pair := a.ID + "|" + b.ID; tt.wantPair != "" && pair != tt.wantPair && b.ID+"|"+a.ID != tt.wantPair {
+				t.Fatalf("%s: picked %s, want %s", tt.name, pair, tt.wantPair)
+			}
+		}
+	}
+
+	// 比较次数最少的选项先出现
+	seen := []model.Comparison{{OptionA: "A", OptionB: "B"}}
+	for i := 0; i < 20; i++ {
+		if a, _, _ := pickPair(options, seen); a.ID != "C" {
+			t.Fatalf("picked %s first, want least seen C", a.ID)
+		}
+	}
+}
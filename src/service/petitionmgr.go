@@ -0,0 +1,240 @@
+package service
+
+import (
+	"FunnyVoteGo/src/api/vm"
+	"FunnyVoteGo/src/constant"
+	"FunnyVoteGo/src/lib/eventbus"
+	"FunnyVoteGo/src/model"
+	"FunnyVoteGo/src/util"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/glog"
+	"github.com/hyperchain/gosdk/abi"
+	"github.com/hyperchain/gosdk/utils/ecdsa"
+)
+
+const (
+	// petitionOption content of the only option of a petition
+	petitionOption = "签名"
+	// maxSignatureTarget highest signature target, totals are int32 on chain
+	maxSignatureTarget = 10000000
+	// openEndedDuration end time written on chain for a petition without deadline, in seconds
+	openEndedDuration = 10 * 365 * 24 * 60 * 60
+)
+
+// SignPetition sign a petition, the signature is kept on chain as a ballot of the sign option,
+// the signature reaching the target writes the completion record and closes the petition
+func SignPetition(ps *vm.PetitionSign) (err error) {
+	vs, b := model.GetVoteSetting(map[string]interface{}{"vote_id": ps.VoteID})
+	if !b || vs.SignatureTarget == 0 {
+		return fmt.Errorf("该投票不是请愿")
+	}
+	if vs.Closed || voteStatus(vs.StartTime, vs.EndTime) != 2 {
+		return &EligibilityError{Reason: "请愿未在进行中"}
+	}
+	release, err := admitBallot(ps.VoteID, ps.UserID, ps.InviteCode)
+	if err != nil {
+		return err
+	}
+	var txhash string
+	defer func() {
+		// 仅在选票未上链时释放邀请码
+		if err != nil && txhash == "" {
+			release()
+		}
+	}()
+
+	ballotid := chainID(util.StringUUID())
+	txhash, err = invokeMethod("signPetition", map[string]string{
+		"id":          ballotid,
+		"vote_id":     ps.VoteID,
+		"user_id":     strconv.Itoa(int(ps.UserID)),
+		"create_time": util.GetNowTimeString(),
+	})
+	if err != nil {
+		glog.Errorf("sign petition %s of user %d fail: %v", ps.VoteID, ps.UserID, err)
+		return fmt.Errorf("签名失败: %v", err)
+	}
+	if _, b := model.CreateHashRecord(&model.HashRecord{
+		VoteID:        ps.VoteID,
+		UserID:        ps.UserID,
+		OptionContent: petitionOption,
+		BallotID:      ballotid,
+		TxHash:        txhash,
+	}); !b {
+		// 选票已上链, 不再返回失败
+		glog.Errorf("create hash record of signature %s in vote %s fail", ballotid, ps.VoteID)
+	}
+	publishLocalEvent(eventbus.Event{
+		Name:   constant.EventBallotCast,
+		VoteID: ps.VoteID,
+		TxHash: txhash,
+		Data: map[string]interface{}{
+			"vote_id": ps.VoteID,
+			"user_id": strconv.Itoa(int(ps.UserID)),
+		},
+	})
+
+	key, err := InitKey()
+	if err != nil {
+		// 签名已上链, 完成状态在查询进度时补上
+		glog.Error(err)
+		return nil
+	}
+	target, signatures, completed, completeTime, b := queryPetition(ps.VoteID, key)
+	if b && completed {
+		completePetition(vs, txhash, target, signatures, completeTime)
+	}
+	return nil
+}
+
+// GetPetitionProgress get signatures of the petition against its target, the number of signatures
+// is hidden by result visibility
+func GetPetitionProgress(voteid string, userid uint) (*vm.PetitionProgress, error) {
+	vs, b := model.GetVoteSetting(map[string]interface{}{"vote_id": voteid})
+	if !b || vs.SignatureTarget == 0 {
+		return nil, fmt.Errorf("该投票不是请愿")
+	}
+	key, err := InitKey()
+	if err != nil {
+		return nil, err
+	}
+	target, signatures, completed, completeTime, b := queryPetition(voteid, key)
+	if !b {
+		return nil, fmt.Errorf("查询请愿失败")
+	}
+	if completed && !vs.PetitionDone {
+		// 签名后未能及时关闭的请愿
+		completePetition(vs, "", target, signatures, completeTime)
+		if vs, b = model.GetVoteSetting(map[string]interface{}{"vote_id": voteid}); !b {
+			return nil, fmt.Errorf("查询请愿失败")
+		}
+	}
+
+	progress := vm.PetitionProgress{
+		VoteID:     voteid,
+		Target:     target,
+		Signatures: signatures,
+		Completed:  completed,
+		TxHash:     vs.PetitionTxHash,
+		Status:     voteStatus(vs.StartTime, vs.EndTime),
+	}
+	if completed {
+		progress.CompletedAt = completeTime
+	}
+	if vs.Closed {
+		progress.Status = 3
+	}
+	if !vs.OpenEnded && !completed {
+		progress.Deadline = vs.EndTime
+	}
+	if !resultVisible(vs, userid) {
+		progress.Hidden = true
+		progress.Signatures = 0
+		return &progress, nil
+	}
+	if signatures < target {
+		progress.Remaining = target - signatures
+		progress.Percent = signatures * 100 / target
+	} else {
+		progress.Percent = 100
+	}
+	return &progress, nil
+}
+
+// completePetition close the petition once, publish PetitionCompleted and VoteClosed
+func completePetition(vs *model.VoteSetting, txhash string, target, signatures int, completeTime string) {
+	if !model.ClaimPetitionDone(vs.ID, txhash, time.Now().Unix()) {
+		return
+	}
+	publishLocalEvent(eventbus.Event{
+		Name:   constant.EventPetitionCompleted,
+		VoteID: vs.VoteID,
+		TxHash: txhash,
+		Data: map[string]interface{}{
+			"vote_id":       vs.VoteID,
+			"target":        strconv.Itoa(target),
+			"signatures":    strconv.Itoa(signatures),
+			"complete_time": completeTime,
+		},
+	})
+	// 合约没有关闭事件, 与定时关闭一样由服务发布
+	eventbus.Publish(eventbus.Event{
+		Name:   constant.EventVoteClosed,
+		VoteID: vs.VoteID,
+		Data: map[string]interface{}{
+			"vote_id":  vs.VoteID,
+			"end_time": completeTime,
+		},
+	})
+}
+
+// preparePetition a petition has the sign option only, and ends far in the future without deadline
+func preparePetition(voteinit *vm.VoteInit) {
+	voteinit.SelectType = model.SelectPetition
+	voteinit.Options = []string{petitionOption}
+	if voteinit.EndTime == "" {
+		start, _ := strconv.ParseInt(voteinit.StartTime, 10, 64)
+		voteinit.EndTime = strconv.FormatInt(start+openEndedDuration, 10)
+	}
+}
+
+// validatePetition the sign option of a petition is created by service
+func validatePetition(voteinit *vm.VoteInit) error {
+	if voteinit.SignatureTarget == 0 {
+		return nil
+	}
+	switch {
+	case voteinit.SignatureTarget < 0 || voteinit.SignatureTarget > maxSignatureTarget:
+		return &ContentError{Field: "signature_target", Reason: fmt.Sprintf("签名目标须在1到%d之间", maxSignatureTarget)}
+	case len(voteinit.Options) > 0:
+		return &ContentError{Field: "options", Reason: "请愿的签名选项自动创建"}
+	case len(voteinit.Questions) > 0 || voteinit.Budget != 0 || voteinit.RunoffMajority != 0 || voteinit.AllowWriteIn:
+		return &ContentError{Field: "signature_target", Reason: "请愿不支持问卷、预算、决选和自由填写选项"}
+	}
+	return nil
+}
+
+// commitPetition set the sign option and the signature target of the petition on chain
+func commitPetition(voteid, optionid string, target int) bool {
+	if _, err := invokeMethod("setPetition", map[string]string{
+		"vote_id":   voteid,
+		"option_id": optionid,
+		"target":    strconv.Itoa(target),
+	}); err != nil {
+		glog.Errorf("set petition %s fail: %v", voteid, err)
+		return false
+	}
+	return true
+}
+
+// queryPetition query target, signatures and completion of the petition on chain
+func queryPetition(voteid string, key *ecdsa.Key) (int, int, bool, string, bool) {
+	retu, err := InvokeContract(vm.ReqInvokeCon{
+		ContractAddr: ContractAddress(),
+		ContractCode: GetContractCode(),
+		MethodName:   "queryPetition",
+		MethodParams: util.Struct2String(model.Vote{ID: voteid}),
+	}, key)
+	if err != nil {
+		return 0, 0, false, "", false
+	}
+	ABI, _ := abi.JSON(strings.NewReader(retu.Abi))
+	var p_ok int32
+	var p_target int32
+	var p_total int32
+	var p_completed bool
+	var p_ct [32]byte
+	res := []interface{}{&p_ok, &p_target, &p_total, &p_completed, &p_ct}
+	if sysErr := ABI.UnpackResult(&res, "queryPetition", retu.Result); sysErr != nil {
+		glog.Info(sysErr)
+		return 0, 0, false, "", false
+	}
+	if p_ok != 0 {
+		return 0, 0, false, "", false
+	}
+	return int(p_target), int(p_total), p_completed, util.Byte32ToString(p_ct), true
+}
@@ -0,0 +1,145 @@
+package service
+
+import (
+	"FunnyVoteGo/src/api/vm"
+	"FunnyVoteGo/src/constant"
+	"FunnyVoteGo/src/model"
+	"strconv"
+
+	"github.com/casbin/casbin"
+	gormadapter "github.com/casbin/gorm-adapter"
+	"github.com/glog"
+	"github.com/spf13/viper"
+)
+
+var enforcer *casbin.SyncedEnforcer
+
+// defaultPolicies seeded when no policy in database, obj/act pairs are checked by route
+var defaultPolicies = [][]string{
+	{constant.RoleAdmin, "*", "*"},
+	{constant.RoleCreator, "vote", "create"},
+	{constant.RoleCreator, "vote", "read"},
+	{constant.RoleCreator, "vote", "close"},
+	{constant.RoleCreator, "vote", "manage"},
+	{constant.RoleCreator, "webhook", "read"},
+	{constant.RoleCreator, "webhook", "write"},
+	{constant.RoleCreator, "notification", "read"},
+	{constant.RoleVoter, "vote", "read"},
+	{constant.RoleVoter, "vote", "cast"},
+	{constant.RoleAuditor, "vote", "read"},
+	{constant.RoleAuditor, "record", "read"},
+	{constant.RoleAuditor, "webhook", "read"},
+	{constant.RoleAuditor, "notification", "read"},
+}
+
+// InitRBAC load policies from mysql, seed default policies at first run
+func InitRBAC() {
+	adapter := gormadapter.NewAdapter("mysql", model.DataSourceName(
+		viper.GetString("db.username"),
+		viper.GetString("db.password"),
+		viper.GetString("db.addr"),
+		viper.GetString("db.name"),
+	), true)
+	modelPath := viper.GetString("rbac.model")
+	if modelPath == "" {
+		modelPath = "./conf/rbac/model.conf"
+	}
+	enforcer = casbin.NewSyncedEnforcer(modelPath, adapter)
+	if len(enforcer.GetPolicy()) == 0 {
+		for _, p := range defaultPolicies {
+			enforcer.AddPolicy(p[0], p[1], p[2])
+		}
+	}
+	for _, id := range viper.GetStringSlice("rbac.admins") {
+		enforcer.AddRoleForUser(id, constant.RoleAdmin)
+	}
+}
+
+// Enforce check whether user can do act on obj
+func Enforce(userid uint, obj, act string) bool {
+	if enforcer == nil {
+		return false
+	}
+	return enforcer.Enforce(rbacSubject(userid), obj, act)
+}
+
+// IsAdmin check whether user is admin
+func IsAdmin(userid uint) bool {
+	if enforcer == nil {
+		return false
+	}
+	return enforcer.HasRoleForUser(rbacSubject(userid), constant.RoleAdmin)
+}
+
+// IsAuditor check whether user is auditor
+func IsAuditor(userid uint) bool {
+	if enforcer == nil {
+		return false
+	}
+	return enforcer.HasRoleForUser(rbacSubject(userid), constant.RoleAuditor)
+}
+
+// canManageVote only creator of the vote and admins can manage it
+func canManageVote(voteid string, userid uint) bool {
+	if IsAdmin(userid) {
+		return true
+	}
+	vs, b := model.GetVoteSetting(map[string]interface{}{"vote_id": voteid})
+	return b && vs.CreatorID == userid
+}
+
+// GetPolicies returns all policies and role assignments
+func GetPolicies() *vm.RBACPolicies {
+	return &vm.RBACPolicies{
+		Policies: enforcer.GetPolicy(),
+		Roles:    enforcer.GetGroupingPolicy(),
+	}
+}
+
+// AddPolicy allow role to do act on obj
+func AddPolicy(p *vm.RBACPolicy) bool {
+	return enforcer.AddPolicy(p.Role, p.Obj, p.Act)
+}
+
+// RemovePolicy remove a policy
+func RemovePolicy(p *vm.RBACPolicy) bool {
+	return enforcer.RemovePolicy(p.Role, p.Obj, p.Act)
+}
+
+// GetUserRoles returns roles of user
+func GetUserRoles(userid uint) []string {
+	return enforcer.GetRolesForUser(rbacSubject(userid))
+}
+
+// AddUserRole assign role to user
+func AddUserRole(ur *vm.RBACUserRole) bool {
+	if _, b := model.GetUser(map[string]interface{}{"id": ur.UserID}); !b {
+		return false
+	}
+	return enforcer.AddRoleForUser(rbacSubject(ur.UserID), ur.Role)
+}
+
+// RemoveUserRole remove role of user
+func RemoveUserRole(ur *vm.RBACUserRole) bool {
+	return enforcer.DeleteRoleForUser(rbacSubject(ur.UserID), ur.Role)
+}
+
+// assignDefaultRoles assign configured roles to a new user
+func assignDefaultRoles(userid uint) {
+	if enforcer == nil {
+		return
+	}
+	roles := viper.GetStringSlice("rbac.default_roles")
+	if len(roles) == 0 {
+		roles = []string{constant.RoleCreator, constant.RoleVoter}
+	}
+	for _, role := range roles {
+		if !enforcer.AddRoleForUser(rbacSubject(userid), role) {
+			glog.Errorf("assign role %s to user %d fail", role, userid)
+		}
+	}
+}
+
+func rbacSubject(userid uint) string {
+	return strconv.FormatUint(uint64(userid), 10)
+}
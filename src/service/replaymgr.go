@@ -0,0 +1,807 @@
+package service
+
+import (
+	"FunnyVoteGo/src/api/vm"
+	"FunnyVoteGo/src/model"
+	"FunnyVoteGo/src/util"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/hyperchain/gosdk/abi"
+	"github.com/hyperchain/gosdk/common"
+	"github.com/hyperchain/gosdk/rpc"
+)
+
+const (
+	// replayBatch blocks fetched per request when replaying
+	replayBatch = 100
+	// return codes of contract
+	replaySuccess int32 = 0
+	replayError   int32 = 1
+)
+
+// replayVote a vote in replayed contract storage, zero id means not inserted
+type replayVote struct {
+	id         [32]byte
+	selectType int32
+	options    [][32]byte
+	questions  int
+	finalized  bool
+	budget     int64
+	petition   replayPetition
+}
+
+type replayPetition struct {
+	option    [32]byte
+	target    int32
+	completed bool
+}
+
+type replayQuestion struct {
+	id         [32]byte
+	selectType int32
+	maxScore   int32
+	options    int
+}
+
+type replayOption struct {
+	id       [32]byte
+	vote     [32]byte
+	question [32]byte
+	content  [32]byte
+	total    int32
+	score    int32
+	cost     int64
+}
+
+type replayResult struct {
+	id     [32]byte
+	vote   [32]byte
+	option [32]byte
+	user   [32]byte
+}
+
+// replayCast total of an option after a ballot, as in BallotCast and DelegationResolved events
+type replayCast struct {
+	option [32]byte
+	total  int32
+}
+
+// replayOutcome result of replaying a transaction
+type replayOutcome struct {
+	vote   [32]byte
+	code   int32
+	reason string
+	casts  []replayCast
+}
+
+// replayArgs decoded arguments of a transaction by name
+type replayArgs map[string]interface{}
+
+func (a replayArgs) b32(name string) [32]byte {
+	v, _ := a[name].([32]byte)
+	return v
+}
+
+func (a replayArgs) b32s(name string) [][32]byte {
+	v, _ := a[name].([][32]byte)
+	return v
+}
+
+func (a replayArgs) i32(name string) int32 {
+	v, _ := a[name].(int32)
+	return v
+}
+
+func (a replayArgs) i32s(name string) []int32 {
+	v, _ := a[name].([]int32)
+	return v
+}
+
+func (a replayArgs) i64(name string) int64 {
+	v, _ := a[name].(int64)
+	return v
+}
+
+func (a replayArgs) i64s(name string) []int64 {
+	v, _ := a[name].([]int64)
+	return v
+}
+
+// tallyReplay storage of vote contract rebuilt from transactions, maps behave as solidity mappings
+type tallyReplay struct {
+	votes         map[[32]byte]*replayVote
+	options       map[[32]byte]*replayOption
+	questions     map[[32]byte]*replayQuestion
+	results       map[[32]byte]*replayResult
+	userResults   map[[32]byte][][32]byte
+	voteResults   map[[32]byte]int
+	optionCosts   map[[32]byte]int64
+	delegation    map[[32]byte]map[[32]byte][32]byte
+	resolved      map[[32]byte]map[[32]byte]bool
+	voteOrder     [][32]byte
+	replayMethods map[string]func(a replayArgs) replayOutcome
+}
+
+func newTallyReplay() *tallyReplay {
+	r := &tallyReplay{
+		votes:       make(map[[32]byte]*replayVote),
+		options:     make(map[[32]byte]*replayOption),
+		questions:   make(map[[32]byte]*replayQuestion),
+		results:     make(map[[32]byte]*replayResult),
+		userResults: make(map[[32]byte][][32]byte),
+		voteResults: make(map[[32]byte]int),
+		optionCosts: make(map[[32]byte]int64),
+		delegation:  make(map[[32]byte]map[[32]byte][32]byte),
+		resolved:    make(map[[32]byte]map[[32]byte]bool),
+	}
+	// 只重放影响票数的方法, 查询和附件、选民名单等设置不影响票数
+	r.replayMethods = map[string]func(a replayArgs) replayOutcome{
+		"insertVote":        r.insertVote,
+		"insertVoteOption":  r.insertVoteOptionTx,
+		"updateVoteOption":  r.updateVoteOption,
+		"insertVoteResult":  r.insertVoteResult,
+		"finalizeVote":      r.finalizeVote,
+		"insertQuestion":    r.insertQuestion,
+		"submitSurvey":      r.submitSurvey,
+		"addVoteOption":     r.addVoteOption,
+		"setBudget":         r.setBudget,
+		"castBudgetBallot":  r.castBudgetBallot,
+		"setPetition":       r.setPetition,
+		"signPetition":      r.signPetition,
+		"compareOptions":    r.compareOptions,
+		"setDelegation":     r.setDelegation,
+		"resolveDelegation": r.resolveDelegation,
+	}
+	return r
+}
+
+// ReplayTally fetch every transaction sent to vote contract in blocks from..to with block and receipt rpcs only,
+// replay the vote logic of the contract on them and compare the receipts and the recomputed totals with
+// queryVoteOption. Replay must start at or before the block the contract was deployed in to be complete
+func ReplayTally(voteid string, from, to uint64) (*vm.TallyReport, error) {
+	hpc := rpc.NewRPCWithPath("./conf/chain_SDK/conf")
+	if hpc == nil {
+		return nil, fmt.Errorf("初始化rpc失败")
+	}
+	ABI, err := GetContractABI()
+	if err != nil {
+		return nil, err
+	}
+	if from == 0 {
+		from = 1
+	}
+	if to == 0 {
+		latest, stdErr := hpc.GetLatestBlock()
+		if stdErr != nil {
+			return nil, stdErr
+		}
+		to = latest.Number
+	}
+	var scope [32]byte
+	copy(scope[:], chainID(voteid))
+
+	report := vm.TallyReport{VoteID: voteid, From: from, To: to}
+	r := newTallyReplay()
+	for start := from; start <= to; start += replayBatch {
+		end := start + replayBatch - 1
+		if end > to {
+			end = to
+		}
+		txs, stdErr := hpc.GetTransactionsByBlkNum(start, end)
+		if stdErr != nil && stdErr.Code() != rpc.DataNotExistCode {
+			return nil, stdErr
+		}
+		sort.SliceStable(txs, func(i, j int) bool {
+			if txs[i].BlockNumber != txs[j].BlockNumber {
+				return txs[i].BlockNumber < txs[j].BlockNumber
+			}
+			return txs[i].TxIndex < txs[j].TxIndex
+		})
+		for i := range txs {
+			tx := &txs[i]
+			if !strings.EqualFold(tx.To, ContractAddress()) || tx.Invalid {
+				continue
+			}
+			report.Transactions++
+			receipt, stdErr := hpc.GetTxReceipt(tx.Hash)
+			if stdErr != nil {
+				return nil, stdErr
+			}
+			r.replayTx(ABI, tx, receipt, scope, &report)
+		}
+	}
+
+	if err := r.compareTallies(scope, &report); err != nil {
+		return nil, err
+	}
+	report.Valid = len(report.Divergences) == 0
+	for _, v := range report.Votes {
+		report.Valid = report.Valid && v.Match
+	}
+	return &report, nil
+}
+
+// replayTx decode a transaction by ABI, replay it and compare return code and ballot events with its receipt
+func (r *tallyReplay) replayTx(ABI *abi.ABI, tx *rpc.TransactionInfo, receipt *rpc.TxReceipt, scope [32]byte, report *vm.TallyReport) {
+	diverge := func(method string, vote [32]byte, reason string) {
+		report.Divergences = append(report.Divergences, vm.TallyDivergence{
+			TxHash:      tx.Hash,
+			BlockNumber: tx.BlockNumber,
+			Method:      method,
+			VoteID:      util.Byte32ToDisplay(vote),
+			Reason:      reason,
+		})
+	}
+	payload := common.FromHex(tx.Payload)
+	if len(payload) < 4 {
+		return
+	}
+	method, err := ABI.MethodById(payload)
+	if err != nil {
+		diverge("", [32]byte{}, "无法识别调用的方法")
+		return
+	}
+	apply, ok := r.replayMethods[method.Name]
+	if !ok {
+		return
+	}
+	values, err := method.Inputs.UnpackValues(payload[4:])
+	if err != nil {
+		diverge(method.Name, [32]byte{}, "无法解析参数: "+err.Error())
+		return
+	}
+	args := make(replayArgs)
+	for i, input := range method.Inputs {
+		args[input.Name] = values[i]
+	}
+	outcome := apply(args)
+	report.Replayed++
+	if scope != ([32]byte{}) && outcome.vote != scope {
+		return
+	}
+
+	rets, err := method.Outputs.UnpackValues(common.FromHex(receipt.Ret))
+	if err != nil || len(rets) == 0 {
+		diverge(method.Name, outcome.vote, "无法解析返回值")
+		return
+	}
+	if code, _ := rets[0].(int32); code != outcome.code {
+		diverge(method.Name, outcome.vote, fmt.Sprintf("合约返回%d, 重放返回%d %s", code, outcome.code, outcome.reason))
+		return
+	}
+	casts := receiptCasts(ABI, receipt.Log)
+	if len(casts) != len(outcome.casts) {
+		diverge(method.Name, outcome.vote, fmt.Sprintf("合约计票%d次, 重放计票%d次", len(casts), len(outcome.casts)))
+		return
+	}
+	for i, c := range casts {
+		if c != outcome.casts[i] {
+			diverge(method.Name, outcome.vote, fmt.Sprintf("选项%s合约计为%d票, 重放为%d票",
+				util.Byte32ToDisplay(c.option), c.total, outcome.casts[i].total))
+		}
+	}
+}
+
+// compareTallies compare recomputed totals of votes in scope with queryVoteOption
+func (r *tallyReplay) compareTallies(scope [32]byte, report *vm.TallyReport) error {
+	key, err := InitKey()
+	if err != nil {
+		return err
+	}
+	ids := r.voteOrder
+	if scope != ([32]byte{}) {
+		ids = [][32]byte{scope}
+	}
+	for _, id := range ids {
+		voteid := util.ByteToString(id[:])
+		tv := vm.TallyVote{VoteID: voteid, Match: true}
+		onChain := make(map[string]int)
+		options, b := queryVoteOptions(voteid, key)
+		for _, o := range options {
+			onChain[o.ID] = int(o.Total)
+		}
+		for _, oid := range r.vote(id).options {
+			option := util.ByteToString(oid[:])
+			total, ok := onChain[option]
+			if !ok {
+				total = -1
+			}
+			delete(onChain, option)
+			replayed := int(r.option(oid).total)
+			tv.Options = append(tv.Options, vm.TallyOption{ID: option, Replayed: replayed, OnChain: total})
+			tv.Match = tv.Match && replayed == total
+		}
+		// 链上有而重放中没有的选项
+		for option, total := range onChain {
+			tv.Options = append(tv.Options, vm.TallyOption{ID: option, Replayed: -1, OnChain: total})
+			tv.Match = false
+		}
+		if !b && len(tv.Options) > 0 {
+			tv.Match = false
+		}
+		report.Votes = append(report.Votes, tv)
+	}
+	return nil
+}
+
+// receiptCasts option totals in BallotCast and DelegationResolved logs of a receipt
+func receiptCasts(ABI *abi.ABI, logs []rpc.TxLog) []replayCast {
+	var casts []replayCast
+	for _, l := range logs {
+		if len(l.Topics) == 0 {
+			continue
+		}
+		for _, name := range []string{"BallotCast", "DelegationResolved"} {
+			event, ok := ABI.Events[name]
+			if !ok || !strings.EqualFold(event.Id().Hex(), l.Topics[0]) {
+				continue
+			}
+			values, err := event.Inputs.NonIndexed().UnpackValues(common.FromHex(l.Data))
+			if err != nil {
+				continue
+			}
+			var c replayCast
+			for i, arg := range event.Inputs.NonIndexed() {
+				switch arg.Name {
+				case "option_id":
+					c.option, _ = values[i].([32]byte)
+				case "total":
+					c.total, _ = values[i].(int32)
+				}
+			}
+			casts = append(casts, c)
+		}
+	}
+	return casts
+}
+
+// vote storage of a vote, created empty as solidity mapping
+func (r *tallyReplay) vote(id [32]byte) *replayVote {
+	v, ok := r.votes[id]
+	if !ok {
+		v = &replayVote{}
+		r.votes[id] = v
+	}
+	return v
+}
+
+func (r *tallyReplay) option(id [32]byte) *replayOption {
+	o, ok := r.options[id]
+	if !ok {
+		o = &replayOption{}
+		r.options[id] = o
+	}
+	return o
+}
+
+func (r *tallyReplay) question(id [32]byte) *replayQuestion {
+	q, ok := r.questions[id]
+	if !ok {
+		q = &replayQuestion{}
+		r.questions[id] = q
+	}
+	return q
+}
+
+func (r *tallyReplay) result(id [32]byte) *replayResult {
+	res, ok := r.results[id]
+	if !ok {
+		res = &replayResult{}
+		r.results[id] = res
+	}
+	return res
+}
+
+func (r *tallyReplay) hasVoted(user, vote [32]byte) bool {
+	for _, id := range r.userResults[user] {
+		if r.result(id).vote == vote {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *tallyReplay) pushResult(id, vote, option, user [32]byte) {
+	*r.result(id) = replayResult{id: id, vote: vote, option: option, user: user}
+	r.userResults[user] = append(r.userResults[user], id)
+	r.voteResults[vote]++
+}
+
+func rejected(vote [32]byte, reason string) replayOutcome {
+	return replayOutcome{vote: vote, code: replayError, reason: reason}
+}
+
+func accepted(vote [32]byte, casts ...replayCast) replayOutcome {
+	return replayOutcome{vote: vote, code: replaySuccess, casts: casts}
+}
+
+func (r *tallyReplay) insertVote(a replayArgs) replayOutcome {
+	id := a.b32("id")
+	ids, contents := a.b32s("option_ids"), a.b32s("option_contents")
+	v := r.vote(id)
+	if v.id != ([32]byte{}) {
+		return rejected(id, "主键已经存在，无法插入")
+	}
+	// 合约中数组越界会回滚整个交易
+	if len(contents) < len(ids) {
+		return rejected(id, "选项内容数组越界")
+	}
+	v.id = id
+	v.selectType = a.i32("select_type")
+	r.voteOrder = append(r.voteOrder, id)
+	for i := range ids {
+		r.insertVoteOption(ids[i], id, contents[i])
+	}
+	return accepted(id)
+}
+
+func (r *tallyReplay) insertVoteOptionTx(a replayArgs) replayOutcome {
+	vote := a.b32("vote_id")
+	if reason := r.insertVoteOption(a.b32("id"), vote, a.b32("content")); reason != "" {
+		return rejected(vote, reason)
+	}
+	return accepted(vote)
+}
+
+// insertVoteOption returns reason of failure, empty when inserted
+func (r *tallyReplay) insertVoteOption(id, vote, content [32]byte) string {
+	if r.option(id).id != ([32]byte{}) {
+		return "主键已经存在，无法插入"
+	}
+	if r.vote(vote).id == ([32]byte{}) {
+		return "复合主键voteID2VoteDetail不存在，无法插入"
+	}
+	*r.option(id) = replayOption{id: id, vote: vote, content: content}
+	v := r.vote(vote)
+	v.options = append(v.options, id)
+	return ""
+}
+
+func (r *tallyReplay) updateVoteOption(a replayArgs) replayOutcome {
+	o := r.option(a.b32("id"))
+	if o.id == ([32]byte{}) {
+		return rejected(o.vote, "主键不存在，无法更新")
+	}
+	if vote := a.b32("vote_id"); !r.isPlainOption(vote, o.id) {
+		return rejected(vote, "选项不属于该投票")
+	}
+	o.total++
+	return accepted(o.vote)
+}
+
+func (r *tallyReplay) insertVoteResult(a replayArgs) replayOutcome {
+	id, vote, option := a.b32("id"), a.b32("vote_id"), a.b32("option_id")
+	if r.result(id).id != ([32]byte{}) {
+		return rejected(vote, "主键已经存在，无法插入")
+	}
+	if r.option(option).id == ([32]byte{}) {
+		return rejected(vote, "复合主键option_id不存在，无法插入")
+	}
+	if !r.isPlainOption(vote, option) {
+		return rejected(vote, "选项不属于该投票")
+	}
+	r.pushResult(id, vote, option, a.b32("user_id"))
+	return accepted(vote, replayCast{option, r.option(option).total})
+}
+
+// isPlainOption option belongs to the vote, which is single or multiple choice
+func (r *tallyReplay) isPlainOption(vote, option [32]byte) bool {
+	selectType := r.vote(vote).selectType
+	return r.option(option).vote == vote && (selectType == model.SelectSingle || selectType == model.SelectMulti)
+}
+
+func (r *tallyReplay) finalizeVote(a replayArgs) replayOutcome {
+	id := a.b32("id")
+	v := r.vote(id)
+	if v.id == ([32]byte{}) {
+		return rejected(id, "投票活动不存在")
+	}
+	if v.finalized {
+		return rejected(id, "投票结果已确认")
+	}
+	v.finalized = true
+	return accepted(id)
+}
+
+func (r *tallyReplay) insertQuestion(a replayArgs) replayOutcome {
+	id, vote := a.b32("id"), a.b32("vote_id")
+	selectType := a.i32("select_type")
+	ids, contents := a.b32s("option_ids"), a.b32s("option_contents")
+	switch {
+	case r.question(id).id != ([32]byte{}):
+		return rejected(vote, "主键已经存在，无法插入")
+	case r.vote(vote).id == ([32]byte{}):
+		return rejected(vote, "投票活动不存在")
+	case r.voteResults[vote] != 0:
+		return rejected(vote, "已有投票记录，无法添加问题")
+	case selectType < 1 || selectType > 4:
+		return rejected(vote, "题型错误")
+	case len(ids) == 0 || len(ids) != len(contents):
+		return rejected(vote, "选项错误")
+	}
+	for _, oid := range ids {
+		if r.option(oid).id != ([32]byte{}) {
+			return rejected(vote, "选项主键已经存在，无法插入")
+		}
+	}
+	*r.question(id) = replayQuestion{id: id, selectType: selectType, maxScore: a.i32("max_score")}
+	r.vote(vote).questions++
+	for i, oid := range ids {
+		r.insertVoteOption(oid, vote, contents[i])
+		r.option(oid).question = id
+		r.question(id).options++
+	}
+	return accepted(vote)
+}
+
+func (r *tallyReplay) submitSurvey(a replayArgs) replayOutcome {
+	id, vote, user := a.b32("id"), a.b32("vote_id"), a.b32("user_id")
+	ids, values := a.b32s("option_ids"), a.i32s("values")
+	switch {
+	case r.vote(vote).questions == 0:
+		return rejected(vote, "问卷不存在")
+	case r.vote(vote).finalized:
+		return rejected(vote, "投票结果已确认")
+	case r.result(id).id != ([32]byte{}):
+		return rejected(vote, "主键已经存在，无法插入")
+	case r.hasVoted(user, vote):
+		return rejected(vote, "已投票")
+	case len(ids) == 0 || len(ids) != len(values):
+		return rejected(vote, "答案错误")
+	}
+	for i, oid := range ids {
+		if !r.validSurveyValue(vote, oid, values[i]) {
+			return rejected(vote, "答案错误")
+		}
+	}
+	var casts []replayCast
+	for i, oid := range ids {
+		o := r.option(oid)
+		o.total++
+		o.score += r.surveyPoints(oid, values[i])
+		casts = append(casts, replayCast{oid, o.total})
+	}
+	r.pushResult(id, vote, [32]byte{}, user)
+	return accepted(vote, casts...)
+}
+
+func (r *tallyReplay) validSurveyValue(vote, option [32]byte, value int32) bool {
+	o := r.option(option)
+	if o.vote != vote || o.question == ([32]byte{}) {
+		return false
+	}
+	q := r.question(o.question)
+	switch q.selectType {
+	case 3:
+		return value >= 1 && int(value) <= q.options
+	case 4:
+		return value >= 0 && value <= q.maxScore
+	}
+	return value == 1
+}
+
+func (r *tallyReplay) surveyPoints(option [32]byte, value int32) int32 {
+	q := r.question(r.option(option).question)
+	switch q.selectType {
+	case 3:
+		return int32(q.options) + 1 - value
+	case 4:
+		return value
+	}
+	return 0
+}
+
+func (r *tallyReplay) addVoteOption(a replayArgs) replayOutcome {
+	id, vote, content := a.b32("id"), a.b32("vote_id"), a.b32("content")
+	v := r.vote(vote)
+	switch {
+	case v.id == ([32]byte{}):
+		return rejected(vote, "投票活动不存在")
+	case v.finalized:
+		return rejected(vote, "投票结果已确认")
+	case v.questions != 0 || v.budget != 0 || v.petition.target != 0:
+		return rejected(vote, "问卷、预算投票和请愿不能添加选项")
+	}
+	for _, oid := range v.options {
+		if r.option(oid).content == content {
+			return rejected(vote, "选项已存在")
+		}
+	}
+	if reason := r.insertVoteOption(id, vote, content); reason != "" {
+		return rejected(vote, reason)
+	}
+	return accepted(vote)
+}
+
+func (r *tallyReplay) setBudget(a replayArgs) replayOutcome {
+	vote, budget := a.b32("vote_id"), a.i64("budget")
+	ids, costs := a.b32s("option_ids"), a.i64s("costs")
+	v := r.vote(vote)
+	switch {
+	case v.id == ([32]byte{}):
+		return rejected(vote, "投票活动不存在")
+	case v.budget != 0:
+		return rejected(vote, "预算已设置")
+	case r.voteResults[vote] != 0:
+		return rejected(vote, "已有投票记录，无法设置预算")
+	case budget <= 0 || len(ids) == 0 || len(ids) != len(costs):
+		return rejected(vote, "预算错误")
+	}
+	for i, oid := range ids {
+		if r.option(oid).vote != vote || costs[i] <= 0 || costs[i] > budget {
+			return rejected(vote, "选项成本错误")
+		}
+	}
+	for i, oid := range ids {
+		r.optionCosts[oid] = costs[i]
+	}
+	v.budget = budget
+	return accepted(vote)
+}
+
+func (r *tallyReplay) castBudgetBallot(a replayArgs) replayOutcome {
+	id, vote, user := a.b32("id"), a.b32("vote_id"), a.b32("user_id")
+	ids := a.b32s("option_ids")
+	v := r.vote(vote)
+	switch {
+	case v.budget == 0:
+		return rejected(vote, "不是预算投票")
+	case v.finalized:
+		return rejected(vote, "投票结果已确认")
+	case r.result(id).id != ([32]byte{}):
+		return rejected(vote, "主键已经存在，无法插入")
+	case r.hasVoted(user, vote):
+		return rejected(vote, "已投票")
+	case len(ids) == 0:
+		return rejected(vote, "须至少选择一个选项")
+	}
+	var cost int64
+	for i, oid := range ids {
+		if r.option(oid).vote != vote || r.optionCosts[oid] == 0 {
+			return rejected(vote, "选项错误")
+		}
+		for _, prev := range ids[:i] {
+			if prev == oid {
+				return rejected(vote, "选项重复")
+			}
+		}
+		cost += r.optionCosts[oid]
+	}
+	if cost > v.budget {
+		return rejected(vote, "超出预算")
+	}
+	var casts []replayCast
+	for _, oid := range ids {
+		o := r.option(oid)
+		o.total++
+		casts = append(casts, replayCast{oid, o.total})
+	}
+	r.pushResult(id, vote, [32]byte{}, user)
+	return accepted(vote, casts...)
+}
+
+func (r *tallyReplay) setPetition(a replayArgs) replayOutcome {
+	vote, option, target := a.b32("vote_id"), a.b32("option_id"), a.i32("target")
+	v := r.vote(vote)
+	switch {
+	case v.id == ([32]byte{}):
+		return rejected(vote, "投票活动不存在")
+	case v.petition.target != 0:
+		return rejected(vote, "请愿已设置")
+	case r.option(option).vote != vote || len(v.options) != 1:
+		return rejected(vote, "请愿须只有一个签名选项")
+	case target <= 0:
+		return rejected(vote, "签名目标错误")
+	}
+	v.petition = replayPetition{option: option, target: target}
+	return accepted(vote)
+}
+
+func (r *tallyReplay) signPetition(a replayArgs) replayOutcome {
+	id, vote, user := a.b32("id"), a.b32("vote_id"), a.b32("user_id")
+	v := r.vote(vote)
+	switch {
+	case v.petition.target == 0:
+		return rejected(vote, "请愿不存在")
+	case v.petition.completed || v.finalized:
+		return rejected(vote, "请愿已结束")
+	case r.result(id).id != ([32]byte{}):
+		return rejected(vote, "主键已经存在，无法插入")
+	case r.hasVoted(user, vote):
+		return rejected(vote, "已签名")
+	}
+	o := r.option(v.petition.option)
+	o.total++
+	r.pushResult(id, vote, v.petition.option, user)
+	if o.total >= v.petition.target {
+		v.petition.completed = true
+	}
+	return accepted(vote, replayCast{v.petition.option, o.total})
+}
+
+func (r *tallyReplay) compareOptions(a replayArgs) replayOutcome {
+	id, vote, user := a.b32("id"), a.b32("vote_id"), a.b32("user_id")
+	winner, loser := a.b32("winner_id"), a.b32("loser_id")
+	v := r.vote(vote)
+	switch {
+	case v.selectType != 8:
+		return rejected(vote, "不是两两比较投票")
+	case v.finalized:
+		return rejected(vote, "投票结果已确认")
+	case r.result(id).id != ([32]byte{}):
+		return rejected(vote, "主键已经存在，无法插入")
+	case winner == loser || r.option(winner).vote != vote || r.option(loser).vote != vote:
+		return rejected(vote, "选项错误")
+	}
+	o := r.option(winner)
+	o.total++
+	r.pushResult(id, vote, winner, user)
+	return accepted(vote, replayCast{winner, o.total})
+}
+
+func (r *tallyReplay) setDelegation(a replayArgs) replayOutcome {
+	vote, delegator, delegate := a.b32("vote_id"), a.b32("delegator_id"), a.b32("delegate_id")
+	if delegator == ([32]byte{}) || delegator == delegate {
+		return rejected(vote, "委托人无效")
+	}
+	if vote != ([32]byte{}) {
+		switch v := r.vote(vote); {
+		case v.id == ([32]byte{}):
+			return rejected(vote, "投票活动不存在")
+		case v.finalized:
+			return rejected(vote, "投票结果已确认")
+		case r.hasVoted(delegator, vote):
+			return rejected(vote, "已投票，无法修改委托")
+		}
+	}
+	if r.delegation[vote] == nil {
+		r.delegation[vote] = make(map[[32]byte][32]byte)
+	}
+	r.delegation[vote][delegator] = delegate
+	return accepted(vote)
+}
+
+func (r *tallyReplay) resolveDelegation(a replayArgs) replayOutcome {
+	vote, delegator := a.b32("vote_id"), a.b32("delegator_id")
+	v := r.vote(vote)
+	switch {
+	case v.id == ([32]byte{}):
+		return rejected(vote, "投票活动不存在")
+	case v.finalized:
+		return rejected(vote, "投票结果已确认")
+	case r.resolved[vote][delegator]:
+		return rejected(vote, "票权已计入")
+	case r.hasVoted(delegator, vote):
+		return rejected(vote, "委托人已投票")
+	}
+	delegate := delegator
+	for depth := 0; depth < maxDelegationDepth; depth++ {
+		next := r.delegation[vote][delegate]
+		if next == ([32]byte{}) {
+			next = r.delegation[[32]byte{}][delegate]
+		}
+		if next == ([32]byte{}) || next == delegator {
+			return rejected(vote, "委托链中无投票人")
+		}
+		delegate = next
+		if !r.hasVoted(delegate, vote) {
+			continue
+		}
+		if r.resolved[vote] == nil {
+			r.resolved[vote] = make(map[[32]byte]bool)
+		}
+		r.resolved[vote][delegator] = true
+		var casts []replayCast
+		for _, rid := range r.userResults[delegate] {
+			if res := r.result(rid); res.vote == vote {
+				o := r.option(res.option)
+				o.total++
+				casts = append(casts, replayCast{res.option, o.total})
+			}
+		}
+		return accepted(vote, casts...)
+	}
+	return rejected(vote, "委托链过长")
+}
@@ -0,0 +1,278 @@
+package service
+
+import (
+	"FunnyVoteGo/src/model"
+	"reflect"
+	"testing"
+
+	"github.com/hyperchain/gosdk/rpc"
+)
+
+// replayStep a transaction replayed in order, with the expected return code and ballot casts
+type replayStep struct {
+	name      string
+	method    string
+	args      replayArgs
+	wantCode  int32
+	wantCasts []replayCast
+}
+
+func runReplay(t *testing.T, r *tallyReplay, steps []replayStep) {
+	for _, s := range steps {
+		apply, ok := r.replayMethods[s.method]
+		if !ok {
+			t.Fatalf("%s: method %s is not replayed", s.name, s.method)
+		}
+		outcome := apply(s.args)
+		if outcome.code != s.wantCode {
+			t.Errorf("%s: code %d %q, want %d", s.name, outcome.code, outcome.reason, s.wantCode)
+			continue
+		}
+		if !reflect.DeepEqual(outcome.casts, s.wantCasts) {
+			t.Errorf("%s: casts %v, want %v", s.name, outcome.casts, s.wantCasts)
+		}
+	}
+}
+
+func insertVoteArgs(id string, selectType int32, options ...string) replayArgs {
+	var ids, contents [][32]byte
+	for _, o := range options {
+		ids = append(ids, b32(o))
+		contents = append(contents, b32("content of "+o))
+	}
+	return replayArgs{"id": b32(id), "select_type": selectType, "option_ids": ids, "option_contents": contents}
+}
+
+func resultArgs(id, vote, option, user string) replayArgs {
+	return replayArgs{"id": b32(id), "vote_id": b32(vote), "option_id": b32(option), "user_id": b32(user)}
+}
+
+func TestReplayPlainBallot(t *testing.T) {
+	r := newTallyReplay()
+	runReplay(t, r, []replayStep{
+		{"insert single", "insertVote", insertVoteArgs("vote1", model.SelectSingle, "option1", "option2"), replaySuccess, nil},
+		{"insert multiple", "insertVote", insertVoteArgs("vote2", model.SelectMulti, "option3"), replaySuccess, nil},
+		{"insert pairwise", "insertVote", insertVoteArgs("vote3", model.SelectPairwise, "option4", "option5"), replaySuccess, nil},
+		{"duplicate vote", "insertVote", insertVoteArgs("vote1", model.SelectSingle), replayError, nil},
+		{"short contents", "insertVote", replayArgs{"id": b32("vote4"), "option_ids": [][32]byte{b32("option6")}}, replayError, nil},
+		{"insert option", "insertVoteOption", replayArgs{"id": b32("option7"), "vote_id": b32("vote2"), "content": b32("c")}, replaySuccess, nil},
+		{"option of missing vote", "insertVoteOption", replayArgs{"id": b32("option8"), "vote_id": b32("vote9"), "content": b32("c")}, replayError, nil},
+
+		{"count", "updateVoteOption", replayArgs{"id": b32("option1"), "vote_id": b32("vote1")}, replaySuccess, nil},
+		{"record", "insertVoteResult", resultArgs("result1", "vote1", "option1", "user1"), replaySuccess, []replayCast{{b32("option1"), 1}}},
+		{"count multiple", "updateVoteOption", replayArgs{"id": b32("option7"), "vote_id": b32("vote2")}, replaySuccess, nil},
+		{"record multiple", "insertVoteResult", resultArgs("result2", "vote2", "option7", "user1"), replaySuccess, []replayCast{{b32("option7"), 1}}},
+		{"count missing option", "updateVoteOption", replayArgs{"id": b32("option9"), "vote_id": b32("vote1")}, replayError, nil},
+		{"count option of other vote", "updateVoteOption", replayArgs{"id": b32("option3"), "vote_id": b32("vote1")}, replayError, nil},
+		{"count without vote id", "updateVoteOption", replayArgs{"id": b32("option1")}, replayError, nil},
+		{"count pairwise option", "updateVoteOption", replayArgs{"id": b32("option4"), "vote_id": b32("vote3")}, replayError, nil},
+		{"duplicate result", "insertVoteResult", resultArgs("result1", "vote1", "option2", "user2"), replayError, nil},
+		{"record missing option", "insertVoteResult", resultArgs("result3", "vote1", "option9", "user2"), replayError, nil},
+		{"record option of other vote", "insertVoteResult", resultArgs("result3", "vote1", "option3", "user2"), replayError, nil},
+		{"record pairwise option", "insertVoteResult", resultArgs("result3", "vote3", "option4", "user2"), replayError, nil},
+
+		{"finalize", "finalizeVote", replayArgs{"id": b32("vote1")}, replaySuccess, nil},
+		{"finalize again", "finalizeVote", replayArgs{"id": b32("vote1")}, replayError, nil},
+		{"finalize missing vote", "finalizeVote", replayArgs{"id": b32("vote9")}, replayError, nil},
+	})
+	if got := r.option(b32("option1")).total; got != 1 {
+		t.Errorf("option1 total %d, want 1", got)
+	}
+	if got := r.option(b32("option4")).total; got != 0 {
+		t.Errorf("pairwise option4 counted as plain ballot, total %d", got)
+	}
+	if want := [][32]byte{b32("vote1"), b32("vote2"), b32("vote3")}; !reflect.DeepEqual(r.voteOrder, want) {
+		t.Errorf("vote order %v, want %v", r.voteOrder, want)
+	}
+}
+
+func TestReplayIsPlainOption(t *testing.T) {
+	r := newTallyReplay()
+	r.insertVote(insertVoteArgs("single", model.SelectSingle, "option1"))
+	r.insertVote(insertVoteArgs("multi", model.SelectMulti, "option2"))
+	r.insertVote(insertVoteArgs("survey", model.SelectSurvey, "option3"))
+	r.insertVote(insertVoteArgs("budget", model.SelectBudget, "option4"))
+	tests := []struct {
+		vote, option string
+		want         bool
+	}{
+		{"single", "option1", true},
+		{"multi", "option2", true},
+		{"single", "option2", false},
+		{"survey", "option3", false},
+		{"budget", "option4", false},
+		{"single", "missing", false},
+		{"missing", "option1", false},
+	}
+	for _, tt := range tests {
+		if got := r.isPlainOption(b32(tt.vote), b32(tt.option)); got != tt.want {
+			t.Errorf("isPlainOption(%s, %s) = %v, want %v", tt.vote, tt.option, got, tt.want)
+		}
+	}
+}
+
+func TestReplaySurvey(t *testing.T) {
+	r := newTallyReplay()
+	question := func(id string, selectType, maxScore int32, options ...string) replayArgs {
+		a := insertVoteArgs("", 0, options...)
+		return replayArgs{"id": b32(id), "vote_id": b32("vote1"), "select_type": selectType, "max_score": maxScore,
+			"option_ids": a["option_ids"], "option_contents": a["option_contents"]}
+	}
+	survey := func(id, user string, options []string, values ...int32) replayArgs {
+		var ids [][32]byte
+		for _, o := range options {
+			ids = append(ids, b32(o))
+		}
+		return replayArgs{"id": b32(id), "vote_id": b32("vote1"), "user_id": b32(user), "option_ids": ids, "values": values}
+	}
+	runReplay(t, r, []replayStep{
+		{"insert survey", "insertVote", insertVoteArgs("vote1", model.SelectSurvey), replaySuccess, nil},
+		{"single question", "insertQuestion", question("q1", 1, 0, "a1", "a2"), replaySuccess, nil},
+		{"ranked question", "insertQuestion", question("q2", 3, 0, "b1", "b2", "b3"), replaySuccess, nil},
+		{"score question", "insertQuestion", question("q3", 4, 10, "c1"), replaySuccess, nil},
+		{"bad question type", "insertQuestion", question("q4", 5, 0, "d1"), replayError, nil},
+		{"submit", "submitSurvey", survey("result1", "user1", []string{"a2", "b3", "b1", "c1"}, 1, 1, 3, 7), replaySuccess,
+			[]replayCast{{b32("a2"), 1}, {b32("b3"), 1}, {b32("b1"), 1}, {b32("c1"), 1}}},
+		{"submit again", "submitSurvey", survey("result2", "user1", []string{"a1"}, 1), replayError, nil},
+		{"rank out of range", "submitSurvey", survey("result2", "user2", []string{"b1"}, 4), replayError, nil},
+		{"score over max", "submitSurvey", survey("result2", "user2", []string{"c1"}, 11), replayError, nil},
+		{"question after ballots", "insertQuestion", question("q5", 1, 0, "e1"), replayError, nil},
+	})
+	if got := r.option(b32("b3")).score; got != 3 {
+		t.Errorf("ranked first score %d, want 3", got)
+	}
+	if got := r.option(b32("b1")).score; got != 1 {
+		t.Errorf("ranked third score %d, want 1", got)
+	}
+	if got := r.option(b32("c1")).score; got != 7 {
+		t.Errorf("score %d, want 7", got)
+	}
+}
+
+func TestReplayBudget(t *testing.T) {
+	r := newTallyReplay()
+	ballot := func(id, user string, options ...string) replayArgs {
+		var ids [][32]byte
+		for _, o := range options {
+			ids = append(ids, b32(o))
+		}
+		return replayArgs{"id": b32(id), "vote_id": b32("vote1"), "user_id": b32(user), "option_ids": ids}
+	}
+	runReplay(t, r, []replayStep{
+		{"insert", "insertVote", insertVoteArgs("vote1", model.SelectBudget, "option1", "option2", "option3"), replaySuccess, nil},
+		{"cost over budget", "setBudget", replayArgs{"vote_id": b32("vote1"), "budget": int64(100),
+			"option_ids": [][32]byte{b32("option1")}, "costs": []int64{101}}, replayError, nil},
+		{"set budget", "setBudget", replayArgs{"vote_id": b32("vote1"), "budget": int64(100),
+			"option_ids": [][32]byte{b32("option1"), b32("option2"), b32("option3")}, "costs": []int64{60, 40, 50}}, replaySuccess, nil},
+		{"set again", "setBudget", replayArgs{"vote_id": b32("vote1"), "budget": int64(10),
+			"option_ids": [][32]byte{b32("option1")}, "costs": []int64{5}}, replayError, nil},
+		{"within budget", "castBudgetBallot", ballot("result1", "user1", "option1", "option2"), replaySuccess,
+			[]replayCast{{b32("option1"), 1}, {b32("option2"), 1}}},
+		{"over budget", "castBudgetBallot", ballot("result2", "user2", "option1", "option3"), replayError, nil},
+		{"repeated option", "castBudgetBallot", ballot("result2", "user2", "option2", "option2"), replayError, nil},
+		{"voted", "castBudgetBallot", ballot("result2", "user1", "option3"), replayError, nil},
+		{"add option", "addVoteOption", replayArgs{"id": b32("option4"), "vote_id": b32("vote1"), "content": b32("c")}, replayError, nil},
+	})
+}
+
+func TestReplayPetitionAndPairwise(t *testing.T) {
+	r := newTallyReplay()
+	sign := func(id, user string) replayArgs {
+		return replayArgs{"id": b32(id), "vote_id": b32("petition"), "user_id": b32(user)}
+	}
+	compare := func(id, user, winner, loser string) replayArgs {
+		return replayArgs{"id": b32(id), "vote_id": b32("pairwise"), "user_id": b32(user),
+			"winner_id": b32(winner), "loser_id": b32(loser)}
+	}
+	runReplay(t, r, []replayStep{
+		{"insert petition", "insertVote", insertVoteArgs("petition", model.SelectPetition, "sign"), replaySuccess, nil},
+		{"set petition", "setPetition", replayArgs{"vote_id": b32("petition"), "option_id": b32("sign"), "target": int32(2)}, replaySuccess, nil},
+		{"sign", "signPetition", sign("result1", "user1"), replaySuccess, []replayCast{{b32("sign"), 1}}},
+		{"sign again", "signPetition", sign("result2", "user1"), replayError, nil},
+		{"reach target", "signPetition", sign("result2", "user2"), replaySuccess, []replayCast{{b32("sign"), 2}}},
+		{"completed", "signPetition", sign("result3", "user3"), replayError, nil},
+
+		{"insert pairwise", "insertVote", insertVoteArgs("pairwise", model.SelectPairwise, "a", "b"), replaySuccess, nil},
+		{"compare", "compareOptions", compare("result4", "user1", "a", "b"), replaySuccess, []replayCast{{b32("a"), 1}}},
+		{"compare same option", "compareOptions", compare("result5", "user1", "a", "a"), replayError, nil},
+		{"compare option of other vote", "compareOptions", compare("result5", "user1", "a", "sign"), replayError, nil},
+		{"compare again", "compareOptions", compare("result5", "user1", "b", "a"), replaySuccess, []replayCast{{b32("b"), 1}}},
+		{"compare in plain vote", "compareOptions", replayArgs{"id": b32("result6"), "vote_id": b32("petition"),
+			"winner_id": b32("sign"), "loser_id": b32("a")}, replayError, nil},
+	})
+}
+
+func TestReplayDelegation(t *testing.T) {
+	r := newTallyReplay()
+	delegate := func(vote, delegator, delegate string) replayArgs {
+		return replayArgs{"vote_id": b32(vote), "delegator_id": b32(delegator), "delegate_id": b32(delegate)}
+	}
+	global := func(delegator, delegate string) replayArgs {
+		return replayArgs{"vote_id": [32]byte{}, "delegator_id": b32(delegator), "delegate_id": b32(delegate)}
+	}
+	resolve := func(delegator string) replayArgs {
+		return replayArgs{"vote_id": b32("vote1"), "delegator_id": b32(delegator)}
+	}
+	runReplay(t, r, []replayStep{
+		{"insert", "insertVote", insertVoteArgs("vote1", model.SelectMulti, "option1", "option2"), replaySuccess, nil},
+		{"vote 1", "updateVoteOption", replayArgs{"id": b32("option1"), "vote_id": b32("vote1")}, replaySuccess, nil},
+		{"record 1", "insertVoteResult", resultArgs("result1", "vote1", "option1", "alice"), replaySuccess, []replayCast{{b32("option1"), 1}}},
+		{"vote 2", "updateVoteOption", replayArgs{"id": b32("option2"), "vote_id": b32("vote1")}, replaySuccess, nil},
+		{"record 2", "insertVoteResult", resultArgs("result2", "vote1", "option2", "alice"), replaySuccess, []replayCast{{b32("option2"), 1}}},
+
+		{"self", "setDelegation", delegate("vote1", "bob", "bob"), replayError, nil},
+		{"voted delegator", "setDelegation", delegate("vote1", "alice", "bob"), replayError, nil},
+		{"missing vote", "setDelegation", delegate("vote9", "bob", "alice"), replayError, nil},
+		{"global to carol", "setDelegation", global("bob", "carol"), replaySuccess, nil},
+		{"vote overrides global", "setDelegation", delegate("vote1", "bob", "alice"), replaySuccess, nil},
+		{"resolve", "resolveDelegation", resolve("bob"), replaySuccess, []replayCast{{b32("option1"), 2}, {b32("option2"), 2}}},
+		{"resolve again", "resolveDelegation", resolve("bob"), replayError, nil},
+		{"chain of global", "setDelegation", global("dave", "bob"), replaySuccess, nil},
+		{"resolve chain", "resolveDelegation", resolve("dave"), replaySuccess, []replayCast{{b32("option1"), 3}, {b32("option2"), 3}}},
+		{"cycle 1", "setDelegation", global("erin", "frank"), replaySuccess, nil},
+		{"cycle 2", "setDelegation", global("frank", "erin"), replaySuccess, nil},
+		{"resolve cycle", "resolveDelegation", resolve("erin"), replayError, nil},
+		{"resolve no delegation", "resolveDelegation", resolve("carol"), replayError, nil},
+		{"resolve voter", "resolveDelegation", resolve("alice"), replayError, nil},
+		{"finalize", "finalizeVote", replayArgs{"id": b32("vote1")}, replaySuccess, nil},
+		{"set after finalize", "setDelegation", delegate("vote1", "grace", "alice"), replayError, nil},
+	})
+}
+
+func TestReplayDelegationTooLong(t *testing.T) {
+	r := newTallyReplay()
+	r.insertVote(insertVoteArgs("vote1", model.SelectSingle, "option1"))
+	users := make([][32]byte, maxDelegationDepth+2)
+	for i := range users {
+		users[i] = b32(string(rune('a' + i)))
+	}
+	for i := 0; i+1 < len(users); i++ {
+		r.setDelegation(replayArgs{"vote_id": b32("vote1"), "delegator_id": users[i], "delegate_id": users[i+1]})
+	}
+	r.insertVoteResult(replayArgs{"id": b32("result1"), "vote_id": b32("vote1"), "option_id": b32("option1"), "user_id": users[len(users)-1]})
+
+	if outcome := r.resolveDelegation(replayArgs{"vote_id": b32("vote1"), "delegator_id": users[0]}); outcome.code != replayError {
+		t.Errorf("chain of %d hops resolved", len(users)-1)
+	}
+	if outcome := r.resolveDelegation(replayArgs{"vote_id": b32("vote1"), "delegator_id": users[1]}); outcome.code != replaySuccess {
+		t.Errorf("chain of %d hops not resolved: %s", maxDelegationDepth, outcome.reason)
+	}
+}
+
+func TestReceiptCasts(t *testing.T) {
+	ABI := testABI(t)
+	logs := []rpc.TxLog{
+		testLog(t, ABI, "BallotCast", "vote1", b32("option1"), b32("user1"), int32(3)),
+		{Data: "0x"},
+		testLog(t, ABI, "VoteFinalized", "vote1", b32("option1"), int32(3)),
+		testLog(t, ABI, "DelegationResolved", "vote1", b32("user2"), b32("user1"), b32("option2"), int32(5)),
+		{Topics: []string{ABI.Events["BallotCast"].Id().Hex()}, Data: "0x01"},
+	}
+	want := []replayCast{{b32("option1"), 3}, {b32("option2"), 5}}
+	if got := receiptCasts(ABI, logs); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got := receiptCasts(ABI, nil); got != nil {
+		t.Errorf("no logs, got %v", got)
+	}
+}
@@ -0,0 +1,348 @@
+package service
+
+import (
+	"FunnyVoteGo/src/api/vm"
+	"FunnyVoteGo/src/constant"
+	"FunnyVoteGo/src/lib/eventbus"
+	"FunnyVoteGo/src/model"
+	"FunnyVoteGo/src/util"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/glog"
+	"github.com/hyperchain/gosdk/abi"
+	"github.com/hyperchain/gosdk/utils/ecdsa"
+)
+
+const (
+	// defaultRunoffTopN options entering runoff if not set
+	defaultRunoffTopN = 2
+	// defaultRunoffDuration duration of runoff round if not set, in seconds
+	defaultRunoffDuration = 24 * 60 * 60
+	// minRunoffDuration shortest runoff round, in seconds
+	minRunoffDuration = 60
+	// runoffClaimLease seconds before an unfinished runoff check can be claimed again
+	runoffClaimLease = 5 * 60
+	// maxRounds guard when walking rounds of a vote
+	maxRounds = 16
+)
+
+// InitRunoff spawn a runoff round when a plurality vote closes without any option reaching the majority
+func InitRunoff() {
+	eventbus.Subscribe(constant.EventVoteClosed, func(e eventbus.Event) {
+		spawnRunoff(e.VoteID)
+	})
+}
+
+// GetVoteRounds get all rounds linked with the vote and the combined outcome,
+// totals of rounds hidden by result visibility are not returned
+func GetVoteRounds(voteid string, userid uint) (*vm.VoteRounds, bool) {
+	vs, b := model.GetVoteSetting(map[string]interface{}{"vote_id": voteid})
+	if !b {
+		return nil, false
+	}
+	// 回到首轮
+	for i := 0; i < maxRounds && vs.PreviousVoteID != ""; i++ {
+		if vs, b = model.GetVoteSetting(map[string]interface{}{"vote_id": vs.PreviousVoteID}); !b {
+			return nil, false
+		}
+	}
+	key, err := InitKey()
+	if err != nil {
+		return nil, false
+	}
+
+	rounds := vm.VoteRounds{FirstVoteID: vs.VoteID}
+	for i := 0; i < maxRounds; i++ {
+		round, b := voteRound(vs, userid, key)
+		if !b {
+			return nil, false
+		}
+		rounds.Rounds = append(rounds.Rounds, *round)
+		if vs.RunoffVoteID == "" {
+			break
+		}
+		if vs, b = model.GetVoteSetting(map[string]interface{}{"vote_id": vs.RunoffVoteID}); !b {
+			return nil, false
+		}
+	}
+
+	last := rounds.Rounds[len(rounds.Rounds)-1]
+	rounds.Outcome = vm.RoundOutcome{VoteID: last.VoteID}
+	switch {
+	case last.Hidden:
+		rounds.Outcome.Reason = "结果暂不可见"
+	case last.Status != 3:
+		rounds.Outcome.Reason = "投票未结束"
+	case last.WinnerID == "":
+		rounds.Outcome.Reason = "无胜出选项"
+	case !last.MajorityReached && last.RunoffMajority > 0:
+		rounds.Outcome.Reason = "未达到胜出比例, 等待决选"
+	default:
+		rounds.Outcome.Decided = true
+		rounds.Outcome.WinnerID = last.WinnerID
+		rounds.Outcome.WinnerContent = last.WinnerContent
+	}
+	return &rounds, true
+}
+
+// voteRound result of a round, link on chain is checked against vote setting
+func voteRound(vs *model.VoteSetting, userid uint, key *ecdsa.Key) (*vm.VoteRound, bool) {
+	options, b := queryVoteOptions(vs.VoteID, key)
+	if !b {
+		return nil, false
+	}
+	round := vm.VoteRound{
+		VoteID:         vs.VoteID,
+		Round:          roundNumber(vs),
+		Title:          vs.Title,
+		Status:         voteStatus(vs.StartTime, vs.EndTime),
+		RunoffMajority: vs.RunoffMajority,
+		RunoffVoteID:   vs.RunoffVoteID,
+		RunoffTxHash:   vs.RunoffTxHash,
+	}
+	if vs.Closed {
+		round.Status = 3
+	}
+	prev, next, _, b := queryRound(vs.VoteID, key)
+	round.Linked = b && prev == chainID(vs.PreviousVoteID) && next == chainID(vs.RunoffVoteID)
+	if !round.Linked {
+		glog.Errorf("rounds of vote %s do not match links on chain", vs.VoteID)
+	}
+
+	if !resultVisible(vs, userid) {
+		round.Hidden = true
+		for i := range options {
+			options[i].Total = 0
+		}
+		round.Options = options
+		return &round, true
+	}
+	round.Options = options
+	for _, option := range options {
+		round.Total += option.Total
+	}
+	if winner, ok := pluralityWinner(options); ok {
+		round.WinnerID = winner.ID
+		round.WinnerContent = winner.Content
+		round.MajorityReached = vs.RunoffMajority == 0 || majorityReached(winner.Total, round.Total, vs.RunoffMajority)
+	}
+	return &round, true
+}
+
+// spawnRunoff create the next round with top options of the closed vote and link them on chain,
+// the vote is marked checked only when done, failed steps are retried by retryRunoffs
+func spawnRunoff(voteid string) {
+	vs, b := model.GetVoteSetting(map[string]interface{}{"vote_id": voteid})
+	if !b || vs.RunoffMajority == 0 || vs.RunoffChecked {
+		return
+	}
+	if !model.ClaimRunoffCheck(vs.ID, time.Now().Unix(), runoffClaimLease) {
+		return
+	}
+	round := roundNumber(vs) + 1
+	runoffid := vs.RunoffVoteID
+	if runoffid == "" {
+		var done bool
+		if runoffid, done = createRunoff(vs, round); runoffid == "" {
+			if done {
+				model.UpdateVoteSetting(vs.ID, map[string]interface{}{"runoff_checked": true})
+			}
+			return
+		}
+	}
+
+	txhash, err := invokeMethod("linkRunoff", map[string]string{
+		"vote_id":   voteid,
+		"runoff_id": runoffid,
+	})
+	if err != nil {
+		glog.Errorf("link runoff %s of vote %s fail: %v", runoffid, voteid, err)
+		return
+	}
+	if !model.UpdateVoteSetting(vs.ID, map[string]interface{}{"runoff_tx_hash": txhash, "runoff_checked": true}) {
+		return
+	}
+	publishLocalEvent(eventbus.Event{
+		Name:   constant.EventRunoffLinked,
+		VoteID: voteid,
+		TxHash: txhash,
+		Data: map[string]interface{}{
+			"vote_id":   voteid,
+			"runoff_id": runoffid,
+			"round":     strconv.Itoa(round),
+		},
+	})
+}
+
+// createRunoff create the runoff round of the vote and record it before linking on chain,
+// returns the runoff id, or whether no runoff is needed
+func createRunoff(vs *model.VoteSetting, round int) (string, bool) {
+	// 委托票权计入后再判断是否达到胜出比例
+	if !countDelegatedWeight(vs) {
+		glog.Errorf("count delegated weight of vote %s for runoff fail", vs.VoteID)
+		return "", false
+	}
+	// 创建者在投票结束后总能看到结果
+	vote, b := GetVoteStatus(&vm.GetVoteStatus{VoteID: vs.VoteID, UserID: vs.CreatorID})
+	if !b {
+		glog.Errorf("query vote %s for runoff fail", vs.VoteID)
+		return "", false
+	}
+	candidates := runoffCandidates(vote.Options, vs.RunoffMajority, vs.RunoffTopN)
+	if len(candidates) < 2 {
+		return "", true
+	}
+
+	invitees, b := model.GetVoteInviteeIDs(vs.VoteID)
+	if !b {
+		return "", false
+	}
+	duration := vs.RunoffDuration
+	if duration == 0 {
+		duration = defaultRunoffDuration
+	}
+	now := time.Now().Unix()
+	runoff := vm.VoteInit{
+		Title:            fmt.Sprintf("%s (第%d轮)", vs.Title, round),
+		Description:      vote.Description,
+		SelectType:       model.SelectSingle,
+		StartTime:        strconv.FormatInt(now, 10),
+		EndTime:          strconv.FormatInt(now+duration, 10),
+		CreatorID:        vs.CreatorID,
+		ResultVisibility: vs.ResultVisibility,
+		Invitees:         invitees,
+		Restricted:       vs.Restricted,
+		InviteOnly:       vs.InviteOnly,
+		Eligibility:      vs.Eligibility,
+	}
+	for _, option := range candidates {
+		runoff.Options = append(runoff.Options, option.Content)
+	}
+	runoffid, b := StartVote(&runoff)
+	if !b {
+		glog.Errorf("create runoff of vote %s fail", vs.VoteID)
+		return "", false
+	}
+	// 先记录下一轮, 关联失败重试时不会再次创建
+	if !model.UpdateVoteSetting(vs.ID, map[string]interface{}{"runoff_vote_id": runoffid}) {
+		glog.Errorf("record runoff %s of vote %s fail", runoffid, vs.VoteID)
+		return "", false
+	}
+	if rvs, b := model.GetVoteSetting(map[string]interface{}{"vote_id": runoffid}); b {
+		model.UpdateVoteSetting(rvs.ID, map[string]interface{}{"round": round, "previous_vote_id": vs.VoteID})
+	}
+	return runoffid, false
+}
+
+// retryRunoffs check runoff of closed votes again, whose spawning failed or was interrupted
+func retryRunoffs() {
+	vss, b := model.GetVoteSettings("closed = ? AND runoff_majority > ? AND runoff_checked = ?", true, 0, false)
+	if !b {
+		return
+	}
+	for _, vs := range vss {
+		spawnRunoff(vs.VoteID)
+	}
+}
+
+// runoffCandidates options entering runoff, none if the leading option reaches the majority,
+// options tied with the last one are included
+func runoffCandidates(options []model.Option, majority, topN int) []model.Option {
+	if topN == 0 {
+		topN = defaultRunoffTopN
+	}
+	sorted := make([]model.Option, len(options))
+	copy(sorted, options)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Total > sorted[j].Total })
+	var total uint
+	for _, option := range sorted {
+		total += option.Total
+	}
+	if total == 0 || majorityReached(sorted[0].Total, total, majority) {
+		return nil
+	}
+	var candidates []model.Option
+	for i, option := range sorted {
+		if option.Total == 0 || (i >= topN && option.Total < sorted[topN-1].Total) {
+			break
+		}
+		candidates = append(candidates, option)
+	}
+	return candidates
+}
+
+// pluralityWinner option with most votes, false if none or tied
+func pluralityWinner(options []model.Option) (model.Option, bool) {
+	var winner model.Option
+	tied := false
+	for _, option := range options {
+		switch {
+		case option.Total > winner.Total:
+			winner, tied = option, false
+		case option.Total == winner.Total:
+			tied = true
+		}
+	}
+	return winner, winner.Total > 0 && !tied
+}
+
+// majorityReached votes of the option exceed majority percent of all votes
+func majorityReached(votes, total uint, majority int) bool {
+	return total > 0 && votes*100 > uint(majority)*total
+}
+
+func roundNumber(vs *model.VoteSetting) int {
+	if vs.Round == 0 {
+		return 1
+	}
+	return vs.Round
+}
+
+// validateRunoff runoff applies to plurality votes only
+func validateRunoff(voteinit *vm.VoteInit) error {
+	if voteinit.RunoffMajority == 0 {
+		return nil
+	}
+	switch {
+	case voteinit.RunoffMajority < 0 || voteinit.RunoffMajority >= 100:
+		return &ContentError{Field: "runoff_majority", Reason: "百分比须在1到99之间"}
+	case len(voteinit.Questions) > 0 || voteinit.SelectType == model.SelectMulti:
+		return &ContentError{Field: "runoff_majority", Reason: "仅单选投票支持决选"}
+	case voteinit.RunoffTopN != 0 && (voteinit.RunoffTopN < 2 || voteinit.RunoffTopN > len(voteinit.Options)):
+		return &ContentError{Field: "runoff_top_n", Reason: "决选选项数须在2到选项数之间"}
+	case voteinit.RunoffDuration != 0 && voteinit.RunoffDuration < minRunoffDuration:
+		return &ContentError{Field: "runoff_duration", Reason: fmt.Sprintf("决选时长不能少于%d秒", minRunoffDuration)}
+	}
+	return nil
+}
+
+// queryRound query previous and next rounds of the vote on chain
+func queryRound(voteid string, key *ecdsa.Key) (string, string, int, bool) {
+	retu, err := InvokeContract(vm.ReqInvokeCon{
+		ContractAddr: ContractAddress(),
+		ContractCode: GetContractCode(),
+		MethodName:   "queryRound",
+		MethodParams: util.Struct2String(model.Vote{ID: voteid}),
+	}, key)
+	if err != nil {
+		return "", "", 0, false
+	}
+	ABI, _ := abi.JSON(strings.NewReader(retu.Abi))
+	var p_ok int32
+	var p_prev [32]byte
+	var p_next [32]byte
+	var p_round int32
+	res := []interface{}{&p_ok, &p_prev, &p_next, &p_round}
+	if sysErr := ABI.UnpackResult(&res, "queryRound", retu.Result); sysErr != nil {
+		glog.Info(sysErr)
+		return "", "", 0, false
+	}
+	if p_ok != 0 {
+		return "", "", 0, false
+	}
+	return util.ByteToString(p_prev[:]), util.ByteToString(p_next[:]), int(p_round), true
+}
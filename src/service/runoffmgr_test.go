@@ -0,0 +1,123 @@
+package service
+
+import (
+	"FunnyVoteGo/src/api/vm"
+	"FunnyVoteGo/src/model"
+	"reflect"
+	"testing"
+)
+
+func tallies(totals ...uint) []model.Option {
+	var options []model.Option
+	for i, total := range totals {
+		options = append(options, model.Option{ID: string(rune('a' + i)), Total: total})
+	}
+	return options
+}
+
+func optionIDs(options []model.Option) []string {
+	var ids []string
+	for _, o := range options {
+		ids = append(ids, o.ID)
+	}
+	return ids
+}
+
+func TestRunoffCandidates(t *testing.T) {
+	tests := []struct {
+		name     string
+		totals   []uint
+		majority int
+		topN     int
+		want     []string
+	}{
+		{"majority reached", []uint{6, 3, 1}, 50, 2, nil},
+		{"exactly majority is not reached", []uint{5, 3, 2}, 50, 2, []string{"a", "b"}},
+		{"top two", []uint{4, 3, 2, 1}, 50, 2, []string{"a", "b"}},
+		{"sorted by total", []uint{1, 4, 2, 3}, 50, 2, []string{"b", "d"}},
+		{"default top two", []uint{4, 3, 2, 1}, 50, 0, []string{"a", "b"}},
+		{"top three", []uint{4, 3, 2, 1}, 50, 3, []string{"a", "b", "c"}},
+		{"tie at boundary included", []uint{4, 3, 3, 1}, 50, 2, []string{"a", "b", "c"}},
+		{"tie at top", []uint{3, 3, 3, 1}, 50, 2, []string{"a", "b", "c"}},
+		{"tie below boundary excluded", []uint{4, 3, 2, 2}, 50, 2, []string{"a", "b"}},
+		{"zero votes excluded", []uint{4, 3, 2, 0, 0}, 50, 4, []string{"a", "b", "c"}},
+		{"no votes", []uint{0, 0, 0}, 50, 2, nil},
+		{"top n above options", []uint{2, 2}, 60, 5, []string{"a", "b"}},
+		{"higher majority", []uint{6, 3, 1}, 60, 2, []string{"a", "b"}},
+		{"no options", nil, 50, 2, nil},
+	}
+	for _, tt := range tests {
+		got := optionIDs(runoffCandidates(tallies(tt.totals...), tt.majority, tt.topN))
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestPluralityWinner(t *testing.T) {
+	tests := []struct {
+		totals []uint
+		want   string
+		ok     bool
+	}{
+		{[]uint{3, 5, 1}, "b", true},
+		{[]uint{5, 5, 1}, "", false},
+		{[]uint{1, 5, 5}, "", false},
+		{[]uint{5, 5, 6}, "c", true},
+		{[]uint{0, 0}, "", false},
+		{[]uint{0, 1}, "b", true},
+		{nil, "", false},
+	}
+	for _, tt := range tests {
+		winner, ok := pluralityWinner(tallies(tt.totals...))
+		if ok != tt.ok || (ok && winner.ID != tt.want) {
+			t.Errorf("pluralityWinner(%v) = %s %v, want %s %v", tt.totals, winner.ID, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestMajorityReached(t *testing.T) {
+	tests := []struct {
+		votes, total uint
+		majority     int
+		want         bool
+	}{
+		{6, 10, 50, true},
+		{5, 10, 50, false},
+		{51, 100, 50, true},
+		{2, 3, 66, true},
+		{2, 3, 67, false},
+		{1, 1, 99, true},
+		{0, 0, 50, false},
+	}
+	for _, tt := range tests {
+		if got := majorityReached(tt.votes, tt.total, tt.majority); got != tt.want {
+			t.Errorf("majorityReached(%d, %d, %d) = %v, want %v", tt.votes, tt.total, tt.majority, got, tt.want)
+		}
+	}
+}
+
+func TestValidateRunoff(t *testing.T) {
+	options := []string{"a", "b", "c"}
+	tests := []struct {
+		name     string
+		voteinit vm.VoteInit
+		wantErr  bool
+	}{
+		{"no runoff", vm.VoteInit{SelectType: model.SelectMulti}, false},
+		{"single", vm.VoteInit{SelectType: model.SelectSingle, Options: options, RunoffMajority: 50}, false},
+		{"majority too high", vm.VoteInit{SelectType: model.SelectSingle, Options: options, RunoffMajority: 100}, true},
+		{"negative majority", vm.VoteInit{SelectType: model.SelectSingle, Options: options, RunoffMajority: -1}, true},
+		{"multiple choice", vm.VoteInit{SelectType: model.SelectMulti, Options: options, RunoffMajority: 50}, true},
+		{"top n of all options", vm.VoteInit{SelectType: model.SelectSingle, Options: options, RunoffMajority: 50, RunoffTopN: 3}, false},
+		{"top n above options", vm.VoteInit{SelectType: model.SelectSingle, Options: options, RunoffMajority: 50, RunoffTopN: 4}, true},
+		{"top one", vm.VoteInit{SelectType: model.SelectSingle, Options: options, RunoffMajority: 50, RunoffTopN: 1}, true},
+		{"short duration", vm.VoteInit{SelectType: model.SelectSingle, Options: options, RunoffMajority: 50, RunoffDuration: minRunoffDuration - 1}, true},
+		{"min duration", vm.VoteInit{SelectType: model.SelectSingle, Options: options, RunoffMajority: 50, RunoffDuration: minRunoffDuration}, false},
+	}
+	for _, tt := range tests {
+		if err := validateRunoff(&tt.voteinit); (err != nil) != tt.wantErr {
+			t.Errorf("%s: got %v, want err %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package service
+
+import (
+	"FunnyVoteGo/src/constant"
+	"FunnyVoteGo/src/lib/eventbus"
+	"FunnyVoteGo/src/model"
+	"time"
+
+	"github.com/glog"
+	"github.com/robfig/cron"
+)
+
+var scheduler = cron.New()
+
+// StartScheduler run periodic jobs of votes
+func StartScheduler() {
+	if err := scheduler.AddFunc("@every 1m", checkOpenedVotes); err != nil {
+		glog.Errorf("add schedule job fail: %v", err)
+		return
+	}
+	if err := scheduler.AddFunc("@every 1m", checkClosedVotes); err != nil {
+		glog.Errorf("add schedule job fail: %v", err)
+		return
+	}
+	if err := scheduler.AddFunc("@every 1m", retryRunoffs); err != nil {
+		glog.Errorf("add schedule job fail: %v", err)
+		return
+	}
+	if err := scheduler.AddFunc("@every 1m", runVoteTemplates); err != nil {
+		glog.Errorf("add schedule job fail: %v", err)
+		return
+	}
+	if err := scheduler.AddFunc("@every 10s", retryWebhookDeliveries); err != nil {
+		glog.Errorf("add schedule job fail: %v", err)
+		return
+	}
+	if err := scheduler.AddFunc("@daily", cleanRevokedTokens); err != nil {
+		glog.Errorf("add schedule job fail: %v", err)
+		return
+	}
+	scheduler.Start()
+}
+
+// checkOpenedVotes publish VoteOpened once for votes past start time
+func checkOpenedVotes() {
+	vss, b := model.GetVoteSettings("opened = ? AND start_time <= ?", false, time.Now().Unix())
+	if !b {
+		return
+	}
+	for _, vs := range vss {
+		if !model.UpdateVoteSetting(vs.ID, map[string]interface{}{"opened": true}) {
+			continue
+		}
+		eventbus.Publish(eventbus.Event{
+			Name:   constant.EventVoteOpened,
+			VoteID: vs.VoteID,
+			Data: map[string]interface{}{
+				"vote_id":    vs.VoteID,
+				"start_time": vs.StartTime,
+			},
+		})
+	}
+}
+
+// checkClosedVotes publish VoteClosed once for votes past end time
+func checkClosedVotes() {
+	vss, b := model.GetVoteSettings("closed = ? AND end_time <= ?", false, time.Now().Unix())
+	if !b {
+		return
+	}
+	for _, vs := range vss {
+		if !model.UpdateVoteSetting(vs.ID, map[string]interface{}{"closed": true}) {
+			continue
+		}
+		eventbus.Publish(eventbus.Event{
+			Name:   constant.EventVoteClosed,
+			VoteID: vs.VoteID,
+			Data: map[string]interface{}{
+				"vote_id":  vs.VoteID,
+				"end_time": vs.EndTime,
+			},
+		})
+	}
+}
+
+// cleanRevokedTokens remove revoked tokens which are expired anyway
+func cleanRevokedTokens() {
+	model.DeleteExpiredTokens(time.Now().Unix())
+}
@@ -0,0 +1,40 @@
+package service
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/hyperchain/gosdk/common"
+	"github.com/hyperchain/gosdk/utils/encrypt"
+)
+
+// signDigest sign a sha256 digest by the key of the service, returns hex signature and address of the key
+func signDigest(digest []byte) (string, string, error) {
+	if len(digest) != 32 {
+		return "", "", fmt.Errorf("摘要须为32字节")
+	}
+	key, err := InitKey()
+	if err != nil {
+		return "", "", err
+	}
+	sig, err := encrypt.Secp256k1Sign(digest, common.FromHex(key.GetPrivKey()))
+	if err != nil {
+		return "", "", err
+	}
+	return hex.EncodeToString(sig), key.GetAddress(), nil
+}
+
+// recoverSigner address of the key which signed the digest, the signature is as returned by signDigest
+func recoverSigner(digest []byte, hexSig string) (string, error) {
+	sig, err := hex.DecodeString(strings.TrimPrefix(hexSig, "0x"))
+	if err != nil || len(sig) != 66 {
+		return "", fmt.Errorf("签名格式错误")
+	}
+	// 首字节为签名算法标识
+	addr, err := encrypt.NewEcdsaEncrypto("ecdsa").UnSign(digest, sig[1:])
+	if err != nil {
+		return "", err
+	}
+	return addr.Hex(), nil
+}
@@ -0,0 +1,361 @@
+package service
+
+import (
+	"FunnyVoteGo/src/api/vm"
+	"FunnyVoteGo/src/constant"
+	"FunnyVoteGo/src/lib/eventbus"
+	"FunnyVoteGo/src/model"
+	"FunnyVoteGo/src/util"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/glog"
+	"github.com/hyperchain/gosdk/abi"
+	"github.com/hyperchain/gosdk/utils/ecdsa"
+)
+
+const (
+	// maxSurveyQuestions most questions of a survey
+	maxSurveyQuestions = 50
+	// maxQuestionScore highest max score of a score question
+	maxQuestionScore = 100
+)
+
+// SubmitSurvey submit answers to all questions of a survey in one ballot,
+// answers are stored off-chain and their hash is written on chain with the counted options
+func SubmitSurvey(sb *vm.SurveyBallot) (err error) {
+	vs, b := model.GetVoteSetting(map[string]interface{}{"vote_id": sb.VoteID})
+	if !b || !vs.Survey {
+		return fmt.Errorf("该投票不是问卷")
+	}
+	// 合约不检查投票时间
+	if vs.Closed || voteStatus(vs.StartTime, vs.EndTime) != 2 {
+		return &EligibilityError{Reason: "问卷未在进行中"}
+	}
+	release, err := admitBallot(sb.VoteID, sb.UserID, sb.InviteCode)
+	if err != nil {
+		return err
+	}
+	var txhash string
+	defer func() {
+		// 仅在选票未上链时释放邀请码
+		if err != nil && txhash == "" {
+			release()
+		}
+	}()
+
+	key, err := InitKey()
+	if err != nil {
+		return err
+	}
+	questions, b := querySurveyQuestions(sb.VoteID, key)
+	if !b {
+		return errBallotFail
+	}
+	answers, optionids, values, err := normalizeAnswers(questions, sb.Answers)
+	if err != nil {
+		return err
+	}
+	// 答案以规范化的json存链下, 链上只存哈希
+	bs, _ := json.Marshal(answers)
+	hash := contentHash(string(bs))
+	if !model.SaveContent(hash, string(bs)) {
+		return errBallotFail
+	}
+	ballotid := chainID(util.StringUUID())
+	txhash, err = invokeMethod("submitSurvey", map[string]interface{}{
+		"id":           ballotid,
+		"vote_id":      sb.VoteID,
+		"user_id":      strconv.Itoa(int(sb.UserID)),
+		"answers_hash": "0x" + hash,
+		"create_time":  util.GetNowTimeString(),
+		"option_ids":   optionids,
+		"values":       values,
+	})
+	if err != nil {
+		glog.Errorf("submit survey %s of user %d fail: %v", sb.VoteID, sb.UserID, err)
+		return errBallotFail
+	}
+
+	if _, b := model.CreateHashRecord(&model.HashRecord{
+		VoteID:        sb.VoteID,
+		UserID:        sb.UserID,
+		OptionContent: "0x" + hash,
+		BallotID:      ballotid,
+		TxHash:        txhash,
+	}); !b {
+		// 选票已上链, 不再返回失败
+		glog.Errorf("create hash record of survey ballot %s in vote %s fail", ballotid, sb.VoteID)
+	}
+	publishLocalEvent(eventbus.Event{
+		Name:   constant.EventSurveySubmitted,
+		VoteID: sb.VoteID,
+		TxHash: txhash,
+		Data: map[string]interface{}{
+			"vote_id":      sb.VoteID,
+			"user_id":      strconv.Itoa(int(sb.UserID)),
+			"answers_hash": hash,
+		},
+	})
+	publishLocalEvent(eventbus.Event{
+		Name:   constant.EventBallotCast,
+		VoteID: sb.VoteID,
+		TxHash: txhash,
+		Data: map[string]interface{}{
+			"vote_id": sb.VoteID,
+			"user_id": strconv.Itoa(int(sb.UserID)),
+		},
+	})
+	return nil
+}
+
+// normalizeAnswers check answers against questions on chain, every question must be answered,
+// returns answers in order of questions, and options with values as submitted to contract
+func normalizeAnswers(questions []model.Question, answers []vm.SurveyAnswer) ([]vm.SurveyAnswer, []string, []string, error) {
+	byQuestion := make(map[string]vm.SurveyAnswer)
+	for _, a := range answers {
+		id := chainID(a.QuestionID)
+		if _, ok := byQuestion[id]; ok {
+			return nil, nil, nil, &ContentError{Field: "answers", Reason: "问题重复作答"}
+		}
+		byQuestion[id] = a
+	}
+	if len(byQuestion) != len(questions) {
+		return nil, nil, nil, &ContentError{Field: "answers", Reason: "须回答全部问题"}
+	}
+
+	var normalized []vm.SurveyAnswer
+	var optionids, values []string
+	for qi, q := range questions {
+		field := "answers[" + strconv.Itoa(qi) + "]"
+		a, ok := byQuestion[q.ID]
+		if !ok {
+			return nil, nil, nil, &ContentError{Field: field, Reason: "须回答全部问题"}
+		}
+		valid := make(map[string]bool)
+		for _, o := range q.Options {
+			valid[o.ID] = true
+		}
+		n := len(a.OptionIDs)
+		switch q.SelectType {
+		case model.SelectSingle:
+			if n != 1 {
+				return nil, nil, nil, &ContentError{Field: field, Reason: "单选题须选择一个选项"}
+			}
+		case model.SelectScore:
+			if len(a.Values) != n {
+				return nil, nil, nil, &ContentError{Field: field, Reason: "分数与选项数量不一致"}
+			}
+		}
+		if n == 0 {
+			return nil, nil, nil, &ContentError{Field: field, Reason: "须至少选择一个选项"}
+		}
+
+		na := vm.SurveyAnswer{QuestionID: q.ID}
+		seen := make(map[string]bool)
+		for i, id := range a.OptionIDs {
+			id = chainID(id)
+			if !valid[id] || seen[id] {
+				return nil, nil, nil, &ContentError{Field: field, Reason: "选项错误"}
+			}
+			seen[id] = true
+			// 单选多选题值为1, 排序题值为名次, 打分题值为分数
+			value := 1
+			switch q.SelectType {
+			case model.SelectRanked:
+				value = i + 1
+			case model.SelectScore:
+				value = a.Values[i]
+				if value < 0 || value > q.MaxScore {
+					return nil, nil, nil, &ContentError{Field: field, Reason: fmt.Sprintf("分数须在0到%d之间", q.MaxScore)}
+				}
+			}
+			na.OptionIDs = append(na.OptionIDs, id)
+			na.Values = append(na.Values, value)
+			optionids = append(optionids, id)
+			values = append(values, strconv.Itoa(value))
+		}
+		normalized = append(normalized, na)
+	}
+	return normalized, optionids, values, nil
+}
+
+// validateQuestions check questions of a survey before the vote is created
+func validateQuestions(questions []vm.QuestionInit) error {
+	if len(questions) > maxSurveyQuestions {
+		return &ContentError{Field: "questions", Reason: fmt.Sprintf("问题不能超过%d个", maxSurveyQuestions)}
+	}
+	for i, q := range questions {
+		field := "questions[" + strconv.Itoa(i) + "]"
+		if err := validateText(field+".title", q.Title); err != nil {
+			return err
+		}
+		if q.SelectType < model.SelectSingle || q.SelectType > model.SelectScore {
+			return &ContentError{Field: field + ".select_type", Reason: "题型错误"}
+		}
+		if q.SelectType == model.SelectScore && (q.MaxScore <= 0 || q.MaxScore > maxQuestionScore) {
+			return &ContentError{Field: field + ".max_score", Reason: fmt.Sprintf("最高分须在1到%d之间", maxQuestionScore)}
+		}
+		if len(q.Options) == 0 {
+			return &ContentError{Field: field + ".options", Reason: "选项不能为空"}
+		}
+		for j, option := range q.Options {
+			if err := validateText(field+".options["+strconv.Itoa(j)+"]", option); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// commitSurveyQuestions insert questions and their options of the survey on chain
+func commitSurveyQuestions(voteid string, questions []vm.QuestionInit) bool {
+	for _, q := range questions {
+		title, b := toChainText(q.Title)
+		if !b {
+			return false
+		}
+		var optionids, contents []string
+		for _, option := range q.Options {
+			content, b := toChainText(option)
+			if !b {
+				return false
+			}
+			optionids = append(optionids, util.StringUUID())
+			contents = append(contents, content)
+		}
+		maxScore := 0
+		if q.SelectType == model.SelectScore {
+			maxScore = q.MaxScore
+		}
+		if _, err := invokeMethod("insertQuestion", map[string]interface{}{
+			"id":              util.StringUUID(),
+			"vote_id":         voteid,
+			"title":           title,
+			"select_type":     strconv.Itoa(q.SelectType),
+			"max_score":       strconv.Itoa(maxScore),
+			"option_ids":      optionids,
+			"option_contents": contents,
+		}); err != nil {
+			glog.Errorf("insert question of survey %s fail: %v", voteid, err)
+			return false
+		}
+	}
+	return true
+}
+
+// querySurveyQuestions query questions of the survey with their options and results
+func querySurveyQuestions(voteid string, key *ecdsa.Key) ([]model.Question, bool) {
+	retu, err := InvokeContract(vm.ReqInvokeCon{
+		ContractAddr: ContractAddress(),
+		ContractCode: GetContractCode(),
+		MethodName:   "querySurvey",
+		MethodParams: util.Struct2String(model.Vote{ID: voteid}),
+	}, key)
+	if err != nil {
+		return nil, false
+	}
+	ABI, _ := abi.JSON(strings.NewReader(retu.Abi))
+	var p_ok int32
+	var p_qarray [][32]byte
+	var p_tarray [][32]byte
+	var p_sarray []int32
+	var p_marray []int32
+	res := []interface{}{&p_ok, &p_qarray, &p_tarray, &p_sarray, &p_marray}
+	if sysErr := ABI.UnpackResult(&res, "querySurvey", retu.Result); sysErr != nil {
+		glog.Info(sysErr)
+		return nil, false
+	}
+	var questions []model.Question
+	for i := range p_qarray {
+		var question model.Question
+		question.ID = util.ByteToString(p_qarray[i][:])
+		title, ok := fromChainText(p_tarray[i])
+		question.Title = title
+		question.Tampered = !ok
+		question.SelectType = int(p_sarray[i])
+		question.MaxScore = int(p_marray[i])
+		options, b := queryQuestionOptions(voteid, question.ID, key)
+		if !b {
+			return nil, false
+		}
+		question.Options = options
+		questions = append(questions, question)
+	}
+	return questions, true
+}
+
+// queryQuestionOptions query options of a question with totals and scores
+func queryQuestionOptions(voteid, questionid string, key *ecdsa.Key) ([]model.Option, bool) {
+	retu, err := InvokeContract(vm.ReqInvokeCon{
+		ContractAddr: ContractAddress(),
+		ContractCode: GetContractCode(),
+		MethodName:   "queryQuestionOptions",
+		MethodParams: util.Struct2String(model.Vote{ID: questionid}),
+	}, key)
+	if err != nil {
+		return nil, false
+	}
+	ABI, _ := abi.JSON(strings.NewReader(retu.Abi))
+	var p_ok int32
+	var p_oarray [][32]byte
+	var p_barray [][32]byte
+	var p_iarray []int32
+	var p_sarray []int32
+	res := []interface{}{&p_ok, &p_oarray, &p_barray, &p_iarray, &p_sarray}
+	if sysErr := ABI.UnpackResult(&res, "queryQuestionOptions", retu.Result); sysErr != nil {
+		glog.Info(sysErr)
+		return nil, false
+	}
+	var options []model.Option
+	for i := range p_oarray {
+		var option model.Option
+		option.ID = util.ByteToString(p_oarray[i][:])
+		content, ok := fromChainText(p_barray[i])
+		option.Content = content
+		option.Tampered = !ok
+		option.Total = uint(p_iarray[i])
+		option.Score = int(p_sarray[i])
+		option.VoteID = voteid
+		options = append(options, option)
+	}
+	return options, true
+}
+
+// surveyTampered fields of the survey whose off-chain content does not match hash on chain
+func surveyTampered(questions []model.Question) []string {
+	var tampered []string
+	for _, q := range questions {
+		if q.Tampered {
+			tampered = append(tampered, "question:"+q.ID)
+		}
+		for _, o := range q.Options {
+			if o.Tampered {
+				tampered = append(tampered, "option:"+o.ID)
+			}
+		}
+	}
+	return tampered
+}
+
+// surveyVoteParams params of insertVote for a survey, which has no options of its own
+func surveyVoteParams(vote *model.Vote2) string {
+	bs, err := json.Marshal(map[string]interface{}{
+		"id":              vote.ID,
+		"title":           vote.Title,
+		"description":     vote.Description,
+		"select_type":     strconv.Itoa(vote.SelectType),
+		"start_time":      vote.StartTime,
+		"end_time":        vote.EndTime,
+		"create_time":     vote.CreateTime,
+		"creator_id":      strconv.Itoa(int(vote.CreatorID)),
+		"option_ids":      []string{},
+		"option_contents": []string{},
+	})
+	if err != nil {
+		return ""
+	}
+	return string(bs)
+}
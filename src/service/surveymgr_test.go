@@ -0,0 +1,115 @@
+package service
+
+import (
+	"FunnyVoteGo/src/api/vm"
+	"FunnyVoteGo/src/model"
+	"reflect"
+	"testing"
+)
+
+func testQuestions() []model.Question {
+	options := func(ids ...string) []model.Option {
+		var os []model.Option
+		for _, id := range ids {
+			os = append(os, model.Option{ID: id})
+		}
+		return os
+	}
+	return []model.Question{
+		{ID: "q1", SelectType: model.SelectSingle, Options: options("a1", "a2")},
+		{ID: "q2", SelectType: model.SelectMulti, Options: options("b1", "b2", "b3")},
+		{ID: "q3", SelectType: model.SelectRanked, Options: options("c1", "c2", "c3")},
+		{ID: "q4", SelectType: model.SelectScore, MaxScore: 5, Options: options("d1", "d2")},
+	}
+}
+
+func TestNormalizeAnswers(t *testing.T) {
+	answers := []vm.SurveyAnswer{
+		{QuestionID: "q4", OptionIDs: []string{"d2", "d1"}, Values: []int{5, 0}},
+		{QuestionID: "q3", OptionIDs: []string{"c3", "c1"}},
+		{QuestionID: "q1", OptionIDs: []string{"a2"}},
+		{QuestionID: "q2", OptionIDs: []string{"b3", "b1"}, Values: []int{9, 9}},
+	}
+	normalized, optionids, values, err := normalizeAnswers(testQuestions(), answers)
+	if err != nil {
+		t.Fatalf("valid answers rejected: %v", err)
+	}
+	want := []vm.SurveyAnswer{
+		{QuestionID: "q1", OptionIDs: []string{"a2"}, Values: []int{1}},
+		{QuestionID: "q2", OptionIDs: []string{"b3", "b1"}, Values: []int{1, 1}},
+		{QuestionID: "q3", OptionIDs: []string{"c3", "c1"}, Values: []int{1, 2}},
+		{QuestionID: "q4", OptionIDs: []string{"d2", "d1"}, Values: []int{5, 0}},
+	}
+	if !reflect.DeepEqual(normalized, want) {
+		t.Errorf("normalized %v, want %v", normalized, want)
+	}
+	if want := []string{"a2", "b3", "b1", "c3", "c1", "d2", "d1"}; !reflect.DeepEqual(optionids, want) {
+		t.Errorf("option ids %v, want %v", optionids, want)
+	}
+	if want := []string{"1", "1", "1", "1", "2", "5", "0"}; !reflect.DeepEqual(values, want) {
+		t.Errorf("values %v, want %v", values, want)
+	}
+}
+
+func TestNormalizeAnswersInvalid(t *testing.T) {
+	valid := func() []vm.SurveyAnswer {
+		return []vm.SurveyAnswer{
+			{QuestionID: "q1", OptionIDs: []string{"a1"}},
+			{QuestionID: "q2", OptionIDs: []string{"b1"}},
+			{QuestionID: "q3", OptionIDs: []string{"c1"}},
+			{QuestionID: "q4", OptionIDs: []string{"d1"}, Values: []int{3}},
+		}
+	}
+	tests := []struct {
+		name   string
+		change func(as []vm.SurveyAnswer) []vm.SurveyAnswer
+	}{
+		{"question missing", func(as []vm.SurveyAnswer) []vm.SurveyAnswer { return as[1:] }},
+		{"question answered twice", func(as []vm.SurveyAnswer) []vm.SurveyAnswer { return append(as, as[0]) }},
+		{"unknown question", func(as []vm.SurveyAnswer) []vm.SurveyAnswer {
+			as[0].QuestionID = "q9"
+			return as
+		}},
+		{"single with two options", func(as []vm.SurveyAnswer) []vm.SurveyAnswer {
+			as[0].OptionIDs = []string{"a1", "a2"}
+			return as
+		}},
+		{"multiple with no option", func(as []vm.SurveyAnswer) []vm.SurveyAnswer {
+			as[1].OptionIDs = nil
+			return as
+		}},
+		{"duplicate option", func(as []vm.SurveyAnswer) []vm.SurveyAnswer {
+			as[1].OptionIDs = []string{"b1", "b1"}
+			return as
+		}},
+		{"duplicate rank", func(as []vm.SurveyAnswer) []vm.SurveyAnswer {
+			as[2].OptionIDs = []string{"c2", "c2"}
+			return as
+		}},
+		{"option of other question", func(as []vm.SurveyAnswer) []vm.SurveyAnswer {
+			as[2].OptionIDs = []string{"b1"}
+			return as
+		}},
+		{"score above max", func(as []vm.SurveyAnswer) []vm.SurveyAnswer {
+			as[3].Values = []int{6}
+			return as
+		}},
+		{"negative score", func(as []vm.SurveyAnswer) []vm.SurveyAnswer {
+			as[3].Values = []int{-1}
+			return as
+		}},
+		{"score without value", func(as []vm.SurveyAnswer) []vm.SurveyAnswer {
+			as[3].Values = nil
+			return as
+		}},
+	}
+	if _, _, _, err := normalizeAnswers(testQuestions(), valid()); err != nil {
+		t.Fatalf("valid answers rejected: %v", err)
+	}
+	for _, tt := range tests {
+		_, _, _, err := normalizeAnswers(testQuestions(), tt.change(valid()))
+		if _, ok := err.(*ContentError); !ok {
+			t.Errorf("%s: got %v, want content error", tt.name, err)
+		}
+	}
+}
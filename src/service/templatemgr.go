@@ -0,0 +1,417 @@
+package service
+
+import (
+	"FunnyVoteGo/src/api/vm"
+	"FunnyVoteGo/src/model"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/glog"
+	"github.com/robfig/cron"
+)
+
+const (
+	// minTemplateDuration shortest vote started from a template, in seconds
+	minTemplateDuration = 60
+	// maxTemplateDuration longest vote started from a template, in seconds
+	maxTemplateDuration = 366 * 24 * 60 * 60
+	// recurrenceSamples consecutive runs checked against the duration
+	recurrenceSamples = 400
+	// maxOccurrenceClaims attempts to number a vote when runs of a template race
+	maxOccurrenceClaims = 5
+)
+
+// errTemplateNotFound template does not exist or belongs to another user
+var errTemplateNotFound = fmt.Errorf("模板不存在")
+
+// CreateVoteTemplate create a vote template, checked the same way as a vote starting now
+func CreateVoteTemplate(ti *vm.TemplateInit) (*vm.VoteTemplate, error) {
+	if ti.SelectType == 0 {
+		ti.SelectType = model.SelectSingle
+	}
+	if ti.Name == "" {
+		ti.Name = ti.Title
+	}
+	t := model.VoteTemplate{
+		CreatorID:        ti.CreatorID,
+		Name:             ti.Name,
+		Title:            ti.Title,
+		Description:      ti.Description,
+		SelectType:       ti.SelectType,
+		ResultVisibility: ti.ResultVisibility,
+		Restricted:       ti.Restricted,
+		InviteOnly:       ti.InviteOnly,
+		Eligibility:      ti.Eligibility,
+		Duration:         ti.Duration,
+		Recurrence:       ti.Recurrence,
+	}
+	bs, _ := json.Marshal(ti.Options)
+	t.Options = string(bs)
+	bs, _ = json.Marshal(ti.Invitees)
+	t.Invitees = string(bs)
+	return saveVoteTemplate(&t)
+}
+
+// CloneVoteTemplate create a vote template from an existing vote by its creator or admins,
+// the duration of the vote is kept
+func CloneVoteTemplate(tc *vm.TemplateClone) (*vm.VoteTemplate, error) {
+	if !canManageVote(tc.VoteID, tc.CreatorID) {
+		return nil, &EligibilityError{Reason: "无权管理该投票"}
+	}
+	vs, b := model.GetVoteSetting(map[string]interface{}{"vote_id": tc.VoteID})
+	if !b {
+		return nil, fmt.Errorf("投票不存在")
+	}
+	if vs.Survey || vs.Budget != 0 || vs.SignatureTarget != 0 || vs.Pairwise {
+		return nil, &ContentError{Field: "vote_id", Reason: "仅单选和多选投票可以作为模板"}
+	}
+	vote, b := GetVoteStatus(&vm.GetVoteStatus{VoteID: tc.VoteID, UserID: vs.CreatorID})
+	if !b {
+		return nil, fmt.Errorf("查询投票失败")
+	}
+	if len(vote.Tampered) > 0 {
+		return nil, &ContentError{Field: "vote_id", Reason: "投票内容与链上哈希不一致"}
+	}
+	invitees, b := model.GetVoteInviteeIDs(tc.VoteID)
+	if !b {
+		return nil, fmt.Errorf("查询受邀用户失败")
+	}
+	var options []string
+	for _, option := range vote.Options {
+		options = append(options, option.Content)
+	}
+	name := tc.Name
+	if name == "" {
+		name = vs.Title
+	}
+	t := model.VoteTemplate{
+		CreatorID:        tc.CreatorID,
+		Name:             name,
+		Title:            vs.Title,
+		Description:      vote.Description,
+		SelectType:       vote.SelectType,
+		ResultVisibility: vs.ResultVisibility,
+		Restricted:       vs.Restricted,
+		InviteOnly:       vs.InviteOnly,
+		Eligibility:      vs.Eligibility,
+		Duration:         vs.EndTime - vs.StartTime,
+		Recurrence:       tc.Recurrence,
+		SourceVoteID:     tc.VoteID,
+	}
+	bs, _ := json.Marshal(options)
+	t.Options = string(bs)
+	bs, _ = json.Marshal(invitees)
+	t.Invitees = string(bs)
+	return saveVoteTemplate(&t)
+}
+
+// GetVoteTemplates get templates of the user
+func GetVoteTemplates(userid uint) ([]vm.VoteTemplate, bool) {
+	ts, b := model.GetVoteTemplates("creator_id = ?", userid)
+	if !b {
+		return nil, false
+	}
+	var templates []vm.VoteTemplate
+	for i := range ts {
+		templates = append(templates, templateInfo(&ts[i]))
+	}
+	return templates, true
+}
+
+// StartVoteTemplate start an occurrence of the template now, besides its recurrence
+func StartVoteTemplate(id, userid uint) (string, error) {
+	t, err := ownTemplate(id, userid)
+	if err != nil {
+		return "", err
+	}
+	voteid, b := startOccurrence(t, time.Now().Unix())
+	if !b {
+		return "", fmt.Errorf("创建投票失败")
+	}
+	return voteid, nil
+}
+
+// SetTemplateRecurrence change recurrence rule of the template, an empty rule stops recurrence
+func SetTemplateRecurrence(tr *vm.TemplateRecurrence, userid uint) (*vm.VoteTemplate, error) {
+	t, err := ownTemplate(tr.ID, userid)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkRecurrence(tr.Recurrence, t.Duration, time.Now()); err != nil {
+		return nil, err
+	}
+	next, err := nextRun(tr.Recurrence, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	if !model.UpdateVoteTemplate(t.ID, map[string]interface{}{"recurrence": tr.Recurrence, "next_run": next}) {
+		return nil, fmt.Errorf("保存模板失败")
+	}
+	t.Recurrence, t.NextRun = tr.Recurrence, next
+	info := templateInfo(t)
+	return &info, nil
+}
+
+// DeleteVoteTemplate delete the template and stop its recurrence, started votes are kept
+func DeleteVoteTemplate(id, userid uint) error {
+	if _, err := ownTemplate(id, userid); err != nil {
+		return err
+	}
+	if !model.DeleteVoteTemplate(id) {
+		return fmt.Errorf("删除模板失败")
+	}
+	return nil
+}
+
+// GetTemplateHistory get all votes started from the template with their results,
+// totals are hidden by result visibility of each vote
+func GetTemplateHistory(id, userid uint) (*vm.TemplateHistory, error) {
+	t, err := ownTemplate(id, userid)
+	if err != nil {
+		return nil, err
+	}
+	vss, b := model.GetVoteSettings("template_id = ?", t.ID)
+	if !b {
+		return nil, fmt.Errorf("查询投票失败")
+	}
+	if t.SourceVoteID != "" {
+		if vs, b := model.GetVoteSetting(map[string]interface{}{"vote_id": t.SourceVoteID}); b && vs.VoteID != "" {
+			vs.Occurrence = 0
+			vss = append(vss, *vs)
+		}
+	}
+	sort.SliceStable(vss, func(i, j int) bool { return vss[i].Occurrence < vss[j].Occurrence })
+
+	history := vm.TemplateHistory{Template: templateInfo(t)}
+	for i := range vss {
+		occurrence, b := templateOccurrence(&vss[i], userid)
+		if !b {
+			return nil, fmt.Errorf("查询投票失败")
+		}
+		history.Occurrences = append(history.Occurrences, *occurrence)
+	}
+	return &history, nil
+}
+
+// runVoteTemplates start votes of templates whose next run passed, each run is claimed once
+func runVoteTemplates() {
+	now := time.Now()
+	ts, b := model.GetVoteTemplates("next_run > ? AND next_run <= ?", 0, now.Unix())
+	if !b {
+		return
+	}
+	for i := range ts {
+		t := &ts[i]
+		next, err := nextRun(t.Recurrence, now)
+		if err != nil {
+			glog.Errorf("invalid recurrence of template %d: %v", t.ID, err)
+		}
+		if !model.ClaimVoteTemplateRun(t.ID, t.NextRun, next) {
+			continue
+		}
+		if _, b := startOccurrence(t, now.Unix()); !b {
+			glog.Errorf("start vote of template %d fail", t.ID)
+		}
+	}
+}
+
+// startOccurrence start a vote from the template, numbered after votes started before
+func startOccurrence(t *model.VoteTemplate, start int64) (string, bool) {
+	voteinit, err := templateVoteInit(t, start)
+	if err != nil {
+		glog.Errorf("template %d: %v", t.ID, err)
+		return "", false
+	}
+	occurrence, b := claimOccurrence(t.ID)
+	if !b {
+		return "", false
+	}
+	voteinit.Title = fmt.Sprintf("%s (第%d期)", t.Title, occurrence)
+	voteinit.TemplateID, voteinit.Occurrence = t.ID, occurrence
+	return StartVote(voteinit)
+}
+
+// claimOccurrence claim the next number of the template on its row, so concurrent runs never share one,
+// templates numbered before the counter existed continue after their started votes
+func claimOccurrence(id uint) (int, bool) {
+	for i := 0; i < maxOccurrenceClaims; i++ {
+		t, b := model.GetVoteTemplate(id)
+		if !b || t == nil {
+			return 0, false
+		}
+		last := t.Occurrences
+		if last == 0 {
+			vss, b := model.GetVoteSettings("template_id = ?", id)
+			if !b {
+				return 0, false
+			}
+			for _, vs := range vss {
+				if vs.Occurrence > last {
+					last = vs.Occurrence
+				}
+			}
+		}
+		if model.ClaimTemplateOccurrence(id, t.Occurrences, last+1) {
+			return last + 1, true
+		}
+	}
+	glog.Errorf("claim occurrence of template %d fail", id)
+	return 0, false
+}
+
+// templateOccurrence status and result of a vote started from a template
+func templateOccurrence(vs *model.VoteSetting, userid uint) (*vm.TemplateOccurrence, bool) {
+	vote, b := GetVoteStatus(&vm.GetVoteStatus{VoteID: vs.VoteID, UserID: userid})
+	if !b {
+		return nil, false
+	}
+	occurrence := vm.TemplateOccurrence{
+		VoteID:     vs.VoteID,
+		Occurrence: vs.Occurrence,
+		Title:      vs.Title,
+		StartTime:  vs.StartTime,
+		EndTime:    vs.EndTime,
+		Status:     vote.Status,
+		Options:    vote.Options,
+		Hidden:     vote.Hidden,
+	}
+	if vote.Hidden {
+		return &occurrence, true
+	}
+	for _, option := range vote.Options {
+		occurrence.Total += option.Total
+	}
+	if winner, ok := pluralityWinner(vote.Options); ok && vote.Status == 3 {
+		occurrence.WinnerID = winner.ID
+		occurrence.WinnerContent = winner.Content
+	}
+	return &occurrence, true
+}
+
+// saveVoteTemplate check the template as a vote starting now and save it
+func saveVoteTemplate(t *model.VoteTemplate) (*vm.VoteTemplate, error) {
+	if err := validateText("name", t.Name); err != nil {
+		return nil, err
+	}
+	voteinit, err := templateVoteInit(t, time.Now().Unix())
+	if err != nil {
+		return nil, err
+	}
+	if err := validateEligibility(t.Eligibility); err != nil {
+		return nil, &ContentError{Field: "eligibility", Reason: err.Error()}
+	}
+	if t.ResultVisibility < 0 || t.ResultVisibility > model.VisibilityCreatorOnly {
+		return nil, &ContentError{Field: "result_visibility", Reason: "结果可见性错误"}
+	}
+	if err := ValidateVoteInit(voteinit); err != nil {
+		return nil, err
+	}
+	if err := checkRecurrence(t.Recurrence, t.Duration, time.Now()); err != nil {
+		return nil, err
+	}
+	if t.NextRun, err = nextRun(t.Recurrence, time.Now()); err != nil {
+		return nil, err
+	}
+	if _, b := model.CreateVoteTemplate(t); !b {
+		return nil, fmt.Errorf("保存模板失败")
+	}
+	info := templateInfo(t)
+	return &info, nil
+}
+
+// templateVoteInit vote of the template starting at the time
+func templateVoteInit(t *model.VoteTemplate, start int64) (*vm.VoteInit, error) {
+	switch {
+	case t.SelectType != model.SelectSingle && t.SelectType != model.SelectMulti:
+		return nil, &ContentError{Field: "select_type", Reason: "模板仅支持单选和多选"}
+	case t.Duration < minTemplateDuration || t.Duration > maxTemplateDuration:
+		return nil, &ContentError{Field: "duration", Reason: fmt.Sprintf("时长须在%d到%d秒之间", minTemplateDuration, maxTemplateDuration)}
+	}
+	info := templateInfo(t)
+	return &vm.VoteInit{
+		Title:            t.Title,
+		Description:      t.Description,
+		Options:          info.Options,
+		SelectType:       t.SelectType,
+		StartTime:        strconv.FormatInt(start, 10),
+		EndTime:          strconv.FormatInt(start+t.Duration, 10),
+		CreatorID:        t.CreatorID,
+		ResultVisibility: t.ResultVisibility,
+		Invitees:         info.Invitees,
+		Restricted:       t.Restricted,
+		InviteOnly:       t.InviteOnly,
+		Eligibility:      t.Eligibility,
+	}, nil
+}
+
+// checkRecurrence runs of the cron rule are at least the duration apart, so occurrences do not overlap
+func checkRecurrence(recurrence string, duration int64, after time.Time) error {
+	if recurrence == "" {
+		return nil
+	}
+	schedule, err := cron.ParseStandard(recurrence)
+	if err != nil {
+		return &ContentError{Field: "recurrence", Reason: "cron表达式错误"}
+	}
+	prev := schedule.Next(after)
+	for i := 0; i < recurrenceSamples; i++ {
+		next := schedule.Next(prev)
+		if next.IsZero() {
+			break
+		}
+		if next.Unix()-prev.Unix() < duration {
+			return &ContentError{Field: "recurrence", Reason: fmt.Sprintf("重复间隔不能短于每期时长%d秒", duration)}
+		}
+		prev = next
+	}
+	return nil
+}
+
+// nextRun next time of the cron rule after the time, 0 for an empty rule
+func nextRun(recurrence string, after time.Time) (int64, error) {
+	if recurrence == "" {
+		return 0, nil
+	}
+	schedule, err := cron.ParseStandard(recurrence)
+	if err != nil {
+		return 0, &ContentError{Field: "recurrence", Reason: "cron表达式错误"}
+	}
+	return schedule.Next(after).Unix(), nil
+}
+
+// ownTemplate template of the user, admins can operate all templates
+func ownTemplate(id, userid uint) (*model.VoteTemplate, error) {
+	t, b := model.GetVoteTemplate(id)
+	if !b {
+		return nil, fmt.Errorf("查询模板失败")
+	}
+	if t == nil || (t.CreatorID != userid && !IsAdmin(userid)) {
+		return nil, errTemplateNotFound
+	}
+	return t, nil
+}
+
+func templateInfo(t *model.VoteTemplate) vm.VoteTemplate {
+	info := vm.VoteTemplate{
+		ID:               t.ID,
+		Name:             t.Name,
+		Title:            t.Title,
+		Description:      t.Description,
+		SelectType:       t.SelectType,
+		ResultVisibility: t.ResultVisibility,
+		Restricted:       t.Restricted,
+		InviteOnly:       t.InviteOnly,
+		Eligibility:      t.Eligibility,
+		Duration:         t.Duration,
+		Recurrence:       t.Recurrence,
+		NextRun:          t.NextRun,
+		SourceVoteID:     t.SourceVoteID,
+		CreatorID:        t.CreatorID,
+	}
+	json.Unmarshal([]byte(t.Options), &info.Options)
+	json.Unmarshal([]byte(t.Invitees), &info.Invitees)
+	return info
+}
@@ -0,0 +1,67 @@
+package service
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNextRun(t *testing.T) {
+	after := time.Date(2026, 10, 19, 9, 30, 0, 0, time.UTC)
+	tests := []struct {
+		recurrence string
+		want       time.Time
+		wantErr    bool
+	}{
+		{"", time.Time{}, false},
+		{"0 10 * * *", time.Date(2026, 10, 19, 10, 0, 0, 0, time.UTC), false},
+		{"0 9 * * *", time.Date(2026, 10, 20, 9, 0, 0, 0, time.UTC), false},
+		{"0 12 * * MON", time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC), false},
+		{"0 9 * * MON", time.Date(2026, 10, 26, 9, 0, 0, 0, time.UTC), false},
+		{"0 0 1 * *", time.Date(2026, 11, 1, 0, 0, 0, 0, time.UTC), false},
+		{"*/15 * * * *", time.Date(2026, 10, 19, 9, 45, 0, 0, time.UTC), false},
+		{"@weekly", time.Date(2026, 10, 25, 0, 0, 0, 0, time.UTC), false},
+		{"61 * * * *", time.Time{}, true},
+		{"every day", time.Time{}, true},
+	}
+	for _, tt := range tests {
+		got, err := nextRun(tt.recurrence, after)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("nextRun(%q) err %v, want err %v", tt.recurrence, err, tt.wantErr)
+			continue
+		}
+		want := int64(0)
+		if !tt.want.IsZero() {
+			want = tt.want.Unix()
+		}
+		if got != want {
+			t.Errorf("nextRun(%q) = %v, want %v", tt.recurrence, time.Unix(got, 0), tt.want)
+		}
+	}
+}
+
+func TestCheckRecurrence(t *testing.T) {
+	after := time.Date(2026, 10, 19, 9, 30, 0, 0, time.UTC)
+	const day = 24 * 60 * 60
+	tests := []struct {
+		recurrence string
+		duration   int64
+		wantErr    bool
+	}{
+		{"", day, false},
+		{"0 10 * * *", day, false},
+		{"0 10 * * *", day + 1, true},
+		{"0 10 * * MON", 7 * day, false},
+		{"0 10 * * MON,TUE", 2 * day, true},
+		{"0 10 * * MON,THU", 3 * day, false},
+		{"*/15 * * * *", 15 * 60, false},
+		{"*/15 * * * *", 3600, true},
+		{"0 0 1 * *", 28 * day, false},
+		{"0 0 1 * *", 30 * day, true},
+		{"bad rule", 60, true},
+	}
+	for _, tt := range tests {
+		if err := checkRecurrence(tt.recurrence, tt.duration, after); (err != nil) != tt.wantErr {
+			t.Errorf("checkRecurrence(%q, %d) = %v, want err %v", tt.recurrence, tt.duration, err, tt.wantErr)
+		}
+	}
+}
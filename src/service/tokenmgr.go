@@ -0,0 +1,157 @@
+package service
+
+import (
+	"FunnyVoteGo/src/api/vm"
+	"FunnyVoteGo/src/constant"
+	"FunnyVoteGo/src/lib/eventbus"
+	"FunnyVoteGo/src/model"
+	"FunnyVoteGo/src/util"
+	"net/mail"
+	"time"
+
+	"github.com/glog"
+)
+
+// maxBallotTokens tokens generated in one request
+const maxBallotTokens = 1000
+
+// GenerateBallotTokens generate one-time ballot tokens of the vote for participants without account,
+// only hashes of tokens are stored, tokens are mailed if emails given
+func GenerateBallotTokens(bt *vm.BallotTokenInit, userid uint) ([]string, bool) {
+	if !canManageVote(bt.VoteID, userid) {
+		glog.Errorf("user %d can not generate ballot tokens of vote %s", userid, bt.VoteID)
+		return nil, false
+	}
+	count := bt.Count
+	if len(bt.Emails) > 0 {
+		count = len(bt.Emails)
+	}
+	if count <= 0 || count > maxBallotTokens {
+		return nil, false
+	}
+	for _, email := range bt.Emails {
+		if _, err := mail.ParseAddress(email); err != nil {
+			glog.Errorf("invalid email: %s", email)
+			return nil, false
+		}
+	}
+	vs, b := model.GetVoteSetting(map[string]interface{}{"vote_id": bt.VoteID})
+	if !b {
+		return nil, false
+	}
+
+	var tokens []string
+	var bts []model.BallotToken
+	for i := 0; i < count; i++ {
+		token, err := util.SecureRandString(32)
+		if err != nil {
+			glog.Error(err)
+			return nil, false
+		}
+		ballot := model.BallotToken{
+			VoteID:    bt.VoteID,
+			TokenHash: inviteCodeHash(token),
+		}
+		if len(bt.Emails) > 0 {
+			ballot.Email = bt.Emails[i]
+		}
+		tokens = append(tokens, token)
+		bts = append(bts, ballot)
+	}
+	if !model.CreateBallotTokens(bts) {
+		return nil, false
+	}
+
+	data := newMailData(vs)
+	for i, email := range bt.Emails {
+		data.Code = tokens[i]
+		sendMail(mailBallotToken, 0, email, data)
+	}
+	return tokens, true
+}
+
+// GetBallotTokens get usage of ballot tokens of the vote, tokens themselves are not stored
+func GetBallotTokens(voteid string, userid uint) ([]model.BallotToken, bool) {
+	if !canManageVote(voteid, userid) {
+		return nil, false
+	}
+	return model.GetBallotTokens(voteid)
+}
+
+// CastTokenBallot cast ballot with a one-time token, the ballot is linked to hash of the token
+func CastTokenBallot(tb *vm.TokenBallot) (err error) {
+	if err := checkOptionBallot(tb.VoteID); err != nil {
+		return err
+	}
+	if ballotTokenExpired(tb.VoteID) {
+		return &EligibilityError{Reason: "投票未在进行中"}
+	}
+	if err := checkVoteOption(tb.VoteID, tb.OptionID); err != nil {
+		return err
+	}
+	hash := inviteCodeHash(tb.Token)
+	if !model.UseBallotToken(tb.VoteID, hash) {
+		glog.Errorf("invalid ballot token of vote %s", tb.VoteID)
+		return &EligibilityError{Reason: "投票令牌无效"}
+	}
+	var txhash string
+	defer func() {
+		// 仅在选票未上链时释放令牌, 合约不限制重复记录
+		if err != nil && txhash == "" {
+			model.UpdateBallotToken(hash, map[string]interface{}{"used": false, "used_at": ""})
+		}
+	}()
+
+	content, b := toChainText(tb.OptionContent)
+	if !b {
+		return errBallotFail
+	}
+	ballotid := chainID(util.StringUUID())
+	txhash, err = castBallot(tb.VoteID, tb.OptionID, model.TokenOption{
+		ID:            ballotid,
+		VoteID:        tb.VoteID,
+		OptionID:      tb.OptionID,
+		OptionContent: content,
+		UserID:        "0x" + hash,
+		Publickey:     util.RandString(25),
+		CreateTime:    util.GetNowTimeString(),
+	})
+	if err != nil {
+		return err
+	}
+
+	_, b = model.CreateHashRecord(&model.HashRecord{
+		VoteID:        tb.VoteID,
+		TokenHash:     hash,
+		OptionID:      tb.OptionID,
+		OptionContent: tb.OptionContent,
+		BallotID:      ballotid,
+		TxHash:        txhash,
+	})
+	if !b {
+		// 选票已上链, 令牌保持已使用
+		glog.Errorf("create hash record of token ballot %s in vote %s fail", ballotid, tb.VoteID)
+	}
+	model.UpdateBallotToken(hash, map[string]interface{}{"tx_hash": txhash})
+	publishLocalEvent(eventbus.Event{
+		Name:   constant.EventBallotCast,
+		VoteID: tb.VoteID,
+		TxHash: txhash,
+		Data: map[string]interface{}{
+			"vote_id":   tb.VoteID,
+			"option_id": tb.OptionID,
+			"user_id":   "0x" + hash,
+		},
+	})
+	return nil
+}
+
+// ballotTokenExpired tokens can only be used while the vote is running
+func ballotTokenExpired(voteid string) bool {
+	vs, b := model.GetVoteSetting(map[string]interface{}{"vote_id": voteid})
+	if !b {
+		return true
+	}
+	now := time.Now().Unix()
+	return vs.Closed || now < vs.StartTime || now > vs.EndTime
+}
@@ -0,0 +1,147 @@
+package service
+
+import (
+	"FunnyVoteGo/src/api/vm"
+	"FunnyVoteGo/src/lib/password"
+	"FunnyVoteGo/src/lib/token"
+	"FunnyVoteGo/src/model"
+	"FunnyVoteGo/src/util"
+	"net/mail"
+	"time"
+
+	"github.com/glog"
+	"github.com/spf13/viper"
+)
+
+// Register create a user
+func Register(ur *vm.UserRegister) (*vm.UserInfo, bool) {
+	if ur.Email != "" {
+		if _, err := mail.ParseAddress(ur.Email); err != nil {
+			glog.Errorf("invalid email: %s", ur.Email)
+			return nil, false
+		}
+	}
+	hashed, err := password.Hash(ur.Password)
+	if err != nil {
+		glog.Error(err)
+		return nil, false
+	}
+	user, b := model.CreateUser(&model.User{
+		UserName: ur.UserName,
+		Email:    ur.Email,
+		Password: hashed,
+	})
+	if !b {
+		return nil, false
+	}
+	assignDefaultRoles(user.ID)
+	return &vm.UserInfo{ID: user.ID, UserName: user.UserName}, true
+}
+
+// Login check password and issue tokens
+func Login(login *vm.Login) (*vm.LoginResult, bool) {
+	user, b := model.GetUser(map[string]interface{}{"user_name": login.UserName})
+	if !b || !password.Verify(login.Password, user.Password) {
+		glog.Errorf("login fail: %s", login.UserName)
+		return nil, false
+	}
+	return issueTokens(vm.UserInfo{ID: user.ID, UserName: user.UserName})
+}
+
+// Refresh issue new tokens by a refresh token, the old one is revoked
+func Refresh(refresh string) (*vm.LoginResult, bool) {
+	claims, b := parseToken(refresh, token.TypeRefresh)
+	if !b {
+		return nil, false
+	}
+	if !revokeToken(claims) {
+		return nil, false
+	}
+	return issueTokens(vm.UserInfo{ID: claims.UserID, UserName: claims.UserName})
+}
+
+// Logout revoke access token, and refresh token if given
+func Logout(access, refresh string) bool {
+	claims, b := parseToken(access, token.TypeAccess)
+	if !b || !revokeToken(claims) {
+		return false
+	}
+	if refresh == "" {
+		return true
+	}
+	claims, b = parseToken(refresh, token.TypeRefresh)
+	return b && revokeToken(claims)
+}
+
+// ParseAccessToken resolve user of an access token
+func ParseAccessToken(access string) (*vm.UserInfo, bool) {
+	claims, b := parseToken(access, token.TypeAccess)
+	if !b {
+		return nil, false
+	}
+	return &vm.UserInfo{ID: claims.UserID, UserName: claims.UserName}, true
+}
+
+func issueTokens(user vm.UserInfo) (*vm.LoginResult, bool) {
+	timeout := viper.GetInt("login.timeoutperiod")
+	if timeout <= 0 {
+		timeout = 1800
+	}
+	refreshPeriod := viper.GetInt("login.refreshperiod")
+	if refreshPeriod <= 0 {
+		refreshPeriod = 7 * 24 * 3600
+	}
+	access, b := signToken(user, token.TypeAccess, time.Duration(timeout)*time.Second)
+	if !b {
+		return nil, false
+	}
+	refresh, b := signToken(user, token.TypeRefresh, time.Duration(refreshPeriod)*time.Second)
+	if !b {
+		return nil, false
+	}
+	return &vm.LoginResult{
+		Token:        access,
+		RefreshToken: refresh,
+		ExpiresIn:    timeout,
+		User:         user,
+	}, true
+}
+
+func signToken(user vm.UserInfo, typ string, ttl time.Duration) (string, bool) {
+	tokenid, err := util.SecureRandString(24)
+	if err != nil {
+		glog.Error(err)
+		return "", false
+	}
+	claims := token.Claims{
+		UserID:   user.ID,
+		UserName: user.UserName,
+		Type:     typ,
+	}
+	claims.Id = tokenid
+	signed, err := token.Sign(claims, ttl)
+	if err != nil {
+		glog.Error(err)
+		return "", false
+	}
+	return signed, true
+}
+
+func parseToken(signed, typ string) (*token.Claims, bool) {
+	claims, err := token.Parse(signed)
+	if err != nil {
+		glog.Errorf("parse token fail: %v", err)
+		return nil, false
+	}
+	if claims.Type != typ || model.IsTokenRevoked(claims.Id) {
+		return nil, false
+	}
+	return claims, true
+}
+
+func revokeToken(claims *token.Claims) bool {
+	return model.CreateRevokedToken(&model.RevokedToken{
+		TokenID:   claims.Id,
+		ExpiresAt: claims.ExpiresAt,
+	})
+}
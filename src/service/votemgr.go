@@ -2,8 +2,12 @@ package service
 
 import (
 	"FunnyVoteGo/src/api/vm"
+	"FunnyVoteGo/src/constant"
+	"FunnyVoteGo/src/lib/eventbus"
 	"FunnyVoteGo/src/model"
 	"FunnyVoteGo/src/util"
+	"encoding/json"
+	"fmt"
 	"io/ioutil"
 	"strconv"
 	"strings"
@@ -14,9 +18,20 @@ import (
 	"github.com/hyperchain/gosdk/utils/ecdsa"
 )
 
-const (
-	ContractAddress = "0xa83d15e1a65ec896b3a648ac77642e92998d2e08"
-)
+// errBallotFail ballot not accepted by contract
+var errBallotFail = fmt.Errorf("投票失败")
+
+// errNoVoteSetting ballots are denied when restrictions of the vote can not be read
+var errNoVoteSetting = &EligibilityError{Reason: "投票设置不存在"}
+
+// errSurveyBallot survey is answered by SubmitSurvey only
+var errSurveyBallot = fmt.Errorf("问卷须一次提交全部答案")
+
+// errBudgetBallot budgeting vote is answered by CastBudgetBallot only
+var errBudgetBallot = fmt.Errorf("预算投票须一次提交所选全部选项")
+
+// errPetitionBallot petition is signed by SignPetition only
+var errPetitionBallot = fmt.Errorf("请愿须通过签名参与")
 
 // GetContractCode get contract code
 func GetContractCode() string {
@@ -32,6 +47,45 @@ func GetContractCode() string {
 
 // StartVote start  a vote
 func StartVote(voteinit *vm.VoteInit) (string, bool) {
+	voteid, _, b := startVote(voteinit)
+	return voteid, b
+}
+
+// startVote start a vote, returns id of the vote and hash of the transaction inserting it
+func startVote(voteinit *vm.VoteInit) (string, string, bool) {
+	if err := validateEligibility(voteinit.Eligibility); err != nil {
+		glog.Errorf("invalid eligibility expression %q: %v", voteinit.Eligibility, err)
+		return "", "", false
+	}
+	if voteinit.ResultVisibility < 0 || voteinit.ResultVisibility > model.VisibilityCreatorOnly {
+		glog.Errorf("invalid result visibility %d", voteinit.ResultVisibility)
+		return "", "", false
+	}
+	if err := ValidateVoteInit(voteinit); err != nil {
+		glog.Errorf("invalid vote: %v", err)
+		return "", "", false
+	}
+	openEnded := voteinit.SignatureTarget != 0 && voteinit.EndTime == ""
+	if voteinit.SignatureTarget != 0 {
+		preparePetition(voteinit)
+	}
+	// 超过bytes32的内容存链下, 链上只存哈希
+	title, b := toChainText(voteinit.Title)
+	if !b {
+		return "", "", false
+	}
+	description, b := toChainText(voteinit.Description)
+	if !b {
+		return "", "", false
+	}
+	var contents []string
+	for _, option := range voteinit.Options {
+		content, b := toChainText(option)
+		if !b {
+			return "", "", false
+		}
+		contents = append(contents, content)
+	}
 	//调用合约新建投票活动
 	// get contract addr and Code
 	contractcode := GetContractCode()
@@ -43,155 +97,337 @@ func StartVote(voteinit *vm.VoteInit) (string, bool) {
 	}
 	vote := model.Vote2{
 		ID:             util.StringUUID(),
-		Title:          voteinit.Title,
-		Description:    voteinit.Description,
+		Title:          title,
+		Description:    description,
 		SelectType:     voteinit.SelectType,
 		StartTime:      voteinit.StartTime,
 		EndTime:        voteinit.EndTime,
 		CreateTime:     util.GetNowTimeString(),
 		CreatorID:      voteinit.CreatorID,
 		OptionIDs:      optionids,
-		OptionContents: voteinit.Options,
+		OptionContents: contents,
+	}
+	survey := len(voteinit.Questions) > 0
+	var params string
+	if survey {
+		// 问卷的选项随问题插入
+		vote.SelectType = model.SelectSurvey
+		params = surveyVoteParams(&vote)
+	} else {
+		if voteinit.Budget != 0 {
+			vote.SelectType = model.SelectBudget
+		}
+		if voteinit.Pairwise {
+			vote.SelectType = model.SelectPairwise
+		}
+		params = util.Struct2String(vote)
 	}
-	params := util.Struct2String(vote)
 	if params == "" {
 
 		glog.Info("222")
-		return "", false
+		return "", "", false
 	}
 	key, err := InitKey()
 	if err != nil {
 		glog.Info("333")
-		return "", false
+		return "", "", false
 	}
 
 	retu, err := InvokeContract(vm.ReqInvokeCon{
-		ContractAddr: ContractAddress,
+		ContractAddr: ContractAddress(),
 		ContractCode: contractcode,
 		MethodName:   "insertVote",
 		MethodParams: params,
 	}, key)
 	if err != nil {
-		return "", false
+		return "", "", false
 	}
 	// 解析合约返回
 	ABI, _ := abi.JSON(strings.NewReader(retu.Abi))
 	p1, _, err := constructOutput(ABI, retu.Methods, retu.Result)
 	// 0 成功 1 失败
 	if p1 != 0 {
-		return "", false
+		return "", "", false
 	}
 	glog.Info("新建投票成功")
+	if survey && !commitSurveyQuestions(vote.ID, voteinit.Questions) {
+		glog.Errorf("insert questions of survey %s fail", vote.ID)
+		return "", "", false
+	}
+	if voteinit.Budget != 0 && !commitBudget(vote.ID, voteinit.Budget, optionids, voteinit.OptionCosts) {
+		glog.Errorf("set budget of vote %s fail", vote.ID)
+		return "", "", false
+	}
+	if voteinit.SignatureTarget != 0 && !commitPetition(vote.ID, optionids[0], voteinit.SignatureTarget) {
+		glog.Errorf("set petition of vote %s fail", vote.ID)
+		return "", "", false
+	}
+
+	starttime, _ := strconv.ParseInt(vote.StartTime, 10, 64)
+	endtime, _ := strconv.ParseInt(vote.EndTime, 10, 64)
+	pairs := 0
+	if voteinit.Pairwise {
+		pairs = pairsPerVoter(voteinit)
+	}
+	visibility := voteinit.ResultVisibility
+	if visibility == 0 {
+		visibility = model.VisibilityAlways
+	}
+	vs, b := model.CreateVoteSetting(&model.VoteSetting{
+		VoteID:           vote.ID,
+		Title:            voteinit.Title,
+		CreatorID:        vote.CreatorID,
+		StartTime:        starttime,
+		EndTime:          endtime,
+		ResultVisibility: visibility,
+		Restricted:       voteinit.Restricted,
+		InviteOnly:       voteinit.InviteOnly,
+		Eligibility:      voteinit.Eligibility,
+		Survey:           survey,
+		AllowWriteIn:     voteinit.AllowWriteIn && !survey,
+		WriteInApproval:  voteinit.WriteInApproval,
+		RunoffMajority:   voteinit.RunoffMajority,
+		RunoffTopN:       voteinit.RunoffTopN,
+		RunoffDuration:   voteinit.RunoffDuration,
+		Budget:           voteinit.Budget,
+		SignatureTarget:  voteinit.SignatureTarget,
+		OpenEnded:        openEnded,
+		Pairwise:         voteinit.Pairwise,
+		PairsPerVoter:    pairs,
+		TemplateID:       voteinit.TemplateID,
+		Occurrence:       voteinit.Occurrence,
+	})
+	if !b {
+		// 没有设置的投票不接受投票
+		glog.Errorf("save vote setting fail: %s", vote.ID)
+		return "", "", false
+	}
+	// 限制未能全部保存时删除设置, 使投票不接受投票而不是不加限制
+	abandon := func(format string) (string, string, bool) {
+		glog.Errorf(format, vote.ID)
+		model.DeleteVoteSetting(vs.ID)
+		return "", "", false
+	}
+	if voteinit.Restricted {
+		// invitees are the eligibility list
+		if _, b := SetVoteVoters(vote.ID, voteinit.Invitees, vote.CreatorID); !b {
+			return abandon("set voters of vote %s fail")
+		}
+	} else if len(voteinit.Invitees) > 0 && !model.CreateVoteInvitees(vote.ID, voteinit.Invitees) {
+		return abandon("save vote invitees fail: %s")
+	}
+	if voteinit.Eligibility != "" {
+		if _, b := SetVoteEligibility(vote.ID, voteinit.Eligibility, vote.CreatorID); !b {
+			return abandon("set eligibility of vote %s fail")
+		}
+	}
+	if !commitOptionAssets(vote.ID, optionids, voteinit, key) {
+		return abandon("save option assets of vote %s fail")
+	}
+	publishLocalEvent(eventbus.Event{
+		Name:   constant.EventVoteCreated,
+		VoteID: vote.ID,
+		TxHash: retu.TxHash,
+		Data: map[string]interface{}{
+			"vote_id":    vote.ID,
+			"creator_id": strconv.Itoa(int(vote.CreatorID)),
+			"start_time": vote.StartTime,
+			"end_time":   vote.EndTime,
+		},
+	})
 
 	//b := AddOptions(voteinit.Options, vote.ID, key)
 	//if !b {
-	//	return "", false
+	//	return "", "", false
 	//}
 	//glog.Info("插入选项成功")
-	return vote.ID, true
+	return vote.ID, retu.TxHash, true
 
 }
 
-// AddOptions add options for a vote
-func AddOptions(options []string, voteid string, key *ecdsa.Key) bool {
-	for _, option := range options {
-		//调用合约添加选项内容
-		params := util.Struct2String(model.Option{
-			ID:      util.StringUUID(),
-			VoteID:  voteid,
-			Content: option,
-			// TODO 这个要去掉
-			Total: 1,
-		})
-
-		contractcode := GetContractCode()
-		retu, err := InvokeContract(vm.ReqInvokeCon{
-			ContractAddr: ContractAddress,
-			ContractCode: contractcode,
-			MethodName:   "insertVoteOption",
-			MethodParams: params,
-		}, key)
-		if err != nil {
-			return false
-		}
-		// 解析合约返回
-		ABI, _ := abi.JSON(strings.NewReader(retu.Abi))
-		p1, _, err := constructOutput(ABI, retu.Methods, retu.Result)
-		// 0 成功 1 失败
-		if p1 != 0 {
-			return false
+// ChooseOption cast a ballot, eligibility and invite code are checked by vote setting
+func ChooseOption(chooseoption *vm.ChooseOption) (err error) {
+	if err := checkOptionBallot(chooseoption.VoteID); err != nil {
+		return err
+	}
+	if err := checkVoteOption(chooseoption.VoteID, chooseoption.OptionID); err != nil {
+		return err
+	}
+	release, err := admitBallot(chooseoption.VoteID, chooseoption.UserID, chooseoption.InviteCode)
+	if err != nil {
+		return err
+	}
+	var txhash string
+	defer func() {
+		// 仅在选票未上链时释放邀请码
+		if err != nil && txhash == "" {
+			release()
 		}
+	}()
 
+	content, b := toChainText(chooseoption.OptionContent)
+	if !b {
+		return errBallotFail
+	}
+	ballotid := chainID(util.StringUUID())
+	txhash, err = castBallot(chooseoption.VoteID, chooseoption.OptionID, model.UserOption{
+		ID:            ballotid,
+		VoteID:        chooseoption.VoteID,
+		OptionID:      chooseoption.OptionID,
+		OptionContent: content,
+		UserID:        chooseoption.UserID,
+		Publickey:     util.RandString(25),
+		CreateTime:    util.GetNowTimeString(),
+	})
+	if err != nil {
+		return err
 	}
-	return true
+
+	// hash 存mysql
+	_, b = model.CreateHashRecord(&model.HashRecord{
+		VoteID:        chooseoption.VoteID,
+		UserID:        chooseoption.UserID,
+		OptionID:      chooseoption.OptionID,
+		OptionContent: chooseoption.OptionContent,
+		BallotID:      ballotid,
+		TxHash:        txhash,
+	})
+	if !b {
+		// 选票已上链, 不再返回失败
+		glog.Errorf("create hash record of ballot %s in vote %s fail", ballotid, chooseoption.VoteID)
+	}
+	glog.Info("2 finish")
+	publishLocalEvent(eventbus.Event{
+		Name:   constant.EventBallotCast,
+		VoteID: chooseoption.VoteID,
+		TxHash: txhash,
+		Data: map[string]interface{}{
+			"vote_id":   chooseoption.VoteID,
+			"option_id": chooseoption.OptionID,
+			"user_id":   strconv.Itoa(int(chooseoption.UserID)),
+		},
+	})
+	return nil
+
+}
+
+// checkOptionBallot surveys, budgeting votes, petitions and pairwise polls take ballots of their own
+// instead of one option
+func checkOptionBallot(voteid string) error {
+	vs, b := model.GetVoteSetting(map[string]interface{}{"vote_id": voteid})
+	switch {
+	case !b:
+		return errNoVoteSetting
+	case vs.Survey:
+		return errSurveyBallot
+	case vs.Budget != 0:
+		return errBudgetBallot
+	case vs.SignatureTarget != 0:
+		return errPetitionBallot
+	case vs.Pairwise:
+		return errPairwiseBallot
+	}
+	return nil
 }
 
-func ChooseOption(chooseoption *vm.ChooseOption) bool {
+// admitBallot check eligibility of the user by vote setting and redeem invite code,
+// release gives the invite code back when the ballot is not recorded on chain
+func admitBallot(voteid string, userid uint, invitecode string) (func(), error) {
+	release := func() {}
+	vs, b := model.GetVoteSetting(map[string]interface{}{"vote_id": voteid})
+	if !b {
+		return release, errNoVoteSetting
+	}
+	if err := checkEligibility(vs, userid); err != nil {
+		return release, err
+	}
+	if vs.InviteOnly {
+		if !redeemInviteCode(vs.VoteID, invitecode, userid) {
+			return release, &EligibilityError{Reason: "邀请码无效"}
+		}
+		release = func() {
+			releaseInviteCode(vs.VoteID, invitecode)
+		}
+	}
+	return release, nil
+}
+
+// castBallot add one to the option and insert the ballot on chain, returns tx hash of the ballot
+func castBallot(voteid, optionid string, ballot interface{}) (string, error) {
 	contractcode := GetContractCode()
 	key, err := InitKey()
 	if err != nil {
-		return false
+		return "", err
 	}
 
 	// 第一个合约  选项+1
 	params1 := util.Struct2String(model.Option{
-		ID: chooseoption.OptionID,
+		ID:     optionid,
+		VoteID: voteid,
 	})
 	retu1, err := InvokeContract(vm.ReqInvokeCon{
-		ContractAddr: ContractAddress,
+		ContractAddr: ContractAddress(),
 		ContractCode: contractcode,
 		MethodName:   "updateVoteOption",
 		MethodParams: params1,
 	}, key)
 	if err != nil {
-		return false
+		return "", err
 	}
 	// 解析合约返回
 	ABI, _ := abi.JSON(strings.NewReader(retu1.Abi))
 	p1, _, err := constructOutput(ABI, retu1.Methods, retu1.Result)
 	if p1 != 0 {
-		return false
+		return "", errBallotFail
 	}
 
 	glog.Info("1 finish")
 	// 第二个合约 插入用户id,选项id等
-	params2 := util.Struct2String(model.UserOption{
-		ID:            util.StringUUID(),
-		VoteID:        chooseoption.VoteID,
-		OptionID:      chooseoption.OptionID,
-		OptionContent: chooseoption.OptionContent,
-		UserID:        chooseoption.UserID,
-		Publickey:     util.RandString(25),
-		CreateTime:    util.GetNowTimeString(),
-	})
 	retu2, err := InvokeContract(vm.ReqInvokeCon{
-		ContractAddr: ContractAddress,
+		ContractAddr: ContractAddress(),
 		ContractCode: contractcode,
 		MethodName:   "insertVoteResult",
-		MethodParams: params2,
+		MethodParams: util.Struct2String(ballot),
 	}, key)
 	if err != nil {
-		return false
+		return "", err
 	}
 	p2, _, err := constructOutput(ABI, retu2.Methods, retu2.Result)
 	if p2 != 0 {
-		return false
+		return "", errBallotFail
 	}
+	return retu2.TxHash, nil
+}
 
-	// hash 存mysql
-	_, b := model.CreateHashRecord(&model.HashRecord{
-		VoteID:        chooseoption.VoteID,
-		UserID:        chooseoption.UserID,
-		OptionID:      chooseoption.OptionID,
-		OptionContent: chooseoption.OptionContent,
-		TxHash:        retu2.TxHash,
-	})
-	if !b {
-		return false
+// invokeMethod invoke method of contract with params marshaled to json as is,
+// unlike util.Struct2String empty values are kept, returns tx hash or message of contract
+func invokeMethod(method string, params interface{}) (string, error) {
+	key, err := InitKey()
+	if err != nil {
+		return "", err
 	}
-	glog.Info("2 finish")
-	return true
-
+	bs, err := json.Marshal(params)
+	if err != nil {
+		return "", err
+	}
+	retu, err := InvokeContract(vm.ReqInvokeCon{
+		ContractAddr: ContractAddress(),
+		ContractCode: GetContractCode(),
+		MethodName:   method,
+		MethodParams: string(bs),
+	}, key)
+	if err != nil {
+		return "", err
+	}
+	ABI, _ := abi.JSON(strings.NewReader(retu.Abi))
+	p1, msg, err := constructOutput(ABI, retu.Methods, retu.Result)
+	if err != nil {
+		return "", err
+	}
+	if p1 != 0 {
+		return "", fmt.Errorf("%s", msg)
+	}
+	return retu.TxHash, nil
 }
 
 func GetVoteStatus(getvotestatus *vm.GetVoteStatus) (*model.Vote, bool) {
@@ -207,7 +443,7 @@ func GetVoteStatus(getvotestatus *vm.GetVoteStatus) (*model.Vote, bool) {
 		ID: getvotestatus.VoteID,
 	})
 	retu1, err := InvokeContract(vm.ReqInvokeCon{
-		ContractAddr: ContractAddress,
+		ContractAddr: ContractAddress(),
 		ContractCode: contractcode,
 		MethodName:   "queryVote",
 		MethodParams: params1,
@@ -233,8 +469,13 @@ func GetVoteStatus(getvotestatus *vm.GetVoteStatus) (*model.Vote, bool) {
 
 	var vote model.Vote
 	vote.ID = getvotestatus.VoteID
-	vote.Title = util.Byte32ToString(p_title)
-	vote.Description = util.Byte32ToString(p_desc)
+	var ok bool
+	if vote.Title, ok = fromChainText(p_title); !ok {
+		vote.Tampered = append(vote.Tampered, "title")
+	}
+	if vote.Description, ok = fromChainText(p_desc); !ok {
+		vote.Tampered = append(vote.Tampered, "description")
+	}
 	vote.SelectType = int(p_type)
 	vote.StartTime = util.Byte32ToString(p_st)
 	vote.EndTime = util.Byte32ToString(p_et)
@@ -245,60 +486,49 @@ func GetVoteStatus(getvotestatus *vm.GetVoteStatus) (*model.Vote, bool) {
 	//add vote  status
 	starttime, _ := strconv.Atoi(vote.StartTime)
 	endtime, _ := strconv.Atoi(vote.EndTime)
-	nowtime := int(time.Now().Unix())
 	glog.Info("StartTime : ", starttime)
 	glog.Info("EndTime : ", endtime)
-	glog.Info("NowTime : ", nowtime)
-	if starttime > nowtime {
-		vote.Status = 1
-	} else if endtime < nowtime {
-		vote.Status = 3
-	} else {
-		vote.Status = 2
-	}
+	vote.Status = voteStatus(int64(starttime), int64(endtime))
 	glog.Infof("vote: %+v", vote)
 	glog.Info("1 finish")
-	// 第二个合约 获得选项内容
-	params2 := util.Struct2String(model.Vote{
-		ID: getvotestatus.VoteID,
-	})
-	retu2, err := InvokeContract(vm.ReqInvokeCon{
-		ContractAddr: ContractAddress,
-		ContractCode: contractcode,
-		MethodName:   "queryVoteOption",
-		MethodParams: params2,
-	}, key)
-	if err != nil {
-		return nil, false
-	}
-	// 处理第二个合约返回
-	var p_ok2 int32
-	var p_oarray [][32]byte
-	var p_barray [][32]byte
-	var p_iarray []int32
-	res2 := []interface{}{&p_ok2, &p_oarray, &p_barray, &p_iarray}
-	if sysErr := ABI.UnpackResult(&res2, "queryVoteOption", retu2.Result); sysErr != nil {
-		glog.Info(sysErr)
-		return nil, false
-	}
-	if p_ok2 == 1 {
-		return nil, false
-	}
-	glog.Info(len(p_oarray))
-	glog.Info(len(p_barray))
-	glog.Info(len(p_iarray))
-	var options []model.Option
-	for i := 0; i < len(p_iarray); i++ {
-		var option model.Option
-		optionid := util.ByteToString(p_oarray[i][:])
-		tal := util.ByteToString(p_barray[i][:])
-		option.ID = optionid
-		option.Total = uint(p_iarray[i])
-		option.Content = tal
-		options = append(options, option)
-
+	// 第二个合约 获得选项内容, 问卷按问题获得
+	if vote.SelectType == model.SelectSurvey {
+		questions, b := querySurveyQuestions(getvotestatus.VoteID, key)
+		if !b {
+			return nil, false
+		}
+		vote.Questions = questions
+		vote.Tampered = append(vote.Tampered, surveyTampered(questions)...)
+	} else {
+		options, b := queryVoteOptions(getvotestatus.VoteID, key)
+		if !b {
+			return nil, false
+		}
+		vote.Options = options
+		for _, option := range options {
+			if option.Tampered {
+				vote.Tampered = append(vote.Tampered, "option:"+option.ID)
+			}
+		}
+		vote.Tampered = append(vote.Tampered, attachOptionAssets(vote.ID, options, key)...)
+		if vote.SelectType == model.SelectBudget {
+			budget, costs, b := queryBudget(vote.ID, key)
+			if !b {
+				return nil, false
+			}
+			vote.Budget = budget
+			for i := range vote.Options {
+				vote.Options[i].Cost = costs[vote.Options[i].ID]
+			}
+		}
+		if vote.SelectType == model.SelectPetition {
+			target, _, _, _, b := queryPetition(vote.ID, key)
+			if !b {
+				return nil, false
+			}
+			vote.Target = target
+		}
 	}
-	vote.Options = options
 
 	glog.Infof("vote: %+v", vote)
 	glog.Info("2 finish")
@@ -308,7 +538,7 @@ func GetVoteStatus(getvotestatus *vm.GetVoteStatus) (*model.Vote, bool) {
 		VoteID: getvotestatus.VoteID,
 	})
 	retu3, err := InvokeContract(vm.ReqInvokeCon{
-		ContractAddr: ContractAddress,
+		ContractAddr: ContractAddress(),
 		ContractCode: contractcode,
 		MethodName:   "queryUserVoteResult",
 		MethodParams: params3,
@@ -331,9 +561,186 @@ func GetVoteStatus(getvotestatus *vm.GetVoteStatus) (*model.Vote, bool) {
 		vote.UserVoted = 1
 	}
 	glog.Info("3 finish")
+	vs, b := model.GetVoteSetting(map[string]interface{}{"vote_id": vote.ID})
+	if b && vs.Closed {
+		// 请愿达到目标时提前结束
+		vote.Status = 3
+	}
+	if b && !resultVisible(vs, getvotestatus.UserID) {
+		vote.Hidden = true
+		for i := range vote.Options {
+			vote.Options[i].Total = 0
+		}
+		for i := range vote.Questions {
+			for j := range vote.Questions[i].Options {
+				vote.Questions[i].Options[j].Total = 0
+				vote.Questions[i].Options[j].Score = 0
+			}
+		}
+	}
 	return &vote, true
 }
 
+// FinalizeVote finalize outcome of an ended vote on chain, by its creator or admins
+func FinalizeVote(voteid string, userid uint) (string, bool) {
+	if !canManageVote(voteid, userid) {
+		glog.Errorf("user %d can not finalize vote %s", userid, voteid)
+		return "", false
+	}
+	if vs, b := model.GetVoteSetting(map[string]interface{}{"vote_id": voteid}); b {
+		if voteStatus(vs.StartTime, vs.EndTime) != 3 {
+			glog.Errorf("vote %s not ended", voteid)
+			return "", false
+		}
+		// 委托票权计入后再确认结果
+		if !countDelegatedWeight(vs) {
+			glog.Errorf("count delegated weight of vote %s fail", voteid)
+			return "", false
+		}
+	}
+	key, err := InitKey()
+	if err != nil {
+		return "", false
+	}
+	params := util.Struct2String(model.Vote{
+		ID: voteid,
+	})
+	retu, err := InvokeContract(vm.ReqInvokeCon{
+		ContractAddr: ContractAddress(),
+		ContractCode: GetContractCode(),
+		MethodName:   "finalizeVote",
+		MethodParams: params,
+	}, key)
+	if err != nil {
+		return "", false
+	}
+	ABI, _ := abi.JSON(strings.NewReader(retu.Abi))
+	p1, msg, err := constructOutput(ABI, retu.Methods, retu.Result)
+	if p1 != 0 {
+		glog.Errorf("finalize vote %s fail: %s", voteid, msg)
+		return "", false
+	}
+	// 结果证书引用确认交易
+	if vs, b := model.GetVoteSetting(map[string]interface{}{"vote_id": voteid}); b {
+		model.UpdateVoteSetting(vs.ID, map[string]interface{}{"finalize_tx_hash": retu.TxHash})
+	}
+	publishLocalEvent(eventbus.Event{
+		Name:   constant.EventVoteFinalized,
+		VoteID: voteid,
+		TxHash: retu.TxHash,
+		Data: map[string]interface{}{
+			"vote_id": voteid,
+		},
+	})
+	return retu.TxHash, true
+}
+
+// ResultVisible check user can see totals and ballots of the vote
+func ResultVisible(voteid string, userid uint) bool {
+	vs, b := model.GetVoteSetting(map[string]interface{}{"vote_id": voteid})
+	if !b {
+		return true
+	}
+	return resultVisible(vs, userid)
+}
+
+// resultVisible check user can see totals and ballots of the vote by its result visibility,
+// admins and auditors can always see them
+func resultVisible(vs *model.VoteSetting, userid uint) bool {
+	if vs.ResultVisibility == model.VisibilityAlways || vs.ResultVisibility == 0 {
+		return true
+	}
+	if userid != 0 && (IsAdmin(userid) || IsAuditor(userid)) {
+		return true
+	}
+	closed := vs.Closed || voteStatus(vs.StartTime, vs.EndTime) == 3
+	switch vs.ResultVisibility {
+	case model.VisibilityAfterClose:
+		return closed
+	case model.VisibilityAfterVoted:
+		if closed {
+			return true
+		}
+		voted, b := model.HasUserVoted(vs.VoteID, userid)
+		return b && voted && userid != 0
+	case model.VisibilityCreatorOnly:
+		return userid != 0 && userid == vs.CreatorID
+	}
+	return false
+}
+
+// voteStatus returns 1:未开始 2:进行中 3:已结束
+func voteStatus(starttime, endtime int64) int {
+	nowtime := time.Now().Unix()
+	if starttime > nowtime {
+		return 1
+	} else if endtime < nowtime {
+		return 3
+	}
+	return 2
+}
+
+// checkVoteOption the option belongs to the vote on chain, checked before invite codes or tokens
+// are used since the contract rejects the ballot anyway
+func checkVoteOption(voteid, optionid string) error {
+	key, err := InitKey()
+	if err != nil {
+		return err
+	}
+	options, b := queryVoteOptions(voteid, key)
+	if !b {
+		return errBallotFail
+	}
+	for _, o := range options {
+		if o.ID == chainID(optionid) {
+			return nil
+		}
+	}
+	return &ContentError{Field: "option_id", Reason: "选项不属于该投票"}
+}
+
+// queryVoteOptions query options and totals of the vote
+func queryVoteOptions(voteid string, key *ecdsa.Key) ([]model.Option, bool) {
+	params := util.Struct2String(model.Vote{
+		ID: voteid,
+	})
+	retu, err := InvokeContract(vm.ReqInvokeCon{
+		ContractAddr: ContractAddress(),
+		ContractCode: GetContractCode(),
+		MethodName:   "queryVoteOption",
+		MethodParams: params,
+	}, key)
+	if err != nil {
+		return nil, false
+	}
+	// 处理合约返回
+	ABI, _ := abi.JSON(strings.NewReader(retu.Abi))
+	var p_ok int32
+	var p_oarray [][32]byte
+	var p_barray [][32]byte
+	var p_iarray []int32
+	res := []interface{}{&p_ok, &p_oarray, &p_barray, &p_iarray}
+	if sysErr := ABI.UnpackResult(&res, "queryVoteOption", retu.Result); sysErr != nil {
+		glog.Info(sysErr)
+		return nil, false
+	}
+	if p_ok == 1 {
+		return nil, false
+	}
+	var options []model.Option
+	for i := 0; i < len(p_iarray); i++ {
+		var option model.Option
+		option.ID = util.ByteToString(p_oarray[i][:])
+		content, ok := fromChainText(p_barray[i])
+		option.Content = content
+		option.Tampered = !ok
+		option.Total = uint(p_iarray[i])
+		option.VoteID = voteid
+		options = append(options, option)
+	}
+	return options, true
+}
+
 func GetVoteRecord(voteid string) ([]model.VoteRecord, bool) {
 	contractcode := GetContractCode()
 	key, err := InitKey()
@@ -348,7 +755,7 @@ func GetVoteRecord(voteid string) ([]model.VoteRecord, bool) {
 		ID: voteid,
 	})
 	retu, err := InvokeContract(vm.ReqInvokeCon{
-		ContractAddr: ContractAddress,
+		ContractAddr: ContractAddress(),
 		ContractCode: contractcode,
 		MethodName:   "queryVoteRecord",
 		MethodParams: params,
@@ -377,15 +784,22 @@ func GetVoteRecord(voteid string) ([]model.VoteRecord, bool) {
 	for i := 0; i < len(p_idarray); i++ {
 		var record model.VoteRecord
 		record.UserID = util.ByteToString(p_idarray[i][:])
-		record.OptionContent = util.ByteToString(p_rarray[i][:])
-		userid, err := strconv.Atoi(record.UserID)
-		if err != nil {
-			return nil, false
+		content, ok := fromChainText(p_rarray[i])
+		record.OptionContent = content
+		record.Tampered = !ok
+		// 投票令牌的选票记录令牌哈希
+		where := map[string]interface{}{"vote_id": voteid}
+		if user := util.Byte32ToDisplay(p_idarray[i]); strings.HasPrefix(user, "0x") {
+			record.UserID = user
+			where["token_hash"] = strings.TrimPrefix(user, "0x")
+		} else {
+			userid, err := strconv.Atoi(record.UserID)
+			if err != nil {
+				return nil, false
+			}
+			where["user_id"] = userid
 		}
-		hr, b := model.GetHashRecord(util.Struct2Map(model.HashRecord{
-			VoteID: voteid,
-			UserID: uint(userid),
-		}))
+		hr, b := model.GetHashRecord(where)
 		if !b {
 			return nil, false
 		}
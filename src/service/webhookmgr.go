@@ -0,0 +1,388 @@
+package service
+
+import (
+	"FunnyVoteGo/src/api/vm"
+	"FunnyVoteGo/src/constant"
+	"FunnyVoteGo/src/lib/eventbus"
+	"FunnyVoteGo/src/lib/httpclient"
+	"FunnyVoteGo/src/lib/worker"
+	"FunnyVoteGo/src/model"
+	"FunnyVoteGo/src/util"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/glog"
+	"github.com/spf13/viper"
+)
+
+// headers of webhook request
+const (
+	webhookSignatureHeader = "X-FunnyVote-Signature"
+	webhookEventHeader     = "X-FunnyVote-Event"
+	webhookDeliveryHeader  = "X-FunnyVote-Delivery"
+)
+
+var webhookEvents = map[string]bool{
+	constant.EventVoteCreated:       true,
+	constant.EventVoteOpened:        true,
+	constant.EventBallotCast:        true,
+	constant.EventVoteClosed:        true,
+	constant.EventVoteFinalized:     true,
+	constant.EventPetitionCompleted: true,
+}
+
+// InitWebhook deliver all vote events to registered webhooks
+func InitWebhook() {
+	eventbus.Subscribe("*", dispatchWebhooks)
+}
+
+// CreateWebhook register a webhook of the vote by its creator, or a global one by admins if vote id is empty
+func CreateWebhook(wi *vm.WebhookInit) (*model.Webhook, bool) {
+	if err := checkWebhookURL(wi.URL); err != nil {
+		glog.Errorf("invalid webhook url %s: %v", wi.URL, err)
+		return nil, false
+	}
+	for _, name := range wi.Events {
+		if !webhookEvents[name] {
+			glog.Errorf("unknown webhook event: %s", name)
+			return nil, false
+		}
+	}
+	if (wi.VoteID == "" && !IsAdmin(wi.CreatorID)) || (wi.VoteID != "" && !canManageVote(wi.VoteID, wi.CreatorID)) {
+		glog.Errorf("user %d can not add webhook of vote %q", wi.CreatorID, wi.VoteID)
+		return nil, false
+	}
+	secret, err := util.SecureRandString(32)
+	if err != nil {
+		glog.Error(err)
+		return nil, false
+	}
+	return model.CreateWebhook(&model.Webhook{
+		VoteID:    wi.VoteID,
+		URL:       wi.URL,
+		Secret:    secret,
+		Events:    strings.Join(wi.Events, ","),
+		CreatorID: wi.CreatorID,
+	})
+}
+
+// GetWebhooks get webhooks of the vote by its creator, or global ones by admins, secrets are hidden
+func GetWebhooks(voteid string, userid uint) ([]model.Webhook, bool) {
+	if (voteid == "" && !IsAdmin(userid)) || (voteid != "" && !canManageVote(voteid, userid)) {
+		glog.Errorf("user %d can not read webhooks of vote %q", userid, voteid)
+		return nil, false
+	}
+	whs, b := model.GetWebhooks(map[string]interface{}{"vote_id": voteid})
+	if !b {
+		return nil, false
+	}
+	for i := range whs {
+		whs[i].Secret = ""
+	}
+	return whs, true
+}
+
+// DeleteWebhook delete a webhook by its creator or admins
+func DeleteWebhook(id, userid uint) bool {
+	if !ownsWebhook(id, userid) {
+		return false
+	}
+	return model.DeleteWebhook(id)
+}
+
+// GetWebhookDeliveries get delivery log of a webhook by its creator or admins
+func GetWebhookDeliveries(webhookid, userid uint) ([]model.WebhookDelivery, bool) {
+	if !ownsWebhook(webhookid, userid) {
+		glog.Errorf("user %d can not read deliveries of webhook %d", userid, webhookid)
+		return nil, false
+	}
+	return model.GetWebhookDeliveries(map[string]interface{}{"webhook_id": webhookid})
+}
+
+// RedeliverWebhook reset a delivery and send it again, by creator of its webhook or admins
+func RedeliverWebhook(deliveryid, userid uint) bool {
+	wd, b := model.GetWebhookDelivery(map[string]interface{}{"id": deliveryid})
+	if !b || !ownsWebhook(wd.WebhookID, userid) {
+		return false
+	}
+	if !model.UpdateWebhookDelivery(deliveryid, map[string]interface{}{
+		"status":       model.DeliveryPending,
+		"attempts":     0,
+		"error":        "",
+		"next_attempt": time.Now().Unix(),
+	}) {
+		return false
+	}
+	submitWebhookDelivery(deliveryid)
+	return true
+}
+
+// ownsWebhook the user created the webhook or is admin
+func ownsWebhook(id, userid uint) bool {
+	wh, b := model.GetWebhook(map[string]interface{}{"id": id})
+	return b && wh.ID != 0 && (wh.CreatorID == userid || IsAdmin(userid))
+}
+
+// checkWebhookURL http(s) url whose host does not resolve to loopback, private or link local addresses,
+// which can be allowed by webhook.allow_private for local tests
+func checkWebhookURL(rawurl string) error {
+	u, err := url.ParseRequestURI(rawurl)
+	if err != nil {
+		return err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("unsupported scheme %q", u.Scheme)
+	}
+	if viper.GetBool("webhook.allow_private") {
+		return nil
+	}
+	ips, err := net.LookupIP(u.Hostname())
+	if err != nil {
+		return err
+	}
+	for _, ip := range ips {
+		if err := checkPublicIP(ip); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func checkPublicIP(ip net.IP) error {
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsUnspecified() || ip.IsMulticast() {
+		return fmt.Errorf("address %s is not public", ip)
+	}
+	return nil
+}
+
+// webhookClient client of deliveries, it does not follow redirects and checks the address it connects to,
+// so neither a redirect nor a dns answer changed after checkWebhookURL reaches a private host
+func webhookClient(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{Timeout: timeout, Control: dialPublic}
+	return &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			DialContext:         dialer.DialContext,
+			TLSHandshakeTimeout: timeout,
+			DisableKeepAlives:   true,
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// dialPublic control of dialer, address is the resolved ip and port to connect to
+func dialPublic(network, address string, c syscall.RawConn) error {
+	if viper.GetBool("webhook.allow_private") {
+		return nil
+	}
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return fmt.Errorf("address %s is not an ip", host)
+	}
+	return checkPublicIP(ip)
+}
+
+// dispatchWebhooks log a delivery for each webhook subscribing the event and send them now
+func dispatchWebhooks(e eventbus.Event) {
+	for _, id := range queueWebhookDeliveries(e) {
+		submitWebhookDelivery(id)
+	}
+}
+
+// queueWebhookDeliveries record a pending delivery of the event for each subscribed webhook
+func queueWebhookDeliveries(e eventbus.Event) []uint {
+	whs, b := model.GetVoteWebhooks(e.VoteID)
+	if !b || len(whs) == 0 {
+		return nil
+	}
+	var ids []uint
+	var vs *model.VoteSetting
+	if e.VoteID != "" {
+		if vs, b = model.GetVoteSetting(map[string]interface{}{"vote_id": e.VoteID}); !b || vs.VoteID == "" {
+			vs = nil
+		}
+	}
+	for _, wh := range whs {
+		if !webhookSubscribed(wh, e.Name) {
+			continue
+		}
+		payload, err := json.Marshal(webhookEvent(e, vs, wh.CreatorID))
+		if err != nil {
+			glog.Error(err)
+			return ids
+		}
+		wd, b := model.CreateWebhookDelivery(&model.WebhookDelivery{
+			WebhookID:   wh.ID,
+			Event:       e.Name,
+			VoteID:      e.VoteID,
+			Payload:     string(payload),
+			Status:      model.DeliveryPending,
+			NextAttempt: time.Now().Unix(),
+		})
+		if !b {
+			continue
+		}
+		ids = append(ids, wd.ID)
+	}
+	return ids
+}
+
+// webhookResultFields event data revealing results, e.g. totals in BallotCast from chain
+var webhookResultFields = []string{"total", "winner_id", "winner_total", "signatures"}
+
+// webhookEvent the event as seen by creator of the webhook, results are removed unless visible to them
+func webhookEvent(e eventbus.Event, vs *model.VoteSetting, userid uint) eventbus.Event {
+	if e.VoteID == "" || (vs != nil && resultVisible(vs, userid)) {
+		return e
+	}
+	data := make(map[string]interface{}, len(e.Data))
+	for k, v := range e.Data {
+		data[k] = v
+	}
+	for _, k := range webhookResultFields {
+		delete(data, k)
+	}
+	e.Data = data
+	return e
+}
+
+func webhookSubscribed(wh model.Webhook, name string) bool {
+	if wh.Events == "" {
+		return true
+	}
+	for _, e := range strings.Split(wh.Events, ",") {
+		if e == name {
+			return true
+		}
+	}
+	return false
+}
+
+// submitWebhookDelivery push delivery to worker, it is sent only if due and not claimed by another attempt
+func submitWebhookDelivery(id uint) {
+	worker.SubmitFunc(func() error {
+		return deliverWebhook(id)
+	})
+}
+
+// retryWebhookDeliveries submit pending deliveries whose next attempt is due, run by scheduler
+// so that retries survive restarts
+func retryWebhookDeliveries() {
+	wds, b := model.GetDueWebhookDeliveries(time.Now().Unix())
+	if !b {
+		return
+	}
+	for _, wd := range wds {
+		submitWebhookDelivery(wd.ID)
+	}
+}
+
+// deliverWebhook post payload once, schedule a retry with exponential backoff on failure
+func deliverWebhook(id uint) error {
+	timeout := viper.GetInt("webhook.timeout")
+	if timeout <= 0 {
+		timeout = 10
+	}
+	// 先占用本次发送, 避免调度器与直接提交重复发送; 进程中断时租约过期后由调度器重试
+	now := time.Now().Unix()
+	if !model.ClaimWebhookDelivery(id, now, now+int64(2*timeout)) {
+		return nil
+	}
+	wd, b := model.GetWebhookDelivery(map[string]interface{}{"id": id})
+	if !b {
+		return fmt.Errorf("webhook delivery %d not found", id)
+	}
+	wh, b := model.GetWebhook(map[string]interface{}{"id": wd.WebhookID})
+	if !b || wh.ID == 0 {
+		model.UpdateWebhookDelivery(id, map[string]interface{}{
+			"status": model.DeliveryFailed,
+			"error":  "webhook不存在",
+		})
+		return fmt.Errorf("webhook %d of delivery %d not found", wd.WebhookID, id)
+	}
+	// 解析结果可能已改变, 发送前再次检查
+	if err := checkWebhookURL(wh.URL); err != nil {
+		model.UpdateWebhookDelivery(id, map[string]interface{}{
+			"status": model.DeliveryFailed,
+			"error":  err.Error(),
+		})
+		return fmt.Errorf("webhook %d url %s rejected: %v", wh.ID, wh.URL, err)
+	}
+
+	body := []byte(wd.Payload)
+	client := webhookClient(time.Duration(timeout) * time.Second)
+	code, resp, err := httpclient.HTTPPostWithHeader(client, wh.URL, body, map[string]string{
+		webhookSignatureHeader: "sha256=" + signWebhookPayload(wh.Secret, body),
+		webhookEventHeader:     wd.Event,
+		webhookDeliveryHeader:  strconv.FormatUint(uint64(id), 10),
+	})
+
+	attempts := wd.Attempts + 1
+	if err == nil && code >= 200 && code < 300 {
+		model.UpdateWebhookDelivery(id, map[string]interface{}{
+			"status":        model.DeliverySuccess,
+			"attempts":      attempts,
+			"response_code": code,
+			"error":         "",
+		})
+		return nil
+	}
+	errmsg := fmt.Sprintf("status %d: %s", code, string(resp))
+	if err != nil {
+		errmsg = err.Error()
+	}
+
+	maxAttempts := viper.GetInt("webhook.max_attempts")
+	if maxAttempts <= 0 {
+		maxAttempts = 6
+	}
+	status := model.DeliveryPending
+	if attempts >= maxAttempts {
+		status = model.DeliveryFailed
+	}
+	model.UpdateWebhookDelivery(id, map[string]interface{}{
+		"status":        status,
+		"attempts":      attempts,
+		"response_code": code,
+		"error":         errmsg,
+		"next_attempt":  time.Now().Add(webhookBackoff(attempts)).Unix(),
+	})
+	return fmt.Errorf("deliver webhook %d to %s fail: %s", id, wh.URL, errmsg)
+}
+
+// webhookBackoff delay before the next attempt, doubled each time
+func webhookBackoff(attempts int) time.Duration {
+	backoff := viper.GetInt("webhook.backoff")
+	if backoff <= 0 {
+		backoff = 10
+	}
+	delay := time.Duration(backoff) * time.Second << uint(attempts-1)
+	if delay > time.Hour || delay <= 0 {
+		delay = time.Hour
+	}
+	return delay
+}
+
+// signWebhookPayload hex of HMAC-SHA256 of payload by webhook secret
+func signWebhookPayload(secret string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(payload)
+	return hex.EncodeToString(mac.Sum(nil))
+}
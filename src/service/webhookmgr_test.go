@@ -0,0 +1,130 @@
+package service
+
+import (
+	"FunnyVoteGo/src/lib/eventbus"
+	"FunnyVoteGo/src/lib/httpclient"
+	"FunnyVoteGo/src/model"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+func TestWebhookEvent(t *testing.T) {
+	e := eventbus.Event{
+		Name:   "BallotCast",
+		VoteID: "vote1",
+		Data:   map[string]interface{}{"vote_id": "vote1", "option_id": "opt1", "total": 3},
+	}
+	future := time.Now().Add(time.Hour).Unix()
+	tests := []struct {
+		name      string
+		vs        *model.VoteSetting
+		userid    uint
+		wantTotal bool
+	}{
+		{"always visible", &model.VoteSetting{VoteID: "vote1", ResultVisibility: model.VisibilityAlways}, 0, true},
+		{"after close, open", &model.VoteSetting{VoteID: "vote1", ResultVisibility: model.VisibilityAfterClose, EndTime: future}, 0, false},
+		{"after close, closed", &model.VoteSetting{VoteID: "vote1", ResultVisibility: model.VisibilityAfterClose, Closed: true}, 0, true},
+		{"creator only, creator", &model.VoteSetting{VoteID: "vote1", ResultVisibility: model.VisibilityCreatorOnly, CreatorID: 2}, 2, true},
+		{"creator only, other", &model.VoteSetting{VoteID: "vote1", ResultVisibility: model.VisibilityCreatorOnly, CreatorID: 2}, 3, false},
+		{"setting missing", nil, 2, false},
+	}
+	for _, tt := range tests {
+		got := webhookEvent(e, tt.vs, tt.userid)
+		if _, ok := got.Data["total"]; ok != tt.wantTotal {
+			t.Errorf("%s: total present %v, want %v", tt.name, ok, tt.wantTotal)
+		}
+		if got.Data["option_id"] != "opt1" {
+			t.Errorf("%s: option_id lost", tt.name)
+		}
+	}
+	if _, ok := e.Data["total"]; !ok {
+		t.Error("webhookEvent modified data of the original event")
+	}
+}
+
+func TestCheckWebhookURL(t *testing.T) {
+	tests := []struct {
+		url     string
+		wantErr bool
+	}{
+		{"https://8.8.8.8/hook", false},
+		{"ftp://8.8.8.8/hook", true},
+		{"not a url", true},
+		{"http://127.0.0.1:8080/hook", true},
+		{"http://[::1]/hook", true},
+		{"http://10.1.2.3/hook", true},
+		{"http://192.168.0.10/hook", true},
+		{"http://169.254.169.254/latest/meta-data", true},
+		{"http://0.0.0.0/hook", true},
+	}
+	for _, tt := range tests {
+		if err := checkWebhookURL(tt.url); (err != nil) != tt.wantErr {
+			t.Errorf("checkWebhookURL(%s) = %v, want err %v", tt.url, err, tt.wantErr)
+		}
+	}
+}
+
+func TestWebhookClient(t *testing.T) {
+	private := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer private.Close()
+	redirect := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, private.URL, http.StatusTemporaryRedirect)
+	}))
+	defer redirect.Close()
+	client := webhookClient(time.Second)
+
+	if _, _, err := httpclient.HTTPPostWithHeader(client, private.URL, nil, nil); err == nil {
+		t.Error("connected to loopback address")
+	}
+
+	viper.Set("webhook.allow_private", true)
+	defer viper.Set("webhook.allow_private", false)
+	code, _, err := httpclient.HTTPPostWithHeader(client, redirect.URL, nil, nil)
+	if err != nil || code != http.StatusTemporaryRedirect {
+		t.Errorf("got %d %v, want redirect not followed", code, err)
+	}
+}
+
+func TestDialPublic(t *testing.T) {
+	tests := []struct {
+		address string
+		wantErr bool
+	}{
+		{"8.8.8.8:443", false},
+		{"[2001:4860:4860::8888]:443", false},
+		{"127.0.0.1:80", true},
+		{"[::1]:80", true},
+		{"169.254.169.254:80", true},
+		{"10.0.0.1:80", true},
+		{"example.com:80", true},
+	}
+	for _, tt := range tests {
+		if err := dialPublic("tcp", tt.address, nil); (err != nil) != tt.wantErr {
+			t.Errorf("dialPublic(%s) = %v, want err %v", tt.address, err, tt.wantErr)
+		}
+	}
+}
+
+func TestWebhookBackoff(t *testing.T) {
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{1, 10 * time.Second},
+		{2, 20 * time.Second},
+		{4, 80 * time.Second},
+		{20, time.Hour},
+		{80, time.Hour},
+	}
+	for _, tt := range tests {
+		if got := webhookBackoff(tt.attempts); got != tt.want {
+			t.Errorf("webhookBackoff(%d) = %v, want %v", tt.attempts, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,252 @@
+package service
+
+import (
+	"FunnyVoteGo/src/api/vm"
+	"FunnyVoteGo/src/constant"
+	"FunnyVoteGo/src/lib/eventbus"
+	"FunnyVoteGo/src/model"
+	"FunnyVoteGo/src/util"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/glog"
+)
+
+// maxWriteInsPerUser most write-ins a voter can propose in a vote, rejected ones not counted
+const maxWriteInsPerUser = 3
+
+// errOptionsLocked options of the vote can not change
+var errOptionsLocked = fmt.Errorf("投票已结束或为问卷、预算投票、请愿, 不能添加选项")
+
+// AddOptions add options to a vote by its creator, options are normalized and duplicates are skipped,
+// returns write-ins recording the added options
+func AddOptions(oa *vm.OptionsAdd, userid uint) ([]model.WriteIn, error) {
+	if !canManageVote(oa.VoteID, userid) {
+		return nil, &EligibilityError{Reason: "无权管理该投票"}
+	}
+	if _, err := optionsAddable(oa.VoteID); err != nil {
+		return nil, err
+	}
+	existing, err := existingOptionKeys(oa.VoteID)
+	if err != nil {
+		return nil, err
+	}
+
+	var added []model.WriteIn
+	for i, option := range oa.Options {
+		content := normalizeOption(option)
+		if err := validateText("options["+strconv.Itoa(i)+"]", content); err != nil {
+			return added, err
+		}
+		key := optionKey(content)
+		if existing[key] {
+			continue
+		}
+		wi := model.WriteIn{
+			VoteID:     oa.VoteID,
+			ProposerID: userid,
+			Content:    content,
+			DedupKey:   key,
+			Status:     model.WriteInApproved,
+			ReviewerID: userid,
+		}
+		if err := addOption(&wi); err != nil {
+			return added, err
+		}
+		existing[key] = true
+		added = append(added, wi)
+	}
+	return added, nil
+}
+
+// ProposeWriteIn propose a new option by a voter, added right away unless the vote requires
+// approval of the creator
+func ProposeWriteIn(wp *vm.WriteInPropose, userid uint) (*model.WriteIn, error) {
+	vs, err := optionsAddable(wp.VoteID)
+	if err != nil {
+		return nil, err
+	}
+	if !vs.AllowWriteIn {
+		return nil, fmt.Errorf("该投票不允许自由填写选项")
+	}
+	if voteStatus(vs.StartTime, vs.EndTime) != 2 {
+		return nil, &EligibilityError{Reason: "投票未在进行中"}
+	}
+	if err := checkEligibility(vs, userid); err != nil {
+		return nil, err
+	}
+	count, b := model.CountWriteIns(wp.VoteID, userid)
+	if !b {
+		return nil, errBallotFail
+	}
+	if count >= maxWriteInsPerUser {
+		return nil, fmt.Errorf("每人最多提议%d个选项", maxWriteInsPerUser)
+	}
+
+	content := normalizeOption(wp.Content)
+	if err := validateText("content", content); err != nil {
+		return nil, err
+	}
+	key := optionKey(content)
+	existing, err := existingOptionKeys(wp.VoteID)
+	if err != nil {
+		return nil, err
+	}
+	pending, b := model.HasPendingWriteIn(wp.VoteID, key)
+	if !b {
+		return nil, errBallotFail
+	}
+	if existing[key] || pending {
+		return nil, &ContentError{Field: "content", Reason: "选项已存在"}
+	}
+
+	wi := model.WriteIn{
+		VoteID:     wp.VoteID,
+		ProposerID: userid,
+		Content:    content,
+		DedupKey:   key,
+		Status:     model.WriteInPending,
+	}
+	if !vs.WriteInApproval {
+		wi.Status = model.WriteInApproved
+		if err := addOption(&wi); err != nil {
+			return nil, err
+		}
+		return &wi, nil
+	}
+	if _, b := model.CreateWriteIn(&wi); !b {
+		return nil, errBallotFail
+	}
+	return &wi, nil
+}
+
+// ReviewWriteIn approve or reject a pending write-in by creator of the vote,
+// an approved write-in is added to options on chain
+func ReviewWriteIn(wr *vm.WriteInReview, userid uint) (*model.WriteIn, error) {
+	wi, b := model.GetWriteIn(wr.ID)
+	if !b {
+		return nil, errBallotFail
+	}
+	if wi == nil || wi.Status != model.WriteInPending {
+		return nil, fmt.Errorf("提议不存在或已审核")
+	}
+	if !canManageVote(wi.VoteID, userid) {
+		return nil, &EligibilityError{Reason: "无权管理该投票"}
+	}
+	if !wr.Approve {
+		if !model.ReviewWriteIn(wi.ID, map[string]interface{}{"status": model.WriteInRejected, "reviewer_id": userid}) {
+			return nil, fmt.Errorf("提议不存在或已审核")
+		}
+		wi.Status, wi.ReviewerID = model.WriteInRejected, userid
+		return wi, nil
+	}
+	if _, err := optionsAddable(wi.VoteID); err != nil {
+		return nil, err
+	}
+	// 先占用提议, 避免重复批准
+	if !model.ReviewWriteIn(wi.ID, map[string]interface{}{"status": model.WriteInApproved, "reviewer_id": userid}) {
+		return nil, fmt.Errorf("提议不存在或已审核")
+	}
+	wi.Status, wi.ReviewerID = model.WriteInApproved, userid
+	if err := addOption(wi); err != nil {
+		model.UpdateWriteIn(wi.ID, map[string]interface{}{"status": model.WriteInPending, "reviewer_id": 0})
+		return nil, err
+	}
+	return wi, nil
+}
+
+// GetWriteIns get write-ins of the vote, for its creator
+func GetWriteIns(voteid string, userid uint) ([]model.WriteIn, bool) {
+	if !canManageVote(voteid, userid) {
+		return nil, false
+	}
+	return model.GetWriteIns(voteid)
+}
+
+// addOption add option of the write-in on chain and save the write-in, the proposer and
+// the approver are recorded by event of contract
+func addOption(wi *model.WriteIn) error {
+	content, b := toChainText(wi.Content)
+	if !b {
+		return errBallotFail
+	}
+	approver := ""
+	if wi.ReviewerID != 0 {
+		approver = strconv.Itoa(int(wi.ReviewerID))
+	}
+	optionid := util.StringUUID()
+	txhash, err := invokeMethod("addVoteOption", map[string]string{
+		"id":          optionid,
+		"vote_id":     wi.VoteID,
+		"content":     content,
+		"proposer_id": strconv.Itoa(int(wi.ProposerID)),
+		"approver_id": approver,
+	})
+	if err != nil {
+		glog.Errorf("add option to vote %s fail: %v", wi.VoteID, err)
+		return fmt.Errorf("添加选项失败: %v", err)
+	}
+	// 与查询返回的选项ID保持一致
+	wi.OptionID = chainID(optionid)
+	wi.TxHash = txhash
+	if wi.ID == 0 {
+		if _, b := model.CreateWriteIn(wi); !b {
+			return errBallotFail
+		}
+	} else if !model.UpdateWriteIn(wi.ID, map[string]interface{}{"option_id": wi.OptionID, "tx_hash": txhash}) {
+		return errBallotFail
+	}
+	publishLocalEvent(eventbus.Event{
+		Name:   constant.EventOptionAdded,
+		VoteID: wi.VoteID,
+		TxHash: txhash,
+		Data: map[string]interface{}{
+			"vote_id":     wi.VoteID,
+			"option_id":   wi.OptionID,
+			"content":     wi.Content,
+			"proposer_id": strconv.Itoa(int(wi.ProposerID)),
+			"approver_id": approver,
+		},
+	})
+	return nil
+}
+
+// optionsAddable options can be added before the vote ends, not to surveys, budgeting votes or petitions
+func optionsAddable(voteid string) (*model.VoteSetting, error) {
+	vs, b := model.GetVoteSetting(map[string]interface{}{"vote_id": voteid})
+	if !b {
+		return nil, fmt.Errorf("投票不存在")
+	}
+	if vs.Survey || vs.Budget != 0 || vs.SignatureTarget != 0 || vs.Closed || voteStatus(vs.StartTime, vs.EndTime) == 3 {
+		return nil, errOptionsLocked
+	}
+	return vs, nil
+}
+
+// existingOptionKeys dedup keys of current options of the vote on chain
+func existingOptionKeys(voteid string) (map[string]bool, error) {
+	key, err := InitKey()
+	if err != nil {
+		return nil, err
+	}
+	options, b := queryVoteOptions(voteid, key)
+	if !b {
+		return nil, fmt.Errorf("查询选项失败")
+	}
+	keys := make(map[string]bool)
+	for _, option := range options {
+		keys[optionKey(normalizeOption(option.Content))] = true
+	}
+	return keys, nil
+}
+
+// normalizeOption trim option and collapse inner whitespace to single spaces
+func normalizeOption(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// optionKey options differing only in case are duplicates
+func optionKey(normalized string) string {
+	return contentHash(strings.ToLower(normalized))
+}
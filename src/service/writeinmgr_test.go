@@ -0,0 +1,43 @@
+package service
+
+import "testing"
+
+func TestNormalizeOption(t *testing.T) {
+	tests := []struct {
+		s    string
+		want string
+	}{
+		{"Pizza", "Pizza"},
+		{"  Pizza  ", "Pizza"},
+		{"New   York\tPizza", "New York Pizza"},
+		{"\n披萨 　 外卖\n", "披萨 外卖"},
+		{"   ", ""},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := normalizeOption(tt.s); got != tt.want {
+			t.Errorf("normalizeOption(%q) = %q, want %q", tt.s, got, tt.want)
+		}
+	}
+}
+
+func TestOptionKey(t *testing.T) {
+	tests := []struct {
+		a, b string
+		same bool
+	}{
+		{"Pizza", "pizza", true},
+		{"NEW YORK pizza", "new york Pizza", true},
+		{" New  York ", "new york", true},
+		{"披萨", "披萨", true},
+		{"Pizza", "Pizza!", false},
+		{"New York", "NewYork", false},
+		{"a b", "ab", false},
+	}
+	for _, tt := range tests {
+		ka, kb := optionKey(normalizeOption(tt.a)), optionKey(normalizeOption(tt.b))
+		if (ka == kb) != tt.same {
+			t.Errorf("optionKey(%q) == optionKey(%q) is %v, want %v", tt.a, tt.b, ka == kb, tt.same)
+		}
+	}
+}
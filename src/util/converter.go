@@ -2,6 +2,7 @@ package util
 
 import (
 	"bytes"
+	"encoding/hex"
 	"fmt"
 	"reflect"
 	"time"
@@ -11,6 +12,8 @@ import (
 	"math/big"
 	"strconv"
 	"strings"
+	"unicode"
+	"unicode/utf8"
 
 	"github.com/glog"
 )
@@ -25,7 +28,7 @@ func Struct2String(st interface{}) string {
 		fmt.Println(f)
 		v := vv.Field(i)
 		fmt.Println(v)
-		chKey := f.Tag.Get("json")
+		chKey := strings.Split(f.Tag.Get("json"), ",")[0]
 		switch v.Kind() {
 		case reflect.String:
 			if s, ok := v.Interface().(string); ok && s != "" {
@@ -57,6 +60,12 @@ func Struct2String(st interface{}) string {
 
 			}
 
+		case reflect.Bool:
+			if bo, ok := v.Interface().(bool); ok && bo {
+				str += "\"" + chKey + "\"" + ":" + "\"true\"" + ","
+			}
+		case reflect.Ptr:
+			// 指针字段不是合约参数, 忽略
 		case reflect.Slice:
 			l := v.Len()
 			stri := ""
@@ -101,7 +110,7 @@ func Struct2Map(st interface{}) map[string]interface{} {
 	for i := 0; i < vt.NumField(); i++ {
 		f := vt.Field(i)
 		v := vv.Field(i)
-		chKey := f.Tag.Get("json")
+		chKey := strings.Split(f.Tag.Get("json"), ",")[0]
 		switch v.Kind() {
 		case reflect.String:
 			if s, ok := v.Interface().(string); ok && s != "" {
@@ -466,8 +475,10 @@ func ABIchangeType(param []interface{}, arg interface{}, t string) []interface{}
 				case 32:
 					var bb [][32]byte
 					for _, v := range arg.([]interface{}) {
-						var b [32]byte
-						copy(b[:], v.(string))
+						b, ok := HexToBytes32(v.(string))
+						if !ok {
+							copy(b[:], v.(string))
+						}
 						bb = append(bb, b)
 					}
 					param = append(param, bb)
@@ -653,6 +664,10 @@ func ABIchangeType(param []interface{}, arg interface{}, t string) []interface{}
 				param = append(param, b)
 				return param
 			case 32:
+				if b, ok := HexToBytes32(s); ok {
+					param = append(param, b)
+					return param
+				}
 				var b [32]byte
 				copy(b[:], s)
 				param = append(param, b)
@@ -954,3 +969,39 @@ func ByteToString(b []byte) string {
 	return string(b[:n])
 
 }
+
+// HexToBytes32 decode 0x prefixed hex of 32 bytes, used to pass hashes as bytes32
+func HexToBytes32(s string) ([32]byte, bool) {
+	var b [32]byte
+	if len(s) != 66 || !strings.HasPrefix(s, "0x") {
+		return b, false
+	}
+	if _, err := hex.Decode(b[:], []byte(s[2:])); err != nil {
+		return b, false
+	}
+	return b, true
+}
+
+// Byte32ToDisplay convert [32]byte to string, or 0x prefixed hex if it is not text, e.g. a hash
+func Byte32ToDisplay(b32 [32]byte) string {
+	s := Byte32ToString(b32)
+	for _, by := range b32[len(s):] {
+		if by != 0 {
+			return "0x" + hex.EncodeToString(b32[:])
+		}
+	}
+	// 中文被截断时末尾可能有不完整字符
+	text := s
+	for i := 0; i < utf8.UTFMax-1 && len(s) == len(b32) && !utf8.ValidString(text); i++ {
+		text = text[:len(text)-1]
+	}
+	if !utf8.ValidString(text) {
+		return "0x" + hex.EncodeToString(b32[:])
+	}
+	for _, r := range text {
+		if unicode.IsControl(r) {
+			return "0x" + hex.EncodeToString(b32[:])
+		}
+	}
+	return s
+}
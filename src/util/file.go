@@ -1,12 +1,17 @@
 package util
 
 import (
+	"bytes"
+	"encoding/csv"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"mime/multipart"
+	"path/filepath"
+	"strings"
 
 	"github.com/glog"
+	"github.com/tealeg/xlsx"
 )
 
 // FileToMap parse file to map
@@ -54,3 +59,40 @@ func FilePathToMap(path string) (m map[string]interface{}, err error) {
 	}
 	return m, err
 }
+
+// FileToRows parse uploaded xlsx or csv file to rows, only the first sheet of xlsx is read
+func FileToRows(f *multipart.FileHeader) ([][]string, error) {
+	fr, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer fr.Close()
+	fByte, err := ioutil.ReadAll(fr)
+	if err != nil {
+		return nil, err
+	}
+
+	switch strings.ToLower(filepath.Ext(f.Filename)) {
+	case ".csv":
+		return csv.NewReader(bytes.NewReader(fByte)).ReadAll()
+	case ".xlsx":
+		xlFile, err := xlsx.OpenBinary(fByte)
+		if err != nil {
+			return nil, err
+		}
+		if len(xlFile.Sheets) == 0 {
+			return nil, nil
+		}
+		var rows [][]string
+		for _, row := range xlFile.Sheets[0].Rows {
+			var cells []string
+			for _, cell := range row.Cells {
+				cells = append(cells, cell.String())
+			}
+			rows = append(rows, cells)
+		}
+		return rows, nil
+	default:
+		return nil, fmt.Errorf("不支持的文件类型")
+	}
+}
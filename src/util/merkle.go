@@ -0,0 +1,90 @@
+package util
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"sort"
+)
+
+// MerkleLeaf hash of a leaf
+func MerkleLeaf(data string) []byte {
+	h := sha256.Sum256([]byte(data))
+	return h[:]
+}
+
+// MerkleRoot root of leaves, pairs are sorted before hashing so proofs need no position
+func MerkleRoot(leaves [][]byte) []byte {
+	if len(leaves) == 0 {
+		return make([]byte, sha256.Size)
+	}
+	level := sortedLeaves(leaves)
+	for len(level) > 1 {
+		level = nextMerkleLevel(level)
+	}
+	return level[0]
+}
+
+// MerkleProof sibling hashes from leaf to root, nil if leaf not found
+func MerkleProof(leaves [][]byte, leaf []byte) [][]byte {
+	level := sortedLeaves(leaves)
+	idx := -1
+	for i, l := range level {
+		if bytes.Equal(l, leaf) {
+			idx = i
+			break
+		}
+	}
+	if idx < 0 {
+		return nil
+	}
+	proof := [][]byte{}
+	for len(level) > 1 {
+		if sibling := idx ^ 1; sibling < len(level) {
+			proof = append(proof, level[sibling])
+		}
+		level = nextMerkleLevel(level)
+		idx /= 2
+	}
+	return proof
+}
+
+// VerifyMerkleProof check leaf is in the tree of root
+func VerifyMerkleProof(leaf []byte, proof [][]byte, root []byte) bool {
+	h := leaf
+	for _, p := range proof {
+		h = hashMerklePair(h, p)
+	}
+	return bytes.Equal(h, root)
+}
+
+func sortedLeaves(leaves [][]byte) [][]byte {
+	level := make([][]byte, len(leaves))
+	copy(level, leaves)
+	sort.Slice(level, func(i, j int) bool {
+		return bytes.Compare(level[i], level[j]) < 0
+	})
+	return level
+}
+
+// nextMerkleLevel hash pairs, the odd one is promoted
+func nextMerkleLevel(level [][]byte) [][]byte {
+	var next [][]byte
+	for i := 0; i < len(level); i += 2 {
+		if i+1 == len(level) {
+			next = append(next, level[i])
+			continue
+		}
+		next = append(next, hashMerklePair(level[i], level[i+1]))
+	}
+	return next
+}
+
+func hashMerklePair(a, b []byte) []byte {
+	if bytes.Compare(a, b) > 0 {
+		a, b = b, a
+	}
+	h := sha256.New()
+	h.Write(a)
+	h.Write(b)
+	return h.Sum(nil)
+}
@@ -0,0 +1,77 @@
+package util
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"strconv"
+	"testing"
+)
+
+func testLeaves(n int) [][]byte {
+	var leaves [][]byte
+	for i := 0; i < n; i++ {
+		leaves = append(leaves, MerkleLeaf("vote1:"+strconv.Itoa(i)))
+	}
+	return leaves
+}
+
+func TestMerkleProof(t *testing.T) {
+	for n := 1; n <= 9; n++ {
+		leaves := testLeaves(n)
+		root := MerkleRoot(leaves)
+		for i, leaf := range leaves {
+			proof := MerkleProof(leaves, leaf)
+			if proof == nil {
+				t.Fatalf("n=%d: no proof of leaf %d", n, i)
+			}
+			if !VerifyMerkleProof(leaf, proof, root) {
+				t.Errorf("n=%d: proof of leaf %d does not verify", n, i)
+			}
+			other := MerkleLeaf("vote2:" + strconv.Itoa(i))
+			if VerifyMerkleProof(other, proof, root) {
+				t.Errorf("n=%d: proof of leaf %d verifies another leaf", n, i)
+			}
+		}
+	}
+}
+
+func TestMerkleRoot(t *testing.T) {
+	a, b, c := MerkleLeaf("a"), MerkleLeaf("b"), MerkleLeaf("c")
+	tests := []struct {
+		name   string
+		leaves [][]byte
+		want   []byte
+	}{
+		{"empty", nil, make([]byte, sha256.Size)},
+		{"single leaf is root", [][]byte{a}, a},
+		{"pair", [][]byte{a, b}, hashMerklePair(a, b)},
+		{"odd leaf promoted", [][]byte{a, b, c}, nil},
+	}
+	for _, tt := range tests {
+		got := MerkleRoot(tt.leaves)
+		if tt.want == nil {
+			// 三个叶子时排序后最大的叶子直接上升一层
+			sorted := sortedLeaves(tt.leaves)
+			tt.want = hashMerklePair(hashMerklePair(sorted[0], sorted[1]), sorted[2])
+		}
+		if !bytes.Equal(got, tt.want) {
+			t.Errorf("%s: root %x, want %x", tt.name, got, tt.want)
+		}
+	}
+
+	// 叶子顺序不影响根
+	leaves := testLeaves(7)
+	reversed := make([][]byte, len(leaves))
+	for i := range leaves {
+		reversed[len(leaves)-1-i] = leaves[i]
+	}
+	if !bytes.Equal(MerkleRoot(leaves), MerkleRoot(reversed)) {
+		t.Error("root depends on order of leaves")
+	}
+	if MerkleProof(leaves, MerkleLeaf("missing")) != nil {
+		t.Error("proof of missing leaf is not nil")
+	}
+	if bytes.Equal(MerkleRoot(leaves), MerkleRoot(leaves[:6])) {
+		t.Error("removing a leaf keeps the root")
+	}
+}
@@ -1,6 +1,7 @@
 package util
 
 import (
+	crand "crypto/rand"
 	"crypto/sha1"
 	"fmt"
 	"io"
@@ -20,6 +21,18 @@ func RandString(n int) string {
 	return string(b)
 }
 
+// SecureRandString init a rand string by crypto/rand, used for secrets
+func SecureRandString(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := crand.Read(b); err != nil {
+		return "", err
+	}
+	for i := range b {
+		b[i] = letterBytes[int(b[i])%len(letterBytes)]
+	}
+	return string(b), nil
+}
+
 // Sha1Hash sha1 hash
 func Sha1Hash(s string) string {
 	t := sha1.New()